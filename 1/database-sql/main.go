@@ -6,7 +6,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"time"
 
 	// database/sql — это набор интерфейсов для работы с базой
 	// Чтобы эти интерфейсы работали, для них нужна реализация. Именно за реализацию и отвечают драйверы.
@@ -15,6 +14,8 @@ import (
 	// Обратите внимание, что мы загружаем драйвер анонимно, присвоив его квалификатору пакета псевдоним, _ ,
 	// чтобы ни одно из его экспортированных имен не было видно нашему коду.
 	// Под капотом драйвер регистрирует себя как доступный для пакета database/sql с помощью функции init()
+
+	"github.com/moguchev/postgres/config" // параметры подключения и пула
 )
 
 const (
@@ -37,17 +38,15 @@ const (
 	*/
 )
 
-const (
-	host     = "localhost"
-	port     = 5432
-	user     = "user"
-	password = "password"
-	dbname   = "playground"
-)
-
 func main() {
+	// параметры подключения: значения по умолчанию (docker-compose.yaml), переопределяются переменными PG* и DATABASE_URL
+	cfg, err := config.Load(config.FromEnv())
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// connection string
-	psqlConn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", host, port, user, password, dbname)
+	psqlConn := cfg.DSN()
 
 	// open database
 	db, err := sql.Open(stdPostgresDriverName, psqlConn) // returns *sql.DB, error
@@ -76,10 +75,11 @@ func main() {
 	fmt.Println("Connection with database successfully established!")
 
 	/* Настройка пула соединений */
-	db.SetConnMaxIdleTime(time.Minute) // время, в течение которого соединение может быть бездействующим.
-	db.SetConnMaxLifetime(time.Hour)   // время, в течение которого соединение может быть повторно использовано.
-	db.SetMaxIdleConns(2)              // максимум 2 простаивающих соединения
-	db.SetMaxOpenConns(4)              // максимум 4 открытых соединений с БД
+	// (значения берем из config.Pool; cfg.OpenDB() делает то же самое)
+	db.SetConnMaxIdleTime(cfg.Pool.MaxConnIdleTime) // время, в течение которого соединение может быть бездействующим.
+	db.SetConnMaxLifetime(cfg.Pool.MaxConnLifetime) // время, в течение которого соединение может быть повторно использовано.
+	db.SetMaxIdleConns(cfg.Pool.MaxIdleConns)       // максимум простаивающих соединений
+	db.SetMaxOpenConns(cfg.Pool.MaxConns)           // максимум открытых соединений с БД
 
 	/* статистика пула соединений */
 	statistics := db.Stats()
@@ -9,6 +9,7 @@ import (
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq" // импортируем драйвер для postgres
+	"github.com/moguchev/postgres/config"
 )
 
 const (
@@ -16,14 +17,6 @@ const (
 	stdPostgresDriverName = "postgres"
 )
 
-const (
-	host     = "localhost"
-	port     = 5432
-	user     = "user"
-	password = "password"
-	dbname   = "playground"
-)
-
 func clear(c io.Closer) {
 	if err := c.Close(); err != nil {
 		fmt.Println("close:", err)
@@ -31,8 +24,14 @@ func clear(c io.Closer) {
 }
 
 func main() {
+	// параметры подключения: значения по умолчанию (docker-compose.yaml), переопределяются переменными PG* и DATABASE_URL
+	cfg, err := config.Load(config.FromEnv())
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// connection string
-	psqlConn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", host, port, user, password, dbname)
+	psqlConn := cfg.DSN()
 
 	// 1
 	sqlDB, err := sql.Open(stdPostgresDriverName, psqlConn) // returns *sql.DB, error
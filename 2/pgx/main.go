@@ -4,11 +4,9 @@ package main
 import (
 	"context"
 	"fmt"
-	"time"
 
 	"github.com/jackc/pgx/v4"
-	"github.com/jackc/pgx/v4/log/zapadapter" // adapter for go.uber.org/zap loggger
-	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/moguchev/postgres/config"
 	"go.uber.org/zap" // zap logger
 	/*
 		github.com/jackc/pgx — именно этот драйвер вы хотите использовать. Почему?
@@ -36,14 +34,6 @@ import (
 		Двоичный формат может быть значительно быстрее, что и использует интерфейс pgx.
 	*/)
 
-const (
-	host     = "localhost"
-	port     = 5432
-	user     = "user"
-	password = "password"
-	dbname   = "playground"
-)
-
 func main() {
 	ctx, cacnel := context.WithCancel(context.Background())
 	defer cacnel()
@@ -52,8 +42,14 @@ func main() {
 	defer zapLogger.Sync()
 	logger := zapLogger.Sugar()
 
+	// параметры подключения: значения по умолчанию (docker-compose.yaml), переопределяются переменными PG* и DATABASE_URL
+	cfg, err := config.Load(config.WithLogLevel("debug"), config.FromEnv()) // уровень логирования pgx выставляем
+	if err != nil {
+		logger.Fatal(err)
+	}
+
 	// connection string
-	psqlConn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", host, port, user, password, dbname)
+	psqlConn := cfg.DSN()
 
 	// open database connection
 	conn, err := pgx.Connect(ctx, psqlConn) // это именно одно соединение, не пул соединений
@@ -91,22 +87,17 @@ func main() {
 	// *pgx.Pool.SendBatch(...) // Send several queries at once (example: https://github.com/jackc/pgx/blob/master/batch_test.go)
 	// *pgx.Pool.CopyFrom(...) // PostgreSQL COPY operator (example: https://github.com/jackc/pgx/blob/master/copy_from_test.go)
 
-	pool, err := pgxpool.Connect(ctx, psqlConn)
+	// настройки пула задаются ДО подключения: pool.Config() возвращает копию конфигурации,
+	// и ее изменения на уже созданный пул не влияют.
+	// config.NewPool разбирает DSN через pgxpool.ParseConfig, выставляет MaxConns, MinConns,
+	// MaxConnLifetime, MaxConnIdleTime из cfg.Pool, логгер с уровнем cfg.LogLevel
+	// и хук AfterConnect с настройками сессии (application_name, statement_timeout, TimeZone)
+	pool, err := config.NewPool(ctx, cfg, config.WithZapLogger(zapLogger)) // передаем наш zap логгер
 	if err != nil {
 		logger.Fatal(err)
 	}
 	defer pool.Close()
 
-	// настраиваем
-	config := pool.Config()
-	config.MaxConnIdleTime = time.Minute
-	config.MaxConnLifetime = time.Hour
-	config.MinConns = 2
-	config.MaxConns = 10
-
-	config.ConnConfig.Logger = zapadapter.NewLogger(zapLogger) // передаем наш zap логгер
-	config.ConnConfig.LogLevel = pgx.LogLevelDebug             // уровень логирования выставляем
-
 	// pgx
 	exampleQuery(ctx, pool)
 	// pgx + scany
@@ -0,0 +1,104 @@
+package logger
+
+import "time"
+
+// Logger - структурированный логгер репозиториев.
+// Реализации: Nop (по умолчанию, ничего не пишет) и NewZap (адаптер go.uber.org/zap)
+type Logger interface {
+	Debug(msg string, fields ...Field)
+	Error(msg string, fields ...Field)
+}
+
+// Field - поле записи лога
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// RedactedValue - значение, которое пишется в лог вместо скрытых параметров (персональные данные и т.п.)
+const RedactedValue = "[REDACTED]"
+
+func String(key, value string) Field {
+	return Field{Key: key, Value: value}
+}
+
+func Int64(key string, value int64) Field {
+	return Field{Key: key, Value: value}
+}
+
+func Any(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Redacted - параметр, значение которого не должно попасть в лог; пустое значение пишется как есть,
+// чтобы было видно, был ли параметр задан
+func Redacted(key, value string) Field {
+	if value == "" {
+		return Field{Key: key, Value: ""}
+	}
+	return Field{Key: key, Value: RedactedValue}
+}
+
+func Operation(name string) Field {
+	return Field{Key: "operation", Value: name}
+}
+
+func Duration(d time.Duration) Field {
+	return Field{Key: "duration", Value: d}
+}
+
+func Error(err error) Field {
+	return Field{Key: "error", Value: err}
+}
+
+type nop struct{}
+
+// Nop - логгер, который ничего не пишет
+func Nop() Logger {
+	return nop{}
+}
+
+func (nop) Debug(string, ...Field) {}
+func (nop) Error(string, ...Field) {}
+
+// opLogger - логгер, которому нужен отдельный экземпляр для записей через Op
+// (у zap caller должен указывать на вызов Op.Error, а не на сам Op)
+type opLogger interface {
+	forOp() Logger
+}
+
+// Op - операция репозитория: имя, параметры и время начала, которые попадают в каждую запись о ней
+type Op struct {
+	logger Logger
+	fields []Field
+	start  time.Time
+}
+
+// Start - начинает операцию name с параметрами params
+func Start(l Logger, name string, params ...Field) *Op {
+	if ol, ok := l.(opLogger); ok {
+		l = ol.forOp()
+	}
+	return &Op{
+		logger: l,
+		fields: append([]Field{Operation(name)}, params...),
+		start:  time.Now(),
+	}
+}
+
+// Done - логирует успешное завершение операции на уровне Debug вместе с ее параметрами и длительностью
+func (o *Op) Done() {
+	o.logger.Debug("done", append(o.fields[:len(o.fields):len(o.fields)], Duration(time.Since(o.start)))...)
+}
+
+// Fail - логирует завершение операции ожидаемой ошибкой (models.ErrNotFound, нарушение ограничения, отмена ctx)
+// на уровне Debug: это результат операции, а не сбой, поэтому уровень Error для него не нужен.
+// Каждая операция завершается ровно одним вызовом Done, Fail или Error
+func (o *Op) Fail(err error) {
+	o.logger.Debug("failed", append(o.fields[:len(o.fields):len(o.fields)], Duration(time.Since(o.start)), Error(err))...)
+}
+
+// Error - логирует ошибку операции вместе с ее параметрами и длительностью
+func (o *Op) Error(msg string, err error) {
+	o.logger.Error(msg, append(o.fields[:len(o.fields):len(o.fields)], Duration(time.Since(o.start)), Error(err))...)
+}
@@ -0,0 +1,51 @@
+package logger
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type zapLogger struct {
+	logger *zap.Logger
+	op     *zapLogger // для записей через Op: caller - на кадр выше, чем у прямых вызовов
+}
+
+// NewZap - адаптер *zap.Logger к Logger
+func NewZap(l *zap.Logger) Logger {
+	op := &zapLogger{
+		logger: l.WithOptions(zap.AddCallerSkip(2)), // адаптер и Op.Error/Op.Done
+	}
+	op.op = op
+	return &zapLogger{
+		logger: l.WithOptions(zap.AddCallerSkip(1)), // адаптер
+		op:     op,
+	}
+}
+
+func (l *zapLogger) forOp() Logger {
+	return l.op
+}
+
+func (l *zapLogger) Debug(msg string, fields ...Field) {
+	l.logger.Debug(msg, zapFields(fields)...)
+}
+
+func (l *zapLogger) Error(msg string, fields ...Field) {
+	l.logger.Error(msg, zapFields(fields)...)
+}
+
+func zapFields(fields []Field) []zap.Field {
+	zf := make([]zap.Field, 0, len(fields))
+	for _, f := range fields {
+		switch v := f.Value.(type) {
+		case error:
+			zf = append(zf, zap.NamedError(f.Key, v))
+		case time.Duration:
+			zf = append(zf, zap.Duration(f.Key, v))
+		default:
+			zf = append(zf, zap.Any(f.Key, v))
+		}
+	}
+	return zf
+}
@@ -0,0 +1,51 @@
+package logger
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestZapCaller(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	l := NewZap(zap.New(core, zap.AddCaller()))
+
+	l.Error("direct", Error(errors.New("boom")))
+	op := Start(l, "op")
+	op.Error("failed", errors.New("boom"))
+	op.Done()
+	op.Fail(errors.New("not found"))
+
+	entries := logs.AllUntimed()
+	if len(entries) != 4 {
+		t.Fatalf("got %d entries, want 4", len(entries))
+	}
+	for _, e := range entries {
+		if file := filepath.Base(e.Caller.File); file != "zap_test.go" {
+			t.Errorf("%q caller = %s, want zap_test.go", e.Message, e.Caller)
+		}
+	}
+
+	done := entries[2]
+	if done.Level != zapcore.DebugLevel {
+		t.Errorf("Done level = %s, want debug", done.Level)
+	}
+	if _, ok := done.ContextMap()["duration"]; !ok {
+		t.Errorf("Done fields = %v, want duration", done.ContextMap())
+	}
+	if got := done.ContextMap()["operation"]; got != "op" {
+		t.Errorf("Done operation = %v, want op", got)
+	}
+
+	fail := entries[3]
+	if fail.Level != zapcore.DebugLevel {
+		t.Errorf("Fail level = %s, want debug", fail.Level)
+	}
+	if got, ok := fail.ContextMap()["error"]; !ok || got != "not found" {
+		t.Errorf("Fail error = %v, want not found", got)
+	}
+}
@@ -2,22 +2,19 @@ package main
 
 import (
 	"context"
-	"database/sql"
-	"fmt"
 	"log"
 
-	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/moguchev/postgres/3/logger"
 	"github.com/moguchev/postgres/3/repository"
+	groups_databasesql "github.com/moguchev/postgres/3/repository/groups/database_sql_implementation"
+	groups_pgx "github.com/moguchev/postgres/3/repository/groups/pgx_implementation"
 	students_databasesql "github.com/moguchev/postgres/3/repository/students/database_sql_implementation"
 	students_pgx "github.com/moguchev/postgres/3/repository/students/pgx_implementation"
-)
-
-const (
-	host     = "localhost"
-	port     = 5432
-	user     = "user"
-	password = "password"
-	dbname   = "playground"
+	tx_databasesql "github.com/moguchev/postgres/3/repository/transaction/database_sql_implementation"
+	tx_pgx "github.com/moguchev/postgres/3/repository/transaction/pgx_implementation"
+	"github.com/moguchev/postgres/3/usecase"
+	"github.com/moguchev/postgres/config"
+	"go.uber.org/zap"
 )
 
 type StudentUsecase struct {
@@ -27,17 +24,26 @@ type StudentUsecase struct {
 func main() {
 	ctx := context.Background()
 
-	// connection string
-	psqlConn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", host, port, user, password, dbname)
+	// параметры подключения и пула: значения по умолчанию (docker-compose.yaml), переопределяются переменными PG* и DATABASE_URL
+	cfg, err := config.Load(config.FromEnv())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	zapLogger, err := zap.NewProduction()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer zapLogger.Sync()
 
 	// open database
-	db, err := sql.Open("postgres", psqlConn)
+	db, err := cfg.OpenDB()
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer db.Close()
 
-	pool, err := pgxpool.Connect(ctx, psqlConn)
+	pool, err := config.NewPool(ctx, cfg, config.WithZapLogger(zapLogger))
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -47,10 +53,42 @@ func main() {
 		log.Fatal(err)
 	}
 
+	repoLogger := logger.NewZap(zapLogger) // внутренние ошибки репозиториев пишем в zap, по умолчанию они не логируются
+
+	// курсоры ListStudents подписываем общим секретом из конфигурации, чтобы они переживали перезапуск
+	// и подходили всем экземплярам сервиса
+	cursors := repository.DefaultCursorCodec()
+	if cfg.CursorSecret != "" {
+		cursors = repository.NewCursorCodec([]byte(cfg.CursorSecret))
+	} else {
+		log.Printf("%s is not set: ListStudents cursors are signed with a random per-process secret", config.EnvCursorSecret)
+	}
+
+	// один TxManager на драйвер: его получают и репозитории (BulkInsert*), и бизнес логика
+	txSQL := tx_databasesql.NewTxManager(db, tx_databasesql.WithLogger(repoLogger))
+	txPgx := tx_pgx.NewTxManager(pool, tx_pgx.WithLogger(repoLogger))
+
+	studentsSQL := students_databasesql.NewRepository(db, students_databasesql.WithLogger(repoLogger), students_databasesql.WithCursorCodec(cursors), students_databasesql.WithTxManager(txSQL))
+	studentsPgx := students_pgx.NewRepository(pool, students_pgx.WithLogger(repoLogger), students_pgx.WithCursorCodec(cursors), students_pgx.WithTxManager(txPgx))
+	groupsSQL := groups_databasesql.NewRepository(db, groups_databasesql.WithLogger(repoLogger), groups_databasesql.WithTxManager(txSQL))
+	groupsPgx := groups_pgx.NewRepository(pool, groups_pgx.WithLogger(repoLogger), groups_pgx.WithTxManager(txPgx))
+
+	// проверяем все запросы репозиториев на текущей схеме: ошибка в запросе роняет сервис при старте,
+	// а не при первом вызове метода
+	if err := repository.ValidateAll(ctx, studentsSQL, studentsPgx, groupsSQL, groupsPgx); err != nil {
+		log.Fatal(err)
+	}
+
 	su := &StudentUsecase{} // наша бизнес логика
 
 	// нашей бизнес логике всеравно что мы используем
 	// мы можем спокойно подменять реализации(мигрировать с одной на другую без особых изменений кода)
-	su.repo = students_databasesql.NewRepository(db)
-	su.repo = students_pgx.NewRepository(pool)
+	su.repo = studentsSQL
+	su.repo = studentsPgx
+
+	// перевод студента в другую группу с повтором при ошибках сериализации
+	// TxManager кладет транзакцию в контекст, репозитории того же драйвера подхватывают ее сами
+	gu := usecase.NewGroupsUsecase(txSQL, groupsSQL, usecase.DefaultRetryPolicy())
+	gu = usecase.NewGroupsUsecase(txPgx, groupsPgx, usecase.DefaultRetryPolicy())
+	_ = gu // main только собирает зависимости: вызовы gu.MoveStudentToGroup - в обработчиках сервиса
 }
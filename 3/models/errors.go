@@ -1,8 +1,93 @@
 package models
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
-	ErrNotFound = errors.New("not found")
-	ErrInternal = errors.New("unexpected error")
+	ErrNotFound   = errors.New("not found")
+	ErrInternal   = errors.New("unexpected error")
+	ErrValidation = errors.New("validation failed")
+
+	ErrAlreadyInGroup = errors.New("student is already in a group")
+
+	// ошибки БД по кодам SQLSTATE (см. DatabaseError)
+	ErrConflict       = errors.New("conflict")              // 23505 unique_violation
+	ErrForeignKey     = errors.New("foreign key violation") // 23503 foreign_key_violation
+	ErrCheckViolation = errors.New("check violation")       // 23514 check_violation
+	// ErrSerialization - транзакция не может быть сериализована (40001) или попала во взаимоблокировку (40P01),
+	// ее можно безопасно повторить
+	ErrSerialization = errors.New("serialization failure")
+	ErrTimeout       = errors.New("timeout")              // 57014 query_canceled (statement_timeout), истекший дедлайн контекста
+	ErrUnavailable   = errors.New("database unavailable") // класс 08, 57P01-57P03, 53300, сетевые ошибки
 )
+
+// DatabaseError - ошибка БД, переведенная в ошибку бизнес уровня.
+// errors.Is(err, Kind) == true; если задан Reason - errors.Is(err, Reason) тоже true.
+type DatabaseError struct {
+	Kind       error  // ErrConflict, ErrForeignKey, ErrCheckViolation, ErrSerialization, ErrTimeout или ErrUnavailable
+	Reason     error  // уточнение для конкретного ограничения или операции (например ErrAlreadyInGroup), может быть nil
+	Code       string // SQLSTATE
+	Constraint string // имя нарушенного ограничения, если есть
+	Table      string
+	Column     string
+	Message    string
+}
+
+func (e *DatabaseError) Error() string {
+	msg := e.Kind.Error()
+	if e.Reason != nil {
+		msg = e.Reason.Error()
+	}
+	if e.Constraint != "" {
+		msg += fmt.Sprintf(" (constraint %s)", e.Constraint)
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+func (e *DatabaseError) Unwrap() error {
+	return e.Kind
+}
+
+func (e *DatabaseError) Is(target error) bool {
+	return e.Reason != nil && errors.Is(e.Reason, target)
+}
+
+// ValidationError - нарушение ограничения на значение поля бизнес сущности
+// (например CHECK (age > 0) в таблице students).
+// errors.Is(err, ErrValidation) == true
+type ValidationError struct {
+	Field  string
+	Reason string
+	Err    error // исходная ошибка (например *DatabaseError), может быть nil
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", ErrValidation, e.Field, e.Reason)
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// RowError - ошибка в конкретной строке массовой операции
+type RowError struct {
+	Row int // индекс строки во входных данных, с 0
+	Err error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("row %d: %s", e.Row, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,23 @@
+package models
+
+import "unicode/utf8"
+
+// Group - учебная группа (студент может состоять не более чем в одной группе)
+type Group struct {
+	ID   int64
+	Name string
+}
+
+// Validate - проверяет ограничения на поля группы (те же, что у таблицы groups)
+func (g Group) Validate() error {
+	if utf8.RuneCountInString(g.Name) > MaxNameLength {
+		return &ValidationError{Field: "name", Reason: "must not be longer than 63 characters"}
+	}
+	return nil
+}
+
+// Membership - студент в группе (строка students_groups)
+type Membership struct {
+	StudentID int64
+	GroupID   int64
+}
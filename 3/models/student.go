@@ -1,5 +1,11 @@
 package models
 
+import (
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
 // Student - наша бизнес сущность (не зависит от того где и как хранится)
 type Student struct {
 	ID         int64
@@ -8,3 +14,35 @@ type Student struct {
 	Age        uint
 	OtherField string
 }
+
+const (
+	MaxNameLength = 63            // varchar(63)
+	MaxAge        = math.MaxInt16 // int2
+)
+
+// Validate - проверяет ограничения на поля студента (те же, что у таблицы students)
+func (s Student) Validate() error {
+	if err := validateName("first_name", s.FirstName); err != nil {
+		return err
+	}
+	if err := validateName("last_name", s.LastName); err != nil {
+		return err
+	}
+	switch {
+	case s.Age == 0:
+		return &ValidationError{Field: "age", Reason: "must be greater than 0"}
+	case s.Age > MaxAge:
+		return &ValidationError{Field: "age", Reason: "must not exceed 32767"}
+	}
+	return nil
+}
+
+func validateName(field, name string) error {
+	switch {
+	case strings.TrimSpace(name) == "":
+		return &ValidationError{Field: field, Reason: "must not be empty"}
+	case utf8.RuneCountInString(name) > MaxNameLength:
+		return &ValidationError{Field: field, Reason: "must not be longer than 63 characters"}
+	}
+	return nil
+}
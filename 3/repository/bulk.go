@@ -0,0 +1,115 @@
+package repository
+
+import "github.com/moguchev/postgres/3/models"
+
+const DefaultBulkBatchSize = 5000
+
+// BulkProgress - прогресс массовой вставки, передается после каждого загруженного пакета
+type BulkProgress struct {
+	Batch     int   // номер пакета, с 1
+	BatchRows int64 // строк в этом пакете
+	Inserted  int64 // строк загружено всего (включая этот пакет)
+	Total     int   // строк во входных данных
+}
+
+type BulkInsertOptions struct {
+	BatchSize  int                // строк в одном COPY (<= 0 - DefaultBulkBatchSize)
+	OnProgress func(BulkProgress) // вызывается после каждого пакета, может быть nil
+	// KeepIDs - вставлять строки с их ID (каждый > 0) вместо id из последовательности, а после вставки
+	// сдвинуть последовательность за наибольший id. Так загружается выгрузка, на id которой ссылаются другие таблицы
+	KeepIDs bool
+}
+
+type BulkInsertOption func(o *BulkInsertOptions)
+
+func WithBatchSize(size int) BulkInsertOption {
+	return func(o *BulkInsertOptions) {
+		o.BatchSize = size
+	}
+}
+
+func WithProgress(fn func(BulkProgress)) BulkInsertOption {
+	return func(o *BulkInsertOptions) {
+		o.OnProgress = fn
+	}
+}
+
+// WithKeepIDs - см. BulkInsertOptions.KeepIDs
+func WithKeepIDs() BulkInsertOption {
+	return func(o *BulkInsertOptions) {
+		o.KeepIDs = true
+	}
+}
+
+// NewBulkInsertOptions - опции с примененными значениями по умолчанию
+func NewBulkInsertOptions(opts ...BulkInsertOption) BulkInsertOptions {
+	o := BulkInsertOptions{
+		BatchSize: DefaultBulkBatchSize,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = DefaultBulkBatchSize
+	}
+	return o
+}
+
+// CheckIDs - с KeepIDs у каждого студента должен быть id: *models.RowError для первой строки без него
+func (o BulkInsertOptions) CheckIDs(students []models.Student) error {
+	return o.checkIDs(len(students), func(i int) int64 {
+		return students[i].ID
+	})
+}
+
+// CheckGroupIDs - как CheckIDs для групп
+func (o BulkInsertOptions) CheckGroupIDs(groups []models.Group) error {
+	return o.checkIDs(len(groups), func(i int) int64 {
+		return groups[i].ID
+	})
+}
+
+func (o BulkInsertOptions) checkIDs(rows int, id func(i int) int64) error {
+	if !o.KeepIDs {
+		return nil
+	}
+	for i := 0; i < rows; i++ {
+		if id(i) <= 0 {
+			return &models.RowError{Row: i, Err: &models.ValidationError{Field: "id", Reason: "must be a positive integer"}}
+		}
+	}
+	return nil
+}
+
+// InsertBatches - вызывает insert для строк [start, end) каждого пакета из BatchSize строк по порядку
+// и сообщает о каждом вставленном пакете; первая ошибка insert прекращает вставку.
+// Возвращает число вставленных строк
+func (o BulkInsertOptions) InsertBatches(total int, insert func(start, end int) error) (int64, error) {
+	var inserted int64
+	for start, batch := 0, 1; start < total; start, batch = start+o.BatchSize, batch+1 {
+		end := start + o.BatchSize
+		if end > total {
+			end = total
+		}
+
+		if err := insert(start, end); err != nil {
+			return inserted, err
+		}
+
+		inserted += int64(end - start)
+		o.Report(BulkProgress{
+			Batch:     batch,
+			BatchRows: int64(end - start),
+			Inserted:  inserted,
+			Total:     total,
+		})
+	}
+	return inserted, nil
+}
+
+// Report - сообщает о загруженном пакете, если задан OnProgress
+func (o BulkInsertOptions) Report(progress BulkProgress) {
+	if o.OnProgress != nil {
+		o.OnProgress(progress)
+	}
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/moguchev/postgres/3/models"
+)
+
+// StudentsCursor - позиция keyset пагинации ListStudents:
+// значение поля сортировки и id последнего студента на странице
+type StudentsCursor struct {
+	SortBy    StudentsSortField `json:"s"`
+	Direction SortDirection     `json:"d"`
+	Name      string            `json:"n,omitempty"` // при сортировке по имени или фамилии
+	Age       uint              `json:"a,omitempty"` // при сортировке по возрасту
+	ID        int64             `json:"i"`
+}
+
+// NewStudentsCursor - курсор, указывающий на позицию сразу после last
+func NewStudentsCursor(filter StudentsFilter, last models.Student) StudentsCursor {
+	cursor := StudentsCursor{
+		SortBy:    filter.SortBy,
+		Direction: filter.SortDirection,
+		ID:        last.ID,
+	}
+	switch filter.SortBy {
+	case SortByFirstName:
+		cursor.Name = last.FirstName
+	case SortByLastName:
+		cursor.Name = last.LastName
+	case SortByAge:
+		cursor.Age = last.Age
+	}
+	return cursor
+}
+
+// SortKey - значение поля сортировки для условия (sort_key, id) > (SortKey, ID)
+func (c StudentsCursor) SortKey() interface{} {
+	switch c.SortBy {
+	case SortByFirstName, SortByLastName:
+		return c.Name
+	case SortByAge:
+		return c.Age
+	}
+	return c.ID
+}
+
+var errInvalidCursor = &models.ValidationError{Field: "cursor", Reason: "malformed or does not match the filter"}
+
+// CursorCodec - кодирует StudentsCursor в непрозрачный base64 токен, подписанный HMAC-SHA256.
+// В подпись входят и параметры фильтра, поэтому курсор, измененный клиентом
+// или переданный с другим фильтром/сортировкой, отвергается.
+type CursorCodec struct {
+	secret []byte
+}
+
+func NewCursorCodec(secret []byte) *CursorCodec {
+	return &CursorCodec{
+		secret: secret,
+	}
+}
+
+var (
+	defaultCursorCodec     *CursorCodec
+	defaultCursorCodecOnce sync.Once
+)
+
+// DefaultCursorCodec - общий для всех репозиториев процесса кодек со случайным ключом, для тестов и утилит.
+// Курсоры, выданные им, не переживают перезапуск и не подходят другим экземплярам сервиса:
+// сервис передает в репозитории NewCursorCodec с секретом из конфигурации (config.Config.CursorSecret).
+func DefaultCursorCodec() *CursorCodec {
+	defaultCursorCodecOnce.Do(func() {
+		secret := make([]byte, sha256.Size)
+		if _, err := rand.Read(secret); err != nil {
+			panic(fmt.Sprintf("generate cursor secret: %s", err))
+		}
+		defaultCursorCodec = NewCursorCodec(secret)
+	})
+	return defaultCursorCodec
+}
+
+func (c *CursorCodec) Encode(filter StudentsFilter, cursor StudentsCursor) string {
+	payload, _ := json.Marshal(cursor) // структура из простых полей - ошибки быть не может
+	return base64.RawURLEncoding.EncodeToString(append(payload, c.sign(filter, payload)...))
+}
+
+func (c *CursorCodec) Decode(filter StudentsFilter, token string) (StudentsCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) <= sha256.Size {
+		return StudentsCursor{}, errInvalidCursor
+	}
+
+	payload, mac := raw[:len(raw)-sha256.Size], raw[len(raw)-sha256.Size:]
+	if !hmac.Equal(mac, c.sign(filter, payload)) {
+		return StudentsCursor{}, errInvalidCursor
+	}
+
+	var cursor StudentsCursor
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(&cursor); err != nil {
+		return StudentsCursor{}, errInvalidCursor
+	}
+	if cursor.SortBy != filter.SortBy || cursor.Direction != filter.SortDirection {
+		return StudentsCursor{}, errInvalidCursor
+	}
+
+	return cursor, nil
+}
+
+// sign - HMAC от параметров фильтра (без Limit/Offset/Cursor) и содержимого курсора
+func (c *CursorCodec) sign(filter StudentsFilter, payload []byte) []byte {
+	mac := hmac.New(sha256.New, c.secret)
+	fmt.Fprintf(mac, "%q|%d|%d|%d|%d|%d|",
+		filter.NamePrefix,
+		filter.MinAge,
+		filter.MaxAge,
+		filter.GroupID,
+		filter.SortBy,
+		filter.SortDirection,
+	)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
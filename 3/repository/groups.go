@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/moguchev/postgres/3/models"
+)
+
+type GroupsRepository interface {
+	GetGroup(ctx context.Context, id int64) (models.Group, error)
+	GetGroups(ctx context.Context) ([]models.Group, error)
+	// StreamGroups - построчно передает в fn все группы в порядке id, не загружая их в память целиком.
+	// Ошибка fn прекращает чтение и возвращается как есть; отмена ctx прерывает чтение с ошибкой ctx.Err()
+	StreamGroups(ctx context.Context, fn func(models.Group) error) error
+	// CreateGroup - создает группу и возвращает ее id: group.ID, если он задан (занятый id - models.ErrConflict),
+	// иначе сгенерированный. Созданная с явным id группа не мешает следующим CreateGroup
+	CreateGroup(ctx context.Context, group models.Group) (int64, error)
+	// UpdateGroup - обновляет группу с group.ID, если такой нет - models.ErrNotFound
+	UpdateGroup(ctx context.Context, group models.Group) error
+	// DeleteGroup - удаляет группу, если такой нет - models.ErrNotFound
+	DeleteGroup(ctx context.Context, id int64) error
+	// BulkInsertGroups - загружает группы пакетами через COPY, как StudentsRepository.BulkInsertStudents:
+	// group.ID игнорируется без WithKeepIDs, либо все, либо ни одной, ошибка строки - *models.RowError
+	BulkInsertGroups(ctx context.Context, groups []models.Group, opts ...BulkInsertOption) (int64, error)
+
+	// AddStudentToGroup - добавляет студента в группу.
+	// Если студент уже состоит в группе - models.ErrAlreadyInGroup,
+	// если студента или группы не существует - models.ErrNotFound
+	AddStudentToGroup(ctx context.Context, studentID, groupID int64) error
+	// RemoveStudentFromGroup - исключает студента из группы, если он в ней не состоит - models.ErrNotFound
+	RemoveStudentFromGroup(ctx context.Context, studentID, groupID int64) error
+	// BulkInsertMemberships - загружает членства (students_groups) так же, как BulkInsertGroups (WithKeepIDs не влияет).
+	// Ошибки строк - как у AddStudentToGroup внутри *models.RowError
+	BulkInsertMemberships(ctx context.Context, memberships []models.Membership, opts ...BulkInsertOption) (int64, error)
+	// ListGroupMembers - студенты группы, упорядоченные по id
+	ListGroupMembers(ctx context.Context, groupID int64) ([]models.Student, error)
+	// StreamMemberships - построчно передает в fn все членства (students_groups) в порядке (group_id, student_id)
+	// одним запросом; ошибки - как у StreamGroups
+	StreamMemberships(ctx context.Context, fn func(studentID, groupID int64) error) error
+	// GetStudentGroup - группа студента, если он не состоит в группе - models.ErrNotFound
+	GetStudentGroup(ctx context.Context, studentID int64) (models.Group, error)
+}
@@ -0,0 +1,39 @@
+package databasesqlimplementation
+
+import (
+	"errors"
+
+	"github.com/moguchev/postgres/3/models"
+	"github.com/moguchev/postgres/3/repository/pgerrors"
+)
+
+// ошибки бизнес уровня для ограничений таблиц groups и students_groups
+var groupsErrors = pgerrors.Constraints{
+	"students_groups_student_id_key": func(e *models.DatabaseError) error {
+		e.Reason = models.ErrAlreadyInGroup
+		return e
+	},
+}
+
+// membershipError - как groupsErrors.Translate, но при добавлении в группу нарушение внешнего ключа
+// означает, что студента или группы нет: errors.Is(err, models.ErrNotFound) == true
+func membershipError(err error) error {
+	terr := groupsErrors.Translate(err)
+
+	var dbErr *models.DatabaseError
+	if errors.As(terr, &dbErr) && dbErr.Kind == models.ErrForeignKey {
+		dbErr.Reason = models.ErrNotFound
+	}
+	return terr
+}
+
+// membershipCopyError - groupsErrors.CopyRowError для COPY в students_groups: ошибка строки - как у membershipError
+func membershipCopyError(err error, batchStart int) error {
+	rerr := groupsErrors.CopyRowError(err, batchStart)
+
+	var dbErr *models.DatabaseError
+	if errors.As(rerr, &dbErr) && dbErr.Kind == models.ErrForeignKey {
+		dbErr.Reason = models.ErrNotFound
+	}
+	return rerr
+}
@@ -0,0 +1,505 @@
+package databasesqlimplementation
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/moguchev/postgres/3/logger"
+	"github.com/moguchev/postgres/3/models"
+	"github.com/moguchev/postgres/3/repository"
+	"github.com/moguchev/postgres/3/repository/sqlq"
+	transaction "github.com/moguchev/postgres/3/repository/transaction/database_sql_implementation"
+)
+
+// проверка удовлетворению интерфейса repository.GroupsRepository
+var _ repository.GroupsRepository = (*groupsRepository)(nil)
+
+// проверка удовлетворению интерфейса repository.Validator
+var _ repository.Validator = (*groupsRepository)(nil)
+
+type groupsRepository struct {
+	db *sql.DB
+	tx repository.TxManager
+
+	logger logger.Logger
+}
+
+// Option - необязательный параметр NewRepository
+type Option func(r *groupsRepository)
+
+// WithLogger - логгер внутренних ошибок (по умолчанию logger.Nop())
+func WithLogger(l logger.Logger) Option {
+	return func(r *groupsRepository) {
+		r.logger = l
+	}
+}
+
+// WithTxManager - TxManager приложения, через который BulkInsertGroups и BulkInsertMemberships открывают транзакцию,
+// если ее нет в ctx (по умолчанию - transaction.NewTxManager(db) с логгером репозитория)
+func WithTxManager(m repository.TxManager) Option {
+	return func(r *groupsRepository) {
+		r.tx = m
+	}
+}
+
+func NewRepository(db *sql.DB, opts ...Option) *groupsRepository {
+	r := &groupsRepository{
+		db: db,
+
+		logger: logger.Nop(),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.tx == nil {
+		r.tx = transaction.NewTxManager(db, transaction.WithLogger(r.logger))
+	}
+	return r
+}
+
+// conn - транзакция из ctx (см. repository.TxManager) или пул соединений, если транзакции нет
+func (r *groupsRepository) conn(ctx context.Context) transaction.Querier {
+	return transaction.GetQuerier(ctx, r.db)
+}
+
+// Validate - подготавливает все запросы репозитория на текущей схеме БД (см. repository.Validator)
+func (r *groupsRepository) Validate(ctx context.Context) error {
+	return repository.ValidateStatements(ctx, sqlq.GroupsStatements(), func(ctx context.Context, query string) error {
+		stmt, err := r.db.PrepareContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		return stmt.Close()
+	})
+}
+
+func (r *groupsRepository) GetGroup(ctx context.Context, id int64) (models.Group, error) {
+	op := logger.Start(r.logger, "get group", logger.Int64("id", id))
+
+	var group models.Group
+	if err := r.conn(ctx).QueryRowContext(ctx, sqlq.GetGroup, id).Scan(
+		&group.ID,
+		&group.Name,
+	); err != nil {
+		if terr := groupsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return models.Group{}, terr
+		}
+		op.Error("database error", err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
+		return models.Group{}, models.ErrInternal
+	}
+
+	op.Done()
+	return group, nil
+}
+
+func (r *groupsRepository) GetGroups(ctx context.Context) ([]models.Group, error) {
+	op := logger.Start(r.logger, "get groups")
+
+	rows, err := r.conn(ctx).QueryContext(ctx, sqlq.GetGroups)
+	if err != nil {
+		if terr := groupsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return nil, terr
+		}
+		op.Error("database error", err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
+		return nil, models.ErrInternal
+	}
+	defer rows.Close()
+
+	groups := make([]models.Group, 0)
+	for rows.Next() {
+		var group models.Group
+		if err = rows.Scan(
+			&group.ID,
+			&group.Name,
+		); err != nil {
+			op.Error("scan error", err)
+			return nil, models.ErrInternal
+		}
+		groups = append(groups, group)
+	}
+
+	if err = rows.Err(); err != nil {
+		if terr := groupsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return nil, terr
+		}
+		op.Error("rows error", err)
+		return nil, models.ErrInternal
+	}
+
+	op.Done()
+	return groups, nil
+}
+
+func (r *groupsRepository) StreamGroups(ctx context.Context, fn func(models.Group) error) error {
+	op := logger.Start(r.logger, "stream groups")
+
+	rows, err := r.conn(ctx).QueryContext(ctx, sqlq.GetGroups)
+	if err != nil {
+		return streamError(ctx, op, "database error", err)
+	}
+	defer rows.Close() // закрываем и при ошибке fn, и при отмене контекста
+
+	for rows.Next() {
+		if err = ctx.Err(); err != nil {
+			op.Fail(err)
+			return err
+		}
+
+		var group models.Group
+		if err = rows.Scan(
+			&group.ID,
+			&group.Name,
+		); err != nil {
+			op.Error("scan error", err)
+			return models.ErrInternal
+		}
+
+		if err = fn(group); err != nil {
+			op.Fail(err)
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return streamError(ctx, op, "rows error", err)
+	}
+
+	op.Done()
+	return nil
+}
+
+func (r *groupsRepository) CreateGroup(ctx context.Context, group models.Group) (int64, error) {
+	op := logger.Start(r.logger, "create group", logger.Int64("id", group.ID), logger.String("name", group.Name))
+
+	var id int64
+	if err := r.conn(ctx).QueryRowContext(ctx, sqlq.CreateGroup, group.ID, group.Name).Scan(&id); err != nil {
+		if terr := groupsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return 0, terr
+		}
+		op.Error("database error", err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
+		return 0, models.ErrInternal
+	}
+
+	op.Done()
+	return id, nil
+}
+
+func (r *groupsRepository) UpdateGroup(ctx context.Context, group models.Group) error {
+	op := logger.Start(r.logger, "update group", logger.Int64("id", group.ID), logger.String("name", group.Name))
+
+	result, err := r.conn(ctx).ExecContext(ctx, sqlq.UpdateGroup, group.ID, group.Name)
+	if err != nil {
+		if terr := groupsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return terr
+		}
+		op.Error("database error", err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
+		return models.ErrInternal
+	}
+
+	return checkAffected(result, op)
+}
+
+func (r *groupsRepository) DeleteGroup(ctx context.Context, id int64) error {
+	op := logger.Start(r.logger, "delete group", logger.Int64("id", id))
+
+	result, err := r.conn(ctx).ExecContext(ctx, sqlq.DeleteGroup, id)
+	if err != nil {
+		if terr := groupsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return terr
+		}
+		op.Error("database error", err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
+		return models.ErrInternal
+	}
+
+	return checkAffected(result, op)
+}
+
+func (r *groupsRepository) BulkInsertGroups(ctx context.Context, groups []models.Group, opts ...repository.BulkInsertOption) (int64, error) {
+	if len(groups) == 0 {
+		return 0, nil
+	}
+	options := repository.NewBulkInsertOptions(opts...)
+	if err := options.CheckGroupIDs(groups); err != nil {
+		return 0, err
+	}
+
+	columns := sqlq.CopyGroupsColumns
+	if options.KeepIDs {
+		columns = sqlq.CopyGroupsColumnsWithID
+	}
+
+	// все пакеты - в одной транзакции (или точке сохранения внешней транзакции)
+	var inserted int64
+	err := r.tx.WithinTx(ctx, repository.TxOptions{}, func(ctx context.Context) error {
+		var err error
+		inserted, err = options.InsertBatches(len(groups), func(start, end int) error {
+			return r.copyIn(ctx, "bulk insert groups", "groups", columns, start, end, groupsErrors.CopyRowError, func(i int) []interface{} {
+				if options.KeepIDs {
+					return []interface{}{groups[i].ID, groups[i].Name}
+				}
+				return []interface{}{groups[i].Name}
+			})
+		})
+		if err != nil {
+			return err
+		}
+		if options.KeepIDs {
+			return r.advanceSequence(ctx)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return inserted, nil
+}
+
+func (r *groupsRepository) BulkInsertMemberships(ctx context.Context, memberships []models.Membership, opts ...repository.BulkInsertOption) (int64, error) {
+	if len(memberships) == 0 {
+		return 0, nil
+	}
+	options := repository.NewBulkInsertOptions(opts...)
+
+	var inserted int64
+	err := r.tx.WithinTx(ctx, repository.TxOptions{}, func(ctx context.Context) error {
+		var err error
+		inserted, err = options.InsertBatches(len(memberships), func(start, end int) error {
+			return r.copyIn(ctx, "bulk insert memberships", "students_groups", sqlq.CopyMembershipsColumns, start, end, membershipCopyError, func(i int) []interface{} {
+				return []interface{}{memberships[i].StudentID, memberships[i].GroupID}
+			})
+		})
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return inserted, nil
+}
+
+// copyIn - строки [start, end) одним COPY FROM STDIN (pq.CopyIn работает только внутри транзакции);
+// row - значения колонок строки i, rowError переводит ошибку строки пакета
+func (r *groupsRepository) copyIn(ctx context.Context, name, table string, columns []string, start, end int,
+	rowError func(err error, batchStart int) error, row func(i int) []interface{}) error {
+	op := logger.Start(r.logger, name, logger.Any("batch_start", start), logger.Any("rows", end-start))
+
+	fail := func(err error) error {
+		if rerr := rowError(err, start); rerr != nil {
+			op.Fail(rerr)
+			return rerr
+		}
+		if terr := groupsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return terr
+		}
+		op.Error("database error", err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
+		return models.ErrInternal
+	}
+
+	stmt, err := r.conn(ctx).PrepareContext(ctx, pq.CopyIn(table, columns...))
+	if err != nil {
+		return fail(err)
+	}
+	defer stmt.Close()
+
+	for i := start; i < end; i++ {
+		if _, err = stmt.ExecContext(ctx, row(i)...); err != nil {
+			return fail(err)
+		}
+	}
+
+	// Exec без аргументов завершает COPY - ошибки данных сервер обычно возвращает именно здесь
+	if _, err = stmt.ExecContext(ctx); err != nil {
+		return fail(err)
+	}
+
+	op.Done()
+	return nil
+}
+
+// advanceSequence - после COPY с явными id сдвигает groups_id_seq, чтобы CreateGroup не выдал занятый id
+func (r *groupsRepository) advanceSequence(ctx context.Context) error {
+	op := logger.Start(r.logger, "advance groups sequence")
+
+	if _, err := r.conn(ctx).ExecContext(ctx, sqlq.AdvanceGroupsSequence); err != nil {
+		if terr := groupsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return terr
+		}
+		op.Error("database error", err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
+		return models.ErrInternal
+	}
+
+	op.Done()
+	return nil
+}
+
+func (r *groupsRepository) AddStudentToGroup(ctx context.Context, studentID, groupID int64) error {
+	op := logger.Start(r.logger, "add student to group", logger.Int64("student_id", studentID), logger.Int64("group_id", groupID))
+
+	if _, err := r.conn(ctx).ExecContext(ctx, sqlq.AddStudentToGroup, studentID, groupID); err != nil {
+		if terr := membershipError(err); terr != nil {
+			op.Fail(terr)
+			return terr
+		}
+		op.Error("database error", err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
+		return models.ErrInternal
+	}
+
+	op.Done()
+	return nil
+}
+
+func (r *groupsRepository) RemoveStudentFromGroup(ctx context.Context, studentID, groupID int64) error {
+	op := logger.Start(r.logger, "remove student from group", logger.Int64("student_id", studentID), logger.Int64("group_id", groupID))
+
+	result, err := r.conn(ctx).ExecContext(ctx, sqlq.RemoveStudentFromGroup, studentID, groupID)
+	if err != nil {
+		if terr := groupsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return terr
+		}
+		op.Error("database error", err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
+		return models.ErrInternal
+	}
+
+	return checkAffected(result, op)
+}
+
+func (r *groupsRepository) ListGroupMembers(ctx context.Context, groupID int64) ([]models.Student, error) {
+	op := logger.Start(r.logger, "list group members", logger.Int64("group_id", groupID))
+
+	rows, err := r.conn(ctx).QueryContext(ctx, sqlq.ListGroupMembers, groupID)
+	if err != nil {
+		if terr := groupsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return nil, terr
+		}
+		op.Error("database error", err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
+		return nil, models.ErrInternal
+	}
+	defer rows.Close()
+
+	students := make([]models.Student, 0)
+	for rows.Next() {
+		var student models.Student
+		if err = rows.Scan(
+			&student.ID,
+			&student.FirstName,
+			&student.LastName,
+			&student.Age,
+		); err != nil {
+			op.Error("scan error", err)
+			return nil, models.ErrInternal
+		}
+		students = append(students, student)
+	}
+
+	if err = rows.Err(); err != nil {
+		if terr := groupsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return nil, terr
+		}
+		op.Error("rows error", err)
+		return nil, models.ErrInternal
+	}
+
+	op.Done()
+	return students, nil
+}
+
+func (r *groupsRepository) StreamMemberships(ctx context.Context, fn func(studentID, groupID int64) error) error {
+	op := logger.Start(r.logger, "stream memberships")
+
+	rows, err := r.conn(ctx).QueryContext(ctx, sqlq.StreamMemberships)
+	if err != nil {
+		return streamError(ctx, op, "database error", err)
+	}
+	defer rows.Close() // закрываем и при ошибке fn, и при отмене контекста
+
+	for rows.Next() {
+		if err = ctx.Err(); err != nil {
+			op.Fail(err)
+			return err
+		}
+
+		var studentID, groupID int64
+		if err = rows.Scan(&studentID, &groupID); err != nil {
+			op.Error("scan error", err)
+			return models.ErrInternal
+		}
+
+		if err = fn(studentID, groupID); err != nil {
+			op.Fail(err)
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return streamError(ctx, op, "rows error", err)
+	}
+
+	op.Done()
+	return nil
+}
+
+func (r *groupsRepository) GetStudentGroup(ctx context.Context, studentID int64) (models.Group, error) {
+	op := logger.Start(r.logger, "get student group", logger.Int64("student_id", studentID))
+
+	var group models.Group
+	if err := r.conn(ctx).QueryRowContext(ctx, sqlq.GetStudentGroup, studentID).Scan(
+		&group.ID,
+		&group.Name,
+	); err != nil {
+		if terr := groupsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return models.Group{}, terr
+		}
+		op.Error("database error", err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
+		return models.Group{}, models.ErrInternal
+	}
+
+	op.Done()
+	return group, nil
+}
+
+// checkAffected - models.ErrNotFound, если запрос не затронул ни одной строки
+func checkAffected(result sql.Result, op *logger.Op) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		if terr := groupsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return terr
+		}
+		op.Error("rows affected error", err)
+		return models.ErrInternal
+	}
+	if affected == 0 {
+		op.Fail(models.ErrNotFound)
+		return models.ErrNotFound
+	}
+	op.Done()
+	return nil
+}
+
+// streamError - ошибка запроса Stream*: отмена ctx возвращается как есть, ошибки БД - как в остальных методах
+func streamError(ctx context.Context, op *logger.Op, msg string, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		op.Fail(ctxErr)
+		return ctxErr
+	}
+	if terr := groupsErrors.Translate(err); terr != nil {
+		op.Fail(terr)
+		return terr
+	}
+	op.Error(msg, err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
+	return models.ErrInternal
+}
@@ -0,0 +1,146 @@
+package databasesqlimplementation
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/moguchev/postgres/3/models"
+	"github.com/moguchev/postgres/3/repository/repositorytest/fakesql"
+	"github.com/moguchev/postgres/3/repository/sqlq"
+)
+
+var groupColumns = []string{"id", "name"}
+
+func TestGetGroup(t *testing.T) {
+	script := fakesql.NewScript(t)
+	script.ExpectQuery(sqlq.GetGroup).WithArgs(int64(1)).
+		WillReturnRows(groupColumns, []interface{}{1, "first"})
+	script.ExpectQuery(sqlq.GetGroup).WithArgs(int64(2)).
+		WillReturnRows(groupColumns)
+	script.ExpectQuery(sqlq.GetGroup).WithArgs(int64(3)).
+		WillReturnError(errors.New("driver: bad connection state"))
+
+	repo := NewRepository(script.DB())
+	ctx := context.Background()
+
+	group, err := repo.GetGroup(ctx, 1)
+	if err != nil || group != (models.Group{ID: 1, Name: "first"}) {
+		t.Fatalf("GetGroup(1) = %+v, %v", group, err)
+	}
+	if _, err = repo.GetGroup(ctx, 2); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("GetGroup(2) error = %v, want %v", err, models.ErrNotFound)
+	}
+	// неизвестная ошибка драйвера не уходит наверх
+	if _, err = repo.GetGroup(ctx, 3); err != models.ErrInternal {
+		t.Fatalf("GetGroup(3) error = %v, want %v", err, models.ErrInternal)
+	}
+}
+
+func TestGetGroupsScanError(t *testing.T) {
+	script := fakesql.NewScript(t)
+	script.ExpectQuery(sqlq.GetGroups).
+		WillReturnRows(groupColumns, []interface{}{1, "first"}, []interface{}{"not a number", "second"})
+
+	// строки закрываются и при ошибке Scan: это проверит сценарий по окончании теста
+	if _, err := NewRepository(script.DB()).GetGroups(context.Background()); err != models.ErrInternal {
+		t.Fatalf("GetGroups error = %v, want %v", err, models.ErrInternal)
+	}
+}
+
+func TestStreamGroupsStopsOnError(t *testing.T) {
+	errStop := errors.New("stop")
+
+	script := fakesql.NewScript(t)
+	script.ExpectQuery(sqlq.GetGroups).
+		WillReturnRows(groupColumns, []interface{}{1, "first"}, []interface{}{2, "second"})
+
+	var got []models.Group
+	err := NewRepository(script.DB()).StreamGroups(context.Background(), func(group models.Group) error {
+		got = append(got, group)
+		return errStop
+	})
+	if !errors.Is(err, errStop) {
+		t.Fatalf("StreamGroups error = %v, want %v", err, errStop)
+	}
+	if len(got) != 1 {
+		t.Fatalf("StreamGroups called fn %d times, want 1", len(got))
+	}
+}
+
+func TestCreateGroup(t *testing.T) {
+	script := fakesql.NewScript(t)
+	script.ExpectQuery(sqlq.CreateGroup).WithArgs(int64(0), "new").
+		WillReturnRows([]string{"id"}, []interface{}{7})
+	script.ExpectQuery(sqlq.CreateGroup).WithArgs(int64(7), "dup").
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "groups_pkey"})
+
+	repo := NewRepository(script.DB())
+	ctx := context.Background()
+
+	id, err := repo.CreateGroup(ctx, models.Group{Name: "new"})
+	if err != nil || id != 7 {
+		t.Fatalf("CreateGroup = %d, %v, want 7", id, err)
+	}
+	if _, err = repo.CreateGroup(ctx, models.Group{ID: 7, Name: "dup"}); !errors.Is(err, models.ErrConflict) {
+		t.Fatalf("CreateGroup with existing id error = %v, want %v", err, models.ErrConflict)
+	}
+}
+
+func TestUpdateGroupNotFound(t *testing.T) {
+	script := fakesql.NewScript(t)
+	script.ExpectExec(sqlq.UpdateGroup).WithArgs(int64(1), "renamed").WillReturnRowsAffected(0)
+
+	err := NewRepository(script.DB()).UpdateGroup(context.Background(), models.Group{ID: 1, Name: "renamed"})
+	if !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("UpdateGroup error = %v, want %v", err, models.ErrNotFound)
+	}
+}
+
+func TestAddStudentToGroup(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want []error
+	}{
+		{
+			name: "ok",
+		},
+		{
+			name: "already in group",
+			err:  &pq.Error{Code: "23505", Constraint: "students_groups_student_id_key"},
+			want: []error{models.ErrConflict, models.ErrAlreadyInGroup},
+		},
+		{
+			name: "missing student",
+			err:  &pq.Error{Code: "23503", Constraint: "students_groups_student_id_fkey"},
+			want: []error{models.ErrForeignKey, models.ErrNotFound},
+		},
+		{
+			name: "connection lost",
+			err:  &pq.Error{Code: "57P01"},
+			want: []error{models.ErrUnavailable},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			script := fakesql.NewScript(t)
+			script.ExpectExec(sqlq.AddStudentToGroup).WithArgs(int64(1), int64(2)).
+				WillReturnRowsAffected(1).
+				WillReturnError(tt.err)
+
+			err := NewRepository(script.DB()).AddStudentToGroup(context.Background(), 1, 2)
+			if tt.want == nil && err != nil {
+				t.Fatalf("AddStudentToGroup error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !errors.Is(err, want) {
+					t.Errorf("AddStudentToGroup error = %v, want errors.Is(err, %v)", err, want)
+				}
+			}
+		})
+	}
+}
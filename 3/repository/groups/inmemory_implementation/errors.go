@@ -0,0 +1,54 @@
+package inmemoryimplementation
+
+import (
+	"github.com/moguchev/postgres/3/models"
+	"github.com/moguchev/postgres/3/repository/pgerrors"
+)
+
+// ошибки, которые реализации над PostgreSQL возвращают при нарушении ограничений таблиц groups и students_groups
+
+func conflictError() error {
+	return &models.DatabaseError{
+		Kind:       models.ErrConflict,
+		Code:       pgerrors.UniqueViolation,
+		Constraint: "groups_pkey",
+		Table:      "groups",
+		Message:    `duplicate key value violates unique constraint "groups_pkey"`,
+	}
+}
+
+func alreadyInGroupError() error {
+	return &models.DatabaseError{
+		Kind:       models.ErrConflict,
+		Reason:     models.ErrAlreadyInGroup,
+		Code:       pgerrors.UniqueViolation,
+		Constraint: "students_groups_student_id_key",
+		Table:      "students_groups",
+		Message:    `duplicate key value violates unique constraint "students_groups_student_id_key"`,
+	}
+}
+
+// missingReferenceError - добавление в группу несуществующего студента или в несуществующую группу:
+// как membershipError реализаций над PostgreSQL, errors.Is(err, models.ErrNotFound) == true
+func missingReferenceError(column string) error {
+	constraint := "students_groups_" + column + "_fkey"
+	return &models.DatabaseError{
+		Kind:       models.ErrForeignKey,
+		Reason:     models.ErrNotFound,
+		Code:       pgerrors.ForeignKeyViolation,
+		Constraint: constraint,
+		Table:      "students_groups",
+		Message:    `insert or update on table "students_groups" violates foreign key constraint "` + constraint + `"`,
+	}
+}
+
+// groupInUseError - удаление группы, в которой есть студенты
+func groupInUseError() error {
+	return &models.DatabaseError{
+		Kind:       models.ErrForeignKey,
+		Code:       pgerrors.ForeignKeyViolation,
+		Constraint: "students_groups_group_id_fkey",
+		Table:      "students_groups",
+		Message:    `update or delete on table "groups" violates foreign key constraint "students_groups_group_id_fkey" on table "students_groups"`,
+	}
+}
@@ -0,0 +1,396 @@
+// Package inmemoryimplementation - repository.GroupsRepository в памяти процесса для unit тестов бизнес логики
+// без PostgreSQL. Семантика совпадает с реализациями над БД: models.ErrNotFound, порядок строк, ошибки
+// ограничений таблиц groups и students_groups (первичный ключ, UNIQUE(student_id), внешние ключи)
+// и последовательность id, которая не откатывается вместе с транзакцией.
+// Строки хранятся в transaction.Table, поэтому репозиторий участвует в транзакциях transaction/inmemory_implementation
+// вместе с репозиторием студентов в памяти. Связать их в обе стороны (внешние ключи students_groups)
+// и с TxManager приложения:
+//
+//	txManager := transaction.NewTxManager()
+//	groups := groups_inmemory.NewRepository(groups_inmemory.WithTxManager(txManager))
+//	students := students_inmemory.NewRepository(students_inmemory.WithGroups(groups), students_inmemory.WithTxManager(txManager))
+//	groups_inmemory.WithStudents(students)(groups)
+package inmemoryimplementation
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/moguchev/postgres/3/logger"
+	"github.com/moguchev/postgres/3/models"
+	"github.com/moguchev/postgres/3/repository"
+	transaction "github.com/moguchev/postgres/3/repository/transaction/inmemory_implementation"
+)
+
+// проверка удовлетворению интерфейса repository.GroupsRepository
+var _ repository.GroupsRepository = (*groupsRepository)(nil)
+
+type groupsRepository struct {
+	mu          sync.Mutex         // проверка ограничений и запись строки - одно действие
+	groups      *transaction.Table // id -> models.Group
+	memberships *transaction.Table // student_id -> group_id (UNIQUE(student_id))
+	lastID      int64              // последнее выданное значение последовательности id (serial), не откатывается
+
+	students repository.StudentsRepository
+	tx       repository.TxManager
+	logger   logger.Logger
+}
+
+// Option - необязательный параметр NewRepository
+type Option func(r *groupsRepository)
+
+// WithLogger - логгер внутренних ошибок (по умолчанию logger.Nop())
+func WithLogger(l logger.Logger) Option {
+	return func(r *groupsRepository) {
+		r.logger = l
+	}
+}
+
+// WithStudents - репозиторий студентов для внешнего ключа students_groups.student_id и данных ListGroupMembers.
+// Без него AddStudentToGroup не проверяет, что студент существует, а ListGroupMembers возвращает только id студентов
+func WithStudents(students repository.StudentsRepository) Option {
+	return func(r *groupsRepository) {
+		r.students = students
+	}
+}
+
+// WithTxManager - TxManager приложения: BulkInsertGroups и BulkInsertMemberships без транзакции в ctx
+// открывают ее через него (по умолчанию - собственный transaction.NewTxManager())
+func WithTxManager(m repository.TxManager) Option {
+	return func(r *groupsRepository) {
+		r.tx = m
+	}
+}
+
+func NewRepository(opts ...Option) *groupsRepository {
+	r := &groupsRepository{
+		groups:      transaction.NewTable(),
+		memberships: transaction.NewTable(),
+
+		tx:     transaction.NewTxManager(),
+		logger: logger.Nop(),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// get - группа id, видимая в ctx
+func (r *groupsRepository) get(ctx context.Context, id int64) (models.Group, bool) {
+	group, ok := r.groups.Get(ctx, id)
+	if !ok {
+		return models.Group{}, false
+	}
+	return group.(models.Group), true
+}
+
+func (r *groupsRepository) GetGroup(ctx context.Context, id int64) (models.Group, error) {
+	group, ok := r.get(ctx, id)
+	if !ok {
+		return models.Group{}, models.ErrNotFound
+	}
+	return group, nil
+}
+
+func (r *groupsRepository) GetGroups(ctx context.Context) ([]models.Group, error) {
+	return r.sortedGroups(ctx), nil
+}
+
+func (r *groupsRepository) StreamGroups(ctx context.Context, fn func(models.Group) error) error {
+	// fn вызывается без блокировки: она может обращаться к репозиторию
+	for _, group := range r.sortedGroups(ctx) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *groupsRepository) CreateGroup(ctx context.Context, group models.Group) (int64, error) {
+	op := logger.Start(r.logger, "create group", logger.Int64("id", group.ID), logger.String("name", group.Name))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// явный id больше выданных сдвигает последовательность - как setval в запросе PostgreSQL
+	if group.ID == 0 {
+		r.lastID++
+		group.ID = r.lastID
+	} else if group.ID > r.lastID {
+		r.lastID = group.ID
+	}
+
+	if err := checkGroup(group); err != nil {
+		op.Error("database error", err) // как у реализаций над БД: ошибка данных - внутренняя
+		return 0, models.ErrInternal
+	}
+	if _, ok := r.get(ctx, group.ID); ok {
+		err := conflictError()
+		op.Fail(err)
+		return 0, err
+	}
+
+	r.groups.Put(ctx, group.ID, models.Group{ID: group.ID, Name: group.Name})
+	op.Done()
+	return group.ID, nil
+}
+
+func (r *groupsRepository) UpdateGroup(ctx context.Context, group models.Group) error {
+	op := logger.Start(r.logger, "update group", logger.Int64("id", group.ID), logger.String("name", group.Name))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.get(ctx, group.ID); !ok {
+		op.Fail(models.ErrNotFound)
+		return models.ErrNotFound
+	}
+	if err := checkGroup(group); err != nil {
+		op.Error("database error", err)
+		return models.ErrInternal
+	}
+
+	r.groups.Put(ctx, group.ID, models.Group{ID: group.ID, Name: group.Name})
+	op.Done()
+	return nil
+}
+
+func (r *groupsRepository) DeleteGroup(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.get(ctx, id); !ok {
+		return models.ErrNotFound
+	}
+	for _, groupID := range r.memberships.Rows(ctx) {
+		if groupID.(int64) == id {
+			return groupInUseError() // внешний ключ students_groups.group_id
+		}
+	}
+
+	r.groups.Delete(ctx, id)
+	return nil
+}
+
+func (r *groupsRepository) BulkInsertGroups(ctx context.Context, groups []models.Group, opts ...repository.BulkInsertOption) (int64, error) {
+	if len(groups) == 0 {
+		return 0, nil
+	}
+	options := repository.NewBulkInsertOptions(opts...)
+	if err := options.CheckGroupIDs(groups); err != nil {
+		return 0, err
+	}
+
+	// строки появляются в общих данных разом, как после COPY в транзакции (см. students BulkInsertStudents)
+	err := r.tx.WithinTx(ctx, repository.TxOptions{}, func(ctx context.Context) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		// сначала проверяем все строки: либо все, либо ни одной
+		ids := make(map[int64]bool, len(groups))
+		for i, group := range groups {
+			if !options.KeepIDs {
+				group.ID = r.lastID + int64(i) + 1
+			}
+			if err := checkGroup(group); err != nil {
+				// как pgerrors.CopyRowError: ошибка данных строки - *models.ValidationError
+				return &models.RowError{Row: i, Err: &models.ValidationError{Field: "name", Reason: "value too long for type character varying(63)"}}
+			}
+			if _, ok := r.get(ctx, group.ID); ok || ids[group.ID] {
+				return &models.RowError{Row: i, Err: conflictError()}
+			}
+			ids[group.ID] = true
+		}
+
+		for _, group := range groups {
+			if !options.KeepIDs {
+				r.lastID++
+				group.ID = r.lastID
+			} else if group.ID > r.lastID {
+				r.lastID = group.ID
+			}
+			r.groups.Put(ctx, group.ID, models.Group{ID: group.ID, Name: group.Name})
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// прогресс - после вставки, без блокировки: OnProgress может обращаться к репозиторию
+	return options.InsertBatches(len(groups), func(start, end int) error {
+		return nil
+	})
+}
+
+func (r *groupsRepository) BulkInsertMemberships(ctx context.Context, memberships []models.Membership, opts ...repository.BulkInsertOption) (int64, error) {
+	if len(memberships) == 0 {
+		return 0, nil
+	}
+	options := repository.NewBulkInsertOptions(opts...)
+
+	err := r.tx.WithinTx(ctx, repository.TxOptions{}, func(ctx context.Context) error {
+		// внешний ключ students_groups.student_id
+		if r.students != nil {
+			for i, m := range memberships {
+				if _, err := r.students.GetStudent(ctx, m.StudentID); err != nil {
+					if errors.Is(err, models.ErrNotFound) {
+						err = missingReferenceError("student_id")
+					}
+					return &models.RowError{Row: i, Err: err}
+				}
+			}
+		}
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		// сначала проверяем все строки: либо все, либо ни одной
+		students := make(map[int64]bool, len(memberships))
+		for i, m := range memberships {
+			if _, ok := r.get(ctx, m.GroupID); !ok {
+				return &models.RowError{Row: i, Err: missingReferenceError("group_id")}
+			}
+			if _, ok := r.memberships.Get(ctx, m.StudentID); ok || students[m.StudentID] {
+				return &models.RowError{Row: i, Err: alreadyInGroupError()}
+			}
+			students[m.StudentID] = true
+		}
+
+		for _, m := range memberships {
+			r.memberships.Put(ctx, m.StudentID, m.GroupID)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return options.InsertBatches(len(memberships), func(start, end int) error {
+		return nil
+	})
+}
+
+func (r *groupsRepository) AddStudentToGroup(ctx context.Context, studentID, groupID int64) error {
+	// внешний ключ students_groups.student_id
+	if r.students != nil {
+		if _, err := r.students.GetStudent(ctx, studentID); err != nil {
+			if errors.Is(err, models.ErrNotFound) {
+				return missingReferenceError("student_id")
+			}
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.get(ctx, groupID); !ok {
+		return missingReferenceError("group_id")
+	}
+	if _, ok := r.memberships.Get(ctx, studentID); ok {
+		return alreadyInGroupError()
+	}
+
+	r.memberships.Put(ctx, studentID, groupID)
+	return nil
+}
+
+func (r *groupsRepository) RemoveStudentFromGroup(ctx context.Context, studentID, groupID int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, ok := r.memberships.Get(ctx, studentID)
+	if !ok || current.(int64) != groupID {
+		return models.ErrNotFound
+	}
+
+	r.memberships.Delete(ctx, studentID)
+	return nil
+}
+
+func (r *groupsRepository) ListGroupMembers(ctx context.Context, groupID int64) ([]models.Student, error) {
+	ids := make([]int64, 0)
+	for studentID, memberOf := range r.memberships.Rows(ctx) {
+		if memberOf.(int64) == groupID {
+			ids = append(ids, studentID)
+		}
+	}
+
+	if r.students != nil {
+		return r.students.GetStudents(ctx, ids...) // упорядочены по id
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	students := make([]models.Student, 0, len(ids))
+	for _, id := range ids {
+		students = append(students, models.Student{ID: id})
+	}
+	return students, nil
+}
+
+func (r *groupsRepository) StreamMemberships(ctx context.Context, fn func(studentID, groupID int64) error) error {
+	type membership struct {
+		studentID, groupID int64
+	}
+	rows := r.memberships.Rows(ctx)
+	memberships := make([]membership, 0, len(rows))
+	for studentID, groupID := range rows {
+		memberships = append(memberships, membership{studentID: studentID, groupID: groupID.(int64)})
+	}
+	// ORDER BY group_id, student_id
+	sort.Slice(memberships, func(i, j int) bool {
+		if memberships[i].groupID != memberships[j].groupID {
+			return memberships[i].groupID < memberships[j].groupID
+		}
+		return memberships[i].studentID < memberships[j].studentID
+	})
+
+	for _, m := range memberships {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(m.studentID, m.groupID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *groupsRepository) GetStudentGroup(ctx context.Context, studentID int64) (models.Group, error) {
+	groupID, ok := r.memberships.Get(ctx, studentID)
+	if !ok {
+		return models.Group{}, models.ErrNotFound
+	}
+	return r.GetGroup(ctx, groupID.(int64))
+}
+
+// sortedGroups - все группы, видимые в ctx, в порядке id
+func (r *groupsRepository) sortedGroups(ctx context.Context) []models.Group {
+	rows := r.groups.Rows(ctx)
+	groups := make([]models.Group, 0, len(rows))
+	for _, group := range rows {
+		groups = append(groups, group.(models.Group))
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].ID < groups[j].ID
+	})
+	return groups
+}
+
+// checkGroup - значение не помещается в колонку name varchar(63)
+func checkGroup(group models.Group) error {
+	if utf8.RuneCountInString(group.Name) > models.MaxNameLength {
+		return errors.New("name: value too long for type character varying(63)")
+	}
+	return nil
+}
@@ -0,0 +1,491 @@
+package pgximplementation
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/moguchev/postgres/3/logger"
+	"github.com/moguchev/postgres/3/models"
+	"github.com/moguchev/postgres/3/repository"
+	"github.com/moguchev/postgres/3/repository/sqlq"
+	transaction "github.com/moguchev/postgres/3/repository/transaction/pgx_implementation"
+)
+
+// проверка удовлетворению интерфейса repository.GroupsRepository
+var _ repository.GroupsRepository = (*groupsRepository)(nil)
+
+// проверка удовлетворению интерфейса repository.Validator
+var _ repository.Validator = (*groupsRepository)(nil)
+
+type groupsRepository struct {
+	db transaction.Querier
+	tx repository.TxManager
+
+	logger logger.Logger
+}
+
+// Option - необязательный параметр NewRepository
+type Option func(r *groupsRepository)
+
+// WithLogger - логгер внутренних ошибок (по умолчанию logger.Nop())
+func WithLogger(l logger.Logger) Option {
+	return func(r *groupsRepository) {
+		r.logger = l
+	}
+}
+
+// WithTxManager - TxManager приложения, через который BulkInsertGroups и BulkInsertMemberships открывают транзакцию,
+// если ее нет в ctx (по умолчанию - transaction.NewTxManager(db) с логгером репозитория)
+func WithTxManager(m repository.TxManager) Option {
+	return func(r *groupsRepository) {
+		r.tx = m
+	}
+}
+
+func NewRepository(db transaction.Querier, opts ...Option) *groupsRepository {
+	r := &groupsRepository{
+		db: db,
+
+		logger: logger.Nop(),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.tx == nil {
+		r.tx = transaction.NewTxManager(db, transaction.WithLogger(r.logger))
+	}
+	return r
+}
+
+// conn - транзакция из ctx (см. repository.TxManager) или db, если транзакции нет
+func (r *groupsRepository) conn(ctx context.Context) transaction.Querier {
+	return transaction.GetQuerier(ctx, r.db)
+}
+
+// Validate - подготавливает все запросы репозитория на текущей схеме БД (см. repository.Validator).
+// Безымянный prepared statement не попадает в кэш запросов pgx и заменяется следующим
+func (r *groupsRepository) Validate(ctx context.Context) error {
+	op := logger.Start(r.logger, "validate groups statements")
+
+	conn, release, err := transaction.AcquireConn(ctx, r.db)
+	if err != nil {
+		if terr := groupsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return terr
+		}
+		op.Error("acquire error", err)
+		return models.ErrInternal
+	}
+	defer release()
+
+	if err = repository.ValidateStatements(ctx, sqlq.GroupsStatements(), func(ctx context.Context, query string) error {
+		_, err := conn.PgConn().Prepare(ctx, "", query, nil)
+		return err
+	}); err != nil {
+		op.Error("validate error", err)
+		return err
+	}
+
+	op.Done()
+	return nil
+}
+
+func (r *groupsRepository) GetGroup(ctx context.Context, id int64) (models.Group, error) {
+	op := logger.Start(r.logger, "get group", logger.Int64("id", id))
+
+	var group models.Group
+	if err := r.conn(ctx).QueryRow(ctx, sqlq.GetGroup, id).Scan(
+		&group.ID,
+		&group.Name,
+	); err != nil {
+		if terr := groupsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return models.Group{}, terr
+		}
+		op.Error("database error", err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
+		return models.Group{}, models.ErrInternal
+	}
+
+	op.Done()
+	return group, nil
+}
+
+func (r *groupsRepository) GetGroups(ctx context.Context) ([]models.Group, error) {
+	op := logger.Start(r.logger, "get groups")
+
+	rows, err := r.conn(ctx).Query(ctx, sqlq.GetGroups)
+	if err != nil {
+		if terr := groupsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return nil, terr
+		}
+		op.Error("database error", err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
+		return nil, models.ErrInternal
+	}
+	defer rows.Close()
+
+	groups := make([]models.Group, 0)
+	for rows.Next() {
+		var group models.Group
+		if err = rows.Scan(
+			&group.ID,
+			&group.Name,
+		); err != nil {
+			op.Error("scan error", err)
+			return nil, models.ErrInternal
+		}
+		groups = append(groups, group)
+	}
+
+	if err = rows.Err(); err != nil {
+		if terr := groupsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return nil, terr
+		}
+		op.Error("rows error", err)
+		return nil, models.ErrInternal
+	}
+
+	op.Done()
+	return groups, nil
+}
+
+func (r *groupsRepository) StreamGroups(ctx context.Context, fn func(models.Group) error) error {
+	op := logger.Start(r.logger, "stream groups")
+
+	var group models.Group
+	scans := []interface{}{
+		&group.ID,
+		&group.Name,
+	}
+
+	var fnErr error // ошибку fn отдаем как есть, а не как ошибку БД
+	_, err := r.conn(ctx).QueryFunc(ctx, sqlq.GetGroups, nil, scans, func(pgx.QueryFuncRow) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		fnErr = fn(group)
+		return fnErr
+	})
+	if err != nil {
+		if fnErr != nil {
+			op.Fail(fnErr)
+			return fnErr
+		}
+		return streamError(ctx, op, err)
+	}
+
+	op.Done()
+	return nil
+}
+
+func (r *groupsRepository) CreateGroup(ctx context.Context, group models.Group) (int64, error) {
+	op := logger.Start(r.logger, "create group", logger.Int64("id", group.ID), logger.String("name", group.Name))
+
+	var id int64
+	if err := r.conn(ctx).QueryRow(ctx, sqlq.CreateGroup, group.ID, group.Name).Scan(&id); err != nil {
+		if terr := groupsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return 0, terr
+		}
+		op.Error("database error", err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
+		return 0, models.ErrInternal
+	}
+
+	op.Done()
+	return id, nil
+}
+
+func (r *groupsRepository) UpdateGroup(ctx context.Context, group models.Group) error {
+	op := logger.Start(r.logger, "update group", logger.Int64("id", group.ID), logger.String("name", group.Name))
+
+	tag, err := r.conn(ctx).Exec(ctx, sqlq.UpdateGroup, group.ID, group.Name)
+	if err != nil {
+		if terr := groupsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return terr
+		}
+		op.Error("database error", err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
+		return models.ErrInternal
+	}
+
+	if tag.RowsAffected() == 0 {
+		op.Fail(models.ErrNotFound)
+		return models.ErrNotFound
+	}
+
+	op.Done()
+	return nil
+}
+
+func (r *groupsRepository) DeleteGroup(ctx context.Context, id int64) error {
+	op := logger.Start(r.logger, "delete group", logger.Int64("id", id))
+
+	tag, err := r.conn(ctx).Exec(ctx, sqlq.DeleteGroup, id)
+	if err != nil {
+		if terr := groupsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return terr
+		}
+		op.Error("database error", err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
+		return models.ErrInternal
+	}
+
+	if tag.RowsAffected() == 0 {
+		op.Fail(models.ErrNotFound)
+		return models.ErrNotFound
+	}
+
+	op.Done()
+	return nil
+}
+
+func (r *groupsRepository) BulkInsertGroups(ctx context.Context, groups []models.Group, opts ...repository.BulkInsertOption) (int64, error) {
+	if len(groups) == 0 {
+		return 0, nil
+	}
+	options := repository.NewBulkInsertOptions(opts...)
+	if err := options.CheckGroupIDs(groups); err != nil {
+		return 0, err
+	}
+
+	columns := sqlq.CopyGroupsColumns
+	if options.KeepIDs {
+		columns = sqlq.CopyGroupsColumnsWithID
+	}
+
+	// все пакеты - в одной транзакции (или точке сохранения внешней транзакции)
+	var inserted int64
+	err := r.tx.WithinTx(ctx, repository.TxOptions{}, func(ctx context.Context) error {
+		var err error
+		inserted, err = options.InsertBatches(len(groups), func(start, end int) error {
+			return r.copyFrom(ctx, "bulk insert groups", "groups", columns, start, end, groupsErrors.CopyRowError, func(i int) []interface{} {
+				if options.KeepIDs {
+					return []interface{}{groups[i].ID, groups[i].Name}
+				}
+				return []interface{}{groups[i].Name}
+			})
+		})
+		if err != nil {
+			return err
+		}
+		if options.KeepIDs {
+			return r.advanceSequence(ctx)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return inserted, nil
+}
+
+func (r *groupsRepository) BulkInsertMemberships(ctx context.Context, memberships []models.Membership, opts ...repository.BulkInsertOption) (int64, error) {
+	if len(memberships) == 0 {
+		return 0, nil
+	}
+	options := repository.NewBulkInsertOptions(opts...)
+
+	var inserted int64
+	err := r.tx.WithinTx(ctx, repository.TxOptions{}, func(ctx context.Context) error {
+		var err error
+		inserted, err = options.InsertBatches(len(memberships), func(start, end int) error {
+			return r.copyFrom(ctx, "bulk insert memberships", "students_groups", sqlq.CopyMembershipsColumns, start, end, membershipCopyError, func(i int) []interface{} {
+				return []interface{}{memberships[i].StudentID, memberships[i].GroupID}
+			})
+		})
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return inserted, nil
+}
+
+// copyFrom - строки [start, end) одним COPY FROM STDIN в бинарном формате;
+// row - значения колонок строки i, rowError переводит ошибку строки пакета
+func (r *groupsRepository) copyFrom(ctx context.Context, name, table string, columns []string, start, end int,
+	rowError func(err error, batchStart int) error, row func(i int) []interface{}) error {
+	op := logger.Start(r.logger, name, logger.Any("batch_start", start), logger.Any("rows", end-start))
+
+	source := pgx.CopyFromSlice(end-start, func(i int) ([]interface{}, error) {
+		return row(start + i), nil
+	})
+	if _, err := r.conn(ctx).CopyFrom(ctx, pgx.Identifier{table}, columns, source); err != nil {
+		if rerr := rowError(err, start); rerr != nil {
+			op.Fail(rerr)
+			return rerr
+		}
+		if terr := groupsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return terr
+		}
+		op.Error("database error", err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
+		return models.ErrInternal
+	}
+
+	op.Done()
+	return nil
+}
+
+// advanceSequence - после COPY с явными id сдвигает groups_id_seq, чтобы CreateGroup не выдал занятый id
+func (r *groupsRepository) advanceSequence(ctx context.Context) error {
+	op := logger.Start(r.logger, "advance groups sequence")
+
+	if _, err := r.conn(ctx).Exec(ctx, sqlq.AdvanceGroupsSequence); err != nil {
+		if terr := groupsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return terr
+		}
+		op.Error("database error", err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
+		return models.ErrInternal
+	}
+
+	op.Done()
+	return nil
+}
+
+func (r *groupsRepository) AddStudentToGroup(ctx context.Context, studentID, groupID int64) error {
+	op := logger.Start(r.logger, "add student to group", logger.Int64("student_id", studentID), logger.Int64("group_id", groupID))
+
+	if _, err := r.conn(ctx).Exec(ctx, sqlq.AddStudentToGroup, studentID, groupID); err != nil {
+		if terr := membershipError(err); terr != nil {
+			op.Fail(terr)
+			return terr
+		}
+		op.Error("database error", err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
+		return models.ErrInternal
+	}
+
+	op.Done()
+	return nil
+}
+
+func (r *groupsRepository) RemoveStudentFromGroup(ctx context.Context, studentID, groupID int64) error {
+	op := logger.Start(r.logger, "remove student from group", logger.Int64("student_id", studentID), logger.Int64("group_id", groupID))
+
+	tag, err := r.conn(ctx).Exec(ctx, sqlq.RemoveStudentFromGroup, studentID, groupID)
+	if err != nil {
+		if terr := groupsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return terr
+		}
+		op.Error("database error", err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
+		return models.ErrInternal
+	}
+
+	if tag.RowsAffected() == 0 {
+		op.Fail(models.ErrNotFound)
+		return models.ErrNotFound
+	}
+
+	op.Done()
+	return nil
+}
+
+func (r *groupsRepository) ListGroupMembers(ctx context.Context, groupID int64) ([]models.Student, error) {
+	op := logger.Start(r.logger, "list group members", logger.Int64("group_id", groupID))
+
+	rows, err := r.conn(ctx).Query(ctx, sqlq.ListGroupMembers, groupID)
+	if err != nil {
+		if terr := groupsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return nil, terr
+		}
+		op.Error("database error", err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
+		return nil, models.ErrInternal
+	}
+	defer rows.Close()
+
+	students := make([]models.Student, 0)
+	for rows.Next() {
+		var student models.Student
+		if err = rows.Scan(
+			&student.ID,
+			&student.FirstName,
+			&student.LastName,
+			&student.Age,
+		); err != nil {
+			op.Error("scan error", err)
+			return nil, models.ErrInternal
+		}
+		students = append(students, student)
+	}
+
+	if err = rows.Err(); err != nil {
+		if terr := groupsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return nil, terr
+		}
+		op.Error("rows error", err)
+		return nil, models.ErrInternal
+	}
+
+	op.Done()
+	return students, nil
+}
+
+func (r *groupsRepository) StreamMemberships(ctx context.Context, fn func(studentID, groupID int64) error) error {
+	op := logger.Start(r.logger, "stream memberships")
+
+	var studentID, groupID int64
+	scans := []interface{}{
+		&studentID,
+		&groupID,
+	}
+
+	var fnErr error // ошибку fn отдаем как есть, а не как ошибку БД
+	_, err := r.conn(ctx).QueryFunc(ctx, sqlq.StreamMemberships, nil, scans, func(pgx.QueryFuncRow) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		fnErr = fn(studentID, groupID)
+		return fnErr
+	})
+	if err != nil {
+		if fnErr != nil {
+			op.Fail(fnErr)
+			return fnErr
+		}
+		return streamError(ctx, op, err)
+	}
+
+	op.Done()
+	return nil
+}
+
+func (r *groupsRepository) GetStudentGroup(ctx context.Context, studentID int64) (models.Group, error) {
+	op := logger.Start(r.logger, "get student group", logger.Int64("student_id", studentID))
+
+	var group models.Group
+	if err := r.conn(ctx).QueryRow(ctx, sqlq.GetStudentGroup, studentID).Scan(
+		&group.ID,
+		&group.Name,
+	); err != nil {
+		if terr := groupsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return models.Group{}, terr
+		}
+		op.Error("database error", err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
+		return models.Group{}, models.ErrInternal
+	}
+
+	op.Done()
+	return group, nil
+}
+
+// streamError - ошибка запроса Stream*: отмена ctx возвращается как есть, ошибки БД - как в остальных методах
+func streamError(ctx context.Context, op *logger.Op, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		op.Fail(ctxErr)
+		return ctxErr
+	}
+	if terr := groupsErrors.Translate(err); terr != nil {
+		op.Fail(terr)
+		return terr
+	}
+	op.Error("database error", err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
+	return models.ErrInternal
+}
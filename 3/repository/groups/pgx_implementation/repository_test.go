@@ -0,0 +1,226 @@
+package pgximplementation
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/moguchev/postgres/3/models"
+	"github.com/moguchev/postgres/3/repository"
+	"github.com/moguchev/postgres/3/repository/repositorytest"
+	"github.com/moguchev/postgres/3/repository/repositorytest/pgxmock"
+	"github.com/moguchev/postgres/3/repository/sqlq"
+	transaction "github.com/moguchev/postgres/3/repository/transaction/pgx_implementation"
+)
+
+func TestStreamGroups(t *testing.T) {
+	mock := pgxmock.New(t)
+	mock.ExpectQuery(sqlq.GetGroups).
+		WillReturnRows([]string{"id", "name"}, []interface{}{int64(1), "first"}, []interface{}{int64(2), "second"})
+
+	var got []models.Group
+	err := NewRepository(mock).StreamGroups(context.Background(), func(group models.Group) error {
+		got = append(got, group)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamGroups error = %v", err)
+	}
+	want := []models.Group{{ID: 1, Name: "first"}, {ID: 2, Name: "second"}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("StreamGroups = %+v, want %+v", got, want)
+	}
+}
+
+func TestStreamMemberships(t *testing.T) {
+	errStop := errors.New("stop")
+
+	tests := []struct {
+		name  string
+		rows  [][]interface{}
+		err   error
+		fnErr error
+		want  error
+		calls int
+	}{
+		{
+			name:  "fn error stops the stream",
+			rows:  [][]interface{}{{int64(1), int64(10)}, {int64(2), int64(10)}},
+			fnErr: errStop,
+			want:  errStop,
+			calls: 1,
+		},
+		{
+			name: "database error",
+			err:  &pgconn.PgError{Code: "57014"},
+			want: models.ErrTimeout,
+		},
+		{
+			name: "scan error",
+			rows: [][]interface{}{{"not a number", int64(10)}},
+			want: models.ErrInternal,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			mock := pgxmock.New(t)
+			mock.ExpectQuery(sqlq.StreamMemberships).
+				WillReturnRows([]string{"student_id", "group_id"}, tt.rows...).
+				WillReturnError(tt.err)
+
+			calls := 0
+			err := NewRepository(mock).StreamMemberships(context.Background(), func(studentID, groupID int64) error {
+				calls++
+				return tt.fnErr
+			})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("StreamMemberships error = %v, want %v", err, tt.want)
+			}
+			if calls != tt.calls {
+				t.Fatalf("StreamMemberships called fn %d times, want %d", calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestOperationsClosedOnce(t *testing.T) {
+	errStop := errors.New("stop")
+
+	tests := []struct {
+		name   string
+		expect func(mock *pgxmock.Mock)
+		call   func(ctx context.Context, r *groupsRepository) error
+		want   error
+		closed []string // операции, завершенные вызовом
+	}{
+		{
+			name: "done",
+			expect: func(mock *pgxmock.Mock) {
+				mock.ExpectQuery(sqlq.GetGroup).WithArgs(int64(1)).WillReturnRows([]string{"id", "name"}, []interface{}{int64(1), "first"})
+			},
+			call: func(ctx context.Context, r *groupsRepository) error {
+				_, err := r.GetGroup(ctx, 1)
+				return err
+			},
+			closed: []string{"get group"},
+		},
+		{
+			name: "not found",
+			expect: func(mock *pgxmock.Mock) {
+				mock.ExpectExec(sqlq.UpdateGroup).WithArgs(int64(1), "first").WillReturnRowsAffected(0)
+			},
+			call: func(ctx context.Context, r *groupsRepository) error {
+				return r.UpdateGroup(ctx, models.Group{ID: 1, Name: "first"})
+			},
+			want:   models.ErrNotFound,
+			closed: []string{"update group"},
+		},
+		{
+			name: "translated database error",
+			expect: func(mock *pgxmock.Mock) {
+				mock.ExpectExec(sqlq.AddStudentToGroup).WithArgs(int64(1), int64(2)).
+					WillReturnError(&pgconn.PgError{Code: "23505", ConstraintName: "students_groups_student_id_key"})
+			},
+			call: func(ctx context.Context, r *groupsRepository) error {
+				return r.AddStudentToGroup(ctx, 1, 2)
+			},
+			want:   models.ErrAlreadyInGroup,
+			closed: []string{"add student to group"},
+		},
+		{
+			name: "internal error",
+			expect: func(mock *pgxmock.Mock) {
+				mock.ExpectExec(sqlq.DeleteGroup).WithArgs(int64(1)).WillReturnError(errors.New("broken pipe"))
+			},
+			call: func(ctx context.Context, r *groupsRepository) error {
+				return r.DeleteGroup(ctx, 1)
+			},
+			want:   models.ErrInternal,
+			closed: []string{"delete group"},
+		},
+		{
+			name: "fn error",
+			expect: func(mock *pgxmock.Mock) {
+				mock.ExpectQuery(sqlq.GetGroups).WillReturnRows([]string{"id", "name"}, []interface{}{int64(1), "first"})
+			},
+			call: func(ctx context.Context, r *groupsRepository) error {
+				return r.StreamGroups(ctx, func(models.Group) error { return errStop })
+			},
+			want:   errStop,
+			closed: []string{"stream groups"},
+		},
+		{
+			name: "bulk insert row error",
+			expect: func(mock *pgxmock.Mock) {
+				mock.ExpectBegin()
+				mock.ExpectCopyFrom(pgx.Identifier{"students_groups"}, sqlq.CopyMembershipsColumns).
+					WillReturnError(&pgconn.PgError{Code: "23503", ConstraintName: "students_groups_group_id_fkey", Where: "COPY students_groups, line 2"})
+				mock.ExpectRollback()
+			},
+			call: func(ctx context.Context, r *groupsRepository) error {
+				_, err := r.BulkInsertMemberships(ctx, []models.Membership{{StudentID: 1, GroupID: 1}, {StudentID: 2, GroupID: 3}})
+				var rowErr *models.RowError
+				if errors.As(err, &rowErr) && rowErr.Row != 1 {
+					t.Errorf("BulkInsertMemberships row = %d, want 1", rowErr.Row)
+				}
+				return err
+			},
+			want:   models.ErrNotFound,
+			closed: []string{"bulk insert memberships"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			mock := pgxmock.New(t)
+			tt.expect(mock)
+			log := &repositorytest.OpLog{}
+
+			err := tt.call(context.Background(), NewRepository(mock, WithLogger(log)))
+			if !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+				t.Fatalf("error = %v, want %v", err, tt.want)
+			}
+			log.ExpectClosed(t, tt.closed...)
+		})
+	}
+}
+
+// countingTx - TxManager, который считает вызовы WithinTx
+type countingTx struct {
+	repository.TxManager
+	calls int
+}
+
+func (m *countingTx) WithinTx(ctx context.Context, opts repository.TxOptions, fn func(ctx context.Context) error) error {
+	m.calls++
+	return m.TxManager.WithinTx(ctx, opts, fn)
+}
+
+func TestBulkInsertUsesTxManager(t *testing.T) {
+	mock := pgxmock.New(t)
+	mock.ExpectBegin()
+	mock.ExpectCopyFrom(pgx.Identifier{"groups"}, sqlq.CopyGroupsColumns).WithRows([]interface{}{"first"})
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectCopyFrom(pgx.Identifier{"students_groups"}, sqlq.CopyMembershipsColumns).WithRows([]interface{}{int64(1), int64(1)})
+	mock.ExpectCommit()
+
+	tx := &countingTx{TxManager: transaction.NewTxManager(mock)}
+	r := NewRepository(mock, WithTxManager(tx))
+	ctx := context.Background()
+
+	if _, err := r.BulkInsertGroups(ctx, []models.Group{{Name: "first"}}); err != nil {
+		t.Fatalf("BulkInsertGroups error = %v", err)
+	}
+	if _, err := r.BulkInsertMemberships(ctx, []models.Membership{{StudentID: 1, GroupID: 1}}); err != nil {
+		t.Fatalf("BulkInsertMemberships error = %v", err)
+	}
+	if tx.calls != 2 {
+		t.Fatalf("WithinTx called %d times, want 2", tx.calls)
+	}
+}
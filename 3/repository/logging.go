@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"github.com/moguchev/postgres/3/logger"
+	"github.com/moguchev/postgres/3/models"
+)
+
+// StudentLogFields - параметры студента для записей в лог; имя и фамилия - персональные данные и скрываются
+func StudentLogFields(student models.Student) []logger.Field {
+	return []logger.Field{
+		logger.Int64("id", student.ID),
+		logger.Redacted("first_name", student.FirstName),
+		logger.Redacted("last_name", student.LastName),
+		logger.Int64("age", int64(student.Age)),
+	}
+}
+
+// LogFields - параметры фильтра для записей в лог; префикс имени скрывается, курсор не пишется
+func (f StudentsFilter) LogFields() []logger.Field {
+	return []logger.Field{
+		logger.Redacted("name_prefix", f.NamePrefix),
+		logger.Any("min_age", f.MinAge),
+		logger.Any("max_age", f.MaxAge),
+		logger.Int64("group_id", f.GroupID),
+		logger.Any("sort_by", f.SortBy),
+		logger.Any("sort_direction", f.SortDirection),
+		logger.Any("limit", f.PageLimit()),
+		logger.Any("offset", f.Offset),
+		logger.Any("with_cursor", f.Cursor != ""),
+	}
+}
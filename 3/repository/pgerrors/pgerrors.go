@@ -0,0 +1,203 @@
+// Package pgerrors - перевод ошибок драйверов PostgreSQL (lib/pq и pgx) в ошибки бизнес уровня из models.
+package pgerrors
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/lib/pq"
+	"github.com/moguchev/postgres/3/models"
+)
+
+// SQLSTATE: https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	ForeignKeyViolation  = "23503"
+	UniqueViolation      = "23505"
+	CheckViolation       = "23514"
+	SerializationFailure = "40001"
+	DeadlockDetected     = "40P01"
+	QueryCanceled        = "57014"
+	AdminShutdown        = "57P01"
+	CrashShutdown        = "57P02"
+	CannotConnectNow     = "57P03"
+	TooManyConnections   = "53300"
+
+	classDataException       = "22"
+	classIntegrityConstraint = "23"
+	classConnectionException = "08"
+)
+
+// Details - поля ошибки сервера, общие для *pq.Error и *pgconn.PgError
+type Details struct {
+	Code       string
+	Message    string
+	Detail     string
+	Where      string
+	Constraint string
+	Table      string
+	Column     string
+}
+
+// Extract - поля ошибки сервера, если err (или одна из обернутых ей ошибок) - *pq.Error или *pgconn.PgError
+func Extract(err error) (Details, bool) {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return Details{
+			Code:       string(pqErr.Code),
+			Message:    pqErr.Message,
+			Detail:     pqErr.Detail,
+			Where:      pqErr.Where,
+			Constraint: pqErr.Constraint,
+			Table:      pqErr.Table,
+			Column:     pqErr.Column,
+		}, true
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return Details{
+			Code:       pgErr.Code,
+			Message:    pgErr.Message,
+			Detail:     pgErr.Detail,
+			Where:      pgErr.Where,
+			Constraint: pgErr.ConstraintName,
+			Table:      pgErr.TableName,
+			Column:     pgErr.ColumnName,
+		}, true
+	}
+
+	return Details{}, false
+}
+
+// Translate - models.ErrNotFound, если запрос не вернул строк (sql.ErrNoRows и pgx.ErrNoRows),
+// *models.DatabaseError для известных кодов SQLSTATE, ошибок соединения и истекшего дедлайна.
+// Возвращает nil, если ошибка неизвестна: такие ошибки репозитории логируют и отдают наверх как models.ErrInternal.
+func Translate(err error) error {
+	return Constraints(nil).Translate(err)
+}
+
+// Constraints - ошибки бизнес уровня для конкретных ограничений по их имени:
+// функция получает уже переведенную ошибку и может уточнить или заменить ее
+type Constraints map[string]func(*models.DatabaseError) error
+
+// Translate - как pgerrors.Translate, но с учетом ошибок конкретных ограничений
+func (c Constraints) Translate(err error) error {
+	if IsNoRows(err) {
+		return models.ErrNotFound
+	}
+
+	dbErr := translate(err)
+	if dbErr == nil {
+		return nil
+	}
+	if override, ok := c[dbErr.Constraint]; ok && dbErr.Constraint != "" {
+		return override(dbErr)
+	}
+	return dbErr
+}
+
+// IsNoRows - запрос, который должен был вернуть одну строку, не вернул ни одной (у каждого драйвера своя ошибка)
+func IsNoRows(err error) bool {
+	return errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows)
+}
+
+func translate(err error) *models.DatabaseError {
+	if err == nil {
+		return nil
+	}
+
+	if d, ok := Extract(err); ok {
+		kind := kindOf(d.Code)
+		if kind == nil {
+			return nil
+		}
+		return &models.DatabaseError{
+			Kind:       kind,
+			Code:       d.Code,
+			Constraint: d.Constraint,
+			Table:      d.Table,
+			Column:     d.Column,
+			Message:    d.Message,
+		}
+	}
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return &models.DatabaseError{Kind: models.ErrTimeout, Message: err.Error()}
+	case isConnectionError(err):
+		return &models.DatabaseError{Kind: models.ErrUnavailable, Message: err.Error()}
+	}
+	return nil
+}
+
+func kindOf(code string) error {
+	switch code {
+	case UniqueViolation:
+		return models.ErrConflict
+	case ForeignKeyViolation:
+		return models.ErrForeignKey
+	case CheckViolation:
+		return models.ErrCheckViolation
+	case SerializationFailure, DeadlockDetected:
+		return models.ErrSerialization
+	case QueryCanceled:
+		return models.ErrTimeout
+	case AdminShutdown, CrashShutdown, CannotConnectNow, TooManyConnections:
+		return models.ErrUnavailable
+	}
+	if strings.HasPrefix(code, classConnectionException) {
+		return models.ErrUnavailable
+	}
+	return nil
+}
+
+func isConnectionError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// copyContext - контекст ошибки COPY: "COPY students, line 3, column age: ..."
+var copyContext = regexp.MustCompile(`line (\d+)(?:, column (\w+))?`)
+
+// CopyRowError - переводит ошибку данных (класс 22) или нарушение ограничения (класс 23) во время COPY
+// в *models.RowError с индексом строки во входных данных (batchStart - индекс первой строки пакета).
+// Возвращает nil, если ошибка не относится к данным строки.
+func (c Constraints) CopyRowError(err error, batchStart int) error {
+	d, ok := Extract(err)
+	if !ok {
+		return nil
+	}
+
+	var cause error
+	switch {
+	case strings.HasPrefix(d.Code, classIntegrityConstraint):
+		if cause = c.Translate(err); cause == nil {
+			cause = &models.ValidationError{Field: d.Column, Reason: d.Message}
+		}
+	case strings.HasPrefix(d.Code, classDataException):
+		cause = &models.ValidationError{Field: d.Column, Reason: d.Message}
+	default:
+		return nil
+	}
+
+	match := copyContext.FindStringSubmatch(d.Where)
+	if match == nil {
+		return cause
+	}
+	if verr, ok := cause.(*models.ValidationError); ok && verr.Field == "" {
+		cause = &models.ValidationError{Field: match[2], Reason: verr.Reason, Err: verr.Err}
+	}
+	line, _ := strconv.Atoi(match[1]) // строки COPY нумеруются с 1
+	return &models.RowError{Row: batchStart + line - 1, Err: cause}
+}
@@ -0,0 +1,221 @@
+package fakesql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// DriverName - имя драйвера в database/sql: sql.Open(fakesql.DriverName, script.DSN())
+const DriverName = "fakesql"
+
+func init() {
+	sql.Register(DriverName, fakeDriver{})
+}
+
+var (
+	scripts   sync.Map // DSN -> *Script
+	scriptSeq uint64
+)
+
+// проверка удовлетворению интерфейсов database/sql/driver
+var (
+	_ driver.ConnBeginTx        = (*conn)(nil)
+	_ driver.ConnPrepareContext = (*conn)(nil)
+	_ driver.QueryerContext     = (*conn)(nil)
+	_ driver.ExecerContext      = (*conn)(nil)
+	_ driver.StmtQueryContext   = (*stmt)(nil)
+	_ driver.StmtExecContext    = (*stmt)(nil)
+)
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(dsn string) (driver.Conn, error) {
+	s, ok := scripts.Load(dsn)
+	if !ok {
+		return nil, fmt.Errorf("fakesql: unknown script %q (use NewScript(t).DSN())", dsn)
+	}
+	return &conn{script: s.(*Script)}, nil
+}
+
+// conn - соединение; все соединения одного DSN выполняют один и тот же сценарий
+type conn struct {
+	script *Script
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if c.script.peekPrepare() {
+		if _, err := c.call(ctx, kindPrepare, query, nil); err != nil {
+			return nil, err
+		}
+	}
+	return &stmt{conn: c, query: query}, nil
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, _ driver.TxOptions) (driver.Tx, error) {
+	if _, err := c.call(ctx, kindBegin, "", nil); err != nil {
+		return nil, err
+	}
+	return &tx{conn: c}, nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	e, err := c.call(ctx, kindQuery, query, values(args))
+	if err != nil {
+		return nil, err
+	}
+	return c.script.open(e), nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, err := c.call(ctx, kindExec, query, values(args))
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(e.affected), nil
+}
+
+// call - сверяет вызов со сценарием, выдерживает задержку и возвращает ошибку ожидания, если она задана
+func (c *conn) call(ctx context.Context, k kind, query string, args []driver.Value) (*Expectation, error) {
+	e, err := c.script.match(k, query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.delay > 0 {
+		timer := time.NewTimer(e.delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	if e.err != nil {
+		return nil, e.err
+	}
+	return e, nil
+}
+
+func values(args []driver.NamedValue) []driver.Value {
+	vs := make([]driver.Value, len(args))
+	for i, arg := range args {
+		vs[i] = arg.Value
+	}
+	return vs
+}
+
+// stmt - подготовленный запрос; текст сверяется со сценарием при каждом выполнении
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+// NumInput - количество аргументов не проверяется database/sql (проверяет сценарий)
+func (s *stmt) NumInput() int {
+	return -1
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), named(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), named(args))
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+func named(args []driver.Value) []driver.NamedValue {
+	nvs := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		nvs[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return nvs
+}
+
+type tx struct {
+	conn *conn
+}
+
+func (t *tx) Commit() error {
+	_, err := t.conn.call(context.Background(), kindCommit, "", nil)
+	return err
+}
+
+func (t *tx) Rollback() error {
+	_, err := t.conn.call(context.Background(), kindRollback, "", nil)
+	return err
+}
+
+// fakeRows - строки результата ожидания; database/sql закрывает их из другой горутины при отмене контекста
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+	err     error
+
+	mu     sync.Mutex
+	next   int
+	closed bool
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closed = true
+	return nil
+}
+
+func (r *fakeRows) isClosed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.closed
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.next >= len(r.rows) {
+		if r.err != nil {
+			return r.err
+		}
+		return io.EOF
+	}
+	copy(dest, r.rows[r.next])
+	r.next++
+	return nil
+}
@@ -0,0 +1,358 @@
+// Package fakesql - драйвер database/sql для unit тестов database_sql_implementation без PostgreSQL.
+//
+// Тест описывает сценарий (Script): какие запросы в каком порядке должны прийти, с какими аргументами,
+// и что драйвер вернет на каждый - строки, результат Exec, ошибку (например *pq.Error) и задержку:
+//
+//	script := fakesql.NewScript(t)
+//	script.ExpectQuery(`SELECT id, first_name, last_name, age FROM students WHERE id = $1`).
+//		WithArgs(int64(1)).
+//		WillReturnRows([]string{"id", "first_name", "last_name", "age"}, []interface{}{1, "Bob", "Brown", 20})
+//	script.ExpectExec(`DELETE FROM students WHERE id = $1`).
+//		WillReturnError(&pq.Error{Code: "23503", Constraint: "students_groups_student_id_fkey"})
+//
+//	repo := databasesqlimplementation.NewRepository(script.DB())
+//
+// Запросы сравниваются после нормализации пробелов (отступы и переводы строк не важны): точно (ExpectQuery)
+// или регулярным выражением (ExpectQueryRegexp). Неожиданный запрос - ошибка теста со сравнением
+// с ожидаемым (указатель на первое отличие, отличающиеся аргументы), а драйвер возвращает ошибку вызывающему коду. По окончании теста Script проверяет, что все ожидания
+// выполнены и что все возвращенные строки закрыты (rows.Close на каждом пути, в т.ч. при ошибке Scan).
+package fakesql
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/moguchev/postgres/3/repository/repositorytest/internal/match"
+)
+
+type kind int
+
+const (
+	kindQuery kind = iota
+	kindExec
+	kindPrepare
+	kindBegin
+	kindCommit
+	kindRollback
+)
+
+func (k kind) String() string {
+	return [...]string{"query", "exec", "prepare", "begin", "commit", "rollback"}[k]
+}
+
+// Argument - условие на аргумент запроса вместо точного значения (см. AnyArg)
+type Argument = match.Argument
+
+// AnyArg - любое значение аргумента
+func AnyArg() Argument {
+	return match.Any()
+}
+
+// Expectation - один ожидаемый вызов драйвера и его результат
+type Expectation struct {
+	kind    kind
+	query   string         // нормализованный запрос для точного сравнения
+	pattern *regexp.Regexp // или регулярное выражение
+	args    []interface{}  // nil - аргументы не проверяются
+	checked bool           // WithArgs был вызван
+
+	columns   []string
+	rows      [][]driver.Value
+	rowsErr   error
+	affected  int64
+	err       error
+	delay     time.Duration
+	convErr   error // значение WithArgs/WillReturnRows, которое драйвер не может передать
+	triggered bool
+	opened    []*fakeRows
+}
+
+// WithArgs - ожидаемые аргументы: Argument или значение, которое сравнивается после приведения к driver.Value
+// так же, как это делает database/sql (int -> int64, driver.Valuer вроде pq.Array -> его Value())
+func (e *Expectation) WithArgs(args ...interface{}) *Expectation {
+	e.checked = true
+	e.args = make([]interface{}, len(args))
+	for i, arg := range args {
+		if a, ok := arg.(Argument); ok {
+			e.args[i] = a
+			continue
+		}
+		v, err := driver.DefaultParameterConverter.ConvertValue(arg)
+		if err != nil && e.convErr == nil {
+			e.convErr = fmt.Errorf("fakesql: argument $%d %#v: %w", i+1, arg, err)
+		}
+		e.args[i] = v
+	}
+	return e
+}
+
+// WillReturnRows - строки результата запроса; значения приводятся к driver.Value
+func (e *Expectation) WillReturnRows(columns []string, rows ...[]interface{}) *Expectation {
+	e.columns = columns
+	e.rows = make([][]driver.Value, len(rows))
+	for i, row := range rows {
+		if len(row) != len(columns) && e.convErr == nil {
+			e.convErr = fmt.Errorf("fakesql: row %d has %d values for %d columns", i, len(row), len(columns))
+		}
+		e.rows[i] = make([]driver.Value, len(row))
+		for j, value := range row {
+			v, err := driver.DefaultParameterConverter.ConvertValue(value)
+			if err != nil && e.convErr == nil {
+				e.convErr = fmt.Errorf("fakesql: row %d column %d %#v: %w", i, j, value, err)
+			}
+			e.rows[i][j] = v
+		}
+	}
+	return e
+}
+
+// WillReturnRowsError - ошибка, которую rows.Next вернет после всех строк (rows.Err())
+func (e *Expectation) WillReturnRowsError(err error) *Expectation {
+	e.rowsErr = err
+	return e
+}
+
+// WillReturnRowsAffected - результат Exec
+func (e *Expectation) WillReturnRowsAffected(n int64) *Expectation {
+	e.affected = n
+	return e
+}
+
+// WillReturnError - ошибка вызова (например *pq.Error с нужным SQLSTATE и ограничением)
+func (e *Expectation) WillReturnError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+// WillDelay - задержка ответа; если контекст вызова завершится раньше, драйвер вернет ctx.Err()
+func (e *Expectation) WillDelay(d time.Duration) *Expectation {
+	e.delay = d
+	return e
+}
+
+func (e *Expectation) String() string {
+	s := e.kind.String()
+	switch {
+	case e.pattern != nil:
+		s += " matching " + strconv.Quote(e.pattern.String())
+	case e.kind == kindQuery || e.kind == kindExec || e.kind == kindPrepare:
+		s += " " + strconv.Quote(e.query)
+	}
+	if e.checked {
+		s += fmt.Sprintf(" with args %s", match.Format(e.args))
+	}
+	return s
+}
+
+// Script - ожидаемые вызовы драйвера по порядку. Методы безопасны для одновременного использования
+type Script struct {
+	t   testing.TB
+	dsn string
+
+	mu       sync.Mutex
+	expected []*Expectation
+	next     int
+	db       *sql.DB
+}
+
+// NewScript - пустой сценарий; по окончании теста он проверяет ExpectationsWereMet и закрывает DB
+func NewScript(t testing.TB) *Script {
+	s := &Script{
+		t:   t,
+		dsn: fmt.Sprintf("script-%d", atomic.AddUint64(&scriptSeq, 1)),
+	}
+	scripts.Store(s.dsn, s)
+
+	t.Cleanup(func() {
+		if err := s.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		s.mu.Lock()
+		db := s.db
+		s.mu.Unlock()
+		if db != nil {
+			db.Close()
+		}
+		scripts.Delete(s.dsn)
+	})
+	return s
+}
+
+// DSN - строка подключения к этому сценарию для sql.Open(DriverName, dsn)
+func (s *Script) DSN() string {
+	return s.dsn
+}
+
+// DB - *sql.DB поверх сценария (создается один раз)
+func (s *Script) DB() *sql.DB {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.db == nil {
+		s.db, _ = sql.Open(DriverName, s.dsn) // sql.Open не подключается и для зарегистрированного драйвера не ошибается
+	}
+	return s.db
+}
+
+// ExpectQuery - запрос, возвращающий строки (QueryContext, QueryRowContext), с точным текстом
+func (s *Script) ExpectQuery(query string) *Expectation {
+	return s.expect(&Expectation{kind: kindQuery, query: match.Normalize(query)})
+}
+
+// ExpectQueryRegexp - запрос, возвращающий строки, текст которого подходит под pattern
+func (s *Script) ExpectQueryRegexp(pattern string) *Expectation {
+	return s.expect(&Expectation{kind: kindQuery, pattern: regexp.MustCompile(pattern)})
+}
+
+// ExpectExec - запрос без строк результата (ExecContext) с точным текстом
+func (s *Script) ExpectExec(query string) *Expectation {
+	return s.expect(&Expectation{kind: kindExec, query: match.Normalize(query)})
+}
+
+// ExpectExecRegexp - запрос без строк результата, текст которого подходит под pattern
+func (s *Script) ExpectExecRegexp(pattern string) *Expectation {
+	return s.expect(&Expectation{kind: kindExec, pattern: regexp.MustCompile(pattern)})
+}
+
+// ExpectPrepare - явная подготовка запроса (PrepareContext). Если следующее ожидание - не подготовка,
+// PrepareContext проходит без проверки, а текст запроса сравнивается при выполнении statement
+func (s *Script) ExpectPrepare(query string) *Expectation {
+	return s.expect(&Expectation{kind: kindPrepare, query: match.Normalize(query)})
+}
+
+func (s *Script) ExpectBegin() *Expectation {
+	return s.expect(&Expectation{kind: kindBegin})
+}
+
+func (s *Script) ExpectCommit() *Expectation {
+	return s.expect(&Expectation{kind: kindCommit})
+}
+
+func (s *Script) ExpectRollback() *Expectation {
+	return s.expect(&Expectation{kind: kindRollback})
+}
+
+func (s *Script) expect(e *Expectation) *Expectation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expected = append(s.expected, e)
+	return e
+}
+
+// ExpectationsWereMet - ошибка, если какие-то ожидания не выполнены или строки результата не закрыты
+func (s *Script) ExpectationsWereMet() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var problems []string
+	for i, e := range s.expected {
+		if !e.triggered {
+			problems = append(problems, fmt.Sprintf("  #%d %s: not called", i, e))
+			continue
+		}
+		for _, rows := range e.opened {
+			if !rows.isClosed() {
+				problems = append(problems, fmt.Sprintf("  #%d %s: rows were not closed", i, e))
+				break
+			}
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("fakesql: script is not complete:\n%s", strings.Join(problems, "\n"))
+}
+
+// match - следующее ожидание, если вызов ему соответствует; иначе ошибка теста и ошибка для вызывающего кода
+func (s *Script) match(k kind, query string, args []driver.Value) (*Expectation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query = match.Normalize(query)
+	if s.next >= len(s.expected) {
+		err := fmt.Errorf("fakesql: unexpected %s (all %d expectations were met):\n  got:  %s\n  args: %s",
+			k, len(s.expected), query, formatValues(args))
+		s.t.Error(err)
+		return nil, err
+	}
+
+	e := s.expected[s.next]
+	if problem := e.mismatch(k, query, args); problem != "" {
+		err := fmt.Errorf("fakesql: unexpected %s, want #%d %s:\n%s", k, s.next, e.kind, problem)
+		s.t.Error(err)
+		return nil, err
+	}
+	if e.convErr != nil {
+		s.t.Error(e.convErr)
+		return nil, e.convErr
+	}
+
+	e.triggered = true
+	s.next++
+	return e, nil
+}
+
+// peekPrepare - следующее ожидание, если это подготовка запроса (см. ExpectPrepare)
+func (s *Script) peekPrepare() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.next < len(s.expected) && s.expected[s.next].kind == kindPrepare
+}
+
+// mismatch - описание отличий вызова от ожидания, пустое если вызов подходит
+func (e *Expectation) mismatch(k kind, query string, args []driver.Value) string {
+	if k != e.kind {
+		return fmt.Sprintf("  want %s\n  got:  %s %s", e, k, query)
+	}
+
+	if k == kindQuery || k == kindExec || k == kindPrepare {
+		if diff := match.Query(e.query, e.pattern, query); diff != "" {
+			return diff
+		}
+	}
+
+	if !e.checked {
+		return ""
+	}
+	if diff := match.Args(e.args, interfaces(args)); diff != "" {
+		return "  query: " + query + "\n" + diff
+	}
+	return ""
+}
+
+func formatValues(args []driver.Value) string {
+	return match.Format(interfaces(args))
+}
+
+func interfaces(args []driver.Value) []interface{} {
+	vs := make([]interface{}, len(args))
+	for i, arg := range args {
+		vs[i] = arg
+	}
+	return vs
+}
+
+// open - новые строки результата ожидания e; Script запоминает их, чтобы проверить закрытие
+func (s *Script) open(e *Expectation) *fakeRows {
+	rows := &fakeRows{
+		columns: e.columns,
+		rows:    e.rows,
+		err:     e.rowsErr,
+	}
+
+	s.mu.Lock()
+	e.opened = append(e.opened, rows)
+	s.mu.Unlock()
+
+	return rows
+}
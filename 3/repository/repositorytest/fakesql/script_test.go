@@ -0,0 +1,257 @@
+package fakesql_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moguchev/postgres/3/repository/repositorytest/fakesql"
+)
+
+// recorder - testing.TB, который запоминает ошибки сценария вместо того, чтобы провалить тест
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Error(args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprint(args...))
+}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestMismatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		expect func(s *fakesql.Script)
+		call   func(ctx context.Context, s *fakesql.Script) error
+		want   []string // подстроки сообщения об ошибке
+	}{
+		{
+			name: "query text",
+			expect: func(s *fakesql.Script) {
+				s.ExpectQuery(`SELECT id FROM groups WHERE id = $1`)
+			},
+			call: func(ctx context.Context, s *fakesql.Script) error {
+				return s.DB().QueryRowContext(ctx, `SELECT id FROM students WHERE id = $1`, 1).Err()
+			},
+			want: []string{
+				"unexpected query, want #0 query",
+				"  want: SELECT id FROM groups WHERE id = $1\n",
+				"  got:  SELECT id FROM students WHERE id = $1\n",
+				"                       ^ first difference at byte 15",
+			},
+		},
+		{
+			name: "args",
+			expect: func(s *fakesql.Script) {
+				s.ExpectExec(`DELETE FROM groups WHERE id = $1 AND name = $2`).WithArgs(1, fakesql.AnyArg())
+			},
+			call: func(ctx context.Context, s *fakesql.Script) error {
+				_, err := s.DB().ExecContext(ctx, `DELETE FROM groups WHERE id = $1 AND name = $2`, 2, "a")
+				return err
+			},
+			want: []string{
+				"  query: DELETE FROM groups WHERE id = $1 AND name = $2",
+				"  $1: want 1, got 2",
+			},
+		},
+		{
+			name: "args count",
+			expect: func(s *fakesql.Script) {
+				s.ExpectExec(`DELETE FROM groups WHERE id = $1`).WithArgs(1)
+			},
+			call: func(ctx context.Context, s *fakesql.Script) error {
+				_, err := s.DB().ExecContext(ctx, `DELETE FROM groups WHERE id = $1`)
+				return err
+			},
+			want: []string{
+				"  want args: [1]\n  got args:  []",
+			},
+		},
+		{
+			name: "kind",
+			expect: func(s *fakesql.Script) {
+				s.ExpectExec(`DELETE FROM groups`)
+			},
+			call: func(ctx context.Context, s *fakesql.Script) error {
+				return s.DB().QueryRowContext(ctx, `DELETE FROM groups`).Err()
+			},
+			want: []string{
+				"unexpected query, want #0 exec",
+				`  want exec "DELETE FROM groups"`,
+			},
+		},
+		{
+			name: "regexp",
+			expect: func(s *fakesql.Script) {
+				s.ExpectQueryRegexp(`^SELECT .* FROM groups`)
+			},
+			call: func(ctx context.Context, s *fakesql.Script) error {
+				return s.DB().QueryRowContext(ctx, `SELECT id FROM students`).Err()
+			},
+			want: []string{
+				"  want: query matching ^SELECT .* FROM groups\n  got:  SELECT id FROM students",
+			},
+		},
+		{
+			name:   "no expectations left",
+			expect: func(s *fakesql.Script) {},
+			call: func(ctx context.Context, s *fakesql.Script) error {
+				_, err := s.DB().ExecContext(ctx, `DELETE FROM groups WHERE id = $1`, 1)
+				return err
+			},
+			want: []string{
+				"unexpected exec (all 0 expectations were met):\n  got:  DELETE FROM groups WHERE id = $1\n  args: [1]",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{TB: t}
+			s := fakesql.NewScript(r)
+			tt.expect(s)
+
+			err := tt.call(context.Background(), s)
+			if err == nil {
+				t.Fatal("call error = nil, want mismatch error")
+			}
+			if len(r.errors) == 0 {
+				t.Fatal("script did not report the mismatch to the test")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(r.errors[0], want) {
+					t.Errorf("mismatch error:\n%s\nwant it to contain:\n%s", r.errors[0], want)
+				}
+			}
+			if r.errors[0] != err.Error() {
+				t.Errorf("caller error = %q, want the reported one %q", err, r.errors[0])
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	s := fakesql.NewScript(t)
+	s.ExpectQuery(`
+		SELECT id, name
+		FROM groups
+		WHERE id = $1`).
+		WithArgs(1).
+		WillReturnRows([]string{"id", "name"}, []interface{}{1, "first"})
+	s.ExpectExecRegexp(`^UPDATE groups SET name = \$2`).
+		WithArgs(fakesql.AnyArg(), "second").
+		WillReturnRowsAffected(1)
+
+	ctx := context.Background()
+	var (
+		id   int64
+		name string
+	)
+	// отступы и переводы строк не важны
+	if err := s.DB().QueryRowContext(ctx, `SELECT id, name FROM groups WHERE id = $1`, 1).Scan(&id, &name); err != nil {
+		t.Fatalf("QueryRow error = %v", err)
+	}
+	if id != 1 || name != "first" {
+		t.Fatalf("QueryRow = %d, %q, want 1, \"first\"", id, name)
+	}
+
+	result, err := s.DB().ExecContext(ctx, `UPDATE groups SET name = $2 WHERE id = $1`, 42, "second")
+	if err != nil {
+		t.Fatalf("Exec error = %v", err)
+	}
+	if affected, _ := result.RowsAffected(); affected != 1 {
+		t.Fatalf("RowsAffected = %d, want 1", affected)
+	}
+}
+
+func TestExpectationsWereMet(t *testing.T) {
+	r := &recorder{TB: t}
+	s := fakesql.NewScript(r)
+	s.ExpectQuery(`SELECT id FROM groups`).
+		WillReturnRows([]string{"id"}, []interface{}{1}, []interface{}{2})
+	s.ExpectExec(`DELETE FROM groups`)
+
+	rows, err := s.DB().QueryContext(context.Background(), `SELECT id FROM groups`)
+	if err != nil {
+		t.Fatalf("Query error = %v", err)
+	}
+	rows.Next() // строки прочитаны не до конца и не закрыты
+
+	err = s.ExpectationsWereMet()
+	if err == nil {
+		t.Fatal("ExpectationsWereMet error = nil, want unclosed rows and a missing call")
+	}
+	for _, want := range []string{
+		`#0 query "SELECT id FROM groups": rows were not closed`,
+		`#1 exec "DELETE FROM groups": not called`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("ExpectationsWereMet error:\n%s\nwant it to contain:\n%s", err, want)
+		}
+	}
+
+	if err = rows.Close(); err != nil {
+		t.Fatalf("Close error = %v", err)
+	}
+	if _, err = s.DB().ExecContext(context.Background(), `DELETE FROM groups`); err != nil {
+		t.Fatalf("Exec error = %v", err)
+	}
+	if err = s.ExpectationsWereMet(); err != nil {
+		t.Fatalf("ExpectationsWereMet error = %v", err)
+	}
+	if len(r.errors) != 0 {
+		t.Fatalf("script reported errors: %v", r.errors)
+	}
+}
+
+func TestTxAndErrors(t *testing.T) {
+	errDB := errors.New("db error")
+
+	s := fakesql.NewScript(t)
+	s.ExpectBegin()
+	s.ExpectExec(`DELETE FROM groups`).WillReturnError(errDB)
+	s.ExpectRollback()
+	s.ExpectQuery(`SELECT pg_sleep(1)`).WillDelay(time.Second)
+	s.ExpectQuery(`SELECT id FROM groups`).
+		WillReturnRows([]string{"id"}, []interface{}{1}).
+		WillReturnRowsError(errDB)
+
+	ctx := context.Background()
+	tx, err := s.DB().BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx error = %v", err)
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM groups`); !errors.Is(err, errDB) {
+		t.Fatalf("Exec error = %v, want %v", err, errDB)
+	}
+	if err = tx.Rollback(); err != nil {
+		t.Fatalf("Rollback error = %v", err)
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err = s.DB().QueryRowContext(timeoutCtx, `SELECT pg_sleep(1)`).Err(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("delayed query error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	rows, err := s.DB().QueryContext(ctx, `SELECT id FROM groups`)
+	if err != nil {
+		t.Fatalf("Query error = %v", err)
+	}
+	defer rows.Close()
+	n := 0
+	for rows.Next() {
+		n++
+	}
+	if n != 1 || !errors.Is(rows.Err(), errDB) {
+		t.Fatalf("rows = %d, rows.Err() = %v, want 1 row and %v", n, rows.Err(), errDB)
+	}
+}
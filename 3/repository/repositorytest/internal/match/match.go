@@ -0,0 +1,89 @@
+// Package match - сравнение запросов и аргументов со сценарием, общее для fakesql и pgxmock
+package match
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Argument - условие на аргумент запроса вместо точного значения
+type Argument interface {
+	Match(v interface{}) bool
+}
+
+type anyArg struct{}
+
+func (anyArg) Match(interface{}) bool { return true }
+func (anyArg) String() string         { return "<any>" }
+
+// Any - любое значение аргумента
+func Any() Argument {
+	return anyArg{}
+}
+
+var whitespace = regexp.MustCompile(`\s+`)
+
+// Normalize - запрос без отступов и лишних пробелов
+func Normalize(query string) string {
+	return strings.TrimSpace(whitespace.ReplaceAllString(query, " "))
+}
+
+// Query - описание отличий запроса got от ожидаемого: точного текста want или регулярного выражения pattern
+// (если задано). Оба запроса - после Normalize. Пусто, если запрос подходит
+func Query(want string, pattern *regexp.Regexp, got string) string {
+	if pattern != nil {
+		if pattern.MatchString(got) {
+			return ""
+		}
+		return fmt.Sprintf("  want: query matching %s\n  got:  %s", pattern, got)
+	}
+	if got == want {
+		return ""
+	}
+
+	pos := 0
+	for pos < len(want) && pos < len(got) && want[pos] == got[pos] {
+		pos++
+	}
+	return fmt.Sprintf("  want: %s\n  got:  %s\n        %s^ first difference at byte %d", want, got, strings.Repeat(" ", pos), pos)
+}
+
+// Args - описание отличий аргументов got от want (значения или Argument). Пусто, если аргументы подходят
+func Args(want, got []interface{}) string {
+	if len(want) != len(got) {
+		return fmt.Sprintf("  want args: %s\n  got args:  %s", Format(want), Format(got))
+	}
+
+	var diff []string
+	for i := range got {
+		if a, ok := want[i].(Argument); ok {
+			if !a.Match(got[i]) {
+				diff = append(diff, fmt.Sprintf("  $%d: want %v, got %#v", i+1, a, got[i]))
+			}
+			continue
+		}
+		if !reflect.DeepEqual(want[i], got[i]) {
+			w, g := fmt.Sprintf("%#v", want[i]), fmt.Sprintf("%#v", got[i])
+			if w == g { // одно значение разных типов, например int64(1) и int(1)
+				w, g = fmt.Sprintf("%T(%s)", want[i], w), fmt.Sprintf("%T(%s)", got[i], g)
+			}
+			diff = append(diff, fmt.Sprintf("  $%d: want %s, got %s", i+1, w, g))
+		}
+	}
+	return strings.Join(diff, "\n")
+}
+
+// Format - аргументы для сообщения об ошибке
+func Format(args []interface{}) string {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		if a, ok := arg.(Argument); ok {
+			parts[i] = fmt.Sprint(a)
+		} else {
+			parts[i] = fmt.Sprintf("%#v", arg)
+		}
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
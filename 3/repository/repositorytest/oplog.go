@@ -0,0 +1,55 @@
+package repositorytest
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/moguchev/postgres/3/logger"
+)
+
+// OpLog - logger.Logger, который запоминает завершения операций репозиториев (Op.Done, Op.Fail, Op.Error).
+// Каждая операция logger.Start должна завершиться ровно одним из них - это проверяет ExpectClosed
+type OpLog struct {
+	mu     sync.Mutex
+	closed []string // имена завершенных операций по порядку
+}
+
+func (l *OpLog) Debug(_ string, fields ...logger.Field) {
+	l.record(fields)
+}
+
+func (l *OpLog) Error(_ string, fields ...logger.Field) {
+	l.record(fields)
+}
+
+func (l *OpLog) record(fields []logger.Field) {
+	for _, f := range fields {
+		if f.Key == logger.Operation("").Key {
+			l.mu.Lock()
+			l.closed = append(l.closed, f.Value.(string))
+			l.mu.Unlock()
+			return
+		}
+	}
+}
+
+// ExpectClosed - с прошлой проверки завершены ровно операции ops, каждая один раз и в этом порядке
+func (l *OpLog) ExpectClosed(t testing.TB, ops ...string) {
+	t.Helper()
+
+	l.mu.Lock()
+	closed := l.closed
+	l.closed = nil
+	l.mu.Unlock()
+
+	if len(closed) != len(ops) {
+		t.Errorf("closed operations %q, want %q", closed, ops)
+		return
+	}
+	for i := range ops {
+		if closed[i] != ops[i] {
+			t.Errorf("closed operations %q, want %q", closed, ops)
+			return
+		}
+	}
+}
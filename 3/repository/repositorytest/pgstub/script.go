@@ -0,0 +1,172 @@
+// Package pgstub - сервер протокола PostgreSQL на 127.0.0.1 для интеграционных тестов без базы данных.
+//
+// Сервер отвечает на startup (без SSL и пароля), на простой протокол (Query) и на расширенный
+// (Parse/Describe/Bind/Execute/Sync) ответами из сценария и записывает все, что прислал клиент.
+// Поэтому в тестах работают настоящие lib/pq и pgx - с подготовкой запросов, форматами параметров
+// и результатов, ошибками и транзакциями:
+//
+//	srv := pgstub.NewServer(t)
+//	srv.Expect(`SELECT id, first_name, last_name, age FROM students WHERE id = $1`).
+//		WithParamOIDs(pgtype.Int8OID).
+//		WithArgs(1).
+//		WillReturnRows([]pgstub.Column{
+//			{Name: "id", OID: pgtype.Int8OID},
+//			{Name: "first_name", OID: pgtype.VarcharOID},
+//			{Name: "last_name", OID: pgtype.VarcharOID},
+//			{Name: "age", OID: pgtype.Int2OID},
+//		}, []interface{}{1, "Bob", "Brown", 20})
+//
+//	db, _ := sql.Open("postgres", srv.DSN()) // или pgx.Connect(ctx, srv.DSN())
+//
+// Типы параметров сервер сообщает клиенту в ParameterDescription (WithParamOIDs, по умолчанию text),
+// типы столбцов - в RowDescription. pgx кодирует аргументы по этим типам, поэтому для нетекстовых
+// параметров их нужно указать. Аргументы декодируются по тем же типам, так что WithArgs(1) для int8
+// подходит и к текстовому "1" от lib/pq, и к двоичному значению от pgx.
+//
+// Запросы сравниваются после нормализации пробелов, по порядку. По окончании теста сервер проверяет,
+// что все ожидания выполнены, и закрывает соединения.
+package pgstub
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgconn"
+
+	"github.com/moguchev/postgres/3/repository/repositorytest/internal/match"
+)
+
+// Argument - условие на аргумент запроса вместо точного значения (см. AnyArg)
+type Argument = match.Argument
+
+// AnyArg - любое значение аргумента
+func AnyArg() Argument {
+	return match.Any()
+}
+
+// Column - столбец результата: имя и OID типа (pgtype.Int8OID, pgtype.VarcharOID, ...)
+type Column struct {
+	Name string
+	OID  uint32
+}
+
+// Statement - выполненный клиентом запрос
+type Statement struct {
+	Query  string        // текст запроса как его прислал клиент
+	Args   []interface{} // параметры, декодированные по типам из ParameterDescription
+	Simple bool          // простой протокол (Query), иначе Parse/Bind/Execute
+}
+
+// Expectation - ожидаемый запрос и ответ сервера на него
+type Expectation struct {
+	prepare   bool           // только Parse/Describe (ExpectPrepare)
+	query     string         // нормализованный запрос для точного сравнения
+	pattern   *regexp.Regexp // или регулярное выражение
+	paramOIDs []uint32
+	args      []interface{}
+	checked   bool // WithArgs был вызван
+
+	columns   []Column
+	rows      [][]interface{}
+	tag       string
+	affected  int64
+	err       *pgconn.PgError
+	delay     time.Duration
+	triggered bool
+}
+
+// WithParamOIDs - типы параметров для ParameterDescription. Без них все параметры - text
+func (e *Expectation) WithParamOIDs(oids ...uint32) *Expectation {
+	e.paramOIDs = oids
+	return e
+}
+
+// WithArgs - ожидаемые аргументы: Argument или значение, которое приводится к типу параметра
+// так же, как присланное клиентом (WithArgs(1) для int8 - это int64(1))
+func (e *Expectation) WithArgs(args ...interface{}) *Expectation {
+	e.checked = true
+	e.args = args
+	return e
+}
+
+// WillReturnRows - столбцы и строки результата; значения кодируются по типам столбцов, nil - NULL
+func (e *Expectation) WillReturnRows(columns []Column, rows ...[]interface{}) *Expectation {
+	e.columns = columns
+	e.rows = rows
+	return e
+}
+
+// WillReturnCommandTag - тег CommandComplete (например "UPDATE 1"). По умолчанию "SELECT n" для строк
+// и глагол запроса для остальных ("INSERT 0 0", "DELETE 0", "BEGIN")
+func (e *Expectation) WillReturnCommandTag(tag string) *Expectation {
+	e.tag = tag
+	return e
+}
+
+// WillReturnRowsAffected - число строк в теге CommandComplete по умолчанию ("UPDATE n")
+func (e *Expectation) WillReturnRowsAffected(n int64) *Expectation {
+	e.affected = n
+	return e
+}
+
+// WillReturnError - ErrorResponse вместо результата: SQLSTATE, сообщение, ограничение, таблица и т.д.
+func (e *Expectation) WillReturnError(err *pgconn.PgError) *Expectation {
+	e.err = err
+	return e
+}
+
+// WillDelay - задержка ответа на запрос
+func (e *Expectation) WillDelay(d time.Duration) *Expectation {
+	e.delay = d
+	return e
+}
+
+func (e *Expectation) String() string {
+	s := "query"
+	if e.prepare {
+		s = "prepare"
+	}
+	if e.pattern != nil {
+		s += " matching " + strconv.Quote(e.pattern.String())
+	} else {
+		s += " " + strconv.Quote(e.query)
+	}
+	if e.checked {
+		s += fmt.Sprintf(" with args %s", match.Format(e.args))
+	}
+	return s
+}
+
+// commandTag - тег CommandComplete для глагола, как его формирует PostgreSQL
+func commandTag(verb string, n int64) string {
+	switch verb {
+	case "INSERT":
+		return fmt.Sprintf("INSERT 0 %d", n)
+	case "SELECT", "UPDATE", "DELETE", "COPY", "FETCH", "MOVE", "MERGE":
+		return fmt.Sprintf("%s %d", verb, n)
+	}
+	return verb
+}
+
+// result - тег CommandComplete ответа на запрос query
+func (e *Expectation) result(query string) string {
+	switch {
+	case e.tag != "":
+		return e.tag
+	case e.columns != nil:
+		return commandTag("SELECT", int64(len(e.rows)))
+	}
+	verb := ""
+	if fields := strings.Fields(query); len(fields) > 0 {
+		verb = strings.ToUpper(fields[0])
+	}
+	return commandTag(verb, e.affected)
+}
+
+// mismatch - описание отличий запроса от ожидания, пустое если запрос подходит
+func (e *Expectation) mismatch(query string) string {
+	return match.Query(e.query, e.pattern, query)
+}
@@ -0,0 +1,354 @@
+package pgstub
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgproto3/v2"
+
+	"github.com/moguchev/postgres/3/repository/repositorytest/internal/match"
+)
+
+// ServerVersion - версия, которую сервер сообщает клиентам в ParameterStatus
+const ServerVersion = "14.0 (pgstub)"
+
+// Server - сервер протокола PostgreSQL на 127.0.0.1 со сценарием ответов.
+// Методы безопасны для одновременного использования, клиентов может быть несколько
+type Server struct {
+	t        testing.TB
+	listener net.Listener
+	done     chan struct{}
+	wg       sync.WaitGroup
+
+	mu         sync.Mutex
+	conns      map[net.Conn]struct{}
+	sessions   map[uint32]*session // по ProcessID для CancelRequest
+	expected   []*Expectation
+	next       int
+	messages   []pgproto3.FrontendMessage
+	statements []Statement
+}
+
+// NewServer - запущенный сервер с пустым сценарием; по окончании теста он проверяет
+// ExpectationsWereMet и закрывает соединения
+func NewServer(t testing.TB) *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("pgstub: listen: %v", err)
+	}
+	s := &Server{
+		t:        t,
+		listener: listener,
+		done:     make(chan struct{}),
+		conns:    make(map[net.Conn]struct{}),
+		sessions: make(map[uint32]*session),
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+
+	t.Cleanup(func() {
+		s.close()
+		if err := s.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+	return s
+}
+
+// Addr - адрес сервера (127.0.0.1:port)
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// DSN - строка подключения к серверу для lib/pq и pgx
+func (s *Server) DSN() string {
+	return fmt.Sprintf("postgres://pgstub@%s/pgstub?sslmode=disable", s.Addr())
+}
+
+// Expect - запрос с точным текстом (простой или расширенный протокол)
+func (s *Server) Expect(query string) *Expectation {
+	return s.expect(&Expectation{query: match.Normalize(query)})
+}
+
+// ExpectRegexp - запрос, текст которого подходит под pattern
+func (s *Server) ExpectRegexp(pattern string) *Expectation {
+	return s.expect(&Expectation{pattern: regexp.MustCompile(pattern)})
+}
+
+// ExpectPrepare - подготовка запроса без выполнения (Parse и Describe, как pgconn.Prepare).
+// Если следующее ожидание - не подготовка, Describe отвечает по ожиданию, которое выполнит запрос
+func (s *Server) ExpectPrepare(query string) *Expectation {
+	return s.expect(&Expectation{prepare: true, query: match.Normalize(query)})
+}
+
+func (s *Server) expect(e *Expectation) *Expectation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expected = append(s.expected, e)
+	return e
+}
+
+// Messages - копии всех сообщений клиентов по порядку получения, включая StartupMessage
+func (s *Server) Messages() []pgproto3.FrontendMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]pgproto3.FrontendMessage(nil), s.messages...)
+}
+
+// Statements - выполненные запросы по порядку
+func (s *Server) Statements() []Statement {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Statement(nil), s.statements...)
+}
+
+// ExpectationsWereMet - ошибка, если какие-то ожидания не выполнены
+func (s *Server) ExpectationsWereMet() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var problems []string
+	for i, e := range s.expected {
+		if !e.triggered {
+			problems = append(problems, fmt.Sprintf("  #%d %s: not called", i, e))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("pgstub: script is not complete:\n%s", strings.Join(problems, "\n"))
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return // listener закрыт
+		}
+
+		s.mu.Lock()
+		select {
+		case <-s.done:
+			s.mu.Unlock()
+			conn.Close()
+			return
+		default:
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go func() {
+			defer s.wg.Done()
+			defer func() {
+				s.mu.Lock()
+				delete(s.conns, conn)
+				s.mu.Unlock()
+				conn.Close()
+			}()
+
+			newSession(s, conn).run()
+		}()
+	}
+}
+
+// close - останавливает сервер, закрывает соединения и ждет их обработчики
+func (s *Server) close() {
+	s.mu.Lock()
+	close(s.done)
+	s.listener.Close()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *Server) register(c *session) {
+	s.mu.Lock()
+	s.sessions[c.pid] = c
+	s.mu.Unlock()
+}
+
+func (s *Server) unregister(c *session) {
+	s.mu.Lock()
+	delete(s.sessions, c.pid)
+	s.mu.Unlock()
+}
+
+// cancel - отмена текущего запроса соединения по данным BackendKeyData
+func (s *Server) cancel(pid, secret uint32) {
+	s.mu.Lock()
+	c := s.sessions[pid]
+	s.mu.Unlock()
+
+	if c == nil || c.secret != secret {
+		return
+	}
+	select {
+	case c.canceled <- struct{}{}:
+	default:
+	}
+}
+
+// record - копия сообщения клиента в журнал; pgproto3 переиспользует сообщения при следующем Receive
+func (s *Server) record(msg pgproto3.FrontendMessage) pgproto3.FrontendMessage {
+	msg = copyMessage(msg)
+
+	s.mu.Lock()
+	s.messages = append(s.messages, msg)
+	s.mu.Unlock()
+
+	return msg
+}
+
+func (s *Server) recordStatement(st Statement) {
+	s.mu.Lock()
+	s.statements = append(s.statements, st)
+	s.mu.Unlock()
+}
+
+// describe - ожидание, по которому Describe отвечает о запросе: подготовка (выполняется),
+// следующее ожидание с тем же запросом или уже выполненное ожидание с ним (повторная подготовка)
+func (s *Server) describe(query string) (*Expectation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query = match.Normalize(query)
+	if s.next < len(s.expected) {
+		e := s.expected[s.next]
+		if e.mismatch(query) == "" {
+			if e.prepare {
+				e.triggered = true
+				s.next++
+			}
+			return e, nil
+		}
+	}
+	for i := s.next - 1; i >= 0; i-- {
+		if e := s.expected[i]; !e.prepare && e.mismatch(query) == "" {
+			return e, nil
+		}
+	}
+	return nil, s.unexpected("prepare", query, nil)
+}
+
+// match - следующее ожидание, если запрос с аргументами ему соответствует; иначе ошибка теста
+func (s *Server) match(query string, args []interface{}) (*Expectation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query = match.Normalize(query)
+	for s.next < len(s.expected) && s.expected[s.next].prepare && s.expected[s.next].mismatch(query) == "" {
+		// подготовка без отдельного Describe (например, запрос без параметров)
+		s.expected[s.next].triggered = true
+		s.next++
+	}
+	if s.next >= len(s.expected) {
+		return nil, s.unexpected("query", query, args)
+	}
+
+	e := s.expected[s.next]
+	if e.prepare {
+		return nil, s.fail(fmt.Errorf("pgstub: unexpected query, want #%d %s:\n  got:  %s", s.next, e, query))
+	}
+	if diff := e.mismatch(query); diff != "" {
+		return nil, s.fail(fmt.Errorf("pgstub: unexpected query, want #%d:\n%s", s.next, diff))
+	}
+	if e.checked {
+		want, err := e.normalizedArgs()
+		if err != nil {
+			return nil, s.fail(fmt.Errorf("pgstub: #%d %s: %w", s.next, e, err))
+		}
+		if diff := match.Args(want, args); diff != "" {
+			return nil, s.fail(fmt.Errorf("pgstub: unexpected query, want #%d:\n  query: %s\n%s", s.next, query, diff))
+		}
+	}
+
+	e.triggered = true
+	s.next++
+	return e, nil
+}
+
+func (s *Server) unexpected(what, query string, args []interface{}) error {
+	err := fmt.Errorf("pgstub: unexpected %s (all %d expectations were met):\n  got:  %s", what, len(s.expected), query)
+	if args != nil {
+		err = fmt.Errorf("%w\n  args: %s", err, match.Format(args))
+	}
+	return s.fail(err)
+}
+
+func (s *Server) fail(err error) error {
+	s.t.Error(err)
+	return err
+}
+
+// normalizedArgs - WithArgs, приведенные к типам параметров
+func (e *Expectation) normalizedArgs() ([]interface{}, error) {
+	oids := e.params(len(e.args))
+	args := make([]interface{}, len(e.args))
+	for i, arg := range e.args {
+		v, err := normalize(oids[i], arg)
+		if err != nil {
+			return nil, fmt.Errorf("argument $%d %#v: %w", i+1, arg, err)
+		}
+		args[i] = v
+	}
+	return args, nil
+}
+
+var placeholder = regexp.MustCompile(`\$(\d+)`)
+
+// params - типы n параметров: WithParamOIDs, остальные - text
+func (e *Expectation) params(n int) []uint32 {
+	oids := make([]uint32, n)
+	for i := range oids {
+		oids[i] = textOID
+		if i < len(e.paramOIDs) {
+			oids[i] = e.paramOIDs[i]
+		}
+	}
+	return oids
+}
+
+// countParams - число параметров запроса по наибольшему $n
+func countParams(query string) int {
+	n := 0
+	for _, m := range placeholder.FindAllStringSubmatch(query, -1) {
+		if i, err := strconv.Atoi(m[1]); err == nil && i > n {
+			n = i
+		}
+	}
+	return n
+}
+
+// copyMessage - независимая копия сообщения: кодирование и декодирование в новое значение того же типа
+func copyMessage(msg pgproto3.FrontendMessage) pgproto3.FrontendMessage {
+	buf := msg.Encode(nil)
+	switch msg.(type) {
+	case *pgproto3.StartupMessage, *pgproto3.SSLRequest, *pgproto3.GSSEncRequest, *pgproto3.CancelRequest:
+		buf = buf[4:] // длина без типа сообщения
+	default:
+		buf = buf[5:] // тип и длина
+	}
+
+	c := reflect.New(reflect.TypeOf(msg).Elem()).Interface().(pgproto3.FrontendMessage)
+	if err := c.Decode(buf); err != nil {
+		panic(errors.New("pgstub: copy " + reflect.TypeOf(msg).String() + ": " + err.Error()))
+	}
+	return c
+}
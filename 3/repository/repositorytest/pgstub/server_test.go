@@ -0,0 +1,258 @@
+package pgstub_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+	"github.com/lib/pq"
+
+	"github.com/moguchev/postgres/3/repository/repositorytest/pgstub"
+)
+
+var studentColumns = []pgstub.Column{
+	{Name: "id", OID: pgtype.Int8OID},
+	{Name: "first_name", OID: pgtype.VarcharOID},
+	{Name: "age", OID: pgtype.Int2OID},
+}
+
+const getStudent = `SELECT id, first_name, age FROM students WHERE id = $1`
+
+// student - строка studentColumns
+type student struct {
+	id        int64
+	firstName string
+	age       int16
+}
+
+// expectStartup - первое сообщение - StartupMessage с пользователем и базой из DSN
+func expectStartup(t *testing.T, srv *pgstub.Server) {
+	t.Helper()
+
+	msgs := srv.Messages()
+	if len(msgs) == 0 {
+		t.Fatal("server received no messages")
+	}
+	startup, ok := msgs[0].(*pgproto3.StartupMessage)
+	if !ok {
+		t.Fatalf("first message = %T, want *pgproto3.StartupMessage", msgs[0])
+	}
+	if startup.Parameters["user"] != "pgstub" || startup.Parameters["database"] != "pgstub" {
+		t.Fatalf("startup parameters = %v, want user and database pgstub", startup.Parameters)
+	}
+}
+
+// messageTypes - типы сообщений клиента после startup, например "Parse Describe Sync"
+func messageTypes(srv *pgstub.Server) string {
+	var types []string
+	for _, msg := range srv.Messages()[1:] {
+		types = append(types, strings.TrimPrefix(fmt.Sprintf("%T", msg), "*pgproto3."))
+	}
+	return strings.Join(types, " ")
+}
+
+func TestLibPQ(t *testing.T) {
+	srv := pgstub.NewServer(t)
+	srv.Expect(getStudent).
+		WithParamOIDs(pgtype.Int8OID).
+		WithArgs(1).
+		WillReturnRows(studentColumns, []interface{}{1, "Bob", 20})
+	srv.Expect(`BEGIN READ WRITE`) // так lib/pq открывает транзакцию с опциями по умолчанию
+	srv.Expect(`DELETE FROM students WHERE id = $1`).
+		WithParamOIDs(pgtype.Int8OID).
+		WithArgs(1).
+		WillReturnError(&pgconn.PgError{Code: "23503", Message: "violates foreign key", ConstraintName: "students_groups_student_id_fkey"})
+	srv.Expect(`ROLLBACK`)
+	srv.Expect(`UPDATE students SET age = age + 1`).WillReturnRowsAffected(3)
+
+	db, err := sql.Open("postgres", srv.DSN())
+	if err != nil {
+		t.Fatalf("sql.Open error = %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	ctx := context.Background()
+
+	// расширенный протокол: параметры lib/pq передает текстом
+	var got student
+	if err = db.QueryRowContext(ctx, getStudent, 1).Scan(&got.id, &got.firstName, &got.age); err != nil {
+		t.Fatalf("QueryRow error = %v", err)
+	}
+	if got != (student{id: 1, firstName: "Bob", age: 20}) {
+		t.Fatalf("QueryRow = %+v", got)
+	}
+
+	// ошибка сервера в транзакции
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx error = %v", err)
+	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM students WHERE id = $1`, 1)
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != "23503" || pqErr.Constraint != "students_groups_student_id_fkey" {
+		t.Fatalf("Exec error = %#v, want *pq.Error 23503 with constraint", err)
+	}
+	if err = tx.Rollback(); err != nil {
+		t.Fatalf("Rollback error = %v", err)
+	}
+
+	// запрос без параметров lib/pq отправляет простым протоколом
+	result, err := db.ExecContext(ctx, `UPDATE students SET age = age + 1`)
+	if err != nil {
+		t.Fatalf("Exec error = %v", err)
+	}
+	if affected, _ := result.RowsAffected(); affected != 3 {
+		t.Fatalf("RowsAffected = %d, want 3", affected)
+	}
+
+	expectStartup(t, srv)
+	if types := messageTypes(srv); !strings.HasPrefix(types, "Parse Describe Sync Bind Execute Sync ") {
+		t.Fatalf("messages = %s, want a query by Parse/Describe/Sync then Bind/Execute/Sync", types)
+	}
+	statements := srv.Statements()
+	if len(statements) != 5 {
+		t.Fatalf("statements = %+v, want 5", statements)
+	}
+	if st := statements[0]; st.Simple || len(st.Args) != 1 || st.Args[0] != int64(1) {
+		t.Fatalf("statement #0 = %+v, want extended protocol with int64 argument", st)
+	}
+	for _, i := range []int{1, 3, 4} {
+		if !statements[i].Simple {
+			t.Errorf("statement #%d %q was not sent by the simple protocol", i, statements[i].Query)
+		}
+	}
+}
+
+func TestPgx(t *testing.T) {
+	srv := pgstub.NewServer(t)
+	srv.Expect(getStudent).
+		WithParamOIDs(pgtype.Int8OID).
+		WithArgs(1).
+		WillReturnRows(studentColumns, []interface{}{1, "Bob", 20}, []interface{}{2, nil, 21})
+	srv.Expect(`UPDATE students SET age = $2 WHERE id = $1`).
+		WithParamOIDs(pgtype.Int8OID, pgtype.Int2OID).
+		WithArgs(1, pgstub.AnyArg()).
+		WillReturnError(&pgconn.PgError{Code: "23514", Message: "violates check constraint", ConstraintName: "students_age_check"})
+	srv.Expect(getStudent).
+		WithParamOIDs(pgtype.Int8OID).
+		WithArgs(2).
+		WillReturnRows(studentColumns)
+
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, srv.DSN())
+	if err != nil {
+		t.Fatalf("pgx.Connect error = %v", err)
+	}
+	defer conn.Close(ctx)
+
+	// pgx готовит именованный запрос и передает параметры и строки в двоичном формате
+	rows, err := conn.Query(ctx, getStudent, int64(1))
+	if err != nil {
+		t.Fatalf("Query error = %v", err)
+	}
+	var got []student
+	for rows.Next() {
+		var (
+			s    student
+			name *string
+		)
+		if err = rows.Scan(&s.id, &name, &s.age); err != nil {
+			t.Fatalf("Scan error = %v", err)
+		}
+		if name != nil {
+			s.firstName = *name
+		}
+		got = append(got, s)
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatalf("rows error = %v", err)
+	}
+	if len(got) != 2 || got[0] != (student{id: 1, firstName: "Bob", age: 20}) || got[1] != (student{id: 2, age: 21}) {
+		t.Fatalf("Query = %+v", got)
+	}
+
+	_, err = conn.Exec(ctx, `UPDATE students SET age = $2 WHERE id = $1`, int64(1), int16(-1))
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "23514" || pgErr.ConstraintName != "students_age_check" {
+		t.Fatalf("Exec error = %#v, want *pgconn.PgError 23514 with constraint", err)
+	}
+
+	// после ошибки соединение продолжает работать, запрос берется из кэша подготовленных
+	if err = conn.QueryRow(ctx, getStudent, int64(2)).Scan(new(int64), new(string), new(int16)); !errors.Is(err, pgx.ErrNoRows) {
+		t.Fatalf("QueryRow error = %v, want %v", err, pgx.ErrNoRows)
+	}
+
+	expectStartup(t, srv)
+	parses := 0
+	for _, msg := range srv.Messages() {
+		switch m := msg.(type) {
+		case *pgproto3.Parse:
+			parses++
+			if m.Name == "" {
+				t.Errorf("Parse %q is unnamed, want a cached prepared statement", m.Query)
+			}
+		case *pgproto3.Bind:
+			if len(m.ParameterFormatCodes) == 0 || m.ParameterFormatCodes[0] != 1 {
+				t.Errorf("Bind parameter formats = %v, want binary", m.ParameterFormatCodes)
+			}
+		}
+	}
+	if parses != 2 {
+		t.Fatalf("Parse messages = %d, want 2 (one per distinct query)", parses)
+	}
+	statements := srv.Statements()
+	if len(statements) != 3 || statements[1].Args[1] != int16(-1) {
+		t.Fatalf("statements = %+v, want 3 with decoded binary arguments", statements)
+	}
+}
+
+// recorder - testing.TB, который запоминает ошибки сценария вместо того, чтобы провалить тест
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Error(args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprint(args...))
+}
+
+func TestUnexpectedQuery(t *testing.T) {
+	r := &recorder{TB: t}
+	srv := pgstub.NewServer(r)
+	srv.Expect(`SELECT 1`)
+	srv.Expect(`SELECT 2`)
+
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, srv.DSN())
+	if err != nil {
+		t.Fatalf("pgx.Connect error = %v", err)
+	}
+	defer conn.Close(ctx)
+
+	_, err = conn.Exec(ctx, `SELECT 3`)
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "XX000" {
+		t.Fatalf("Exec error = %#v, want *pgconn.PgError XX000", err)
+	}
+	if len(r.errors) != 1 || !strings.Contains(r.errors[0], "  want: SELECT 1\n  got:  SELECT 3") {
+		t.Fatalf("reported errors = %q, want a mismatch with SELECT 1", r.errors)
+	}
+
+	// соединение не сломано, следующие запросы сверяются со сценарием дальше
+	if _, err = conn.Exec(ctx, `SELECT 1`); err != nil {
+		t.Fatalf("Exec after mismatch error = %v", err)
+	}
+	if err = srv.ExpectationsWereMet(); err == nil || !strings.Contains(err.Error(), `#1 query "SELECT 2": not called`) {
+		t.Fatalf("ExpectationsWereMet error = %v, want SELECT 2 not called", err)
+	}
+	if _, err = conn.Exec(ctx, `SELECT 2`); err != nil {
+		t.Fatalf("Exec error = %v", err)
+	}
+}
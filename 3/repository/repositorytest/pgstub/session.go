@@ -0,0 +1,400 @@
+package pgstub
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgtype"
+
+	"github.com/moguchev/postgres/3/repository/repositorytest/internal/match"
+)
+
+const (
+	textOID = pgtype.TextOID
+
+	txIdle   = 'I'
+	txActive = 'T'
+	txFailed = 'E'
+)
+
+// statement - подготовленный запрос соединения (Parse)
+type statement struct {
+	query     string
+	parseOIDs []uint32 // типы параметров из Parse, 0 - на усмотрение сервера
+	described bool
+	oids      []uint32 // типы параметров, сообщенные клиенту
+	columns   []Column // столбцы, сообщенные клиенту; nil - NoData
+}
+
+// portal - запрос с параметрами (Bind)
+type portal struct {
+	statement *statement
+	args      []interface{}
+	formats   []int16 // форматы столбцов результата
+}
+
+var pidSeq uint32 = 1000
+
+// session - одно соединение клиента
+type session struct {
+	server  *Server
+	conn    net.Conn
+	backend *pgproto3.Backend
+
+	pid, secret uint32
+	canceled    chan struct{}
+
+	statements map[string]*statement
+	portals    map[string]*portal
+	txStatus   byte
+	failed     bool // после ошибки в расширенном протоколе сообщения пропускаются до Sync
+}
+
+func newSession(s *Server, conn net.Conn) *session {
+	pid := atomic.AddUint32(&pidSeq, 1)
+	return &session{
+		server:     s,
+		conn:       conn,
+		backend:    pgproto3.NewBackend(pgproto3.NewChunkReader(conn), conn),
+		pid:        pid,
+		secret:     pid ^ 0x5eed,
+		canceled:   make(chan struct{}, 1),
+		statements: make(map[string]*statement),
+		portals:    make(map[string]*portal),
+		txStatus:   txIdle,
+	}
+}
+
+func (c *session) run() {
+	if !c.startup() {
+		return
+	}
+	c.server.register(c)
+	defer c.server.unregister(c)
+
+	for {
+		msg, err := c.backend.Receive()
+		if err != nil {
+			return
+		}
+		msg = c.server.record(msg)
+
+		if _, ok := msg.(*pgproto3.Sync); c.failed && !ok {
+			continue
+		}
+		switch m := msg.(type) {
+		case *pgproto3.Query:
+			c.simpleQuery(m.String)
+		case *pgproto3.Parse:
+			c.statements[m.Name] = &statement{query: m.Query, parseOIDs: m.ParameterOIDs}
+			c.send(&pgproto3.ParseComplete{})
+		case *pgproto3.Describe:
+			c.describe(m.ObjectType, m.Name)
+		case *pgproto3.Bind:
+			c.bind(m)
+		case *pgproto3.Execute:
+			c.execute(m.Portal)
+		case *pgproto3.Close:
+			if m.ObjectType == 'S' {
+				delete(c.statements, m.Name)
+			} else {
+				delete(c.portals, m.Name)
+			}
+			c.send(&pgproto3.CloseComplete{})
+		case *pgproto3.Sync:
+			c.failed = false
+			c.send(&pgproto3.ReadyForQuery{TxStatus: c.txStatus})
+		case *pgproto3.Flush:
+			// сервер отвечает сразу
+		case *pgproto3.Terminate:
+			return
+		default:
+			c.error(&pgconn.PgError{Code: "0A000", Message: fmt.Sprintf("pgstub: %T is not supported", msg)})
+		}
+	}
+}
+
+// startup - SSLRequest (отказ), StartupMessage и ответ без пароля. CancelRequest отменяет запрос
+// другого соединения, после чего соединение закрывается
+func (c *session) startup() bool {
+	for {
+		msg, err := c.backend.ReceiveStartupMessage()
+		if err != nil {
+			return false
+		}
+		msg = c.server.record(msg)
+
+		switch m := msg.(type) {
+		case *pgproto3.SSLRequest, *pgproto3.GSSEncRequest:
+			if _, err := c.conn.Write([]byte{'N'}); err != nil {
+				return false
+			}
+		case *pgproto3.StartupMessage:
+			c.send(&pgproto3.AuthenticationOk{})
+			for _, p := range [][2]string{
+				{"server_version", ServerVersion},
+				{"server_encoding", "UTF8"},
+				{"client_encoding", "UTF8"},
+				{"DateStyle", "ISO, MDY"},
+				{"IntervalStyle", "postgres"},
+				{"integer_datetimes", "on"},
+				{"standard_conforming_strings", "on"},
+				{"TimeZone", "UTC"},
+				{"application_name", m.Parameters["application_name"]},
+			} {
+				c.send(&pgproto3.ParameterStatus{Name: p[0], Value: p[1]})
+			}
+			c.send(&pgproto3.BackendKeyData{ProcessID: c.pid, SecretKey: c.secret})
+			c.send(&pgproto3.ReadyForQuery{TxStatus: c.txStatus})
+			return true
+		case *pgproto3.CancelRequest:
+			c.server.cancel(m.ProcessID, m.SecretKey)
+			return false
+		default:
+			return false
+		}
+	}
+}
+
+func (c *session) simpleQuery(query string) {
+	if strings.Trim(query, "; \t\r\n") == "" { // как у PostgreSQL: ";" (ping lib/pq и pgx) - пустой запрос
+		c.send(&pgproto3.EmptyQueryResponse{})
+	} else {
+		c.exec(query, nil, nil, true)
+	}
+	c.failed = false
+	c.send(&pgproto3.ReadyForQuery{TxStatus: c.txStatus})
+}
+
+func (c *session) describe(objectType byte, name string) {
+	var st *statement
+	var formats []int16
+	if objectType == 'S' {
+		st = c.statements[name]
+	} else if p := c.portals[name]; p != nil {
+		st, formats = p.statement, p.formats
+	}
+	if st == nil {
+		c.missing(objectType, name)
+		return
+	}
+	if err := c.prepare(st); err != nil {
+		c.error(stubError(err))
+		return
+	}
+
+	if objectType == 'S' {
+		c.send(&pgproto3.ParameterDescription{ParameterOIDs: st.oids})
+	}
+	if st.columns == nil {
+		c.send(&pgproto3.NoData{})
+		return
+	}
+	c.send(rowDescription(st.columns, formats))
+}
+
+// prepare - типы параметров и столбцы запроса по ожиданию из сценария (один раз на statement)
+func (c *session) prepare(st *statement) error {
+	if st.described {
+		return nil
+	}
+	e, err := c.server.describe(st.query)
+	if err != nil {
+		return err
+	}
+
+	n := countParams(st.query)
+	if len(st.parseOIDs) > n {
+		n = len(st.parseOIDs)
+	}
+	st.oids = e.params(n)
+	for i, oid := range st.parseOIDs {
+		if oid != 0 {
+			st.oids[i] = oid
+		}
+	}
+	st.columns = e.columns
+	st.described = true
+	return nil
+}
+
+func (c *session) bind(m *pgproto3.Bind) {
+	st := c.statements[m.PreparedStatement]
+	if st == nil {
+		c.missing('S', m.PreparedStatement)
+		return
+	}
+	if err := c.prepare(st); err != nil {
+		c.error(stubError(err))
+		return
+	}
+
+	args := make([]interface{}, len(m.Parameters))
+	for i, param := range m.Parameters {
+		oid := uint32(textOID)
+		if i < len(st.oids) {
+			oid = st.oids[i]
+		}
+		v, err := decode(oid, format(m.ParameterFormatCodes, i), param)
+		if err != nil {
+			c.error(&pgconn.PgError{Code: "22P02", Message: fmt.Sprintf("pgstub: parameter $%d: %v", i+1, err)})
+			return
+		}
+		args[i] = v
+	}
+	c.portals[m.DestinationPortal] = &portal{statement: st, args: args, formats: m.ResultFormatCodes}
+	c.send(&pgproto3.BindComplete{})
+}
+
+func (c *session) execute(name string) {
+	p := c.portals[name]
+	if p == nil {
+		c.missing('P', name)
+		return
+	}
+	c.exec(p.statement.query, p.args, p.formats, false)
+}
+
+// exec - выполнение запроса по следующему ожиданию сценария: строки, CommandComplete или ошибка
+func (c *session) exec(query string, args []interface{}, formats []int16, simple bool) {
+	c.server.recordStatement(Statement{Query: query, Args: args, Simple: simple})
+
+	e, err := c.server.match(query, args)
+	if err != nil {
+		c.error(stubError(err))
+		return
+	}
+	if !c.wait(e.delay) {
+		c.error(&pgconn.PgError{Code: "57014", Message: "canceling statement due to user request"})
+		return
+	}
+	if e.err != nil {
+		c.error(e.err)
+		return
+	}
+
+	if simple && e.columns != nil {
+		c.send(rowDescription(e.columns, nil))
+	}
+	for i, row := range e.rows {
+		values := make([][]byte, len(row))
+		for j, v := range row {
+			if j >= len(e.columns) {
+				c.error(stubError(c.server.fail(fmt.Errorf("pgstub: %s: row %d has %d values for %d columns", e, i, len(row), len(e.columns)))))
+				return
+			}
+			buf, err := encode(e.columns[j].OID, format(formats, j), v)
+			if err != nil {
+				c.error(stubError(c.server.fail(fmt.Errorf("pgstub: %s: row %d column %q: %w", e, i, e.columns[j].Name, err))))
+				return
+			}
+			values[j] = buf
+		}
+		c.send(&pgproto3.DataRow{Values: values})
+	}
+	c.send(&pgproto3.CommandComplete{CommandTag: []byte(e.result(query))})
+	c.transaction(query)
+}
+
+// wait - задержка ответа; false, если запрос отменен (CancelRequest) или сервер остановлен
+func (c *session) wait(d time.Duration) bool {
+	select {
+	case <-c.canceled: // отмена, пришедшая между запросами, не относится к следующему
+	default:
+	}
+	if d <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-c.canceled:
+	case <-c.server.done:
+	}
+	return false
+}
+
+// transaction - статус транзакции после успешного запроса
+func (c *session) transaction(query string) {
+	query = strings.ToUpper(match.Normalize(query))
+	switch {
+	case strings.HasPrefix(query, "BEGIN"), strings.HasPrefix(query, "START TRANSACTION"):
+		c.txStatus = txActive
+	case strings.HasPrefix(query, "ROLLBACK TO"):
+		c.txStatus = txActive
+	case strings.HasPrefix(query, "COMMIT"), strings.HasPrefix(query, "END"),
+		strings.HasPrefix(query, "ROLLBACK"), strings.HasPrefix(query, "ABORT"):
+		c.txStatus = txIdle
+	}
+}
+
+func (c *session) missing(objectType byte, name string) {
+	if objectType == 'S' {
+		c.error(&pgconn.PgError{Code: "26000", Message: fmt.Sprintf("prepared statement %q does not exist", name)})
+		return
+	}
+	c.error(&pgconn.PgError{Code: "34000", Message: fmt.Sprintf("portal %q does not exist", name)})
+}
+
+// error - ErrorResponse; в транзакции она становится прерванной, расширенный протокол ждет Sync
+func (c *session) error(err *pgconn.PgError) {
+	severity := err.Severity
+	if severity == "" {
+		severity = "ERROR"
+	}
+	c.send(&pgproto3.ErrorResponse{
+		Severity:         severity,
+		Code:             err.Code,
+		Message:          err.Message,
+		Detail:           err.Detail,
+		Hint:             err.Hint,
+		Position:         err.Position,
+		InternalPosition: err.InternalPosition,
+		InternalQuery:    err.InternalQuery,
+		Where:            err.Where,
+		SchemaName:       err.SchemaName,
+		TableName:        err.TableName,
+		ColumnName:       err.ColumnName,
+		DataTypeName:     err.DataTypeName,
+		ConstraintName:   err.ConstraintName,
+		File:             err.File,
+		Line:             err.Line,
+		Routine:          err.Routine,
+	})
+	if c.txStatus == txActive {
+		c.txStatus = txFailed
+	}
+	c.failed = true
+}
+
+func (c *session) send(msg pgproto3.BackendMessage) {
+	_ = c.backend.Send(msg) // ошибку записи вернет следующий Receive
+}
+
+// stubError - ошибка сервера (неожиданный запрос и т.п.) для клиента
+func stubError(err error) *pgconn.PgError {
+	return &pgconn.PgError{Code: "XX000", Message: err.Error()}
+}
+
+func rowDescription(columns []Column, formats []int16) *pgproto3.RowDescription {
+	fields := make([]pgproto3.FieldDescription, len(columns))
+	for i, column := range columns {
+		fields[i] = pgproto3.FieldDescription{
+			Name:         []byte(column.Name),
+			DataTypeOID:  column.OID,
+			DataTypeSize: -1,
+			TypeModifier: -1,
+			Format:       format(formats, i),
+		}
+	}
+	return &pgproto3.RowDescription{Fields: fields}
+}
@@ -0,0 +1,127 @@
+package pgstub
+
+import (
+	"fmt"
+
+	"github.com/jackc/pgtype"
+)
+
+const (
+	textFormat   = 0
+	binaryFormat = 1
+)
+
+// connInfo - типы PostgreSQL, которые сервер умеет кодировать и декодировать
+var connInfo = pgtype.NewConnInfo()
+
+// format - код формата i-го значения: один код на все значения, по коду на каждое или text, если кодов нет
+func format(codes []int16, i int) int16 {
+	switch len(codes) {
+	case 0:
+		return textFormat
+	case 1:
+		return codes[0]
+	}
+	return codes[i]
+}
+
+// encode - значение v типа oid в формате f; nil - NULL
+func encode(oid uint32, f int16, v interface{}) ([]byte, error) {
+	if v == nil {
+		return nil, nil
+	}
+	value, err := typed(oid, v)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf []byte
+	switch f {
+	case textFormat:
+		encoder, ok := value.(pgtype.TextEncoder)
+		if !ok {
+			return nil, fmt.Errorf("type %d has no text format", oid)
+		}
+		buf, err = encoder.EncodeText(connInfo, nil)
+	case binaryFormat:
+		encoder, ok := value.(pgtype.BinaryEncoder)
+		if !ok {
+			return nil, fmt.Errorf("type %d has no binary format", oid)
+		}
+		buf, err = encoder.EncodeBinary(connInfo, nil)
+	default:
+		return nil, fmt.Errorf("unknown format code %d", f)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if buf == nil {
+		buf = []byte{} // nil из EncodeText/EncodeBinary - это NULL, а v не nil
+	}
+	return buf, nil
+}
+
+// decode - значение типа oid, присланное клиентом в формате f; неизвестный тип остается строкой или []byte
+func decode(oid uint32, f int16, src []byte) (interface{}, error) {
+	if src == nil {
+		return nil, nil
+	}
+	dt, ok := connInfo.DataTypeForOID(oid)
+	if !ok {
+		if f == textFormat {
+			return string(src), nil
+		}
+		return append([]byte(nil), src...), nil
+	}
+
+	value := pgtype.NewValue(dt.Value)
+	switch f {
+	case textFormat:
+		decoder, ok := value.(pgtype.TextDecoder)
+		if !ok {
+			return nil, fmt.Errorf("type %s has no text format", dt.Name)
+		}
+		if err := decoder.DecodeText(connInfo, src); err != nil {
+			return nil, err
+		}
+	case binaryFormat:
+		decoder, ok := value.(pgtype.BinaryDecoder)
+		if !ok {
+			return nil, fmt.Errorf("type %s has no binary format", dt.Name)
+		}
+		if err := decoder.DecodeBinary(connInfo, src); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown format code %d", f)
+	}
+	return value.Get(), nil
+}
+
+// normalize - значение v, приведенное к типу oid так же, как декодированный параметр (1 для int8 - int64(1))
+func normalize(oid uint32, v interface{}) (interface{}, error) {
+	if _, ok := v.(Argument); ok || v == nil {
+		return v, nil
+	}
+	if _, ok := connInfo.DataTypeForOID(oid); !ok {
+		return v, nil
+	}
+	value, err := typed(oid, v)
+	if err != nil {
+		return nil, err
+	}
+	return value.Get(), nil
+}
+
+// typed - значение pgtype для oid, в которое записано v
+func typed(oid uint32, v interface{}) (pgtype.Value, error) {
+	dt, ok := connInfo.DataTypeForOID(oid)
+	if !ok {
+		return nil, fmt.Errorf("unknown type oid %d", oid)
+	}
+	value := pgtype.NewValue(dt.Value)
+	if err := value.Set(v); err != nil {
+		return nil, fmt.Errorf("%s: %w", dt.Name, err)
+	}
+	return value, nil
+}
@@ -0,0 +1,518 @@
+// Package pgxmock - сценарный мок transaction.Querier для unit тестов pgx_implementation без PostgreSQL.
+//
+// Мок передается в репозиторий вместо *pgxpool.Pool; тест описывает, какие запросы в каком порядке должны прийти,
+// с какими аргументами, и что вернуть на каждый - строки, CommandTag, ошибку (например *pgconn.PgError) и задержку:
+//
+//	mock := pgxmock.New(t)
+//	mock.ExpectQuery(`SELECT id, first_name, last_name, age FROM students WHERE id = $1`).
+//		WithArgs(int64(1)).
+//		WillReturnRows([]string{"id", "first_name", "last_name", "age"}, []interface{}{int32(1), "Bob", "Brown", int16(20)})
+//	mock.ExpectExec(`DELETE FROM students WHERE id = $1`).WillReturnRowsAffected(0)
+//
+//	repo := pgximplementation.NewRepository(mock)
+//
+// Запросы сравниваются после нормализации пробелов: точно (ExpectQuery) или регулярным выражением (ExpectQueryRegexp);
+// аргументы - как их передал репозиторий (без приведения типов) или через AnyArg. Неожиданный вызов - ошибка теста
+// со сравнением с ожидаемым. По окончании теста мок проверяет, что все ожидания выполнены, а строки
+// и результаты пакетов закрыты.
+//
+// Транзакции тоже часть сценария: transaction.NewTxManager(mock) открывает их через BeginTx мока,
+// поэтому WithinTx описывается как ExpectBegin, запросы fn и ExpectCommit (или ExpectRollback при ошибке fn).
+// Вложенный WithinTx (SAVEPOINT) - такие же ExpectBegin и ExpectCommit/ExpectRollback внутри внешних.
+package pgxmock
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/moguchev/postgres/3/repository/repositorytest/internal/match"
+	transaction "github.com/moguchev/postgres/3/repository/transaction/pgx_implementation"
+)
+
+// проверка удовлетворению интерфейса transaction.Querier
+var _ transaction.Querier = (*Mock)(nil)
+
+type kind int
+
+const (
+	kindQuery kind = iota
+	kindExec
+	kindCopy
+	kindBatch
+	kindBegin
+	kindCommit
+	kindRollback
+)
+
+func (k kind) String() string {
+	return [...]string{"query", "exec", "copy", "batch", "begin", "commit", "rollback"}[k]
+}
+
+// Argument - условие на аргумент запроса вместо точного значения (см. AnyArg)
+type Argument = match.Argument
+
+// AnyArg - любое значение аргумента
+func AnyArg() Argument {
+	return match.Any()
+}
+
+// Expectation - один ожидаемый вызов Querier (или результат одного запроса пакета, см. BatchExpectation) и его результат
+type Expectation struct {
+	kind    kind
+	query   string         // нормализованный запрос для точного сравнения
+	pattern *regexp.Regexp // или регулярное выражение
+	args    []interface{}
+	checked bool // WithArgs (WithRows для CopyFrom, WithTxOptions для Begin) был вызван
+
+	columns []string
+	rows    [][]interface{}
+	rowsErr error
+	tag     pgconn.CommandTag
+	err     error
+	delay   time.Duration
+
+	triggered bool
+	opened    []*rows
+}
+
+// WithArgs - ожидаемые аргументы: Argument или значение, которое сравнивается с переданным через reflect.DeepEqual
+func (e *Expectation) WithArgs(args ...interface{}) *Expectation {
+	e.checked = true
+	e.args = args
+	return e
+}
+
+// WillReturnRows - строки результата запроса. Scan приводит целые числа к типу назначения с проверкой переполнения,
+// остальные значения должны быть присваиваемыми (или назначение - sql.Scanner)
+func (e *Expectation) WillReturnRows(columns []string, rows ...[]interface{}) *Expectation {
+	e.columns = columns
+	e.rows = rows
+	return e
+}
+
+// WillReturnRowsError - ошибка, которую вернет rows.Err() после всех строк
+func (e *Expectation) WillReturnRowsError(err error) *Expectation {
+	e.rowsErr = err
+	return e
+}
+
+// WillReturnCommandTag - результат Exec как его возвращает сервер, например "UPDATE 1"
+func (e *Expectation) WillReturnCommandTag(tag string) *Expectation {
+	e.tag = pgconn.CommandTag(tag)
+	return e
+}
+
+// WillReturnRowsAffected - результат Exec с n затронутыми строками (команда - первое слово запроса)
+func (e *Expectation) WillReturnRowsAffected(n int64) *Expectation {
+	verb := "UPDATE"
+	if fields := strings.Fields(e.query); len(fields) > 0 {
+		verb = strings.ToUpper(fields[0])
+	}
+	if verb == "INSERT" {
+		verb += " 0" // INSERT oid rows
+	}
+	e.tag = pgconn.CommandTag(verb + " " + strconv.FormatInt(n, 10))
+	return e
+}
+
+// WillReturnError - ошибка вызова (например *pgconn.PgError с нужным SQLSTATE и ограничением)
+func (e *Expectation) WillReturnError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+// WillDelay - задержка ответа; если ctx завершится раньше, вызов вернет ctx.Err()
+func (e *Expectation) WillDelay(d time.Duration) *Expectation {
+	e.delay = d
+	return e
+}
+
+func (e *Expectation) String() string {
+	s := e.kind.String()
+	switch {
+	case e.pattern != nil:
+		s += " matching " + strconv.Quote(e.pattern.String())
+	case e.query != "":
+		s += " " + strconv.Quote(e.query)
+	}
+	switch {
+	case e.checked && e.kind == kindBegin:
+		s += " with options " + match.Format(e.args)
+	case e.checked && e.kind != kindCopy:
+		s += " with args " + match.Format(e.args)
+	}
+	return s
+}
+
+// CopyExpectation - ожидаемый CopyFrom
+type CopyExpectation struct {
+	*Expectation
+}
+
+// WithRows - ожидаемые строки COPY (значения сравниваются как аргументы WithArgs)
+func (e CopyExpectation) WithRows(rows ...[]interface{}) CopyExpectation {
+	e.checked = true
+	e.rows = rows
+	return e
+}
+
+// BeginExpectation - ожидаемое открытие транзакции (BeginTx) или точки сохранения (Begin открытой транзакции)
+type BeginExpectation struct {
+	*Expectation
+}
+
+// WithTxOptions - ожидаемые опции транзакции; у точки сохранения опций нет, с ней это ожидание не совпадет
+func (e BeginExpectation) WithTxOptions(opts pgx.TxOptions) BeginExpectation {
+	e.checked = true
+	e.args = []interface{}{opts}
+	return e
+}
+
+// BatchExpectation - ожидаемый SendBatch. pgx.Batch не дает прочитать тексты запросов,
+// поэтому проверяется только их количество: по одному Result на каждый запрос пакета, в порядке очереди
+type BatchExpectation struct {
+	*Expectation
+	results []*Expectation
+	closed  []*batchResults
+}
+
+// Result - результат следующего запроса пакета (WillReturnRows, WillReturnCommandTag, WillReturnError...)
+func (e *BatchExpectation) Result() *Expectation {
+	r := &Expectation{kind: kindQuery}
+	e.results = append(e.results, r)
+	return r
+}
+
+// Mock - ожидаемые вызовы по порядку. Методы безопасны для одновременного использования
+type Mock struct {
+	t testing.TB
+
+	mu       sync.Mutex
+	expected []*Expectation
+	batches  map[*Expectation]*BatchExpectation
+	next     int
+}
+
+// New - мок без ожиданий; по окончании теста он проверяет ExpectationsWereMet
+func New(t testing.TB) *Mock {
+	m := &Mock{
+		t:       t,
+		batches: make(map[*Expectation]*BatchExpectation),
+	}
+	t.Cleanup(func() {
+		if err := m.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+	return m
+}
+
+// ExpectQuery - Query или QueryRow с точным текстом запроса
+func (m *Mock) ExpectQuery(query string) *Expectation {
+	return m.expect(&Expectation{kind: kindQuery, query: match.Normalize(query)})
+}
+
+// ExpectQueryRegexp - Query или QueryRow, текст которого подходит под pattern
+func (m *Mock) ExpectQueryRegexp(pattern string) *Expectation {
+	return m.expect(&Expectation{kind: kindQuery, pattern: regexp.MustCompile(pattern)})
+}
+
+// ExpectExec - Exec с точным текстом запроса
+func (m *Mock) ExpectExec(query string) *Expectation {
+	return m.expect(&Expectation{kind: kindExec, query: match.Normalize(query)})
+}
+
+// ExpectExecRegexp - Exec, текст которого подходит под pattern
+func (m *Mock) ExpectExecRegexp(pattern string) *Expectation {
+	return m.expect(&Expectation{kind: kindExec, pattern: regexp.MustCompile(pattern)})
+}
+
+// ExpectCopyFrom - CopyFrom в таблицу table с колонками columns
+func (m *Mock) ExpectCopyFrom(table pgx.Identifier, columns []string) CopyExpectation {
+	return CopyExpectation{m.expect(&Expectation{kind: kindCopy, query: copyQuery(table, columns)})}
+}
+
+// ExpectBatch - SendBatch; результаты запросов пакета задаются через BatchExpectation.Result
+func (m *Mock) ExpectBatch() *BatchExpectation {
+	e := m.expect(&Expectation{kind: kindBatch})
+	b := &BatchExpectation{Expectation: e}
+
+	m.mu.Lock()
+	m.batches[e] = b
+	m.mu.Unlock()
+
+	return b
+}
+
+// ExpectBegin - открытие транзакции или точки сохранения
+func (m *Mock) ExpectBegin() BeginExpectation {
+	return BeginExpectation{m.expect(&Expectation{kind: kindBegin})}
+}
+
+// ExpectCommit - фиксация транзакции или RELEASE SAVEPOINT
+func (m *Mock) ExpectCommit() *Expectation {
+	return m.expect(&Expectation{kind: kindCommit})
+}
+
+// ExpectRollback - откат транзакции или ROLLBACK TO SAVEPOINT. Откат после Commit мок, как и pgx,
+// не выполняет (pgx.ErrTxClosed), поэтому ожидать его нужно только при ошибке fn или Commit
+func (m *Mock) ExpectRollback() *Expectation {
+	return m.expect(&Expectation{kind: kindRollback})
+}
+
+func (m *Mock) expect(e *Expectation) *Expectation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expected = append(m.expected, e)
+	return e
+}
+
+// ExpectationsWereMet - ошибка, если какие-то ожидания не выполнены, строки или результаты пакета не закрыты
+func (m *Mock) ExpectationsWereMet() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var problems []string
+	for i, e := range m.expected {
+		if !e.triggered {
+			problems = append(problems, fmt.Sprintf("  #%d %s: not called", i, e))
+			continue
+		}
+		opened := e.opened
+		if b, ok := m.batches[e]; ok {
+			for _, br := range b.closed {
+				if !br.isClosed() {
+					problems = append(problems, fmt.Sprintf("  #%d %s: batch results were not closed", i, e))
+				}
+			}
+			for _, r := range b.results {
+				opened = append(opened, r.opened...)
+			}
+		}
+		for _, r := range opened {
+			if !r.isClosed() {
+				problems = append(problems, fmt.Sprintf("  #%d %s: rows were not closed", i, e))
+				break
+			}
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("pgxmock: expectations are not met:\n%s", strings.Join(problems, "\n"))
+}
+
+func (m *Mock) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	e, err := m.call(ctx, kindExec, sql, args)
+	if err != nil {
+		return nil, err
+	}
+	return e.tag, nil
+}
+
+func (m *Mock) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	e, err := m.call(ctx, kindQuery, sql, args)
+	if err != nil {
+		return nil, err
+	}
+	return m.open(e), nil
+}
+
+func (m *Mock) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	rows, err := m.Query(ctx, sql, args...)
+	return &row{rows: rows, err: err}
+}
+
+// QueryFunc - ожидание ExpectQuery: каждая строка сканируется в scans и передается f, как в pgx
+func (m *Mock) QueryFunc(ctx context.Context, sql string, args []interface{}, scans []interface{}, f func(pgx.QueryFuncRow) error) (pgconn.CommandTag, error) {
+	rows, err := m.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	return queryFunc(rows, scans, f)
+}
+
+// Begin - BeginTx с опциями по умолчанию
+func (m *Mock) Begin(ctx context.Context) (pgx.Tx, error) {
+	return m.BeginTx(ctx, pgx.TxOptions{})
+}
+
+// BeginTx - ожидание ExpectBegin; запросы возвращенной транзакции сверяются с тем же сценарием
+func (m *Mock) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	if _, err := m.call(ctx, kindBegin, "", []interface{}{txOptions}); err != nil {
+		return nil, err
+	}
+	return &tx{mock: m}, nil
+}
+
+func (m *Mock) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	var copied [][]interface{}
+	for rowSrc.Next() {
+		values, err := rowSrc.Values()
+		if err != nil {
+			return 0, err
+		}
+		copied = append(copied, values)
+	}
+	if err := rowSrc.Err(); err != nil {
+		return 0, err
+	}
+
+	e, err := m.call(ctx, kindCopy, copyQuery(tableName, columnNames), nil)
+	if err != nil {
+		return 0, err
+	}
+	if e.checked {
+		if problem := copyRowsDiff(e.rows, copied); problem != "" {
+			err = fmt.Errorf("pgxmock: unexpected copy rows:\n%s", problem)
+			m.t.Error(err)
+			return 0, err
+		}
+	}
+	return int64(len(copied)), nil
+}
+
+func (m *Mock) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	e, err := m.call(ctx, kindBatch, fmt.Sprintf("batch of %d queries", b.Len()), nil)
+	if err != nil {
+		return &batchResults{err: err}
+	}
+
+	m.mu.Lock()
+	expectation := m.batches[e]
+	m.mu.Unlock()
+
+	br := &batchResults{mock: m, results: expectation.results}
+	if len(expectation.results) != b.Len() {
+		br.err = fmt.Errorf("pgxmock: batch of %d queries, want %d", b.Len(), len(expectation.results))
+		m.t.Error(br.err)
+	}
+
+	m.mu.Lock()
+	expectation.closed = append(expectation.closed, br)
+	m.mu.Unlock()
+
+	return br
+}
+
+// call - сверяет вызов со сценарием, выдерживает задержку и возвращает ошибку ожидания, если она задана
+func (m *Mock) call(ctx context.Context, k kind, sql string, args []interface{}) (*Expectation, error) {
+	e, err := m.match(k, sql, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.delay > 0 {
+		timer := time.NewTimer(e.delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	if e.err != nil {
+		return nil, e.err
+	}
+	return e, nil
+}
+
+// match - следующее ожидание, если вызов ему соответствует; иначе ошибка теста и ошибка для вызывающего кода
+func (m *Mock) match(k kind, sql string, args []interface{}) (*Expectation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	query := match.Normalize(sql)
+	if m.next >= len(m.expected) {
+		err := fmt.Errorf("pgxmock: unexpected %s (all %d expectations were met):\n  got:  %s\n  args: %s",
+			k, len(m.expected), query, match.Format(args))
+		m.t.Error(err)
+		return nil, err
+	}
+
+	e := m.expected[m.next]
+	if problem := e.mismatch(k, query, args); problem != "" {
+		err := fmt.Errorf("pgxmock: unexpected %s, want #%d %s:\n%s", k, m.next, e.kind, problem)
+		m.t.Error(err)
+		return nil, err
+	}
+
+	e.triggered = true
+	m.next++
+	return e, nil
+}
+
+// mismatch - описание отличий вызова от ожидания, пустое если вызов подходит
+func (e *Expectation) mismatch(k kind, query string, args []interface{}) string {
+	if k != e.kind {
+		return fmt.Sprintf("  want %s\n  got:  %s %s", e, k, query)
+	}
+	switch k {
+	case kindBatch, kindCommit, kindRollback:
+		return ""
+	case kindBegin:
+		if !e.checked {
+			return ""
+		}
+		if diff := match.Args(e.args, args); diff != "" {
+			return "  options:\n" + diff
+		}
+		return ""
+	}
+
+	if diff := match.Query(e.query, e.pattern, query); diff != "" {
+		return diff
+	}
+	if e.checked && k != kindCopy {
+		if diff := match.Args(e.args, args); diff != "" {
+			return "  query: " + query + "\n" + diff
+		}
+	}
+	return ""
+}
+
+// open - строки результата ожидания e; мок запоминает их, чтобы проверить закрытие
+func (m *Mock) open(e *Expectation) *rows {
+	r := &rows{
+		columns: e.columns,
+		values:  e.rows,
+		err:     e.rowsErr,
+	}
+
+	m.mu.Lock()
+	e.opened = append(e.opened, r)
+	m.mu.Unlock()
+
+	return r
+}
+
+// copyQuery - CopyFrom как текст запроса для сравнения и сообщений об ошибках
+func copyQuery(table pgx.Identifier, columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = pgx.Identifier{column}.Sanitize()
+	}
+	return "COPY " + table.Sanitize() + " (" + strings.Join(quoted, ", ") + ") FROM STDIN"
+}
+
+func copyRowsDiff(want, got [][]interface{}) string {
+	if len(want) != len(got) {
+		return fmt.Sprintf("  want %d rows, got %d", len(want), len(got))
+	}
+	for i := range got {
+		if diff := match.Args(want[i], got[i]); diff != "" {
+			return fmt.Sprintf("  row %d:\n%s", i, diff)
+		}
+	}
+	return ""
+}
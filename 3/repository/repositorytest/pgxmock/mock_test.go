@@ -0,0 +1,282 @@
+package pgxmock_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/moguchev/postgres/3/repository/repositorytest/pgxmock"
+)
+
+// recorder - testing.TB, который запоминает ошибки мока вместо того, чтобы провалить тест
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Error(args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprint(args...))
+}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestMismatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		expect func(m *pgxmock.Mock)
+		call   func(ctx context.Context, m *pgxmock.Mock) error
+		want   []string // подстроки сообщения об ошибке
+	}{
+		{
+			name: "query text",
+			expect: func(m *pgxmock.Mock) {
+				m.ExpectQuery(`SELECT id FROM groups WHERE id = $1`)
+			},
+			call: func(ctx context.Context, m *pgxmock.Mock) error {
+				var id int64
+				return m.QueryRow(ctx, `SELECT id FROM students WHERE id = $1`, int64(1)).Scan(&id)
+			},
+			want: []string{
+				"unexpected query, want #0 query",
+				"  want: SELECT id FROM groups WHERE id = $1\n",
+				"  got:  SELECT id FROM students WHERE id = $1\n",
+				"                       ^ first difference at byte 15",
+			},
+		},
+		{
+			name: "args are compared without conversion",
+			expect: func(m *pgxmock.Mock) {
+				m.ExpectExec(`DELETE FROM groups WHERE id = $1 AND name = $2`).WithArgs(int64(1), pgxmock.AnyArg())
+			},
+			call: func(ctx context.Context, m *pgxmock.Mock) error {
+				_, err := m.Exec(ctx, `DELETE FROM groups WHERE id = $1 AND name = $2`, 1, "a")
+				return err
+			},
+			want: []string{
+				"  query: DELETE FROM groups WHERE id = $1 AND name = $2",
+				"  $1: want int64(1), got int(1)",
+			},
+		},
+		{
+			name: "args count",
+			expect: func(m *pgxmock.Mock) {
+				m.ExpectExec(`DELETE FROM groups WHERE id = $1`).WithArgs(int64(1))
+			},
+			call: func(ctx context.Context, m *pgxmock.Mock) error {
+				_, err := m.Exec(ctx, `DELETE FROM groups WHERE id = $1`)
+				return err
+			},
+			want: []string{
+				"  want args: [1]\n  got args:  []",
+			},
+		},
+		{
+			name: "kind",
+			expect: func(m *pgxmock.Mock) {
+				m.ExpectExec(`DELETE FROM groups`)
+			},
+			call: func(ctx context.Context, m *pgxmock.Mock) error {
+				rows, err := m.Query(ctx, `DELETE FROM groups`)
+				if err == nil {
+					rows.Close()
+				}
+				return err
+			},
+			want: []string{
+				"unexpected query, want #0 exec",
+				`  want exec "DELETE FROM groups"`,
+			},
+		},
+		{
+			name: "copy table",
+			expect: func(m *pgxmock.Mock) {
+				m.ExpectCopyFrom(pgx.Identifier{"groups"}, []string{"name"})
+			},
+			call: func(ctx context.Context, m *pgxmock.Mock) error {
+				_, err := m.CopyFrom(ctx, pgx.Identifier{"students"}, []string{"name"}, pgx.CopyFromRows(nil))
+				return err
+			},
+			want: []string{
+				`  want: COPY "groups" ("name") FROM STDIN`,
+				`  got:  COPY "students" ("name") FROM STDIN`,
+			},
+		},
+		{
+			name: "copy rows",
+			expect: func(m *pgxmock.Mock) {
+				m.ExpectCopyFrom(pgx.Identifier{"groups"}, []string{"name"}).WithRows([]interface{}{"first"}, []interface{}{"second"})
+			},
+			call: func(ctx context.Context, m *pgxmock.Mock) error {
+				_, err := m.CopyFrom(ctx, pgx.Identifier{"groups"}, []string{"name"},
+					pgx.CopyFromRows([][]interface{}{{"first"}, {"third"}}))
+				return err
+			},
+			want: []string{
+				"pgxmock: unexpected copy rows:\n  row 1:\n  $1: want \"second\", got \"third\"",
+			},
+		},
+		{
+			name: "tx options",
+			expect: func(m *pgxmock.Mock) {
+				m.ExpectBegin().WithTxOptions(pgx.TxOptions{IsoLevel: pgx.Serializable})
+			},
+			call: func(ctx context.Context, m *pgxmock.Mock) error {
+				_, err := m.BeginTx(ctx, pgx.TxOptions{})
+				return err
+			},
+			want: []string{
+				"unexpected begin, want #0 begin",
+				"  options:\n",
+				`IsoLevel:"serializable"`,
+			},
+		},
+		{
+			name: "batch size",
+			expect: func(m *pgxmock.Mock) {
+				m.ExpectBatch().Result().WillReturnRowsAffected(1)
+			},
+			call: func(ctx context.Context, m *pgxmock.Mock) error {
+				b := &pgx.Batch{}
+				b.Queue(`DELETE FROM groups WHERE id = $1`, int64(1))
+				b.Queue(`DELETE FROM groups WHERE id = $1`, int64(2))
+				return m.SendBatch(ctx, b).Close()
+			},
+			want: []string{
+				"pgxmock: batch of 2 queries, want 1",
+			},
+		},
+		{
+			name:   "no expectations left",
+			expect: func(m *pgxmock.Mock) {},
+			call: func(ctx context.Context, m *pgxmock.Mock) error {
+				_, err := m.Exec(ctx, `DELETE FROM groups WHERE id = $1`, int64(1))
+				return err
+			},
+			want: []string{
+				"unexpected exec (all 0 expectations were met):\n  got:  DELETE FROM groups WHERE id = $1\n  args: [1]",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{TB: t}
+			m := pgxmock.New(r)
+			tt.expect(m)
+
+			err := tt.call(context.Background(), m)
+			if err == nil {
+				t.Fatal("call error = nil, want mismatch error")
+			}
+			if len(r.errors) == 0 {
+				t.Fatal("mock did not report the mismatch to the test")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(r.errors[0], want) {
+					t.Errorf("mismatch error:\n%s\nwant it to contain:\n%s", r.errors[0], want)
+				}
+			}
+			if r.errors[0] != err.Error() {
+				t.Errorf("caller error = %q, want the reported one %q", err, r.errors[0])
+			}
+		})
+	}
+}
+
+func TestExpectationsWereMet(t *testing.T) {
+	r := &recorder{TB: t}
+	m := pgxmock.New(r)
+	m.ExpectQuery(`SELECT id FROM groups`).
+		WillReturnRows([]string{"id"}, []interface{}{int64(1)}, []interface{}{int64(2)})
+	m.ExpectBatch().Result().WillReturnRowsAffected(1)
+	m.ExpectExec(`DELETE FROM groups`)
+
+	ctx := context.Background()
+	rows, err := m.Query(ctx, `SELECT id FROM groups`)
+	if err != nil {
+		t.Fatalf("Query error = %v", err)
+	}
+	rows.Next() // строки прочитаны не до конца и не закрыты
+
+	b := &pgx.Batch{}
+	b.Queue(`DELETE FROM groups WHERE id = $1`, int64(1))
+	results := m.SendBatch(ctx, b) // результаты пакета не закрыты
+
+	err = m.ExpectationsWereMet()
+	if err == nil {
+		t.Fatal("ExpectationsWereMet error = nil, want unclosed rows and batch results and a missing call")
+	}
+	for _, want := range []string{
+		`#0 query "SELECT id FROM groups": rows were not closed`,
+		`#1 batch: batch results were not closed`,
+		`#2 exec "DELETE FROM groups": not called`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("ExpectationsWereMet error:\n%s\nwant it to contain:\n%s", err, want)
+		}
+	}
+
+	rows.Close()
+	if _, err = results.Exec(); err != nil {
+		t.Fatalf("batch Exec error = %v", err)
+	}
+	if err = results.Close(); err != nil {
+		t.Fatalf("batch Close error = %v", err)
+	}
+	if _, err = m.Exec(ctx, `DELETE FROM groups`); err != nil {
+		t.Fatalf("Exec error = %v", err)
+	}
+	if err = m.ExpectationsWereMet(); err != nil {
+		t.Fatalf("ExpectationsWereMet error = %v", err)
+	}
+	if len(r.errors) != 0 {
+		t.Fatalf("mock reported errors: %v", r.errors)
+	}
+}
+
+func TestTx(t *testing.T) {
+	errDB := errors.New("db error")
+
+	m := pgxmock.New(t)
+	m.ExpectBegin().WithTxOptions(pgx.TxOptions{IsoLevel: pgx.Serializable})
+	m.ExpectExec(`UPDATE groups SET name = $2 WHERE id = $1`).WithArgs(int64(1), "first").WillReturnRowsAffected(1)
+	m.ExpectBegin() // точка сохранения
+	m.ExpectExec(`DELETE FROM groups`).WillReturnError(errDB)
+	m.ExpectRollback()
+	m.ExpectCommit()
+
+	ctx := context.Background()
+	tx, err := m.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable})
+	if err != nil {
+		t.Fatalf("BeginTx error = %v", err)
+	}
+	tag, err := tx.Exec(ctx, `UPDATE groups SET name = $2 WHERE id = $1`, int64(1), "first")
+	if err != nil || tag.RowsAffected() != 1 || !tag.Update() {
+		t.Fatalf("Exec = %q, %v, want UPDATE 1", tag, err)
+	}
+
+	err = tx.BeginFunc(ctx, func(savepoint pgx.Tx) error {
+		_, err := savepoint.Exec(ctx, `DELETE FROM groups`)
+		return err
+	})
+	if !errors.Is(err, errDB) {
+		t.Fatalf("BeginFunc error = %v, want %v", err, errDB)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		t.Fatalf("Commit error = %v", err)
+	}
+	// закрытая транзакция не обращается к сценарию
+	if err = tx.Rollback(ctx); !errors.Is(err, pgx.ErrTxClosed) {
+		t.Fatalf("Rollback after Commit error = %v, want %v", err, pgx.ErrTxClosed)
+	}
+	if _, err = tx.Exec(ctx, `DELETE FROM groups`); !errors.Is(err, pgx.ErrTxClosed) {
+		t.Fatalf("Exec after Commit error = %v, want %v", err, pgx.ErrTxClosed)
+	}
+}
@@ -0,0 +1,319 @@
+package pgxmock
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+// проверка удовлетворению интерфейсов pgx
+var (
+	_ pgx.Rows         = (*rows)(nil)
+	_ pgx.Row          = (*row)(nil)
+	_ pgx.BatchResults = (*batchResults)(nil)
+)
+
+// rows - строки результата ожидания. Как и у pgx, они закрываются сами, когда Next вернул false
+// или Scan завершился ошибкой
+type rows struct {
+	columns []string
+	values  [][]interface{}
+
+	err error // WillReturnRowsError
+
+	mu      sync.Mutex
+	next    int // номер следующей строки; текущая - next-1
+	done    bool
+	scanErr error
+	closed  bool
+}
+
+func (r *rows) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closed = true
+}
+
+func (r *rows) isClosed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.closed
+}
+
+// Err - ошибка WillReturnRowsError (только после того, как прочитаны все строки) или ошибка Scan
+func (r *rows) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch {
+	case r.scanErr != nil:
+		return r.scanErr
+	case r.done:
+		return r.err
+	}
+	return nil
+}
+
+func (r *rows) CommandTag() pgconn.CommandTag {
+	return pgconn.CommandTag(fmt.Sprintf("SELECT %d", len(r.values)))
+}
+
+func (r *rows) FieldDescriptions() []pgproto3.FieldDescription {
+	fields := make([]pgproto3.FieldDescription, len(r.columns))
+	for i, column := range r.columns {
+		fields[i] = pgproto3.FieldDescription{Name: []byte(column)}
+	}
+	return fields
+}
+
+func (r *rows) Next() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed || r.next >= len(r.values) {
+		r.done = r.done || r.next >= len(r.values)
+		r.closed = true
+		return false
+	}
+	r.next++
+	return true
+}
+
+func (r *rows) Scan(dest ...interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err := r.scan(dest)
+	if err != nil {
+		r.scanErr = err
+		r.closed = true
+	}
+	return err
+}
+
+func (r *rows) scan(dest []interface{}) error {
+	if r.closed || r.next == 0 {
+		return errors.New("pgxmock: Scan called without a successful Next")
+	}
+	values := r.values[r.next-1]
+	if len(dest) != len(values) {
+		return fmt.Errorf("pgxmock: number of field descriptions must equal number of destinations, got %d and %d", len(values), len(dest))
+	}
+	for i := range dest {
+		if err := assign(dest[i], values[i]); err != nil {
+			return fmt.Errorf("can't scan into dest[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (r *rows) Values() ([]interface{}, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed || r.next == 0 {
+		return nil, errors.New("pgxmock: Values called without a successful Next")
+	}
+	return append([]interface{}(nil), r.values[r.next-1]...), nil
+}
+
+func (r *rows) RawValues() [][]byte {
+	return nil // у мока нет представления значений в протоколе
+}
+
+// row - результат QueryRow, как у pgx: первая строка или pgx.ErrNoRows
+type row struct {
+	rows pgx.Rows
+	err  error
+}
+
+func (r *row) Scan(dest ...interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	defer r.rows.Close()
+
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+		return pgx.ErrNoRows
+	}
+	if err := r.rows.Scan(dest...); err != nil {
+		return err
+	}
+	r.rows.Close()
+	return r.rows.Err()
+}
+
+// batchResults - результаты запросов пакета по порядку
+type batchResults struct {
+	mock    *Mock
+	results []*Expectation
+	err     error
+
+	mu     sync.Mutex
+	next   int
+	closed bool
+}
+
+// result - ожидание следующего запроса пакета
+func (b *batchResults) result() (*Expectation, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case b.err != nil:
+		return nil, b.err
+	case b.closed:
+		return nil, errors.New("batch already closed")
+	case b.next >= len(b.results):
+		return nil, errors.New("no result")
+	}
+	e := b.results[b.next]
+	b.next++
+	return e, e.err
+}
+
+func (b *batchResults) Exec() (pgconn.CommandTag, error) {
+	e, err := b.result()
+	if err != nil {
+		return nil, err
+	}
+	return e.tag, nil
+}
+
+func (b *batchResults) Query() (pgx.Rows, error) {
+	e, err := b.result()
+	if err != nil {
+		return nil, err
+	}
+	return b.mock.open(e), nil
+}
+
+func (b *batchResults) QueryRow() pgx.Row {
+	rows, err := b.Query()
+	return &row{rows: rows, err: err}
+}
+
+func (b *batchResults) QueryFunc(scans []interface{}, f func(pgx.QueryFuncRow) error) (pgconn.CommandTag, error) {
+	rows, err := b.Query()
+	if err != nil {
+		return nil, err
+	}
+	return queryFunc(rows, scans, f)
+}
+
+func (b *batchResults) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	return b.err
+}
+
+func (b *batchResults) isClosed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.closed
+}
+
+// queryFunc - QueryFunc поверх строк: сканирует каждую в scans, вызывает f и закрывает rows
+func queryFunc(rows pgx.Rows, scans []interface{}, f func(pgx.QueryFuncRow) error) (pgconn.CommandTag, error) {
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := rows.Scan(scans...); err != nil {
+			return nil, err
+		}
+		if err := f(rows); err != nil {
+			return nil, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return rows.CommandTag(), nil
+}
+
+// assign - записывает значение строки src в dest, как Scan: sql.Scanner, присваиваемый тип,
+// указатель (nil для NULL) или целое число другого размера с проверкой переполнения
+func assign(dest, src interface{}) error {
+	if scanner, ok := dest.(sql.Scanner); ok {
+		return scanner.Scan(src)
+	}
+
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Ptr || dv.IsNil() {
+		return fmt.Errorf("destination %T is not a non-nil pointer", dest)
+	}
+	dv = dv.Elem()
+
+	if src == nil {
+		switch dv.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+			dv.Set(reflect.Zero(dv.Type()))
+			return nil
+		}
+		return fmt.Errorf("cannot scan NULL into %T", dest)
+	}
+
+	sv := reflect.ValueOf(src)
+	switch {
+	case sv.Type().AssignableTo(dv.Type()):
+		dv.Set(sv)
+		return nil
+	case dv.Kind() == reflect.Ptr:
+		p := reflect.New(dv.Type().Elem())
+		if err := assign(p.Interface(), src); err != nil {
+			return err
+		}
+		dv.Set(p)
+		return nil
+	}
+
+	switch {
+	case isInt(sv.Kind()) && isInt(dv.Kind()):
+		if dv.OverflowInt(sv.Int()) {
+			return fmt.Errorf("%d overflows %s", sv.Int(), dv.Type())
+		}
+		dv.SetInt(sv.Int())
+		return nil
+	case isInt(sv.Kind()) && isUint(dv.Kind()):
+		if sv.Int() < 0 || dv.OverflowUint(uint64(sv.Int())) {
+			return fmt.Errorf("%d overflows %s", sv.Int(), dv.Type())
+		}
+		dv.SetUint(uint64(sv.Int()))
+		return nil
+	case isUint(sv.Kind()) && isUint(dv.Kind()):
+		if dv.OverflowUint(sv.Uint()) {
+			return fmt.Errorf("%d overflows %s", sv.Uint(), dv.Type())
+		}
+		dv.SetUint(sv.Uint())
+		return nil
+	case isUint(sv.Kind()) && isInt(dv.Kind()):
+		if sv.Uint() > 1<<63-1 || dv.OverflowInt(int64(sv.Uint())) {
+			return fmt.Errorf("%d overflows %s", sv.Uint(), dv.Type())
+		}
+		dv.SetInt(int64(sv.Uint()))
+		return nil
+	}
+	return fmt.Errorf("cannot scan %T into %T", src, dest)
+}
+
+func isInt(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Int64
+}
+
+func isUint(k reflect.Kind) bool {
+	return k >= reflect.Uint && k <= reflect.Uint64
+}
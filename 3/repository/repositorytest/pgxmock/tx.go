@@ -0,0 +1,141 @@
+package pgxmock
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// проверка удовлетворению интерфейса pgx.Tx
+var _ pgx.Tx = (*tx)(nil)
+
+// tx - транзакция или точка сохранения мока. Запросы идут в сценарий мока, Commit и Rollback - ожидания
+// ExpectCommit и ExpectRollback. Как и у pgx, после Commit или Rollback транзакция закрыта: повторные вызовы
+// и запросы возвращают pgx.ErrTxClosed без обращения к сценарию
+type tx struct {
+	mock *Mock
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// Begin - точка сохранения: ожидание ExpectBegin без опций
+func (t *tx) Begin(ctx context.Context) (pgx.Tx, error) {
+	if err := t.check(); err != nil {
+		return nil, err
+	}
+	if _, err := t.mock.call(ctx, kindBegin, "", nil); err != nil {
+		return nil, err
+	}
+	return &tx{mock: t.mock}, nil
+}
+
+func (t *tx) BeginFunc(ctx context.Context, f func(pgx.Tx) error) error {
+	savepoint, err := t.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = savepoint.Rollback(ctx)
+	}()
+
+	if err = f(savepoint); err != nil {
+		return err
+	}
+	return savepoint.Commit(ctx)
+}
+
+func (t *tx) Commit(ctx context.Context) error {
+	if err := t.close(); err != nil {
+		return err
+	}
+	_, err := t.mock.call(ctx, kindCommit, "", nil)
+	return err
+}
+
+func (t *tx) Rollback(ctx context.Context) error {
+	if err := t.close(); err != nil {
+		return err
+	}
+	_, err := t.mock.call(ctx, kindRollback, "", nil)
+	return err
+}
+
+func (t *tx) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	if err := t.check(); err != nil {
+		return nil, err
+	}
+	return t.mock.Exec(ctx, sql, args...)
+}
+
+func (t *tx) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	if err := t.check(); err != nil {
+		return nil, err
+	}
+	return t.mock.Query(ctx, sql, args...)
+}
+
+func (t *tx) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	rows, err := t.Query(ctx, sql, args...)
+	return &row{rows: rows, err: err}
+}
+
+func (t *tx) QueryFunc(ctx context.Context, sql string, args []interface{}, scans []interface{}, f func(pgx.QueryFuncRow) error) (pgconn.CommandTag, error) {
+	if err := t.check(); err != nil {
+		return nil, err
+	}
+	return t.mock.QueryFunc(ctx, sql, args, scans, f)
+}
+
+func (t *tx) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	if err := t.check(); err != nil {
+		return 0, err
+	}
+	return t.mock.CopyFrom(ctx, tableName, columnNames, rowSrc)
+}
+
+func (t *tx) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	if err := t.check(); err != nil {
+		return &batchResults{err: err}
+	}
+	return t.mock.SendBatch(ctx, b)
+}
+
+func (t *tx) LargeObjects() pgx.LargeObjects {
+	return pgx.LargeObjects{}
+}
+
+// Prepare - у мока нет соединения, поэтому подготовка запросов не поддерживается
+func (t *tx) Prepare(context.Context, string, string) (*pgconn.StatementDescription, error) {
+	return nil, errors.New("pgxmock: Prepare is not supported")
+}
+
+// Conn - nil: у мока нет соединения (transaction.AcquireConn вернет transaction.ErrNoConn)
+func (t *tx) Conn() *pgx.Conn {
+	return nil
+}
+
+func (t *tx) check() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return pgx.ErrTxClosed
+	}
+	return nil
+}
+
+// close - закрывает транзакцию; pgx.ErrTxClosed, если она уже закрыта
+func (t *tx) close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return pgx.ErrTxClosed
+	}
+	t.closed = true
+	return nil
+}
@@ -0,0 +1,68 @@
+package repositorytest
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/moguchev/postgres/config"
+	"github.com/moguchev/postgres/db/migrations"
+)
+
+// Postgres - параметры тестовой БД из окружения (PGHOST, PGPORT, PGUSER, ... или DATABASE_URL, см. config.FromEnv).
+// Без PGHOST и DATABASE_URL тест пропускается: проверки на реальной БД запускаются только там, где она есть,
+// например: PGHOST=localhost go test ./... с БД из docker-compose.yaml.
+// Миграции схемы применяются перед возвратом (уже примененные пропускаются)
+func Postgres(t *testing.T) config.Config {
+	t.Helper()
+
+	if os.Getenv("PGHOST") == "" && os.Getenv("DATABASE_URL") == "" {
+		t.Skip("PGHOST and DATABASE_URL are not set: skipping tests against a real PostgreSQL")
+	}
+
+	cfg, err := config.Load(config.FromEnv(), config.WithApplicationName("repositorytest"))
+	if err != nil {
+		t.Fatalf("config: %v", err)
+	}
+
+	db := OpenDB(t, cfg)
+	m, err := migrations.New(db)
+	if err != nil {
+		t.Fatalf("migrations: %v", err)
+	}
+	if _, err = m.Up(context.Background(), 0); err != nil {
+		t.Fatalf("migrations up: %v", err)
+	}
+
+	return cfg
+}
+
+// OpenDB - *sql.DB (lib/pq) к cfg, закрывается по окончании теста
+func OpenDB(t *testing.T, cfg config.Config) *sql.DB {
+	t.Helper()
+
+	db, err := cfg.OpenDB()
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err = db.PingContext(context.Background()); err != nil {
+		t.Fatalf("ping db: %v", err)
+	}
+	return db
+}
+
+// NewPool - *pgxpool.Pool к cfg, закрывается по окончании теста
+func NewPool(t *testing.T, cfg config.Config) *pgxpool.Pool {
+	t.Helper()
+
+	pool, err := config.NewPool(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("new pool: %v", err)
+	}
+	t.Cleanup(pool.Close)
+	return pool
+}
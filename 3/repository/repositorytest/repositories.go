@@ -0,0 +1,218 @@
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/moguchev/postgres/3/models"
+	"github.com/moguchev/postgres/3/repository"
+)
+
+// Repositories - репозитории одной реализации, связанные между собой, и TxManager того же драйвера,
+// переданный репозиториям (WithTxManager)
+type Repositories struct {
+	Students repository.StudentsRepository
+	Groups   repository.GroupsRepository
+	Tx       repository.TxManager
+}
+
+// RepositoriesFactory - создает проверяемые репозитории; таблицы могут быть не пустыми
+type RepositoriesFactory func(t *testing.T) Repositories
+
+var errRollback = errors.New("rollback")
+
+// RunRepositoriesSuite - проверки, которым нужны несколько репозиториев реализации: фильтр StudentsFilter.GroupID
+// (ListStudents с Offset и с курсором, StreamStudents) и методы StudentsRepository внутри TxManager.WithinTx
+// (видимость до фиксации, откат, BulkInsertStudents во внешней транзакции)
+func RunRepositoriesSuite(t *testing.T, newRepos RepositoriesFactory) {
+	t.Run("GroupID filter", func(t *testing.T) {
+		repos := newRepos(t)
+		ctx := context.Background()
+
+		prefix := uniquePrefix()
+		students := createStudents(t, repos.Students, prefix, 5)
+		groupID, err := repos.Groups.CreateGroup(ctx, models.Group{Name: prefix})
+		if err != nil {
+			t.Fatalf("CreateGroup error = %v", err)
+		}
+		otherID, err := repos.Groups.CreateGroup(ctx, models.Group{Name: prefix + "other"})
+		if err != nil {
+			t.Fatalf("CreateGroup error = %v", err)
+		}
+		for i, student := range students {
+			group := groupID
+			if i%2 == 1 {
+				group = otherID
+			}
+			if i == 4 {
+				continue // студент без группы
+			}
+			if err = repos.Groups.AddStudentToGroup(ctx, student.ID, group); err != nil {
+				t.Fatalf("AddStudentToGroup error = %v", err)
+			}
+		}
+		members := []models.Student{students[0], students[2]}
+
+		// внешний ключ students_groups.student_id
+		if err = repos.Students.DeleteStudent(ctx, students[0].ID); !errors.Is(err, models.ErrForeignKey) {
+			t.Fatalf("DeleteStudent of a group member error = %v, want %v", err, models.ErrForeignKey)
+		}
+
+		filter := repository.StudentsFilter{NamePrefix: prefix, GroupID: groupID}
+		page, err := repos.Students.ListStudents(ctx, filter)
+		if err != nil {
+			t.Fatalf("ListStudents error = %v", err)
+		}
+		requireStudents(t, "ListStudents(group)", page.Students, members)
+		if page.Total != int64(len(members)) {
+			t.Fatalf("ListStudents(group) total = %d, want %d", page.Total, len(members))
+		}
+		requireStudents(t, "ListStudents(group, offset)", listByOffset(t, repos.Students, filter, 1), members)
+		requireStudents(t, "ListStudents(group, cursor)", listByCursor(t, repos.Students, filter, 1), members)
+
+		// курсор фильтра по группе - позиция только в этом фильтре
+		filter.Limit = 1
+		first, err := repos.Students.ListStudents(ctx, filter)
+		if err != nil {
+			t.Fatalf("ListStudents error = %v", err)
+		}
+		other := repository.StudentsFilter{NamePrefix: prefix, GroupID: otherID, Limit: 1, Cursor: first.NextCursor}
+		if _, err = repos.Students.ListStudents(ctx, other); !errors.Is(err, models.ErrValidation) {
+			t.Fatalf("ListStudents(cursor of another group) error = %v, want %v", err, models.ErrValidation)
+		}
+
+		var streamed []models.Student
+		if err = repos.Students.StreamStudents(ctx, repository.StudentsFilter{NamePrefix: prefix, GroupID: otherID}, func(s models.Student) error {
+			streamed = append(streamed, s)
+			return nil
+		}); err != nil {
+			t.Fatalf("StreamStudents error = %v", err)
+		}
+		requireStudents(t, "StreamStudents(group)", streamed, []models.Student{students[1], students[3]})
+
+		page, err = repos.Students.ListStudents(ctx, repository.StudentsFilter{NamePrefix: prefix, GroupID: missingID})
+		if err != nil {
+			t.Fatalf("ListStudents error = %v", err)
+		}
+		if len(page.Students) != 0 || page.Total != 0 {
+			t.Fatalf("ListStudents(missing group) = %+v, want no students", page)
+		}
+	})
+
+	t.Run("WithinTx commit", func(t *testing.T) {
+		repos := newRepos(t)
+		ctx := context.Background()
+
+		student := models.Student{FirstName: uniquePrefix(), LastName: "Tx", Age: 20}
+		err := repos.Tx.WithinTx(ctx, repository.TxOptions{}, func(ctx context.Context) (err error) {
+			if student.ID, err = repos.Students.CreateStudent(ctx, student); err != nil {
+				return err
+			}
+			student.Age = 21
+			if err = repos.Students.UpdateStudent(ctx, student); err != nil {
+				return err
+			}
+
+			got, err := repos.Students.GetStudent(ctx, student.ID)
+			if err != nil || got != student {
+				t.Errorf("GetStudent inside tx = %+v, %v, want %+v", got, err, student)
+			}
+			// незафиксированные изменения не видны вне транзакции
+			if _, err := repos.Students.GetStudent(context.Background(), student.ID); !errors.Is(err, models.ErrNotFound) {
+				t.Errorf("GetStudent outside tx error = %v, want %v", err, models.ErrNotFound)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("WithinTx error = %v", err)
+		}
+
+		requireStudent(t, repos.Students, student)
+	})
+
+	t.Run("WithinTx rollback", func(t *testing.T) {
+		repos := newRepos(t)
+		ctx := context.Background()
+
+		prefix := uniquePrefix()
+		existing := createStudents(t, repos.Students, prefix, 1)[0]
+		groupID, err := repos.Groups.CreateGroup(ctx, models.Group{Name: prefix})
+		if err != nil {
+			t.Fatalf("CreateGroup error = %v", err)
+		}
+
+		err = repos.Tx.WithinTx(ctx, repository.TxOptions{}, func(ctx context.Context) error {
+			if _, err := repos.Students.CreateStudent(ctx, models.Student{FirstName: prefix + "created", LastName: "Tx", Age: 20}); err != nil {
+				return err
+			}
+			if _, err := repos.Students.BulkInsertStudents(ctx, []models.Student{
+				{FirstName: prefix + "bulk0", LastName: "Tx", Age: 20},
+				{FirstName: prefix + "bulk1", LastName: "Tx", Age: 21},
+			}); err != nil {
+				return err
+			}
+			if err := repos.Groups.AddStudentToGroup(ctx, existing.ID, groupID); err != nil {
+				return err
+			}
+
+			page, err := repos.Students.ListStudents(ctx, repository.StudentsFilter{NamePrefix: prefix})
+			if err != nil || page.Total != 4 {
+				t.Errorf("ListStudents inside tx = %+v, %v, want 4 students", page, err)
+			}
+			page, err = repos.Students.ListStudents(ctx, repository.StudentsFilter{NamePrefix: prefix, GroupID: groupID})
+			if err != nil || page.Total != 1 {
+				t.Errorf("ListStudents(group) inside tx = %+v, %v, want 1 student", page, err)
+			}
+			return errRollback
+		})
+		if !errors.Is(err, errRollback) {
+			t.Fatalf("WithinTx error = %v, want %v", err, errRollback)
+		}
+
+		page, err := repos.Students.ListStudents(ctx, repository.StudentsFilter{NamePrefix: prefix})
+		if err != nil {
+			t.Fatalf("ListStudents error = %v", err)
+		}
+		requireStudents(t, "ListStudents after rollback", page.Students, []models.Student{existing})
+		if _, err = repos.Groups.GetStudentGroup(ctx, existing.ID); !errors.Is(err, models.ErrNotFound) {
+			t.Fatalf("GetStudentGroup after rollback error = %v, want %v", err, models.ErrNotFound)
+		}
+	})
+
+	t.Run("BulkInsertStudents error inside WithinTx", func(t *testing.T) {
+		repos := newRepos(t)
+		ctx := context.Background()
+
+		prefix := uniquePrefix()
+		var created models.Student
+		err := repos.Tx.WithinTx(ctx, repository.TxOptions{}, func(ctx context.Context) (err error) {
+			created = models.Student{FirstName: prefix + "0", LastName: "Tx", Age: 20}
+			if created.ID, err = repos.Students.CreateStudent(ctx, created); err != nil {
+				return err
+			}
+
+			// ошибка строки откатывает только загрузку (точка сохранения), внешняя транзакция продолжается
+			_, err = repos.Students.BulkInsertStudents(ctx, []models.Student{
+				{FirstName: prefix + "1", LastName: "Tx", Age: 20},
+				{FirstName: prefix + "2", LastName: "Tx", Age: 0},
+			}, repository.WithBatchSize(1))
+			var rowErr *models.RowError
+			if !errors.As(err, &rowErr) || rowErr.Row != 1 {
+				t.Errorf("BulkInsertStudents error = %v, want *models.RowError for row 1", err)
+			}
+
+			created.Age = 22
+			return repos.Students.UpdateStudent(ctx, created)
+		})
+		if err != nil {
+			t.Fatalf("WithinTx error = %v", err)
+		}
+
+		page, err := repos.Students.ListStudents(ctx, repository.StudentsFilter{NamePrefix: prefix})
+		if err != nil {
+			t.Fatalf("ListStudents error = %v", err)
+		}
+		requireStudents(t, "ListStudents after commit", page.Students, []models.Student{created})
+	})
+}
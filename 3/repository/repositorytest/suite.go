@@ -0,0 +1,664 @@
+// Package repositorytest - поведенческий контракт репозиториев, общий для всех реализаций:
+// каждая реализация запускает один и тот же набор проверок из своих тестов.
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/moguchev/postgres/3/models"
+	"github.com/moguchev/postgres/3/repository"
+)
+
+// StudentsFactory - создает проверяемый репозиторий; таблица students может быть не пустой,
+// проверки создают нужные им данные сами
+type StudentsFactory func(t *testing.T) repository.StudentsRepository
+
+// missingID - id, которого заведомо нет в таблице (serial - int4)
+const missingID = math.MaxInt32
+
+// largeIDs - размер большого списка id в GetStudents: больше лимита параметров одного запроса PostgreSQL (65535),
+// поэтому ловит реализацию через IN ($1, $2, ...) вместо массива
+const largeIDs = 70000
+
+// RunStudentsRepositorySuite - полный набор проверок repository.StudentsRepository, который запускает каждая реализация
+// (database/sql, pgx, в памяти и будущие): отсутствие студентов, ошибки, порядок строк, пустые входные данные,
+// повторяющиеся и большие списки id для каждого метода интерфейса.
+// Проверки не рассчитывают на пустую таблицу: каждая создает студентов с уникальным префиксом имени
+// и ищет только их. Фильтр по группе (StudentsFilter.GroupID) и транзакции проверяет RunRepositoriesSuite
+func RunStudentsRepositorySuite(t *testing.T, newRepo StudentsFactory) {
+	t.Run("GetStudent not found", func(t *testing.T) {
+		repo := newRepo(t)
+
+		_, err := repo.GetStudent(context.Background(), missingID)
+		if !errors.Is(err, models.ErrNotFound) {
+			t.Fatalf("GetStudent(%d) error = %v, want %v", missingID, err, models.ErrNotFound)
+		}
+	})
+
+	t.Run("GetStudents empty", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+
+		for name, ids := range map[string][]int64{
+			"no ids":      nil,
+			"missing ids": {missingID, missingID - 1},
+		} {
+			students, err := repo.GetStudents(ctx, ids...)
+			if err != nil {
+				t.Fatalf("GetStudents(%s) error = %v", name, err)
+			}
+			if students == nil || len(students) != 0 {
+				t.Fatalf("GetStudents(%s) = %#v, want empty non-nil slice", name, students)
+			}
+		}
+	})
+
+	t.Run("GetStudents duplicate ids", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+
+		student := models.Student{FirstName: "Contract", LastName: "Duplicate", Age: 20}
+		id, err := repo.CreateStudent(ctx, student)
+		if err != nil {
+			t.Fatalf("CreateStudent error = %v", err)
+		}
+		student.ID = id
+
+		students, err := repo.GetStudents(ctx, id, id, missingID, id)
+		if err != nil {
+			t.Fatalf("GetStudents error = %v", err)
+		}
+		if len(students) != 1 || students[0] != student {
+			t.Fatalf("GetStudents(%d, %d, %d, %d) = %+v, want exactly [%+v]", id, id, missingID, id, students, student)
+		}
+	})
+
+	t.Run("Update and Delete not found", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+
+		err := repo.UpdateStudent(ctx, models.Student{ID: missingID, FirstName: "A", LastName: "B", Age: 1})
+		if !errors.Is(err, models.ErrNotFound) {
+			t.Fatalf("UpdateStudent(%d) error = %v, want %v", missingID, err, models.ErrNotFound)
+		}
+		if err = repo.DeleteStudent(ctx, missingID); !errors.Is(err, models.ErrNotFound) {
+			t.Fatalf("DeleteStudent(%d) error = %v, want %v", missingID, err, models.ErrNotFound)
+		}
+	})
+
+	t.Run("CreateStudent and GetStudent", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+
+		student := models.Student{FirstName: "Suite", LastName: "Create", Age: 20}
+		id, err := repo.CreateStudent(ctx, student)
+		if err != nil {
+			t.Fatalf("CreateStudent error = %v", err)
+		}
+		if id <= 0 {
+			t.Fatalf("CreateStudent id = %d, want > 0", id)
+		}
+		student.ID = id
+
+		got, err := repo.GetStudent(ctx, id)
+		if err != nil {
+			t.Fatalf("GetStudent(%d) error = %v", id, err)
+		}
+		if got != student {
+			t.Fatalf("GetStudent(%d) = %+v, want %+v", id, got, student)
+		}
+
+		next, err := repo.CreateStudent(ctx, student)
+		if err != nil {
+			t.Fatalf("CreateStudent error = %v", err)
+		}
+		if next <= id {
+			t.Fatalf("CreateStudent ids = %d then %d, want increasing", id, next)
+		}
+	})
+
+	t.Run("CreateStudent check violation", func(t *testing.T) {
+		repo := newRepo(t)
+
+		_, err := repo.CreateStudent(context.Background(), models.Student{FirstName: "Suite", LastName: "Check", Age: 0})
+		requireCheckViolation(t, "CreateStudent", err)
+	})
+
+	t.Run("UpdateStudent", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+
+		id, err := repo.CreateStudent(ctx, models.Student{FirstName: "Suite", LastName: "Update", Age: 20})
+		if err != nil {
+			t.Fatalf("CreateStudent error = %v", err)
+		}
+
+		updated := models.Student{ID: id, FirstName: "Suite", LastName: "Updated", Age: 21}
+		if err = repo.UpdateStudent(ctx, updated); err != nil {
+			t.Fatalf("UpdateStudent error = %v", err)
+		}
+		requireStudent(t, repo, updated)
+
+		err = repo.UpdateStudent(ctx, models.Student{ID: id, FirstName: "Suite", LastName: "Updated", Age: 0})
+		requireCheckViolation(t, "UpdateStudent", err)
+		requireStudent(t, repo, updated) // ошибка не меняет строку
+	})
+
+	t.Run("DeleteStudent", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+
+		id, err := repo.CreateStudent(ctx, models.Student{FirstName: "Suite", LastName: "Delete", Age: 20})
+		if err != nil {
+			t.Fatalf("CreateStudent error = %v", err)
+		}
+
+		if err = repo.DeleteStudent(ctx, id); err != nil {
+			t.Fatalf("DeleteStudent(%d) error = %v", id, err)
+		}
+		if _, err = repo.GetStudent(ctx, id); !errors.Is(err, models.ErrNotFound) {
+			t.Fatalf("GetStudent(%d) after delete error = %v, want %v", id, err, models.ErrNotFound)
+		}
+		if err = repo.DeleteStudent(ctx, id); !errors.Is(err, models.ErrNotFound) {
+			t.Fatalf("second DeleteStudent(%d) error = %v, want %v", id, err, models.ErrNotFound)
+		}
+	})
+
+	t.Run("UpsertStudent", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+
+		student := models.Student{FirstName: "Suite", LastName: "Upsert", Age: 20}
+		id, err := repo.UpsertStudent(ctx, student)
+		if err != nil {
+			t.Fatalf("UpsertStudent(id 0) error = %v", err)
+		}
+		if id <= 0 {
+			t.Fatalf("UpsertStudent(id 0) id = %d, want > 0", id)
+		}
+		student.ID = id
+		requireStudent(t, repo, student)
+
+		student.LastName, student.Age = "Upserted", 21
+		if got, err := repo.UpsertStudent(ctx, student); err != nil || got != id {
+			t.Fatalf("UpsertStudent(id %d) = %d, %v, want %d, nil", id, got, err, id)
+		}
+		requireStudent(t, repo, student)
+
+		student.Age = 0
+		_, err = repo.UpsertStudent(ctx, student)
+		requireCheckViolation(t, "UpsertStudent", err)
+
+		// явный id, которого еще нет: следующий CreateStudent не должен получить его же
+		explicit := models.Student{ID: id + 1000, FirstName: "Suite", LastName: "Explicit", Age: 22}
+		if got, err := repo.UpsertStudent(ctx, explicit); err != nil || got != explicit.ID {
+			t.Fatalf("UpsertStudent(id %d) = %d, %v, want %d, nil", explicit.ID, got, err, explicit.ID)
+		}
+		requireStudent(t, repo, explicit)
+		created, err := repo.CreateStudent(ctx, models.Student{FirstName: "Suite", LastName: "After", Age: 23})
+		if err != nil {
+			t.Fatalf("CreateStudent after UpsertStudent(id %d) error = %v", explicit.ID, err)
+		}
+		if created == explicit.ID {
+			t.Fatalf("CreateStudent after UpsertStudent(id %d) reused the id", explicit.ID)
+		}
+	})
+
+	t.Run("GetStudents order", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+
+		students := createStudents(t, repo, uniquePrefix(), 3)
+
+		ids := []int64{students[2].ID, missingID, students[0].ID, students[1].ID}
+		got, err := repo.GetStudents(ctx, ids...)
+		if err != nil {
+			t.Fatalf("GetStudents error = %v", err)
+		}
+		requireStudents(t, fmt.Sprintf("GetStudents(%v)", ids), got, students)
+	})
+
+	t.Run("GetStudents large id list", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+
+		students := createStudents(t, repo, uniquePrefix(), 3)
+
+		ids := make([]int64, 0, largeIDs)
+		for i := 0; len(ids) < largeIDs-len(students); i++ {
+			ids = append(ids, missingID-int64(i))
+		}
+		for _, student := range students {
+			ids = append(ids, student.ID)
+		}
+
+		got, err := repo.GetStudents(ctx, ids...)
+		if err != nil {
+			t.Fatalf("GetStudents(%d ids) error = %v", len(ids), err)
+		}
+		requireStudents(t, fmt.Sprintf("GetStudents(%d ids)", len(ids)), got, students)
+	})
+
+	t.Run("ListStudents empty", func(t *testing.T) {
+		repo := newRepo(t)
+
+		page, err := repo.ListStudents(context.Background(), repository.StudentsFilter{NamePrefix: uniquePrefix()})
+		if err != nil {
+			t.Fatalf("ListStudents error = %v", err)
+		}
+		if page.Students == nil || len(page.Students) != 0 || page.Total != 0 || page.NextCursor != "" {
+			t.Fatalf("ListStudents = %#v, want empty page with non-nil slice", page)
+		}
+	})
+
+	t.Run("ListStudents and StreamStudents order", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+
+		prefix := uniquePrefix()
+		students := createSortFixture(t, repo, prefix)
+
+		for _, sortBy := range []repository.StudentsSortField{
+			repository.SortByID,
+			repository.SortByFirstName,
+			repository.SortByLastName,
+			repository.SortByAge,
+		} {
+			for _, direction := range []repository.SortDirection{repository.SortAsc, repository.SortDesc} {
+				filter := repository.StudentsFilter{NamePrefix: prefix, SortBy: sortBy, SortDirection: direction}
+				want := sortedStudents(students, sortBy, direction)
+				name := fmt.Sprintf("sort %d direction %d", sortBy, direction)
+
+				page, err := repo.ListStudents(ctx, filter)
+				if err != nil {
+					t.Fatalf("ListStudents(%s) error = %v", name, err)
+				}
+				if page.Total != int64(len(want)) || page.NextCursor != "" {
+					t.Fatalf("ListStudents(%s) total = %d, next cursor = %q, want %d and no cursor", name, page.Total, page.NextCursor, len(want))
+				}
+				requireStudents(t, "ListStudents("+name+")", page.Students, want)
+
+				requireStudents(t, "ListStudents("+name+") by offset", listByOffset(t, repo, filter, 2), want)
+				requireStudents(t, "ListStudents("+name+") by cursor", listByCursor(t, repo, filter, 2), want)
+
+				var streamed []models.Student
+				if err = repo.StreamStudents(ctx, filter, func(student models.Student) error {
+					streamed = append(streamed, student)
+					return nil
+				}); err != nil {
+					t.Fatalf("StreamStudents(%s) error = %v", name, err)
+				}
+				requireStudents(t, "StreamStudents("+name+")", streamed, want)
+			}
+		}
+	})
+
+	t.Run("ListStudents filter", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+
+		prefix := uniquePrefix()
+		students := createSortFixture(t, repo, prefix)
+
+		var want []models.Student
+		for _, student := range students {
+			if student.Age >= 21 && student.Age <= 22 {
+				want = append(want, student)
+			}
+		}
+
+		// префикс - без учета регистра
+		filter := repository.StudentsFilter{NamePrefix: strings.ToUpper(prefix), MinAge: 21, MaxAge: 22}
+		page, err := repo.ListStudents(ctx, filter)
+		if err != nil {
+			t.Fatalf("ListStudents error = %v", err)
+		}
+		if page.Total != int64(len(want)) {
+			t.Fatalf("ListStudents total = %d, want %d", page.Total, len(want))
+		}
+		requireStudents(t, "ListStudents(age 21-22)", page.Students, want)
+
+		// offset за концом выборки - пустая страница, но общее количество
+		filter.Offset = uint64(len(want))
+		if page, err = repo.ListStudents(ctx, filter); err != nil {
+			t.Fatalf("ListStudents(offset %d) error = %v", filter.Offset, err)
+		}
+		if len(page.Students) != 0 || page.Total != int64(len(want)) {
+			t.Fatalf("ListStudents(offset %d) = %+v, want no students and total %d", filter.Offset, page, len(want))
+		}
+	})
+
+	t.Run("ListStudents invalid filter", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+
+		prefix := uniquePrefix()
+		createStudents(t, repo, prefix, 2)
+
+		// курсор, выданный для другой сортировки
+		page, err := repo.ListStudents(ctx, repository.StudentsFilter{NamePrefix: prefix, Limit: 1})
+		if err != nil {
+			t.Fatalf("ListStudents error = %v", err)
+		}
+		if page.NextCursor == "" {
+			t.Fatalf("ListStudents(limit 1) of 2 students returned no next cursor")
+		}
+
+		for name, filter := range map[string]repository.StudentsFilter{
+			"unknown sort field":     {NamePrefix: prefix, SortBy: 100},
+			"unknown sort direction": {NamePrefix: prefix, SortDirection: 100},
+			"malformed cursor":       {NamePrefix: prefix, Cursor: "not a cursor"},
+			"cursor of other filter": {NamePrefix: prefix, SortBy: repository.SortByAge, Cursor: page.NextCursor},
+		} {
+			if _, err = repo.ListStudents(ctx, filter); !errors.Is(err, models.ErrValidation) {
+				t.Fatalf("ListStudents(%s) error = %v, want %v", name, err, models.ErrValidation)
+			}
+		}
+
+		err = repo.StreamStudents(ctx, repository.StudentsFilter{SortBy: 100}, func(models.Student) error { return nil })
+		if !errors.Is(err, models.ErrValidation) {
+			t.Fatalf("StreamStudents(unknown sort field) error = %v, want %v", err, models.ErrValidation)
+		}
+	})
+
+	t.Run("StreamStudents errors", func(t *testing.T) {
+		repo := newRepo(t)
+
+		prefix := uniquePrefix()
+		createStudents(t, repo, prefix, 3)
+		filter := repository.StudentsFilter{NamePrefix: prefix}
+
+		// ошибка fn прекращает чтение и возвращается как есть
+		errStop := errors.New("stop")
+		calls := 0
+		err := repo.StreamStudents(context.Background(), filter, func(models.Student) error {
+			calls++
+			return errStop
+		})
+		if err != errStop || calls != 1 {
+			t.Fatalf("StreamStudents with failing fn = %v after %d calls, want %v after 1 call", err, calls, errStop)
+		}
+
+		// отмена ctx прерывает чтение с ошибкой ctx.Err()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		calls = 0
+		err = repo.StreamStudents(ctx, filter, func(models.Student) error {
+			calls++
+			cancel()
+			return nil
+		})
+		if !errors.Is(err, context.Canceled) || calls != 1 {
+			t.Fatalf("StreamStudents with canceled ctx = %v after %d calls, want %v after 1 call", err, calls, context.Canceled)
+		}
+	})
+
+	t.Run("BulkInsertStudents", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+
+		if n, err := repo.BulkInsertStudents(ctx, nil); n != 0 || err != nil {
+			t.Fatalf("BulkInsertStudents(nil) = %d, %v, want 0, nil", n, err)
+		}
+
+		prefix := uniquePrefix()
+		students := make([]models.Student, 3)
+		for i := range students {
+			students[i] = models.Student{FirstName: prefix + strconv.Itoa(i), LastName: "Bulk", Age: uint(20 + i)}
+		}
+
+		var progress []repository.BulkProgress
+		n, err := repo.BulkInsertStudents(ctx, students,
+			repository.WithBatchSize(2),
+			repository.WithProgress(func(p repository.BulkProgress) {
+				progress = append(progress, p)
+			}),
+		)
+		if err != nil || n != int64(len(students)) {
+			t.Fatalf("BulkInsertStudents = %d, %v, want %d, nil", n, err, len(students))
+		}
+		wantProgress := []repository.BulkProgress{
+			{Batch: 1, BatchRows: 2, Inserted: 2, Total: 3},
+			{Batch: 2, BatchRows: 1, Inserted: 3, Total: 3},
+		}
+		if fmt.Sprint(progress) != fmt.Sprint(wantProgress) {
+			t.Fatalf("BulkInsertStudents progress = %+v, want %+v", progress, wantProgress)
+		}
+
+		page, err := repo.ListStudents(ctx, repository.StudentsFilter{NamePrefix: prefix, SortBy: repository.SortByFirstName})
+		if err != nil {
+			t.Fatalf("ListStudents error = %v", err)
+		}
+		if len(page.Students) != len(students) {
+			t.Fatalf("ListStudents after BulkInsertStudents = %+v, want %d students", page.Students, len(students))
+		}
+		for i, student := range page.Students {
+			students[i].ID = student.ID
+		}
+		requireStudents(t, "ListStudents after BulkInsertStudents", page.Students, students)
+	})
+
+	t.Run("BulkInsertStudents keep ids", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+
+		first := createStudents(t, repo, uniquePrefix(), 1)[0]
+
+		if _, err := repo.BulkInsertStudents(ctx, []models.Student{{FirstName: "Suite", LastName: "Bulk", Age: 20}}, repository.WithKeepIDs()); !errors.Is(err, models.ErrValidation) {
+			t.Fatalf("BulkInsertStudents(WithKeepIDs) without id error = %v, want %v", err, models.ErrValidation)
+		}
+
+		students := []models.Student{
+			{ID: first.ID + 2000, FirstName: "Suite", LastName: "Bulk", Age: 20},
+			{ID: first.ID + 2001, FirstName: "Suite", LastName: "Bulk", Age: 21},
+		}
+		if n, err := repo.BulkInsertStudents(ctx, students, repository.WithKeepIDs()); err != nil || n != int64(len(students)) {
+			t.Fatalf("BulkInsertStudents(WithKeepIDs) = %d, %v, want %d, nil", n, err, len(students))
+		}
+		for _, student := range students {
+			requireStudent(t, repo, student)
+		}
+
+		// последовательность сдвинута за загруженные id
+		created, err := repo.CreateStudent(ctx, models.Student{FirstName: "Suite", LastName: "After", Age: 23})
+		if err != nil {
+			t.Fatalf("CreateStudent after BulkInsertStudents(WithKeepIDs) error = %v", err)
+		}
+		if created <= students[1].ID {
+			t.Fatalf("CreateStudent after BulkInsertStudents(WithKeepIDs) = %d, want id greater than %d", created, students[1].ID)
+		}
+	})
+
+	t.Run("BulkInsertStudents row error", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+
+		prefix := uniquePrefix()
+		students := []models.Student{
+			{FirstName: prefix + "0", LastName: "Bulk", Age: 20},
+			{FirstName: prefix + "1", LastName: "Bulk", Age: 21},
+			{FirstName: prefix + "2", LastName: "Bulk", Age: 0},
+		}
+
+		n, err := repo.BulkInsertStudents(ctx, students, repository.WithBatchSize(2))
+		var rowErr *models.RowError
+		if !errors.As(err, &rowErr) || rowErr.Row != 2 || !errors.Is(err, models.ErrValidation) {
+			t.Fatalf("BulkInsertStudents error = %v, want *models.RowError for row 2 wrapping %v", err, models.ErrValidation)
+		}
+		if n != 0 {
+			t.Fatalf("BulkInsertStudents inserted = %d, want 0", n)
+		}
+
+		// либо все, либо ни одного: первый пакет тоже откатывается
+		page, err := repo.ListStudents(ctx, repository.StudentsFilter{NamePrefix: prefix})
+		if err != nil {
+			t.Fatalf("ListStudents error = %v", err)
+		}
+		if page.Total != 0 {
+			t.Fatalf("ListStudents after failed BulkInsertStudents = %+v, want no students", page.Students)
+		}
+	})
+}
+
+var prefixSeq uint32
+
+// uniquePrefix - префикс имени, которого нет у студентов других проверок и прошлых запусков
+// (только строчные буквы и цифры, чтобы порядок не зависел от collation БД)
+func uniquePrefix() string {
+	seq := atomic.AddUint32(&prefixSeq, 1)
+	return "suite" + strconv.FormatInt(time.Now().UnixNano(), 36) + strconv.FormatUint(uint64(seq), 36) + "x"
+}
+
+// createStudents - n студентов с именами prefix+"0", prefix+"1", ... в порядке возрастания id
+func createStudents(t *testing.T, repo repository.StudentsRepository, prefix string, n int) []models.Student {
+	t.Helper()
+
+	students := make([]models.Student, n)
+	for i := range students {
+		students[i] = models.Student{FirstName: prefix + strconv.Itoa(i), LastName: "Suite", Age: 20}
+		id, err := repo.CreateStudent(context.Background(), students[i])
+		if err != nil {
+			t.Fatalf("CreateStudent error = %v", err)
+		}
+		students[i].ID = id
+	}
+	return students
+}
+
+// createSortFixture - студенты, порядок которых различается для каждого поля сортировки,
+// с повторяющимися значениями имени и возраста (проверка дополнительной сортировки по id)
+func createSortFixture(t *testing.T, repo repository.StudentsRepository, prefix string) []models.Student {
+	t.Helper()
+
+	students := []models.Student{
+		{FirstName: prefix + "c", LastName: prefix + "a", Age: 22},
+		{FirstName: prefix + "a", LastName: prefix + "c", Age: 20},
+		{FirstName: prefix + "b", LastName: prefix + "b", Age: 22},
+		{FirstName: prefix + "d", LastName: prefix + "d", Age: 21},
+		{FirstName: prefix + "a", LastName: prefix + "e", Age: 23},
+	}
+	for i := range students {
+		id, err := repo.CreateStudent(context.Background(), students[i])
+		if err != nil {
+			t.Fatalf("CreateStudent error = %v", err)
+		}
+		students[i].ID = id
+	}
+	return students
+}
+
+// sortedStudents - ожидаемый порядок ListStudents: поле сортировки, затем id в том же направлении
+func sortedStudents(students []models.Student, sortBy repository.StudentsSortField, direction repository.SortDirection) []models.Student {
+	sorted := append([]models.Student(nil), students...)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if direction == repository.SortDesc {
+			a, b = b, a
+		}
+		switch {
+		case sortBy == repository.SortByFirstName && a.FirstName != b.FirstName:
+			return a.FirstName < b.FirstName
+		case sortBy == repository.SortByLastName && a.LastName != b.LastName:
+			return a.LastName < b.LastName
+		case sortBy == repository.SortByAge && a.Age != b.Age:
+			return a.Age < b.Age
+		}
+		return a.ID < b.ID
+	})
+	return sorted
+}
+
+// listByOffset - все студенты фильтра страницами по limit через Offset
+func listByOffset(t *testing.T, repo repository.StudentsRepository, filter repository.StudentsFilter, limit uint64) []models.Student {
+	t.Helper()
+
+	filter.Limit = limit
+	var students []models.Student
+	for {
+		page, err := repo.ListStudents(context.Background(), filter)
+		if err != nil {
+			t.Fatalf("ListStudents(offset %d) error = %v", filter.Offset, err)
+		}
+		students = append(students, page.Students...)
+		if uint64(len(page.Students)) < limit {
+			return students
+		}
+		filter.Offset += limit
+	}
+}
+
+// listByCursor - все студенты фильтра страницами по limit через NextCursor
+func listByCursor(t *testing.T, repo repository.StudentsRepository, filter repository.StudentsFilter, limit uint64) []models.Student {
+	t.Helper()
+
+	filter.Limit = limit
+	var students []models.Student
+	var total int64
+	for pages := 0; ; pages++ {
+		page, err := repo.ListStudents(context.Background(), filter)
+		if err != nil {
+			t.Fatalf("ListStudents(page %d) error = %v", pages, err)
+		}
+		if uint64(len(page.Students)) > limit {
+			t.Fatalf("ListStudents(page %d) returned %d students, limit %d", pages, len(page.Students), limit)
+		}
+		// общее количество - только на первой странице
+		if pages == 0 {
+			total = page.Total
+		} else if page.Total != repository.TotalUnknown {
+			t.Fatalf("ListStudents(page %d) total = %d, want TotalUnknown on cursor pages", pages, page.Total)
+		}
+		students = append(students, page.Students...)
+		if page.NextCursor == "" {
+			return students
+		}
+		if pages > int(total) {
+			t.Fatalf("ListStudents returns next cursor after %d pages of %d students", pages, total)
+		}
+		filter.Cursor = page.NextCursor
+	}
+}
+
+func requireStudent(t *testing.T, repo repository.StudentsRepository, want models.Student) {
+	t.Helper()
+
+	got, err := repo.GetStudent(context.Background(), want.ID)
+	if err != nil {
+		t.Fatalf("GetStudent(%d) error = %v", want.ID, err)
+	}
+	if got != want {
+		t.Fatalf("GetStudent(%d) = %+v, want %+v", want.ID, got, want)
+	}
+}
+
+func requireStudents(t *testing.T, call string, got, want []models.Student) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("%s = %+v, want %+v", call, got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("%s = %+v, want %+v", call, got, want)
+		}
+	}
+}
+
+// requireCheckViolation - нарушение CHECK (age > 0): ошибка валидации поля age поверх ошибки БД
+func requireCheckViolation(t *testing.T, call string, err error) {
+	t.Helper()
+
+	var verr *models.ValidationError
+	if !errors.As(err, &verr) || verr.Field != "age" || !errors.Is(err, models.ErrCheckViolation) {
+		t.Fatalf("%s error = %v, want *models.ValidationError for age wrapping %v", call, err, models.ErrCheckViolation)
+	}
+}
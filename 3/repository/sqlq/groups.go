@@ -0,0 +1,103 @@
+package sqlq
+
+import (
+	"strings"
+
+	"github.com/moguchev/postgres/3/repository"
+)
+
+const (
+	GetGroup = `
+	SELECT id, COALESCE(name, '')
+	FROM groups
+	WHERE id = $1`
+
+	GetGroups = `
+	SELECT id, COALESCE(name, '')
+	FROM groups
+	ORDER BY id`
+
+	// если id не задан - берем следующий из последовательности serial колонки; явный id больше выданных
+	// последовательностью сдвигает ее (как UpsertStudent)
+	CreateGroup = `
+	WITH created AS (
+	    INSERT INTO groups (id, name)
+	    VALUES (COALESCE(NULLIF($1, 0), nextval(pg_get_serial_sequence('groups', 'id'))), $2)
+	    RETURNING id
+	)
+	SELECT COALESCE(CASE
+	           WHEN id > COALESCE(pg_sequence_last_value(pg_get_serial_sequence('groups', 'id')), 0)
+	           THEN setval(pg_get_serial_sequence('groups', 'id'), id)
+	       END, id)
+	FROM created`
+
+	UpdateGroup = `
+	UPDATE groups
+	SET name = $2
+	WHERE id = $1`
+
+	DeleteGroup = `
+	DELETE FROM groups
+	WHERE id = $1`
+
+	AddStudentToGroup = `
+	INSERT INTO students_groups (student_id, group_id)
+	VALUES ($1, $2)`
+
+	RemoveStudentFromGroup = `
+	DELETE FROM students_groups
+	WHERE student_id = $1 AND group_id = $2`
+
+	ListGroupMembers = `
+	SELECT s.id, s.first_name, s.last_name, s.age
+	FROM students s
+	JOIN students_groups sg ON sg.student_id = s.id
+	WHERE sg.group_id = $1
+	ORDER BY s.id`
+
+	StreamMemberships = `
+	SELECT student_id, group_id
+	FROM students_groups
+	ORDER BY group_id, student_id`
+
+	GetStudentGroup = `
+	SELECT g.id, COALESCE(g.name, '')
+	FROM groups g
+	JOIN students_groups sg ON sg.group_id = g.id
+	WHERE sg.student_id = $1`
+)
+
+// CopyGroupsColumns - колонки, которые BulkInsertGroups загружает через COPY
+var CopyGroupsColumns = []string{"name"}
+
+// CopyGroupsColumnsWithID - колонки COPY для BulkInsertGroups с repository.WithKeepIDs
+var CopyGroupsColumnsWithID = []string{"id", "name"}
+
+// CopyMembershipsColumns - колонки, которые BulkInsertMemberships загружает через COPY
+var CopyMembershipsColumns = []string{"student_id", "group_id"}
+
+// AdvanceGroupsSequence - как AdvanceStudentsSequence для groups.id
+const AdvanceGroupsSequence = `
+	SELECT setval(pg_get_serial_sequence('groups', 'id'), max(id))
+	FROM groups
+	HAVING max(id) > COALESCE(pg_sequence_last_value(pg_get_serial_sequence('groups', 'id')), 0)`
+
+// GroupsStatements - все запросы репозитория для Validate
+func GroupsStatements() []repository.Statement {
+	return []repository.Statement{
+		{Name: "GetGroup", SQL: GetGroup},
+		{Name: "GetGroups", SQL: GetGroups},
+		{Name: "CreateGroup", SQL: CreateGroup},
+		{Name: "UpdateGroup", SQL: UpdateGroup},
+		{Name: "DeleteGroup", SQL: DeleteGroup},
+		// COPY не подготавливается, поэтому проверяем только существование колонок
+		{Name: "BulkInsertGroups", SQL: "SELECT " + strings.Join(CopyGroupsColumnsWithID, ", ") + " FROM groups"},
+		{Name: "BulkInsertGroups (advance sequence)", SQL: AdvanceGroupsSequence},
+		{Name: "AddStudentToGroup", SQL: AddStudentToGroup},
+		{Name: "RemoveStudentFromGroup", SQL: RemoveStudentFromGroup},
+		{Name: "BulkInsertMemberships", SQL: "SELECT " + strings.Join(CopyMembershipsColumns, ", ") + " FROM students_groups"},
+		{Name: "ListGroupMembers", SQL: ListGroupMembers},
+		{Name: "StreamMemberships", SQL: StreamMemberships},
+		{Name: "GetStudentGroup", SQL: GetStudentGroup},
+	}
+}
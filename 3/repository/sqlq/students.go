@@ -0,0 +1,238 @@
+// Package sqlq - тексты SQL запросов репозиториев и построители динамических запросов (фильтр, сортировка, keyset),
+// общие для реализаций database/sql и pgx: обе отправляют одни и те же запросы, поэтому они не расходятся
+package sqlq
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/moguchev/postgres/3/models"
+	"github.com/moguchev/postgres/3/repository"
+)
+
+const (
+	GetStudent = `
+	SELECT id, first_name, last_name, age 
+	FROM students
+	WHERE id = $1`
+
+	GetStudents = `
+	SELECT id, first_name, last_name, age 
+	FROM students
+	WHERE id = ANY($1)
+	ORDER BY id`
+
+	CreateStudent = `
+	INSERT INTO students (first_name, last_name, age)
+	VALUES ($1, $2, $3)
+	RETURNING id`
+
+	UpdateStudent = `
+	UPDATE students
+	SET first_name = $2, last_name = $3, age = $4
+	WHERE id = $1`
+
+	DeleteStudent = `
+	DELETE FROM students
+	WHERE id = $1`
+
+	// если id не задан - берем следующий из последовательности serial колонки.
+	// Явный id больше последнего выданного последовательностью сдвигает ее (setval возвращает id),
+	// иначе следующий CreateStudent получил бы этот id из последовательности и нарушил первичный ключ
+	UpsertStudent = `
+	WITH upserted AS (
+	    INSERT INTO students (id, first_name, last_name, age)
+	    VALUES (COALESCE(NULLIF($1, 0), nextval(pg_get_serial_sequence('students', 'id'))), $2, $3, $4)
+	    ON CONFLICT (id) DO UPDATE
+	    SET first_name = EXCLUDED.first_name,
+	        last_name  = EXCLUDED.last_name,
+	        age        = EXCLUDED.age
+	    RETURNING id
+	)
+	SELECT COALESCE(CASE
+	           WHEN id > COALESCE(pg_sequence_last_value(pg_get_serial_sequence('students', 'id')), 0)
+	           THEN setval(pg_get_serial_sequence('students', 'id'), id)
+	       END, id)
+	FROM upserted`
+
+	CountStudents = `
+	SELECT count(*)
+	FROM students s` + studentsFilterCondition
+)
+
+// studentsFilterCondition - условие ListStudents; незаданное (нулевое) поле фильтра отключает соответствующую часть условия,
+// поэтому текст запроса не зависит от фильтра, а все значения передаются только параметрами
+const studentsFilterCondition = `
+	WHERE ($1 = '' OR starts_with(lower(s.first_name), lower($1)) OR starts_with(lower(s.last_name), lower($1)))
+	  AND ($2 = 0 OR s.age >= $2)
+	  AND ($3 = 0 OR s.age <= $3)
+	  AND ($4 = 0 OR EXISTS (
+	      SELECT 1
+	      FROM students_groups sg
+	      WHERE sg.student_id = s.id AND sg.group_id = $4))`
+
+// studentsSortColumns - допустимые поля сортировки ListStudents
+var studentsSortColumns = map[repository.StudentsSortField]string{
+	repository.SortByID:        "s.id",
+	repository.SortByFirstName: "s.first_name",
+	repository.SortByLastName:  "s.last_name",
+	repository.SortByAge:       "s.age",
+}
+
+// studentsOrderBy - выражение ORDER BY для фильтра; id добавляется последним, чтобы порядок страниц был детерминированным
+func studentsOrderBy(filter repository.StudentsFilter) (string, error) {
+	column, ok := studentsSortColumns[filter.SortBy]
+	if !ok {
+		return "", &models.ValidationError{Field: "sort_by", Reason: "unknown sort field"}
+	}
+
+	var direction string
+	switch filter.SortDirection {
+	case repository.SortAsc:
+		direction = " ASC"
+	case repository.SortDesc:
+		direction = " DESC"
+	default:
+		return "", &models.ValidationError{Field: "sort_direction", Reason: "unknown sort direction"}
+	}
+
+	if filter.SortBy == repository.SortByID {
+		return column + direction, nil
+	}
+	return column + direction + ", s.id" + direction, nil
+}
+
+// studentsKeysetCondition - условие "строго после курсора" в порядке сортировки фильтра;
+// firstParam - номер первого параметра со значениями курсора
+func studentsKeysetCondition(filter repository.StudentsFilter, firstParam int) string {
+	op := " > "
+	if filter.SortDirection == repository.SortDesc {
+		op = " < "
+	}
+
+	if filter.SortBy == repository.SortByID {
+		return "s.id" + op + "$" + strconv.Itoa(firstParam)
+	}
+	// сравнение строк (a, b) > (c, d) совпадает с порядком ORDER BY a, b при одинаковом направлении
+	return "(" + studentsSortColumns[filter.SortBy] + ", s.id)" + op + "($" + strconv.Itoa(firstParam) + ", $" + strconv.Itoa(firstParam+1) + ")"
+}
+
+// CopyStudentsColumns - колонки, которые BulkInsertStudents загружает через COPY
+var CopyStudentsColumns = []string{"first_name", "last_name", "age"}
+
+// CopyStudentsColumnsWithID - колонки COPY для BulkInsertStudents с repository.WithKeepIDs
+var CopyStudentsColumnsWithID = []string{"id", "first_name", "last_name", "age"}
+
+// AdvanceStudentsSequence - после COPY с явными id сдвигает последовательность students.id
+// за наибольший id таблицы, если он больше последнего выданного (HAVING отсекает setval в остальных случаях)
+const AdvanceStudentsSequence = `
+	SELECT setval(pg_get_serial_sequence('students', 'id'), max(id))
+	FROM students
+	HAVING max(id) > COALESCE(pg_sequence_last_value(pg_get_serial_sequence('students', 'id')), 0)`
+
+// StudentsPage - запрос страницы ListStudents. Параметры: $1-$4 - фильтр (studentsFilterCondition),
+// затем значения курсора (keyset), LIMIT и OFFSET (только без keyset).
+// ORDER BY нельзя передать параметром, поэтому в текст запроса подставляем только
+// заранее известные константы (studentsSortColumns) и номера параметров
+func StudentsPage(filter repository.StudentsFilter, keyset bool) (string, error) {
+	orderBy, err := studentsOrderBy(filter)
+	if err != nil {
+		return "", err
+	}
+
+	query := `
+	SELECT s.id, s.first_name, s.last_name, s.age
+	FROM students s` + studentsFilterCondition
+
+	const filterParams = 4
+	if keyset {
+		query += "\n\t  AND " + studentsKeysetCondition(filter, filterParams+1)
+		next := filterParams + 3
+		if filter.SortBy == repository.SortByID {
+			next = filterParams + 2
+		}
+		return query + "\n\tORDER BY " + orderBy + "\n\tLIMIT $" + strconv.Itoa(next), nil
+	}
+	return query + "\n\tORDER BY " + orderBy + "\n\tLIMIT $" + strconv.Itoa(filterParams+1) + " OFFSET $" + strconv.Itoa(filterParams+2), nil
+}
+
+// StudentsFilterArgs - значения параметров $1-$4 фильтра (studentsFilterCondition) для CountStudents и StudentsStream
+func StudentsFilterArgs(filter repository.StudentsFilter) []interface{} {
+	return []interface{}{
+		filter.NamePrefix,
+		filter.MinAge,
+		filter.MaxAge,
+		filter.GroupID,
+	}
+}
+
+// StudentsPageArgs - значения параметров StudentsPage в том же порядке: фильтр, значения курсора
+// (если задан filter.Cursor) и limit, затем OFFSET (без курсора)
+func StudentsPageArgs(filter repository.StudentsFilter, cursor repository.StudentsCursor, limit uint64) []interface{} {
+	args := StudentsFilterArgs(filter)
+	if filter.Cursor == "" {
+		return append(args, limit, filter.Offset)
+	}
+	if filter.SortBy == repository.SortByID {
+		args = append(args, cursor.ID)
+	} else {
+		args = append(args, cursor.SortKey(), cursor.ID)
+	}
+	return append(args, limit)
+}
+
+// StudentsStream - запрос StreamStudents, параметры $1-$4 - фильтр (studentsFilterCondition)
+func StudentsStream(filter repository.StudentsFilter) (string, error) {
+	orderBy, err := studentsOrderBy(filter)
+	if err != nil {
+		return "", err
+	}
+
+	return `
+	SELECT s.id, s.first_name, s.last_name, s.age
+	FROM students s` + studentsFilterCondition + `
+	ORDER BY ` + orderBy, nil
+}
+
+// StudentsStatements - все запросы репозитория для Validate, включая каждый вариант динамических запросов
+func StudentsStatements() []repository.Statement {
+	statements := []repository.Statement{
+		{Name: "GetStudent", SQL: GetStudent},
+		{Name: "GetStudents", SQL: GetStudents},
+		{Name: "CreateStudent", SQL: CreateStudent},
+		{Name: "UpdateStudent", SQL: UpdateStudent},
+		{Name: "DeleteStudent", SQL: DeleteStudent},
+		{Name: "UpsertStudent", SQL: UpsertStudent},
+		{Name: "ListStudents (count)", SQL: CountStudents},
+		// COPY не подготавливается, поэтому проверяем только существование колонок
+		{Name: "BulkInsertStudents", SQL: "SELECT " + strings.Join(CopyStudentsColumnsWithID, ", ") + " FROM students"},
+		{Name: "BulkInsertStudents (advance sequence)", SQL: AdvanceStudentsSequence},
+	}
+
+	sortFields := []repository.StudentsSortField{
+		repository.SortByID,
+		repository.SortByFirstName,
+		repository.SortByLastName,
+		repository.SortByAge,
+	}
+	for _, sortBy := range sortFields {
+		for _, direction := range []repository.SortDirection{repository.SortAsc, repository.SortDesc} {
+			filter := repository.StudentsFilter{SortBy: sortBy, SortDirection: direction}
+			name, _ := studentsOrderBy(filter)
+
+			for _, keyset := range []bool{false, true} {
+				query, _ := StudentsPage(filter, keyset)
+				mode := "offset"
+				if keyset {
+					mode = "keyset"
+				}
+				statements = append(statements, repository.Statement{Name: "ListStudents (ORDER BY " + name + ", " + mode + ")", SQL: query})
+			}
+
+			query, _ := StudentsStream(filter)
+			statements = append(statements, repository.Statement{Name: "StreamStudents (ORDER BY " + name + ")", SQL: query})
+		}
+	}
+
+	return statements
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// Statement - SQL запрос репозитория с именем для сообщений об ошибках (обычно имя метода)
+type Statement struct {
+	Name string
+	SQL  string
+}
+
+// Validator - репозиторий, который умеет проверить свои запросы на живой схеме БД.
+// Вызывается при старте сервиса, чтобы опечатки в именах колонок и несовпадения типов
+// обнаруживались сразу, а не при первом вызове метода
+type Validator interface {
+	Validate(ctx context.Context) error
+}
+
+// StatementError - запрос, который не удалось подготовить
+type StatementError struct {
+	Name string
+	Err  error
+}
+
+func (e StatementError) Error() string {
+	return e.Name + ": " + e.Err.Error()
+}
+
+// StatementsError - все запросы, не прошедшие проверку
+type StatementsError []StatementError
+
+func (e StatementsError) Error() string {
+	lines := make([]string, 0, len(e)+1)
+	lines = append(lines, "invalid statements:")
+	for _, se := range e {
+		lines = append(lines, "\t"+se.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ValidateStatements - подготавливает (PREPARE) каждый запрос через prepare и возвращает
+// StatementsError со всеми запросами, которые не удалось подготовить.
+// Проверка прерывается только при отмене ctx
+func ValidateStatements(ctx context.Context, statements []Statement, prepare func(ctx context.Context, query string) error) error {
+	var errs StatementsError
+	for _, statement := range statements {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := prepare(ctx, statement.SQL); err != nil {
+			errs = append(errs, StatementError{Name: statement.Name, Err: err})
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ValidateAll - проверяет запросы всех репозиториев и объединяет их ошибки в один StatementsError
+func ValidateAll(ctx context.Context, validators ...Validator) error {
+	var errs StatementsError
+	for _, v := range validators {
+		err := v.Validate(ctx)
+		if err == nil {
+			continue
+		}
+
+		var serr StatementsError
+		if !errors.As(err, &serr) {
+			return err
+		}
+		errs = append(errs, serr...)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -9,4 +9,90 @@ import (
 type StudentsRepository interface {
 	GetStudent(ctx context.Context, id int64) (models.Student, error)
 	GetStudents(ctx context.Context, ids ...int64) ([]models.Student, error)
+
+	// CreateStudent - создает студента и возвращает сгенерированный id
+	CreateStudent(ctx context.Context, student models.Student) (int64, error)
+	// UpdateStudent - обновляет студента с student.ID, если такого нет - models.ErrNotFound
+	UpdateStudent(ctx context.Context, student models.Student) error
+	// DeleteStudent - удаляет студента, если такого нет - models.ErrNotFound
+	DeleteStudent(ctx context.Context, id int64) error
+	// UpsertStudent - создает студента (если student.ID == 0 или такого id нет) либо обновляет существующего.
+	// Созданный с явным id студент не мешает следующим CreateStudent. Возвращает id студента
+	UpsertStudent(ctx context.Context, student models.Student) (int64, error)
+
+	// ListStudents - страница студентов, удовлетворяющих фильтру, и их общее количество
+	ListStudents(ctx context.Context, filter StudentsFilter) (StudentsPage, error)
+	// StreamStudents - построчно передает в fn всех студентов, удовлетворяющих фильтру, в порядке его сортировки
+	// (Limit, Offset и Cursor игнорируются), не загружая результат в память целиком.
+	// Ошибка fn прекращает чтение и возвращается как есть; отмена ctx прерывает чтение с ошибкой ctx.Err()
+	StreamStudents(ctx context.Context, filter StudentsFilter, fn func(models.Student) error) error
+
+	// BulkInsertStudents - загружает студентов (student.ID игнорируется, если не задан WithKeepIDs) пакетами через COPY в одной транзакции
+	// (или точке сохранения, если вызван внутри repository.TxManager.WithinTx): либо все, либо ни одного.
+	// Возвращает число вставленных строк. Если строка нарушает ограничения таблицы - *models.RowError
+	// с индексом строки во входном срезе
+	BulkInsertStudents(ctx context.Context, students []models.Student, opts ...BulkInsertOption) (int64, error)
+}
+
+// StudentsSortField - поле сортировки в ListStudents
+type StudentsSortField int
+
+const (
+	SortByID StudentsSortField = iota
+	SortByFirstName
+	SortByLastName
+	SortByAge
+)
+
+type SortDirection int
+
+const (
+	SortAsc SortDirection = iota
+	SortDesc
+)
+
+const (
+	DefaultStudentsLimit = 50   // размер страницы, если Limit не задан
+	MaxStudentsLimit     = 1000 // максимальный размер страницы
+)
+
+// TotalUnknown - StudentsPage.Total страницы, запрошенной по курсору
+const TotalUnknown int64 = -1
+
+// StudentsFilter - параметры ListStudents; нулевые значения полей фильтрации означают "без ограничения"
+type StudentsFilter struct {
+	NamePrefix string // префикс имени или фамилии (без учета регистра)
+	MinAge     uint
+	MaxAge     uint
+	GroupID    int64 // только студенты этой группы
+
+	SortBy        StudentsSortField // при равенстве значений дополнительно сортируем по id
+	SortDirection SortDirection
+
+	Limit  uint64 // 0 - DefaultStudentsLimit, не больше MaxStudentsLimit
+	Offset uint64 // игнорируется, если задан Cursor
+	// Cursor - StudentsPage.NextCursor предыдущей страницы (keyset пагинация по (поле сортировки, id)).
+	// В отличие от Offset не замедляется на дальних страницах и не пропускает/не повторяет строки при конкурентных вставках.
+	// Фильтр и сортировка должны совпадать с теми, с которыми был получен курсор
+	Cursor string
+}
+
+// PageLimit - размер страницы с учетом значения по умолчанию и ограничения сверху
+func (f StudentsFilter) PageLimit() uint64 {
+	switch {
+	case f.Limit == 0:
+		return DefaultStudentsLimit
+	case f.Limit > MaxStudentsLimit:
+		return MaxStudentsLimit
+	}
+	return f.Limit
+}
+
+type StudentsPage struct {
+	Students []models.Student
+	// Total - количество студентов, удовлетворяющих фильтру, без учета Limit/Offset.
+	// Считается только на страницах без Cursor, на страницах по курсору - TotalUnknown
+	Total int64
+	// NextCursor - курсор следующей страницы, пустой если это последняя страница
+	NextCursor string
 }
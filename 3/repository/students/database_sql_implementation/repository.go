@@ -3,34 +3,90 @@ package databasesqlimplementation
 import (
 	"context"
 	"database/sql"
-	"errors"
-	"log"
 
 	"github.com/lib/pq"
+	"github.com/moguchev/postgres/3/logger"
 	"github.com/moguchev/postgres/3/models"
 	"github.com/moguchev/postgres/3/repository"
+	"github.com/moguchev/postgres/3/repository/sqlq"
+	transaction "github.com/moguchev/postgres/3/repository/transaction/database_sql_implementation"
 )
 
 // проверка удовлетворению интерфейса repository.StudentsRepository
 var _ repository.StudentsRepository = (*studentsRepository)(nil)
 
+// проверка удовлетворению интерфейса repository.Validator
+var _ repository.Validator = (*studentsRepository)(nil)
+
 type studentsRepository struct {
 	db *sql.DB
+	tx repository.TxManager
+
+	cursors *repository.CursorCodec
+	logger  logger.Logger
+}
+
+// Option - необязательный параметр NewRepository
+type Option func(r *studentsRepository)
+
+// WithLogger - логгер внутренних ошибок (по умолчанию logger.Nop())
+func WithLogger(l logger.Logger) Option {
+	return func(r *studentsRepository) {
+		r.logger = l
+	}
+}
+
+// WithCursorCodec - кодек курсоров keyset пагинации ListStudents (по умолчанию repository.DefaultCursorCodec())
+func WithCursorCodec(codec *repository.CursorCodec) Option {
+	return func(r *studentsRepository) {
+		r.cursors = codec
+	}
+}
+
+// WithTxManager - TxManager приложения, через который BulkInsertStudents открывают транзакцию,
+// если ее нет в ctx (по умолчанию - transaction.NewTxManager(db) с логгером репозитория)
+func WithTxManager(m repository.TxManager) Option {
+	return func(r *studentsRepository) {
+		r.tx = m
+	}
 }
 
-func NewRepository(db *sql.DB /*logger*/) *studentsRepository {
-	return &studentsRepository{
+func NewRepository(db *sql.DB, opts ...Option) *studentsRepository {
+	r := &studentsRepository{
 		db: db,
+
+		cursors: repository.DefaultCursorCodec(),
+		logger:  logger.Nop(),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.tx == nil {
+		r.tx = transaction.NewTxManager(db, transaction.WithLogger(r.logger))
 	}
+	return r
+}
+
+// conn - транзакция из ctx (см. repository.TxManager) или пул соединений, если транзакции нет
+func (r *studentsRepository) conn(ctx context.Context) transaction.Querier {
+	return transaction.GetQuerier(ctx, r.db)
+}
+
+// Validate - подготавливает все запросы репозитория на текущей схеме БД (см. repository.Validator)
+func (r *studentsRepository) Validate(ctx context.Context) error {
+	return repository.ValidateStatements(ctx, sqlq.StudentsStatements(), func(ctx context.Context, query string) error {
+		stmt, err := r.db.PrepareContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		return stmt.Close()
+	})
 }
 
 func (r *studentsRepository) GetStudent(ctx context.Context, id int64) (models.Student, error) {
-	const query = `
-	SELECT id, first_name, last_name, age 
-	FROM students
-	WHERE id = $1`
+	op := logger.Start(r.logger, "get student", logger.Int64("id", id))
 
-	row := r.db.QueryRowContext(ctx, query, id)
+	row := r.conn(ctx).QueryRowContext(ctx, sqlq.GetStudent, id)
 
 	var student models.Student
 	if err := row.Scan(
@@ -39,25 +95,34 @@ func (r *studentsRepository) GetStudent(ctx context.Context, id int64) (models.S
 		&student.LastName,
 		&student.Age,
 	); err != nil {
-		if errors.Is(err, sql.ErrNoRows) { // обязательно обрабатываем(перехватываем) известные нам ошибки уровня БД и отдаем наружу уже обработанные
-			return models.Student{}, models.ErrNotFound
+		// обязательно обрабатываем(перехватываем) известные нам ошибки уровня БД (в т.ч. отсутствие строки) и отдаем наружу уже обработанные
+		if terr := studentsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return models.Student{}, terr
 		}
-		log.Printf("get student %d: database error: %s", id, err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
+		op.Error("database error", err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
 		return models.Student{}, models.ErrInternal
 	}
 
+	op.Done()
 	return student, nil
 }
 
 func (r *studentsRepository) GetStudents(ctx context.Context, ids ...int64) ([]models.Student, error) {
-	const query = `
-	SELECT id, first_name, last_name, age 
-	FROM students
-	WHERE ids = ANY($1)`
+	op := logger.Start(r.logger, "get students", logger.Any("ids", ids))
 
-	rows, err := r.db.QueryContext(ctx, query, pq.Array(ids))
+	if len(ids) == 0 {
+		op.Done()
+		return []models.Student{}, nil
+	}
+
+	rows, err := r.conn(ctx).QueryContext(ctx, sqlq.GetStudents, pq.Array(ids))
 	if err != nil {
-		log.Printf("get students %v: database error: %s", ids, err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
+		if terr := studentsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return nil, terr
+		}
+		op.Error("database error", err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
 		return nil, models.ErrInternal
 	}
 	defer rows.Close()
@@ -71,16 +136,373 @@ func (r *studentsRepository) GetStudents(ctx context.Context, ids ...int64) ([]m
 			&student.LastName,
 			&student.Age,
 		); err != nil {
-			log.Printf("get students %v: scan error: %s", ids, err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
+			op.Error("scan error", err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
 			return nil, models.ErrInternal
 		}
 		students = append(students, student)
 	}
 
 	if err = rows.Err(); err != nil {
-		log.Printf("get students %v: rows error: %s", ids, err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
+		if terr := studentsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return nil, terr
+		}
+		op.Error("rows error", err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
 		return nil, models.ErrInternal
 	}
 
+	op.Done()
 	return students, nil
 }
+
+func (r *studentsRepository) CreateStudent(ctx context.Context, student models.Student) (int64, error) {
+	op := logger.Start(r.logger, "create student", repository.StudentLogFields(student)...)
+
+	var id int64
+	if err := r.conn(ctx).QueryRowContext(ctx, sqlq.CreateStudent,
+		student.FirstName,
+		student.LastName,
+		student.Age,
+	).Scan(&id); err != nil {
+		if terr := studentsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return 0, terr
+		}
+		op.Error("database error", err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
+		return 0, models.ErrInternal
+	}
+
+	op.Done()
+	return id, nil
+}
+
+func (r *studentsRepository) UpdateStudent(ctx context.Context, student models.Student) error {
+	op := logger.Start(r.logger, "update student", repository.StudentLogFields(student)...)
+
+	result, err := r.conn(ctx).ExecContext(ctx, sqlq.UpdateStudent,
+		student.ID,
+		student.FirstName,
+		student.LastName,
+		student.Age,
+	)
+	if err != nil {
+		if terr := studentsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return terr
+		}
+		op.Error("database error", err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
+		return models.ErrInternal
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		if terr := studentsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return terr
+		}
+		op.Error("rows affected error", err)
+		return models.ErrInternal
+	}
+	if affected == 0 {
+		op.Fail(models.ErrNotFound)
+		return models.ErrNotFound
+	}
+
+	op.Done()
+	return nil
+}
+
+func (r *studentsRepository) DeleteStudent(ctx context.Context, id int64) error {
+	op := logger.Start(r.logger, "delete student", logger.Int64("id", id))
+
+	result, err := r.conn(ctx).ExecContext(ctx, sqlq.DeleteStudent, id)
+	if err != nil {
+		if terr := studentsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return terr
+		}
+		op.Error("database error", err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
+		return models.ErrInternal
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		if terr := studentsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return terr
+		}
+		op.Error("rows affected error", err)
+		return models.ErrInternal
+	}
+	if affected == 0 {
+		op.Fail(models.ErrNotFound)
+		return models.ErrNotFound
+	}
+
+	op.Done()
+	return nil
+}
+
+func (r *studentsRepository) UpsertStudent(ctx context.Context, student models.Student) (int64, error) {
+	op := logger.Start(r.logger, "upsert student", repository.StudentLogFields(student)...)
+
+	var id int64
+	if err := r.conn(ctx).QueryRowContext(ctx, sqlq.UpsertStudent,
+		student.ID,
+		student.FirstName,
+		student.LastName,
+		student.Age,
+	).Scan(&id); err != nil {
+		if terr := studentsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return 0, terr
+		}
+		op.Error("database error", err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
+		return 0, models.ErrInternal
+	}
+
+	op.Done()
+	return id, nil
+}
+
+func (r *studentsRepository) ListStudents(ctx context.Context, filter repository.StudentsFilter) (repository.StudentsPage, error) {
+	op := logger.Start(r.logger, "list students", filter.LogFields()...)
+
+	pageQuery, err := sqlq.StudentsPage(filter, filter.Cursor != "")
+	if err != nil {
+		op.Fail(err)
+		return repository.StudentsPage{}, err
+	}
+
+	var cursor repository.StudentsCursor
+	if filter.Cursor != "" {
+		if cursor, err = r.cursors.Decode(filter, filter.Cursor); err != nil {
+			op.Fail(err)
+			return repository.StudentsPage{}, err
+		}
+	}
+
+	// count(*) по всему фильтру - только без курсора: на каждой странице keyset пагинации
+	// он стоил бы столько же, сколько OFFSET, от которого курсор избавляет
+	page := repository.StudentsPage{Total: repository.TotalUnknown}
+	if filter.Cursor == "" {
+		if err = r.conn(ctx).QueryRowContext(ctx, sqlq.CountStudents, sqlq.StudentsFilterArgs(filter)...).Scan(&page.Total); err != nil {
+			if terr := studentsErrors.Translate(err); terr != nil {
+				op.Fail(terr)
+				return repository.StudentsPage{}, terr
+			}
+			op.Error("count error", err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
+			return repository.StudentsPage{}, models.ErrInternal
+		}
+	}
+
+	limit := filter.PageLimit()
+	page.Students = make([]models.Student, 0, limit+1)
+	if page.Total == 0 {
+		op.Done()
+		return page, nil
+	}
+
+	// запрашиваем на одну строку больше, чтобы понять, есть ли следующая страница
+	rows, err := r.conn(ctx).QueryContext(ctx, pageQuery, sqlq.StudentsPageArgs(filter, cursor, limit+1)...)
+	if err != nil {
+		if terr := studentsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return repository.StudentsPage{}, terr
+		}
+		op.Error("database error", err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
+		return repository.StudentsPage{}, models.ErrInternal
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var student models.Student
+		if err = rows.Scan(
+			&student.ID,
+			&student.FirstName,
+			&student.LastName,
+			&student.Age,
+		); err != nil {
+			op.Error("scan error", err)
+			return repository.StudentsPage{}, models.ErrInternal
+		}
+		page.Students = append(page.Students, student)
+	}
+
+	if err = rows.Err(); err != nil {
+		if terr := studentsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return repository.StudentsPage{}, terr
+		}
+		op.Error("rows error", err)
+		return repository.StudentsPage{}, models.ErrInternal
+	}
+
+	// запрашиваем на одну строку больше, чтобы понять, есть ли следующая страница
+	if uint64(len(page.Students)) > limit {
+		page.Students = page.Students[:limit]
+		last := page.Students[len(page.Students)-1]
+		page.NextCursor = r.cursors.Encode(filter, repository.NewStudentsCursor(filter, last))
+	}
+
+	op.Done()
+	return page, nil
+}
+
+func (r *studentsRepository) StreamStudents(ctx context.Context, filter repository.StudentsFilter, fn func(models.Student) error) error {
+	op := logger.Start(r.logger, "stream students", filter.LogFields()...)
+
+	query, err := sqlq.StudentsStream(filter)
+	if err != nil {
+		op.Fail(err)
+		return err
+	}
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, sqlq.StudentsFilterArgs(filter)...)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			op.Fail(ctxErr)
+			return ctxErr
+		}
+		if terr := studentsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return terr
+		}
+		op.Error("database error", err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
+		return models.ErrInternal
+	}
+	defer rows.Close() // закрываем и при ошибке fn, и при отмене контекста
+
+	for rows.Next() {
+		if err = ctx.Err(); err != nil {
+			op.Fail(err)
+			return err
+		}
+
+		var student models.Student
+		if err = rows.Scan(
+			&student.ID,
+			&student.FirstName,
+			&student.LastName,
+			&student.Age,
+		); err != nil {
+			op.Error("scan error", err)
+			return models.ErrInternal
+		}
+
+		if err = fn(student); err != nil {
+			op.Fail(err)
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			op.Fail(ctxErr)
+			return ctxErr
+		}
+		if terr := studentsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return terr
+		}
+		op.Error("rows error", err)
+		return models.ErrInternal
+	}
+
+	op.Done()
+	return nil
+}
+
+func (r *studentsRepository) BulkInsertStudents(ctx context.Context, students []models.Student, opts ...repository.BulkInsertOption) (int64, error) {
+	if len(students) == 0 {
+		return 0, nil
+	}
+	options := repository.NewBulkInsertOptions(opts...)
+	if err := options.CheckIDs(students); err != nil {
+		return 0, err
+	}
+
+	// все пакеты - в одной транзакции (или точке сохранения внешней транзакции)
+	var inserted int64
+	err := r.tx.WithinTx(ctx, repository.TxOptions{}, func(ctx context.Context) error {
+		var err error
+		inserted, err = options.InsertBatches(len(students), func(start, end int) error {
+			return r.copyStudents(ctx, students[start:end], start, options.KeepIDs)
+		})
+		if err != nil {
+			return err
+		}
+		if options.KeepIDs {
+			return r.advanceSequence(ctx)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return inserted, nil
+}
+
+// copyStudents - один пакет через COPY FROM STDIN (pq.CopyIn работает только внутри транзакции)
+func (r *studentsRepository) copyStudents(ctx context.Context, students []models.Student, batchStart int, keepIDs bool) error {
+	op := logger.Start(r.logger, "bulk insert students", logger.Any("batch_start", batchStart), logger.Any("rows", len(students)))
+
+	columns := sqlq.CopyStudentsColumns
+	if keepIDs {
+		columns = sqlq.CopyStudentsColumnsWithID
+	}
+	stmt, err := r.conn(ctx).PrepareContext(ctx, pq.CopyIn("students", columns...))
+	if err != nil {
+		return copyError(op, err, batchStart)
+	}
+	defer stmt.Close()
+
+	for _, student := range students {
+		args := []interface{}{student.FirstName, student.LastName, student.Age}
+		if keepIDs {
+			args = append([]interface{}{student.ID}, args...)
+		}
+		if _, err = stmt.ExecContext(ctx, args...); err != nil {
+			return copyError(op, err, batchStart)
+		}
+	}
+
+	// Exec без аргументов завершает COPY - ошибки данных сервер обычно возвращает именно здесь
+	if _, err = stmt.ExecContext(ctx); err != nil {
+		return copyError(op, err, batchStart)
+	}
+
+	op.Done()
+	return nil
+}
+
+// advanceSequence - после COPY с явными id сдвигает students_id_seq, чтобы CreateStudent не выдал занятый id
+func (r *studentsRepository) advanceSequence(ctx context.Context) error {
+	op := logger.Start(r.logger, "advance students sequence")
+
+	if _, err := r.conn(ctx).ExecContext(ctx, sqlq.AdvanceStudentsSequence); err != nil {
+		if terr := studentsErrors.Translate(err); terr != nil {
+			op.Fail(terr)
+			return terr
+		}
+		op.Error("database error", err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
+		return models.ErrInternal
+	}
+
+	op.Done()
+	return nil
+}
+
+func copyError(op *logger.Op, err error, batchStart int) error {
+	if rerr := studentsErrors.CopyRowError(err, batchStart); rerr != nil {
+		op.Fail(rerr)
+		return rerr
+	}
+	if terr := studentsErrors.Translate(err); terr != nil {
+		op.Fail(terr)
+		return terr
+	}
+	op.Error("database error", err) // логируем внутренние ошибки и НЕ пробрасываем их наверх
+	return models.ErrInternal
+}
@@ -0,0 +1,549 @@
+package databasesqlimplementation
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/moguchev/postgres/3/models"
+	"github.com/moguchev/postgres/3/repository"
+	groups "github.com/moguchev/postgres/3/repository/groups/database_sql_implementation"
+	"github.com/moguchev/postgres/3/repository/repositorytest"
+	"github.com/moguchev/postgres/3/repository/repositorytest/fakesql"
+	"github.com/moguchev/postgres/3/repository/sqlq"
+	transaction "github.com/moguchev/postgres/3/repository/transaction/database_sql_implementation"
+)
+
+func TestStudentsRepository(t *testing.T) {
+	db := repositorytest.OpenDB(t, repositorytest.Postgres(t))
+
+	repositorytest.RunStudentsRepositorySuite(t, func(t *testing.T) repository.StudentsRepository {
+		return NewRepository(db)
+	})
+}
+
+func TestRepositories(t *testing.T) {
+	db := repositorytest.OpenDB(t, repositorytest.Postgres(t))
+
+	repositorytest.RunRepositoriesSuite(t, func(t *testing.T) repositorytest.Repositories {
+		txManager := transaction.NewTxManager(db)
+		return repositorytest.Repositories{
+			Students: NewRepository(db, WithTxManager(txManager)),
+			Groups:   groups.NewRepository(db, groups.WithTxManager(txManager)),
+			Tx:       txManager,
+		}
+	})
+}
+
+var studentColumns = []string{"id", "first_name", "last_name", "age"}
+
+// тексты запросов целиком, а не константы sqlq: тест фиксирует то, что уходит в PostgreSQL
+const (
+	upsertStudentQuery = `WITH upserted AS ( ` +
+		`INSERT INTO students (id, first_name, last_name, age) ` +
+		`VALUES (COALESCE(NULLIF($1, 0), nextval(pg_get_serial_sequence('students', 'id'))), $2, $3, $4) ` +
+		`ON CONFLICT (id) DO UPDATE SET first_name = EXCLUDED.first_name, last_name = EXCLUDED.last_name, age = EXCLUDED.age ` +
+		`RETURNING id ) ` +
+		`SELECT COALESCE(CASE WHEN id > COALESCE(pg_sequence_last_value(pg_get_serial_sequence('students', 'id')), 0) ` +
+		`THEN setval(pg_get_serial_sequence('students', 'id'), id) END, id) FROM upserted`
+
+	filterCondition = `WHERE ($1 = '' OR starts_with(lower(s.first_name), lower($1)) OR starts_with(lower(s.last_name), lower($1))) ` +
+		`AND ($2 = 0 OR s.age >= $2) ` +
+		`AND ($3 = 0 OR s.age <= $3) ` +
+		`AND ($4 = 0 OR EXISTS ( SELECT 1 FROM students_groups sg WHERE sg.student_id = s.id AND sg.group_id = $4))`
+
+	countQuery = `SELECT count(*) FROM students s ` + filterCondition
+	pageQuery  = `SELECT s.id, s.first_name, s.last_name, s.age FROM students s ` + filterCondition
+
+	copyQuery       = `COPY "students" ("first_name", "last_name", "age") FROM STDIN`
+	copyWithIDQuery = `COPY "students" ("id", "first_name", "last_name", "age") FROM STDIN`
+	advanceSequence = `SELECT setval(pg_get_serial_sequence('students', 'id'), max(id)) FROM students ` +
+		`HAVING max(id) > COALESCE(pg_sequence_last_value(pg_get_serial_sequence('students', 'id')), 0)`
+)
+
+var testCursors = repository.NewCursorCodec([]byte("test"))
+
+// after - filter с курсором, указывающим на позицию сразу после last
+func after(filter repository.StudentsFilter, last models.Student) repository.StudentsFilter {
+	filter.Cursor = testCursors.Encode(filter, repository.NewStudentsCursor(filter, last))
+	return filter
+}
+
+func TestGetStudent(t *testing.T) {
+	script := fakesql.NewScript(t)
+	script.ExpectQuery(sqlq.GetStudent).WithArgs(int64(1)).
+		WillReturnRows(studentColumns, []interface{}{1, "Bob", "Brown", 20})
+	script.ExpectQuery(sqlq.GetStudent).WithArgs(int64(2)).
+		WillReturnRows(studentColumns)
+	script.ExpectQuery(sqlq.GetStudent).WithArgs(int64(3)).
+		WillReturnError(errors.New("driver: bad connection state"))
+
+	log := &repositorytest.OpLog{}
+	repo := NewRepository(script.DB(), WithLogger(log))
+	ctx := context.Background()
+
+	student, err := repo.GetStudent(ctx, 1)
+	if err != nil || student != (models.Student{ID: 1, FirstName: "Bob", LastName: "Brown", Age: 20}) {
+		t.Fatalf("GetStudent(1) = %+v, %v", student, err)
+	}
+	if _, err = repo.GetStudent(ctx, 2); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("GetStudent(2) error = %v, want %v", err, models.ErrNotFound)
+	}
+	// неизвестная ошибка драйвера не уходит наверх
+	if _, err = repo.GetStudent(ctx, 3); err != models.ErrInternal {
+		t.Fatalf("GetStudent(3) error = %v, want %v", err, models.ErrInternal)
+	}
+	log.ExpectClosed(t, "get student", "get student", "get student")
+}
+
+func TestGetStudents(t *testing.T) {
+	script := fakesql.NewScript(t)
+	script.ExpectQuery(sqlq.GetStudents).WithArgs(pq.Array([]int64{3, 1})).
+		WillReturnRows(studentColumns, []interface{}{1, "Bob", "Brown", 20}, []interface{}{3, "Harry", "Bell", 19})
+
+	repo := NewRepository(script.DB())
+	ctx := context.Background()
+
+	students, err := repo.GetStudents(ctx, 3, 1)
+	want := []models.Student{{ID: 1, FirstName: "Bob", LastName: "Brown", Age: 20}, {ID: 3, FirstName: "Harry", LastName: "Bell", Age: 19}}
+	if err != nil || !reflect.DeepEqual(students, want) {
+		t.Fatalf("GetStudents = %+v, %v, want %+v", students, err, want)
+	}
+	// без id запроса нет
+	if students, err = repo.GetStudents(ctx); err != nil || students == nil || len(students) != 0 {
+		t.Fatalf("GetStudents() = %#v, %v, want empty slice", students, err)
+	}
+}
+
+func TestUpsertStudent(t *testing.T) {
+	ageCheck := &pq.Error{Code: "23514", Constraint: "students_age_check", Table: "students", Message: "new row violates check constraint"}
+
+	tests := []struct {
+		name    string
+		student models.Student
+		expect  func(e *fakesql.Expectation)
+		wantID  int64
+		wantErr error
+	}{
+		{
+			name:    "id from the sequence",
+			student: models.Student{FirstName: "Bob", LastName: "Brown", Age: 20},
+			expect: func(e *fakesql.Expectation) {
+				e.WithArgs(int64(0), "Bob", "Brown", 20).WillReturnRows([]string{"coalesce"}, []interface{}{4})
+			},
+			wantID: 4,
+		},
+		{
+			name:    "explicit id",
+			student: models.Student{ID: 100, FirstName: "Bob", LastName: "Brown", Age: 20},
+			expect: func(e *fakesql.Expectation) {
+				e.WithArgs(int64(100), "Bob", "Brown", 20).WillReturnRows([]string{"coalesce"}, []interface{}{100})
+			},
+			wantID: 100,
+		},
+		{
+			name:    "age check violation",
+			student: models.Student{ID: 1, FirstName: "Bob", LastName: "Brown"},
+			expect: func(e *fakesql.Expectation) {
+				e.WithArgs(int64(1), "Bob", "Brown", 0).WillReturnError(ageCheck)
+			},
+			wantErr: &models.ValidationError{Field: "age", Reason: "must be greater than 0", Err: &models.DatabaseError{
+				Kind:       models.ErrCheckViolation,
+				Code:       "23514",
+				Constraint: "students_age_check",
+				Table:      "students",
+				Message:    "new row violates check constraint",
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			script := fakesql.NewScript(t)
+			tt.expect(script.ExpectQuery(upsertStudentQuery))
+
+			id, err := NewRepository(script.DB()).UpsertStudent(context.Background(), tt.student)
+			if !reflect.DeepEqual(err, tt.wantErr) {
+				t.Fatalf("UpsertStudent error = %#v, want %#v", err, tt.wantErr)
+			}
+			if id != tt.wantID {
+				t.Fatalf("UpsertStudent = %d, want %d", id, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestUpdateDeleteStudent(t *testing.T) {
+	student := models.Student{ID: 1, FirstName: "Bob", LastName: "Brown", Age: 20}
+
+	tests := []struct {
+		name    string
+		expect  func(s *fakesql.Script)
+		run     func(ctx context.Context, r repository.StudentsRepository) error
+		wantErr error
+	}{
+		{
+			name: "update",
+			expect: func(s *fakesql.Script) {
+				s.ExpectExec(sqlq.UpdateStudent).WithArgs(int64(1), "Bob", "Brown", 20).WillReturnRowsAffected(1)
+			},
+			run: func(ctx context.Context, r repository.StudentsRepository) error {
+				return r.UpdateStudent(ctx, student)
+			},
+		},
+		{
+			name: "update of a missing student",
+			expect: func(s *fakesql.Script) {
+				s.ExpectExec(sqlq.UpdateStudent).WithArgs(int64(1), "Bob", "Brown", 20).WillReturnRowsAffected(0)
+			},
+			run: func(ctx context.Context, r repository.StudentsRepository) error {
+				return r.UpdateStudent(ctx, student)
+			},
+			wantErr: models.ErrNotFound,
+		},
+		{
+			name: "delete",
+			expect: func(s *fakesql.Script) {
+				s.ExpectExec(sqlq.DeleteStudent).WithArgs(int64(1)).WillReturnRowsAffected(1)
+			},
+			run: func(ctx context.Context, r repository.StudentsRepository) error {
+				return r.DeleteStudent(ctx, 1)
+			},
+		},
+		{
+			name: "delete of a missing student",
+			expect: func(s *fakesql.Script) {
+				s.ExpectExec(sqlq.DeleteStudent).WithArgs(int64(1)).WillReturnRowsAffected(0)
+			},
+			run: func(ctx context.Context, r repository.StudentsRepository) error {
+				return r.DeleteStudent(ctx, 1)
+			},
+			wantErr: models.ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			script := fakesql.NewScript(t)
+			tt.expect(script)
+
+			if err := tt.run(context.Background(), NewRepository(script.DB())); err != tt.wantErr {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStudentsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		wantErr error
+	}{
+		{
+			name: "foreign key",
+			err: &pq.Error{Code: "23503", Constraint: "students_groups_student_id_fkey", Table: "students_groups",
+				Message: `update or delete on table "students" violates foreign key constraint`},
+			wantErr: &models.DatabaseError{
+				Kind:       models.ErrForeignKey,
+				Code:       "23503",
+				Constraint: "students_groups_student_id_fkey",
+				Table:      "students_groups",
+				Message:    `update or delete on table "students" violates foreign key constraint`,
+			},
+		},
+		{
+			name:    "unique violation",
+			err:     &pq.Error{Code: "23505", Constraint: "students_pkey", Table: "students", Message: "duplicate key"},
+			wantErr: &models.DatabaseError{Kind: models.ErrConflict, Code: "23505", Constraint: "students_pkey", Table: "students", Message: "duplicate key"},
+		},
+		{
+			name:    "serialization failure",
+			err:     &pq.Error{Code: "40001", Message: "could not serialize access"},
+			wantErr: &models.DatabaseError{Kind: models.ErrSerialization, Code: "40001", Message: "could not serialize access"},
+		},
+		{
+			name:    "deadlock",
+			err:     &pq.Error{Code: "40P01", Message: "deadlock detected"},
+			wantErr: &models.DatabaseError{Kind: models.ErrSerialization, Code: "40P01", Message: "deadlock detected"},
+		},
+		{
+			name:    "statement timeout",
+			err:     &pq.Error{Code: "57014", Message: "canceling statement due to statement timeout"},
+			wantErr: &models.DatabaseError{Kind: models.ErrTimeout, Code: "57014", Message: "canceling statement due to statement timeout"},
+		},
+		{
+			name:    "server shutdown",
+			err:     &pq.Error{Code: "57P01", Message: "terminating connection due to administrator command"},
+			wantErr: &models.DatabaseError{Kind: models.ErrUnavailable, Code: "57P01", Message: "terminating connection due to administrator command"},
+		},
+		{
+			name:    "unknown SQLSTATE",
+			err:     &pq.Error{Code: "42P01", Message: `relation "students" does not exist`},
+			wantErr: models.ErrInternal,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			script := fakesql.NewScript(t)
+			script.ExpectExec(sqlq.DeleteStudent).WithArgs(int64(1)).WillReturnError(tt.err)
+
+			log := &repositorytest.OpLog{}
+			err := NewRepository(script.DB(), WithLogger(log)).DeleteStudent(context.Background(), 1)
+			if !reflect.DeepEqual(err, tt.wantErr) {
+				t.Fatalf("DeleteStudent error = %#v, want %#v", err, tt.wantErr)
+			}
+			log.ExpectClosed(t, "delete student")
+		})
+	}
+}
+
+func TestListStudents(t *testing.T) {
+	bob := models.Student{ID: 5, FirstName: "Bob", LastName: "Brown", Age: 20}
+	will := models.Student{ID: 6, FirstName: "Will", LastName: "Williams", Age: 21}
+	bobRow := []interface{}{5, "Bob", "Brown", 20}
+	willRow := []interface{}{6, "Will", "Williams", 21}
+
+	filtered := repository.StudentsFilter{
+		NamePrefix:    "b",
+		MinAge:        18,
+		MaxAge:        30,
+		GroupID:       7,
+		SortBy:        repository.SortByFirstName,
+		SortDirection: repository.SortDesc,
+		Limit:         1,
+		Offset:        2,
+	}
+	byAge := repository.StudentsFilter{MinAge: 18, SortBy: repository.SortByAge, SortDirection: repository.SortDesc, Limit: 2}
+
+	tests := []struct {
+		name    string
+		filter  repository.StudentsFilter
+		expect  func(s *fakesql.Script)
+		want    repository.StudentsPage
+		wantErr error
+	}{
+		{
+			name:   "offset, sort by id",
+			filter: repository.StudentsFilter{Limit: 2, Offset: 4},
+			expect: func(s *fakesql.Script) {
+				s.ExpectQuery(countQuery).WithArgs("", 0, 0, 0).WillReturnRows([]string{"count"}, []interface{}{6})
+				s.ExpectQuery(pageQuery+` ORDER BY s.id ASC LIMIT $5 OFFSET $6`).WithArgs("", 0, 0, 0, 3, 4).
+					WillReturnRows(studentColumns, bobRow, willRow)
+			},
+			want: repository.StudentsPage{Students: []models.Student{bob, will}, Total: 6},
+		},
+		{
+			name:   "offset, filter and sort by first name, next page",
+			filter: filtered,
+			expect: func(s *fakesql.Script) {
+				s.ExpectQuery(countQuery).WithArgs("b", 18, 30, 7).WillReturnRows([]string{"count"}, []interface{}{4})
+				s.ExpectQuery(pageQuery+` ORDER BY s.first_name DESC, s.id DESC LIMIT $5 OFFSET $6`).WithArgs("b", 18, 30, 7, 2, 2).
+					WillReturnRows(studentColumns, willRow, bobRow)
+			},
+			want: repository.StudentsPage{Students: []models.Student{will}, Total: 4, NextCursor: after(filtered, will).Cursor},
+		},
+		{
+			name:   "cursor, sort by age",
+			filter: after(byAge, bob),
+			expect: func(s *fakesql.Script) {
+				// count(*) на страницах по курсору не считается
+				s.ExpectQuery(pageQuery+` AND (s.age, s.id) < ($5, $6) ORDER BY s.age DESC, s.id DESC LIMIT $7`).
+					WithArgs("", 18, 0, 0, 20, 5, 3).
+					WillReturnRows(studentColumns, willRow)
+			},
+			want: repository.StudentsPage{Students: []models.Student{will}, Total: repository.TotalUnknown},
+		},
+		{
+			name:   "cursor, sort by id",
+			filter: after(repository.StudentsFilter{}, bob),
+			expect: func(s *fakesql.Script) {
+				s.ExpectQuery(pageQuery+` AND s.id > $5 ORDER BY s.id ASC LIMIT $6`).WithArgs("", 0, 0, 0, 5, repository.DefaultStudentsLimit+1).
+					WillReturnRows(studentColumns, willRow)
+			},
+			want: repository.StudentsPage{Students: []models.Student{will}, Total: repository.TotalUnknown},
+		},
+		{
+			name:   "no students",
+			filter: filtered,
+			expect: func(s *fakesql.Script) {
+				// страница не запрашивается
+				s.ExpectQuery(countQuery).WithArgs("b", 18, 30, 7).WillReturnRows([]string{"count"}, []interface{}{0})
+			},
+			want: repository.StudentsPage{Students: []models.Student{}},
+		},
+		{
+			name:    "cursor of another filter",
+			filter:  repository.StudentsFilter{MinAge: 19, SortBy: repository.SortByAge, SortDirection: repository.SortDesc, Cursor: after(byAge, bob).Cursor},
+			expect:  func(s *fakesql.Script) {},
+			wantErr: models.ErrValidation,
+		},
+		{
+			name:    "unknown sort field",
+			filter:  repository.StudentsFilter{SortBy: repository.StudentsSortField(42)},
+			expect:  func(s *fakesql.Script) {},
+			wantErr: models.ErrValidation,
+		},
+		{
+			name:   "count error",
+			filter: repository.StudentsFilter{},
+			expect: func(s *fakesql.Script) {
+				s.ExpectQuery(countQuery).WithArgs("", 0, 0, 0).WillReturnError(&pq.Error{Code: "57014"})
+			},
+			wantErr: models.ErrTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			script := fakesql.NewScript(t)
+			tt.expect(script)
+
+			log := &repositorytest.OpLog{}
+			page, err := NewRepository(script.DB(), WithLogger(log), WithCursorCodec(testCursors)).ListStudents(context.Background(), tt.filter)
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Fatalf("ListStudents error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(page, tt.want) {
+				t.Fatalf("ListStudents = %+v, want %+v", page, tt.want)
+			}
+			log.ExpectClosed(t, "list students")
+		})
+	}
+}
+
+func TestStreamStudents(t *testing.T) {
+	script := fakesql.NewScript(t)
+	script.ExpectQuery(pageQuery+` ORDER BY s.last_name ASC, s.id ASC`).WithArgs("b", 0, 0, 7).
+		WillReturnRows(studentColumns, []interface{}{5, "Bob", "Brown", 20}, []interface{}{3, "Harry", "Bell", 19})
+
+	// Limit, Offset и Cursor игнорируются
+	filter := repository.StudentsFilter{NamePrefix: "b", GroupID: 7, SortBy: repository.SortByLastName, Limit: 1, Offset: 1, Cursor: "ignored"}
+	var got []int64
+	err := NewRepository(script.DB()).StreamStudents(context.Background(), filter, func(s models.Student) error {
+		got = append(got, s.ID)
+		return nil
+	})
+	if err != nil || !reflect.DeepEqual(got, []int64{5, 3}) {
+		t.Fatalf("StreamStudents = %v, %v, want [5 3]", got, err)
+	}
+}
+
+func TestBulkInsertStudents(t *testing.T) {
+	students := []models.Student{
+		{ID: 10, FirstName: "Bob", LastName: "Brown", Age: 20},
+		{ID: 11, FirstName: "Will", LastName: "Williams", Age: 21},
+		{ID: 12, FirstName: "Harry", LastName: "Bell", Age: 19},
+	}
+
+	tests := []struct {
+		name         string
+		students     []models.Student // по умолчанию students
+		opts         []repository.BulkInsertOption
+		expect       func(s *fakesql.Script)
+		wantInserted int64
+		wantErr      error
+		wantOps      []string
+	}{
+		{
+			name: "batches in one transaction, ids from the sequence",
+			opts: []repository.BulkInsertOption{repository.WithBatchSize(2)},
+			expect: func(s *fakesql.Script) {
+				s.ExpectBegin()
+				s.ExpectPrepare(copyQuery)
+				s.ExpectExec(copyQuery).WithArgs("Bob", "Brown", 20)
+				s.ExpectExec(copyQuery).WithArgs("Will", "Williams", 21)
+				s.ExpectExec(copyQuery).WithArgs() // конец COPY
+				s.ExpectPrepare(copyQuery)
+				s.ExpectExec(copyQuery).WithArgs("Harry", "Bell", 19)
+				s.ExpectExec(copyQuery).WithArgs()
+				s.ExpectCommit()
+			},
+			wantInserted: 3,
+			wantOps:      []string{"bulk insert students", "bulk insert students"},
+		},
+		{
+			name: "keep ids advances the sequence",
+			opts: []repository.BulkInsertOption{repository.WithKeepIDs()},
+			expect: func(s *fakesql.Script) {
+				s.ExpectBegin()
+				s.ExpectPrepare(copyWithIDQuery)
+				s.ExpectExec(copyWithIDQuery).WithArgs(10, "Bob", "Brown", 20)
+				s.ExpectExec(copyWithIDQuery).WithArgs(11, "Will", "Williams", 21)
+				s.ExpectExec(copyWithIDQuery).WithArgs(12, "Harry", "Bell", 19)
+				s.ExpectExec(copyWithIDQuery).WithArgs()
+				s.ExpectExec(advanceSequence).WithArgs()
+				s.ExpectCommit()
+			},
+			wantInserted: 3,
+			wantOps:      []string{"bulk insert students", "advance students sequence"},
+		},
+		{
+			name: "row error rolls back all batches",
+			opts: []repository.BulkInsertOption{repository.WithBatchSize(2)},
+			expect: func(s *fakesql.Script) {
+				s.ExpectBegin()
+				s.ExpectPrepare(copyQuery)
+				s.ExpectExec(copyQuery).WithArgs("Bob", "Brown", 20)
+				s.ExpectExec(copyQuery).WithArgs("Will", "Williams", 21)
+				s.ExpectExec(copyQuery).WithArgs()
+				s.ExpectPrepare(copyQuery)
+				s.ExpectExec(copyQuery).WithArgs("Harry", "Bell", 19)
+				// строка 1 второго пакета - третья во входных данных
+				s.ExpectExec(copyQuery).WithArgs().WillReturnError(&pq.Error{
+					Code:       "23514",
+					Constraint: "students_age_check",
+					Table:      "students",
+					Where:      "COPY students, line 1: \"Harry\tBell\t0\"",
+					Message:    "new row violates check constraint",
+				})
+				s.ExpectRollback()
+			},
+			wantErr: &models.RowError{Row: 2, Err: &models.ValidationError{Field: "age", Reason: "must be greater than 0", Err: &models.DatabaseError{
+				Kind:       models.ErrCheckViolation,
+				Code:       "23514",
+				Constraint: "students_age_check",
+				Table:      "students",
+				Message:    "new row violates check constraint",
+			}}},
+			wantOps: []string{"bulk insert students", "bulk insert students"},
+		},
+		{
+			name:     "keep ids without id",
+			students: []models.Student{students[0], {FirstName: "Bob", LastName: "Brown", Age: 20}},
+			opts:     []repository.BulkInsertOption{repository.WithKeepIDs()},
+			expect:   func(s *fakesql.Script) {}, // запросов нет
+			wantErr:  &models.RowError{Row: 1, Err: &models.ValidationError{Field: "id", Reason: "must be a positive integer"}},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			script := fakesql.NewScript(t)
+			tt.expect(script)
+
+			input := tt.students
+			if input == nil {
+				input = students
+			}
+
+			log := &repositorytest.OpLog{}
+			inserted, err := NewRepository(script.DB(), WithLogger(log)).BulkInsertStudents(context.Background(), input, tt.opts...)
+			if !reflect.DeepEqual(err, tt.wantErr) {
+				t.Fatalf("BulkInsertStudents error = %#v, want %#v", err, tt.wantErr)
+			}
+			if inserted != tt.wantInserted {
+				t.Fatalf("BulkInsertStudents = %d, want %d", inserted, tt.wantInserted)
+			}
+			log.ExpectClosed(t, tt.wantOps...)
+		})
+	}
+}
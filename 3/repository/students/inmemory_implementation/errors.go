@@ -0,0 +1,72 @@
+package inmemoryimplementation
+
+import (
+	"unicode/utf8"
+
+	"github.com/moguchev/postgres/3/models"
+	"github.com/moguchev/postgres/3/repository/pgerrors"
+)
+
+// ошибки бизнес уровня для ограничений таблицы students (те же, что у реализаций над PostgreSQL)
+var studentsErrors = pgerrors.Constraints{
+	"students_age_check": func(e *models.DatabaseError) error {
+		return &models.ValidationError{Field: "age", Reason: "must be greater than 0", Err: e}
+	},
+}
+
+// violation - ошибка, которую реализации над PostgreSQL возвращают при нарушении ограничения таблицы students
+func violation(kind error, code, constraint, message string) error {
+	dbErr := &models.DatabaseError{
+		Kind:       kind,
+		Code:       code,
+		Constraint: constraint,
+		Table:      "students",
+		Message:    message,
+	}
+	if override, ok := studentsErrors[constraint]; ok {
+		return override(dbErr)
+	}
+	return dbErr
+}
+
+func conflictError() error {
+	return violation(models.ErrConflict, pgerrors.UniqueViolation, "students_pkey",
+		`duplicate key value violates unique constraint "students_pkey"`)
+}
+
+// dataError - значение не помещается в тип колонки (класс SQLSTATE 22).
+// Реализации над PostgreSQL не переводят такую ошибку в одиночных операциях (models.ErrInternal),
+// а в BulkInsertStudents возвращают ее как *models.RowError
+type dataError struct {
+	column  string
+	message string
+}
+
+func (e *dataError) Error() string {
+	return e.column + ": " + e.message
+}
+
+// checkStudent - ограничения таблицы students в порядке их проверки PostgreSQL:
+// сначала приведение значений к типам колонок, затем CHECK
+func checkStudent(student models.Student) error {
+	for _, column := range []struct {
+		name  string
+		value string
+	}{
+		{"first_name", student.FirstName},
+		{"last_name", student.LastName},
+	} {
+		if utf8.RuneCountInString(column.value) > models.MaxNameLength {
+			return &dataError{column: column.name, message: "value too long for type character varying(63)"}
+		}
+	}
+	if student.Age > models.MaxAge {
+		return &dataError{column: "age", message: "smallint out of range"}
+	}
+
+	if student.Age == 0 {
+		return violation(models.ErrCheckViolation, pgerrors.CheckViolation, "students_age_check",
+			`new row for relation "students" violates check constraint "students_age_check"`)
+	}
+	return nil
+}
@@ -0,0 +1,463 @@
+// Package inmemoryimplementation - repository.StudentsRepository в памяти процесса для unit тестов бизнес логики
+// без PostgreSQL. Семантика совпадает с реализациями над БД: models.ErrNotFound, порядок строк, ошибки
+// ограничений таблицы students (первичный ключ, CHECK (age > 0)) и последовательность id, которая,
+// как и в PostgreSQL, не откатывается вместе с транзакцией.
+// Строки хранятся в transaction.Table, поэтому репозиторий участвует в транзакциях transaction/inmemory_implementation.
+package inmemoryimplementation
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/moguchev/postgres/3/logger"
+	"github.com/moguchev/postgres/3/models"
+	"github.com/moguchev/postgres/3/repository"
+	"github.com/moguchev/postgres/3/repository/pgerrors"
+	transaction "github.com/moguchev/postgres/3/repository/transaction/inmemory_implementation"
+)
+
+// проверка удовлетворению интерфейса repository.StudentsRepository
+var _ repository.StudentsRepository = (*studentsRepository)(nil)
+
+type studentsRepository struct {
+	mu       sync.Mutex // проверка ограничений и запись строки - одно действие
+	students *transaction.Table
+	lastID   int64 // последнее выданное значение последовательности id (serial), не откатывается
+
+	groups  repository.GroupsRepository
+	tx      repository.TxManager
+	cursors *repository.CursorCodec
+	logger  logger.Logger
+}
+
+// Option - необязательный параметр NewRepository
+type Option func(r *studentsRepository)
+
+// WithLogger - логгер внутренних ошибок (по умолчанию logger.Nop())
+func WithLogger(l logger.Logger) Option {
+	return func(r *studentsRepository) {
+		r.logger = l
+	}
+}
+
+// WithCursorCodec - кодек курсоров keyset пагинации ListStudents (по умолчанию repository.DefaultCursorCodec())
+func WithCursorCodec(codec *repository.CursorCodec) Option {
+	return func(r *studentsRepository) {
+		r.cursors = codec
+	}
+}
+
+// WithGroups - репозиторий групп для фильтра StudentsFilter.GroupID и внешнего ключа students_groups:
+// DeleteStudent студента, состоящего в группе, - models.ErrForeignKey.
+// Без него фильтр по группе не находит ни одного студента, а DeleteStudent не проверяет членство в группах
+func WithGroups(groups repository.GroupsRepository) Option {
+	return func(r *studentsRepository) {
+		r.groups = groups
+	}
+}
+
+// WithTxManager - TxManager приложения: BulkInsertStudents без транзакции в ctx открывает ее через него
+// (по умолчанию - собственный transaction.NewTxManager(), не упорядоченный с транзакциями приложения)
+func WithTxManager(m repository.TxManager) Option {
+	return func(r *studentsRepository) {
+		r.tx = m
+	}
+}
+
+func NewRepository(opts ...Option) *studentsRepository {
+	r := &studentsRepository{
+		students: transaction.NewTable(),
+
+		tx:      transaction.NewTxManager(),
+		cursors: repository.DefaultCursorCodec(),
+		logger:  logger.Nop(),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// get - студент id, видимый в ctx
+func (r *studentsRepository) get(ctx context.Context, id int64) (models.Student, bool) {
+	student, ok := r.students.Get(ctx, id)
+	if !ok {
+		return models.Student{}, false
+	}
+	return student.(models.Student), true
+}
+
+func (r *studentsRepository) GetStudent(ctx context.Context, id int64) (models.Student, error) {
+	student, ok := r.get(ctx, id)
+	if !ok {
+		return models.Student{}, models.ErrNotFound
+	}
+	return student, nil
+}
+
+func (r *studentsRepository) GetStudents(ctx context.Context, ids ...int64) ([]models.Student, error) {
+	// как WHERE id = ANY($1) ORDER BY id: повторяющиеся и несуществующие id не дают строк
+	seen := make(map[int64]bool, len(ids))
+	students := make([]models.Student, 0, len(ids))
+	for _, id := range ids {
+		student, ok := r.get(ctx, id)
+		if !ok || seen[id] {
+			continue
+		}
+		seen[id] = true
+		students = append(students, student)
+	}
+	sort.Slice(students, func(i, j int) bool {
+		return students[i].ID < students[j].ID
+	})
+
+	return students, nil
+}
+
+func (r *studentsRepository) CreateStudent(ctx context.Context, student models.Student) (int64, error) {
+	op := logger.Start(r.logger, "create student", repository.StudentLogFields(student)...)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// значение по умолчанию (nextval) вычисляется до проверки ограничений, поэтому id расходуется и при ошибке
+	r.lastID++
+	student.ID = r.lastID
+
+	if err := checkStudent(student); err != nil {
+		return 0, r.translate(op, err)
+	}
+	if _, ok := r.get(ctx, student.ID); ok {
+		err := conflictError() // id уже занят явным id из UpsertStudent
+		op.Fail(err)
+		return 0, err
+	}
+
+	r.students.Put(ctx, student.ID, row(student))
+	op.Done()
+	return student.ID, nil
+}
+
+func (r *studentsRepository) UpdateStudent(ctx context.Context, student models.Student) error {
+	op := logger.Start(r.logger, "update student", repository.StudentLogFields(student)...)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.get(ctx, student.ID); !ok {
+		op.Fail(models.ErrNotFound)
+		return models.ErrNotFound
+	}
+	if err := checkStudent(student); err != nil {
+		return r.translate(op, err)
+	}
+
+	r.students.Put(ctx, student.ID, row(student))
+	op.Done()
+	return nil
+}
+
+func (r *studentsRepository) DeleteStudent(ctx context.Context, id int64) error {
+	// проверка внешнего ключа и удаление - под одной блокировкой, как и остальные изменения строк
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.get(ctx, id); !ok {
+		return models.ErrNotFound
+	}
+
+	// внешний ключ students_groups.student_id: студента из группы удалить нельзя
+	if r.groups != nil {
+		_, err := r.groups.GetStudentGroup(ctx, id)
+		switch {
+		case err == nil:
+			return &models.DatabaseError{
+				Kind:       models.ErrForeignKey,
+				Code:       pgerrors.ForeignKeyViolation,
+				Constraint: "students_groups_student_id_fkey",
+				Table:      "students_groups",
+				Message:    `update or delete on table "students" violates foreign key constraint "students_groups_student_id_fkey" on table "students_groups"`,
+			}
+		case !errors.Is(err, models.ErrNotFound):
+			return err
+		}
+	}
+
+	r.students.Delete(ctx, id)
+	return nil
+}
+
+func (r *studentsRepository) UpsertStudent(ctx context.Context, student models.Student) (int64, error) {
+	op := logger.Start(r.logger, "upsert student", repository.StudentLogFields(student)...)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// явный id больше выданных сдвигает последовательность - как setval в запросе PostgreSQL
+	if student.ID == 0 {
+		r.lastID++
+		student.ID = r.lastID
+	} else if student.ID > r.lastID {
+		r.lastID = student.ID
+	}
+
+	if err := checkStudent(student); err != nil {
+		return 0, r.translate(op, err)
+	}
+
+	r.students.Put(ctx, student.ID, row(student)) // ON CONFLICT (id) DO UPDATE
+	op.Done()
+	return student.ID, nil
+}
+
+func (r *studentsRepository) ListStudents(ctx context.Context, filter repository.StudentsFilter) (repository.StudentsPage, error) {
+	compare, err := studentsOrder(filter)
+	if err != nil {
+		return repository.StudentsPage{}, err
+	}
+
+	var cursor repository.StudentsCursor
+	if filter.Cursor != "" {
+		if cursor, err = r.cursors.Decode(filter, filter.Cursor); err != nil {
+			return repository.StudentsPage{}, err
+		}
+	}
+
+	students, err := r.matching(ctx, filter, compare)
+	if err != nil {
+		return repository.StudentsPage{}, err
+	}
+
+	limit := filter.PageLimit()
+	page := repository.StudentsPage{
+		Students: make([]models.Student, 0, limit+1),
+		Total:    int64(len(students)),
+	}
+	if filter.Cursor != "" {
+		page.Total = repository.TotalUnknown // как в реализациях для PostgreSQL
+	}
+
+	if filter.Cursor != "" {
+		// строки строго после курсора в порядке сортировки фильтра
+		after := cursorStudent(cursor)
+		students = students[sort.Search(len(students), func(i int) bool {
+			return compare(students[i], after) > 0
+		}):]
+	} else if filter.Offset < uint64(len(students)) {
+		students = students[filter.Offset:]
+	} else {
+		students = nil
+	}
+
+	// берем на одну строку больше, чтобы понять, есть ли следующая страница
+	if uint64(len(students)) > limit {
+		page.Students = append(page.Students, students[:limit]...)
+		last := page.Students[len(page.Students)-1]
+		page.NextCursor = r.cursors.Encode(filter, repository.NewStudentsCursor(filter, last))
+	} else {
+		page.Students = append(page.Students, students...)
+	}
+
+	return page, nil
+}
+
+func (r *studentsRepository) StreamStudents(ctx context.Context, filter repository.StudentsFilter, fn func(models.Student) error) error {
+	compare, err := studentsOrder(filter)
+	if err != nil {
+		return err
+	}
+
+	// fn вызывается без блокировки: она может обращаться к репозиторию
+	students, err := r.matching(ctx, filter, compare)
+	if err != nil {
+		return err
+	}
+
+	for _, student := range students {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		if err = fn(student); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *studentsRepository) BulkInsertStudents(ctx context.Context, students []models.Student, opts ...repository.BulkInsertOption) (int64, error) {
+	if len(students) == 0 {
+		return 0, nil
+	}
+	options := repository.NewBulkInsertOptions(opts...)
+	if err := options.CheckIDs(students); err != nil {
+		return 0, err
+	}
+
+	// строки появляются в общих данных разом, как после COPY в транзакции; внутри транзакции из ctx -
+	// в ее слое. r.mu берется внутри WithinTx: транзакции TxManager упорядочены раньше блокировки репозитория
+	err := r.tx.WithinTx(ctx, repository.TxOptions{}, func(ctx context.Context) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		// сначала проверяем все строки: либо все, либо ни одной
+		ids := make(map[int64]bool, len(students))
+		for i, student := range students {
+			if !options.KeepIDs {
+				student.ID = r.lastID + int64(i) + 1
+			}
+			if err := checkStudent(student); err != nil {
+				var derr *dataError
+				if errors.As(err, &derr) {
+					err = &models.ValidationError{Field: derr.column, Reason: derr.message}
+				}
+				return &models.RowError{Row: i, Err: err}
+			}
+			if _, ok := r.get(ctx, student.ID); ok || ids[student.ID] {
+				return &models.RowError{Row: i, Err: conflictError()}
+			}
+			ids[student.ID] = true
+		}
+
+		for _, student := range students {
+			if !options.KeepIDs {
+				r.lastID++
+				student.ID = r.lastID
+			} else if student.ID > r.lastID {
+				r.lastID = student.ID
+			}
+			r.students.Put(ctx, student.ID, row(student))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// прогресс - после вставки, без блокировки: OnProgress может обращаться к репозиторию
+	return options.InsertBatches(len(students), func(start, end int) error {
+		return nil
+	})
+}
+
+// translate - ошибка checkStudent так, как ее возвращают реализации над PostgreSQL:
+// ошибки данных логируются и НЕ пробрасываются наверх
+func (r *studentsRepository) translate(op *logger.Op, err error) error {
+	var derr *dataError
+	if errors.As(err, &derr) {
+		op.Error("database error", err)
+		return models.ErrInternal
+	}
+	op.Fail(err)
+	return err
+}
+
+// matching - студенты, удовлетворяющие фильтру, в порядке compare
+func (r *studentsRepository) matching(ctx context.Context, filter repository.StudentsFilter, compare func(a, b models.Student) int) ([]models.Student, error) {
+	var members map[int64]bool
+	if filter.GroupID != 0 {
+		members = make(map[int64]bool)
+		if r.groups != nil {
+			students, err := r.groups.ListGroupMembers(ctx, filter.GroupID)
+			if err != nil {
+				return nil, err
+			}
+			for _, student := range students {
+				members[student.ID] = true
+			}
+		}
+	}
+	prefix := strings.ToLower(filter.NamePrefix)
+
+	rows := r.students.Rows(ctx)
+	students := make([]models.Student, 0, len(rows))
+	for _, value := range rows {
+		student := value.(models.Student)
+		switch {
+		case prefix != "" &&
+			!strings.HasPrefix(strings.ToLower(student.FirstName), prefix) &&
+			!strings.HasPrefix(strings.ToLower(student.LastName), prefix):
+			continue
+		case filter.MinAge != 0 && student.Age < filter.MinAge:
+			continue
+		case filter.MaxAge != 0 && student.Age > filter.MaxAge:
+			continue
+		case members != nil && !members[student.ID]:
+			continue
+		}
+		students = append(students, student)
+	}
+
+	sort.Slice(students, func(i, j int) bool {
+		return compare(students[i], students[j]) < 0
+	})
+	return students, nil
+}
+
+// studentsOrder - сравнение студентов в порядке сортировки фильтра (поле сортировки, затем id в том же направлении).
+// Строки сравниваются побайтно, как с COLLATE "C": в БД с другой collation порядок имен может отличаться
+func studentsOrder(filter repository.StudentsFilter) (func(a, b models.Student) int, error) {
+	var key func(a, b models.Student) int
+	switch filter.SortBy {
+	case repository.SortByID:
+		key = func(models.Student, models.Student) int { return 0 }
+	case repository.SortByFirstName:
+		key = func(a, b models.Student) int { return strings.Compare(a.FirstName, b.FirstName) }
+	case repository.SortByLastName:
+		key = func(a, b models.Student) int { return strings.Compare(a.LastName, b.LastName) }
+	case repository.SortByAge:
+		key = func(a, b models.Student) int { return compareInt64(int64(a.Age), int64(b.Age)) }
+	default:
+		return nil, &models.ValidationError{Field: "sort_by", Reason: "unknown sort field"}
+	}
+
+	var sign int
+	switch filter.SortDirection {
+	case repository.SortAsc:
+		sign = 1
+	case repository.SortDesc:
+		sign = -1
+	default:
+		return nil, &models.ValidationError{Field: "sort_direction", Reason: "unknown sort direction"}
+	}
+
+	return func(a, b models.Student) int {
+		if c := key(a, b); c != 0 {
+			return sign * c
+		}
+		return sign * compareInt64(a.ID, b.ID)
+	}, nil
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// cursorStudent - позиция курсора как студент для сравнения в порядке сортировки
+func cursorStudent(cursor repository.StudentsCursor) models.Student {
+	return models.Student{
+		ID:        cursor.ID,
+		FirstName: cursor.Name,
+		LastName:  cursor.Name,
+		Age:       cursor.Age,
+	}
+}
+
+// row - только поля, которые хранятся в таблице students
+func row(student models.Student) models.Student {
+	return models.Student{
+		ID:        student.ID,
+		FirstName: student.FirstName,
+		LastName:  student.LastName,
+		Age:       student.Age,
+	}
+}
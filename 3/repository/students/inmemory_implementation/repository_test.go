@@ -0,0 +1,127 @@
+package inmemoryimplementation
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/moguchev/postgres/3/models"
+	"github.com/moguchev/postgres/3/repository"
+	groups_inmemory "github.com/moguchev/postgres/3/repository/groups/inmemory_implementation"
+	"github.com/moguchev/postgres/3/repository/repositorytest"
+	transaction "github.com/moguchev/postgres/3/repository/transaction/inmemory_implementation"
+)
+
+func TestStudentsRepository(t *testing.T) {
+	repositorytest.RunStudentsRepositorySuite(t, func(t *testing.T) repository.StudentsRepository {
+		return NewRepository()
+	})
+}
+
+func TestRepositories(t *testing.T) {
+	repositorytest.RunRepositoriesSuite(t, func(t *testing.T) repositorytest.Repositories {
+		txManager := transaction.NewTxManager()
+		groups := groups_inmemory.NewRepository(groups_inmemory.WithTxManager(txManager))
+		students := NewRepository(WithGroups(groups), WithTxManager(txManager))
+		groups_inmemory.WithStudents(students)(groups)
+		return repositorytest.Repositories{Students: students, Groups: groups, Tx: txManager}
+	})
+}
+
+var errRollback = errors.New("rollback")
+
+// count - студенты, видимые в ctx
+func count(t *testing.T, ctx context.Context, r repository.StudentsRepository) int {
+	t.Helper()
+
+	n := 0
+	if err := r.StreamStudents(ctx, repository.StudentsFilter{}, func(models.Student) error {
+		n++
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestBulkInsertStudentsTx(t *testing.T) {
+	students := []models.Student{
+		{FirstName: "Bob", LastName: "Brown", Age: 20},
+		{FirstName: "Will", LastName: "Williams", Age: 21},
+	}
+	ctx := context.Background()
+
+	t.Run("rows belong to the transaction in ctx", func(t *testing.T) {
+		txManager := transaction.NewTxManager()
+		r := NewRepository(WithTxManager(txManager))
+
+		err := txManager.WithinTx(ctx, repository.TxOptions{}, func(txCtx context.Context) error {
+			if n, err := r.BulkInsertStudents(txCtx, students); err != nil || n != 2 {
+				t.Fatalf("BulkInsertStudents = %d, %v, want 2", n, err)
+			}
+			if n := count(t, txCtx, r); n != 2 {
+				t.Errorf("%d students inside the transaction, want 2", n)
+			}
+			return errRollback
+		})
+		if !errors.Is(err, errRollback) {
+			t.Fatalf("WithinTx error = %v, want errRollback", err)
+		}
+		if n := count(t, ctx, r); n != 0 {
+			t.Fatalf("%d students after rollback, want 0", n)
+		}
+	})
+
+	t.Run("invalid row is returned and nothing is inserted", func(t *testing.T) {
+		r := NewRepository()
+
+		invalid := append(append([]models.Student{}, students...), models.Student{FirstName: "Ann", LastName: "Smith"})
+		_, err := r.BulkInsertStudents(ctx, invalid)
+		var rerr *models.RowError
+		if !errors.As(err, &rerr) || rerr.Row != 2 {
+			t.Fatalf("BulkInsertStudents error = %v, want RowError for row 2", err)
+		}
+		if n := count(t, ctx, r); n != 0 {
+			t.Fatalf("%d students after a failed insert, want 0", n)
+		}
+
+		if n, err := r.BulkInsertStudents(ctx, students); err != nil || n != 2 {
+			t.Fatalf("BulkInsertStudents = %d, %v, want 2", n, err)
+		}
+		if n := count(t, ctx, r); n != 2 {
+			t.Fatalf("%d students, want 2", n)
+		}
+	})
+}
+
+func TestDeleteStudentInGroup(t *testing.T) {
+	ctx := context.Background()
+	groups := groups_inmemory.NewRepository()
+	r := NewRepository(WithGroups(groups))
+	groups_inmemory.WithStudents(r)(groups)
+
+	studentID, err := r.CreateStudent(ctx, models.Student{FirstName: "Bob", LastName: "Brown", Age: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	groupID, err := groups.CreateGroup(ctx, models.Group{Name: "first"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = groups.AddStudentToGroup(ctx, studentID, groupID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = r.DeleteStudent(ctx, studentID); !errors.Is(err, models.ErrForeignKey) {
+		t.Fatalf("DeleteStudent error = %v, want ErrForeignKey", err)
+	}
+	if err = groups.RemoveStudentFromGroup(ctx, studentID, groupID); err != nil {
+		t.Fatal(err)
+	}
+	if err = r.DeleteStudent(ctx, studentID); err != nil {
+		t.Fatalf("DeleteStudent error = %v", err)
+	}
+	if err = r.DeleteStudent(ctx, studentID); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("DeleteStudent error = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,13 @@
+package pgximplementation
+
+import (
+	"github.com/moguchev/postgres/3/models"
+	"github.com/moguchev/postgres/3/repository/pgerrors"
+)
+
+// ошибки бизнес уровня для ограничений таблицы students
+var studentsErrors = pgerrors.Constraints{
+	"students_age_check": func(e *models.DatabaseError) error {
+		return &models.ValidationError{Field: "age", Reason: "must be greater than 0", Err: e}
+	},
+}
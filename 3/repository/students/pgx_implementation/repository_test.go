@@ -0,0 +1,292 @@
+package pgximplementation
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/moguchev/postgres/3/models"
+	"github.com/moguchev/postgres/3/repository"
+	groups "github.com/moguchev/postgres/3/repository/groups/pgx_implementation"
+	"github.com/moguchev/postgres/3/repository/repositorytest"
+	"github.com/moguchev/postgres/3/repository/repositorytest/pgxmock"
+	"github.com/moguchev/postgres/3/repository/sqlq"
+	transaction "github.com/moguchev/postgres/3/repository/transaction/pgx_implementation"
+)
+
+func TestStudentsRepository(t *testing.T) {
+	pool := repositorytest.NewPool(t, repositorytest.Postgres(t))
+
+	repositorytest.RunStudentsRepositorySuite(t, func(t *testing.T) repository.StudentsRepository {
+		return NewRepository(pool)
+	})
+}
+
+func TestRepositories(t *testing.T) {
+	pool := repositorytest.NewPool(t, repositorytest.Postgres(t))
+
+	repositorytest.RunRepositoriesSuite(t, func(t *testing.T) repositorytest.Repositories {
+		txManager := transaction.NewTxManager(pool)
+		return repositorytest.Repositories{
+			Students: NewRepository(pool, WithTxManager(txManager)),
+			Groups:   groups.NewRepository(pool, groups.WithTxManager(txManager)),
+			Tx:       txManager,
+		}
+	})
+}
+
+var studentColumns = []string{"id", "first_name", "last_name", "age"}
+
+var testCursors = repository.NewCursorCodec([]byte("test"))
+
+// pageQuery - запрос страницы ListStudents; его текст проверяют тесты database_sql_implementation
+func pageQuery(t *testing.T, filter repository.StudentsFilter) string {
+	t.Helper()
+
+	query, err := sqlq.StudentsPage(filter, filter.Cursor != "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return query
+}
+
+func TestGetStudent(t *testing.T) {
+	mock := pgxmock.New(t)
+	// pgx сканирует int4/int2 колонки в поля модели с приведением
+	mock.ExpectQuery(sqlq.GetStudent).WithArgs(int64(1)).
+		WillReturnRows(studentColumns, []interface{}{int32(1), "Bob", "Brown", int16(20)})
+	mock.ExpectQuery(sqlq.GetStudent).WithArgs(int64(2)).
+		WillReturnRows(studentColumns)
+	mock.ExpectQuery(sqlq.GetStudent).WithArgs(int64(3)).
+		WillReturnError(&pgconn.PgError{Code: "57P01"})
+
+	log := &repositorytest.OpLog{}
+	repo := NewRepository(mock, WithLogger(log))
+	ctx := context.Background()
+
+	student, err := repo.GetStudent(ctx, 1)
+	if err != nil || student != (models.Student{ID: 1, FirstName: "Bob", LastName: "Brown", Age: 20}) {
+		t.Fatalf("GetStudent(1) = %+v, %v", student, err)
+	}
+	if _, err = repo.GetStudent(ctx, 2); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("GetStudent(2) error = %v, want %v", err, models.ErrNotFound)
+	}
+	if _, err = repo.GetStudent(ctx, 3); !errors.Is(err, models.ErrUnavailable) {
+		t.Fatalf("GetStudent(3) error = %v, want %v", err, models.ErrUnavailable)
+	}
+	log.ExpectClosed(t, "get student", "get student", "get student")
+}
+
+func TestListStudents(t *testing.T) {
+	bob := models.Student{ID: 5, FirstName: "Bob", LastName: "Brown", Age: 20}
+	will := models.Student{ID: 6, FirstName: "Will", LastName: "Williams", Age: 21}
+	bobRow := []interface{}{int32(5), "Bob", "Brown", int16(20)}
+	willRow := []interface{}{int32(6), "Will", "Williams", int16(21)}
+
+	byName := repository.StudentsFilter{NamePrefix: "b", GroupID: 7, SortBy: repository.SortByLastName, Limit: 1, Offset: 3}
+	byAge := repository.StudentsFilter{MinAge: 18, SortBy: repository.SortByAge, SortDirection: repository.SortDesc, Limit: 2}
+	afterBob := byAge
+	afterBob.Cursor = testCursors.Encode(byAge, repository.NewStudentsCursor(byAge, bob))
+
+	tests := []struct {
+		name   string
+		filter repository.StudentsFilter
+		expect func(m *pgxmock.Mock, query string)
+		want   repository.StudentsPage
+	}{
+		{
+			name:   "offset",
+			filter: byName,
+			expect: func(m *pgxmock.Mock, query string) {
+				// аргументы pgx получает без приведения: uint возраста, uint64 limit и offset
+				m.ExpectQuery(sqlq.CountStudents).WithArgs("b", uint(0), uint(0), int64(7)).
+					WillReturnRows([]string{"count"}, []interface{}{int64(5)})
+				m.ExpectQuery(query).WithArgs("b", uint(0), uint(0), int64(7), uint64(2), uint64(3)).
+					WillReturnRows(studentColumns, bobRow, willRow)
+			},
+			want: repository.StudentsPage{
+				Students:   []models.Student{bob},
+				Total:      5,
+				NextCursor: testCursors.Encode(byName, repository.NewStudentsCursor(byName, bob)),
+			},
+		},
+		{
+			name:   "cursor",
+			filter: afterBob,
+			expect: func(m *pgxmock.Mock, query string) {
+				// без count(*): значения курсора (возраст и id) и limit+1
+				m.ExpectQuery(query).WithArgs("", uint(18), uint(0), int64(0), uint(20), int64(5), uint64(3)).
+					WillReturnRows(studentColumns, willRow)
+			},
+			want: repository.StudentsPage{Students: []models.Student{will}, Total: repository.TotalUnknown},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			mock := pgxmock.New(t)
+			tt.expect(mock, pageQuery(t, tt.filter))
+
+			page, err := NewRepository(mock, WithCursorCodec(testCursors)).ListStudents(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatalf("ListStudents error = %v", err)
+			}
+			if !reflect.DeepEqual(page, tt.want) {
+				t.Fatalf("ListStudents = %+v, want %+v", page, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamStudents(t *testing.T) {
+	errStop := errors.New("stop")
+	rows := [][]interface{}{{int32(5), "Bob", "Brown", int16(20)}, {int32(6), "Will", "Williams", int16(21)}}
+
+	tests := []struct {
+		name    string
+		rowsErr error
+		fnErr   error
+		wantErr error
+		want    []int64
+	}{
+		{
+			name: "all rows",
+			want: []int64{5, 6},
+		},
+		{
+			name:    "fn error stops the stream",
+			fnErr:   errStop,
+			wantErr: errStop,
+			want:    []int64{5},
+		},
+		{
+			// соединение оборвалось после переданных строк
+			name:    "database error mid-stream",
+			rowsErr: &pgconn.PgError{Code: "57P01", Message: "terminating connection due to administrator command"},
+			wantErr: models.ErrUnavailable,
+			want:    []int64{5, 6},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			filter := repository.StudentsFilter{GroupID: 7}
+			query, err := sqlq.StudentsStream(filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			mock := pgxmock.New(t)
+			mock.ExpectQuery(query).WithArgs("", uint(0), uint(0), int64(7)).
+				WillReturnRows(studentColumns, rows...).
+				WillReturnRowsError(tt.rowsErr)
+
+			log := &repositorytest.OpLog{}
+			var got []int64
+			err = NewRepository(mock, WithLogger(log)).StreamStudents(context.Background(), filter, func(s models.Student) error {
+				got = append(got, s.ID)
+				return tt.fnErr
+			})
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Fatalf("StreamStudents error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("StreamStudents passed %v to fn, want %v", got, tt.want)
+			}
+			log.ExpectClosed(t, "stream students")
+		})
+	}
+}
+
+func TestBulkInsertStudentsKeepIDs(t *testing.T) {
+	students := []models.Student{
+		{ID: 10, FirstName: "Bob", LastName: "Brown", Age: 20},
+		{ID: 11, FirstName: "Will", LastName: "Williams", Age: 21},
+		{ID: 12, FirstName: "Harry", LastName: "Bell", Age: 19},
+	}
+
+	tests := []struct {
+		name         string
+		expect       func(m *pgxmock.Mock)
+		wantInserted int64
+		wantErr      error
+		wantOps      []string
+	}{
+		{
+			name: "copy with ids, then the sequence",
+			expect: func(m *pgxmock.Mock) {
+				m.ExpectBegin()
+				m.ExpectCopyFrom(pgx.Identifier{"students"}, sqlq.CopyStudentsColumnsWithID).WithRows(
+					[]interface{}{int64(10), "Bob", "Brown", uint(20)},
+					[]interface{}{int64(11), "Will", "Williams", uint(21)},
+				)
+				m.ExpectCopyFrom(pgx.Identifier{"students"}, sqlq.CopyStudentsColumnsWithID).WithRows(
+					[]interface{}{int64(12), "Harry", "Bell", uint(19)},
+				)
+				m.ExpectExec(sqlq.AdvanceStudentsSequence).WithArgs().WillReturnCommandTag("SELECT 1")
+				m.ExpectCommit()
+			},
+			wantInserted: 3,
+			wantOps:      []string{"bulk insert students", "bulk insert students", "advance students sequence"},
+		},
+		{
+			name: "duplicate id rolls back",
+			expect: func(m *pgxmock.Mock) {
+				m.ExpectBegin()
+				m.ExpectCopyFrom(pgx.Identifier{"students"}, sqlq.CopyStudentsColumnsWithID)
+				m.ExpectCopyFrom(pgx.Identifier{"students"}, sqlq.CopyStudentsColumnsWithID).WillReturnError(&pgconn.PgError{
+					Code:           "23505",
+					ConstraintName: "students_pkey",
+					TableName:      "students",
+					Where:          "COPY students, line 1",
+					Message:        "duplicate key value violates unique constraint",
+				})
+				m.ExpectRollback()
+			},
+			wantErr: &models.RowError{Row: 2, Err: &models.DatabaseError{
+				Kind:       models.ErrConflict,
+				Code:       "23505",
+				Constraint: "students_pkey",
+				Table:      "students",
+				Message:    "duplicate key value violates unique constraint",
+			}},
+			wantOps: []string{"bulk insert students", "bulk insert students"},
+		},
+		{
+			name: "sequence error rolls back",
+			expect: func(m *pgxmock.Mock) {
+				m.ExpectBegin()
+				m.ExpectCopyFrom(pgx.Identifier{"students"}, sqlq.CopyStudentsColumnsWithID)
+				m.ExpectCopyFrom(pgx.Identifier{"students"}, sqlq.CopyStudentsColumnsWithID)
+				m.ExpectExec(sqlq.AdvanceStudentsSequence).WillReturnError(&pgconn.PgError{Code: "42501", Message: "permission denied for sequence students_id_seq"})
+				m.ExpectRollback()
+			},
+			wantErr: models.ErrInternal,
+			wantOps: []string{"bulk insert students", "bulk insert students", "advance students sequence"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			mock := pgxmock.New(t)
+			tt.expect(mock)
+
+			log := &repositorytest.OpLog{}
+			inserted, err := NewRepository(mock, WithLogger(log)).BulkInsertStudents(context.Background(), students,
+				repository.WithKeepIDs(), repository.WithBatchSize(2))
+			if !reflect.DeepEqual(err, tt.wantErr) {
+				t.Fatalf("BulkInsertStudents error = %#v, want %#v", err, tt.wantErr)
+			}
+			if inserted != tt.wantInserted {
+				t.Fatalf("BulkInsertStudents = %d, want %d", inserted, tt.wantInserted)
+			}
+			log.ExpectClosed(t, tt.wantOps...)
+		})
+	}
+}
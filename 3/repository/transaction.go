@@ -0,0 +1,43 @@
+package repository
+
+import "context"
+
+// IsolationLevel - уровень изоляции транзакции, не зависящий от драйвера
+type IsolationLevel int
+
+const (
+	IsolationDefault IsolationLevel = iota // уровень по умолчанию (default_transaction_isolation, обычно READ COMMITTED)
+	IsolationReadCommitted
+	IsolationRepeatableRead
+	IsolationSerializable
+)
+
+type TxOptions struct {
+	Isolation IsolationLevel
+	ReadOnly  bool
+}
+
+// TxManager - unit of work поверх любого драйвера.
+// WithinTx открывает транзакцию и кладет ее в контекст, который передается в fn:
+// все методы репозиториев, вызванные с этим контекстом, выполняются в этой транзакции.
+// Если fn вернула ошибку (или запаниковала) - транзакция откатывается, иначе коммитится.
+// Вложенный вызов WithinTx выполняется внутри точки сохранения (SAVEPOINT) уже открытой транзакции:
+// ошибка вложенной fn откатывает только ее изменения (ROLLBACK TO SAVEPOINT), успех - RELEASE SAVEPOINT.
+// Опции вложенного вызова игнорируются.
+type TxManager interface {
+	WithinTx(ctx context.Context, opts TxOptions, fn func(ctx context.Context) error) error
+}
+
+type inTxKey struct{}
+
+// ContextWithTx - ctx для fn из TxManager.WithinTx: реализации TxManager отмечают им открытую транзакцию (см. InTx)
+func ContextWithTx(ctx context.Context) context.Context {
+	return context.WithValue(ctx, inTxKey{}, true)
+}
+
+// InTx - ctx получен внутри TxManager.WithinTx: WithinTx с ним не откроет новую транзакцию,
+// а вложится в уже открытую через SAVEPOINT, и ее опции (уровень изоляции) будут проигнорированы
+func InTx(ctx context.Context) bool {
+	inTx, _ := ctx.Value(inTxKey{}).(bool)
+	return inTx
+}
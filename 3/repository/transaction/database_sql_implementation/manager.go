@@ -0,0 +1,162 @@
+package databasesqlimplementation
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/moguchev/postgres/3/logger"
+	"github.com/moguchev/postgres/3/models"
+	"github.com/moguchev/postgres/3/repository"
+	"github.com/moguchev/postgres/3/repository/pgerrors"
+)
+
+// проверка удовлетворению интерфейса repository.TxManager
+var _ repository.TxManager = (*txManager)(nil)
+
+// Querier - общие методы *sql.DB и *sql.Tx, которыми пользуются репозитории
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+type txKey struct{}
+
+// txState - активная транзакция и счетчик ее точек сохранения: у соседних вложенных WithinTx
+// имена точек не повторяются (sp_1, sp_2, ...), как у pgx
+type txState struct {
+	tx         *sql.Tx
+	savepoints *int
+}
+
+// GetQuerier - транзакция, открытая через TxManager.WithinTx, или db, если ее нет в ctx
+func GetQuerier(ctx context.Context, db *sql.DB) Querier {
+	if state, ok := ctx.Value(txKey{}).(txState); ok {
+		return state.tx
+	}
+	return db
+}
+
+type txManager struct {
+	db *sql.DB
+
+	logger logger.Logger
+}
+
+// Option - необязательный параметр NewTxManager
+type Option func(m *txManager)
+
+// WithLogger - логгер ошибок открытия, фиксации и отката транзакций (по умолчанию logger.Nop())
+func WithLogger(l logger.Logger) Option {
+	return func(m *txManager) {
+		m.logger = l
+	}
+}
+
+func NewTxManager(db *sql.DB, opts ...Option) *txManager {
+	m := &txManager{
+		db: db,
+
+		logger: logger.Nop(),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+func (m *txManager) WithinTx(ctx context.Context, opts repository.TxOptions, fn func(ctx context.Context) error) error {
+	if state, ok := ctx.Value(txKey{}).(txState); ok {
+		return m.withinSavepoint(ctx, state, fn) // уже внутри транзакции - вкладываем через SAVEPOINT
+	}
+
+	tx, err := m.db.BeginTx(ctx, &sql.TxOptions{
+		Isolation: isolationLevel(opts.Isolation),
+		ReadOnly:  opts.ReadOnly,
+	})
+	if err != nil {
+		if terr := pgerrors.Translate(err); terr != nil {
+			return terr
+		}
+		m.logger.Error("begin transaction: database error", logger.Error(err))
+		return models.ErrInternal
+	}
+
+	// откатываем и при ошибке, и при панике в fn; после Commit - no-op (sql.ErrTxDone)
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			m.logger.Error("rollback transaction: database error", logger.Error(err))
+		}
+	}()
+
+	if err = fn(repository.ContextWithTx(context.WithValue(ctx, txKey{}, txState{tx: tx, savepoints: new(int)}))); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		if terr := pgerrors.Translate(err); terr != nil {
+			return terr
+		}
+		m.logger.Error("commit transaction: database error", logger.Error(err))
+		return models.ErrInternal
+	}
+
+	return nil
+}
+
+// withinSavepoint - выполняет fn внутри точки сохранения уже открытой транзакции:
+// ошибка fn откатывает только изменения fn (ROLLBACK TO SAVEPOINT), внешняя транзакция продолжается.
+// Уровень изоляции и режим доступа вложенного вызова не меняются - они задаются внешней транзакцией.
+func (m *txManager) withinSavepoint(ctx context.Context, state txState, fn func(ctx context.Context) error) error {
+	*state.savepoints++
+	savepoint := fmt.Sprintf("sp_%d", *state.savepoints)
+
+	if _, err := state.tx.ExecContext(ctx, "SAVEPOINT "+savepoint); err != nil {
+		if terr := pgerrors.Translate(err); terr != nil {
+			return terr
+		}
+		m.logger.Error("savepoint: database error", logger.String("savepoint", savepoint), logger.Error(err))
+		return models.ErrInternal
+	}
+
+	// откатываем к точке сохранения и при ошибке, и при панике в fn - как pgx_implementation
+	released := false
+	defer func() {
+		if released {
+			return
+		}
+		if _, err := state.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+savepoint); err != nil {
+			m.logger.Error("rollback to savepoint: database error", logger.String("savepoint", savepoint), logger.Error(err))
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, txKey{}, state)); err != nil {
+		return err
+	}
+
+	if _, err := state.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+savepoint); err != nil {
+		if terr := pgerrors.Translate(err); terr != nil {
+			return terr
+		}
+		m.logger.Error("release savepoint: database error", logger.String("savepoint", savepoint), logger.Error(err))
+		return models.ErrInternal
+	}
+	released = true
+
+	return nil
+}
+
+func isolationLevel(level repository.IsolationLevel) sql.IsolationLevel {
+	switch level {
+	case repository.IsolationReadCommitted:
+		return sql.LevelReadCommitted
+	case repository.IsolationRepeatableRead:
+		return sql.LevelRepeatableRead
+	case repository.IsolationSerializable:
+		return sql.LevelSerializable
+	}
+	return sql.LevelDefault
+}
@@ -0,0 +1,232 @@
+package databasesqlimplementation_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/moguchev/postgres/3/models"
+	"github.com/moguchev/postgres/3/repository"
+	"github.com/moguchev/postgres/3/repository/repositorytest/fakesql"
+	transaction "github.com/moguchev/postgres/3/repository/transaction/database_sql_implementation"
+)
+
+var errRollback = errors.New("rollback")
+
+// exec - запрос через транзакцию из ctx (или db, если ее нет), как это делают репозитории
+func exec(ctx context.Context, db *sql.DB, query string) error {
+	_, err := transaction.GetQuerier(ctx, db).ExecContext(ctx, query)
+	return err
+}
+
+func TestWithinTx(t *testing.T) {
+	tests := []struct {
+		name   string
+		expect func(s *fakesql.Script)
+		fn     func(ctx context.Context, db *sql.DB) error
+		want   error
+	}{
+		{
+			name: "commit",
+			expect: func(s *fakesql.Script) {
+				s.ExpectBegin()
+				s.ExpectExec(`SELECT 1`)
+				s.ExpectCommit()
+			},
+			fn: func(ctx context.Context, db *sql.DB) error {
+				return exec(ctx, db, `SELECT 1`)
+			},
+		},
+		{
+			name: "rollback on error",
+			expect: func(s *fakesql.Script) {
+				s.ExpectBegin()
+				s.ExpectExec(`SELECT 1`)
+				s.ExpectRollback()
+			},
+			fn: func(ctx context.Context, db *sql.DB) error {
+				if err := exec(ctx, db, `SELECT 1`); err != nil {
+					return err
+				}
+				return errRollback
+			},
+			want: errRollback,
+		},
+		{
+			name: "commit error",
+			expect: func(s *fakesql.Script) {
+				s.ExpectBegin()
+				s.ExpectCommit().WillReturnError(&pq.Error{Code: "40001"})
+			},
+			fn: func(context.Context, *sql.DB) error {
+				return nil
+			},
+			want: models.ErrSerialization,
+		},
+		{
+			name: "begin error",
+			expect: func(s *fakesql.Script) {
+				s.ExpectBegin().WillReturnError(&pq.Error{Code: "57P01"})
+			},
+			fn: func(context.Context, *sql.DB) error {
+				t.Error("fn was called without a transaction")
+				return nil
+			},
+			want: models.ErrUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			s := fakesql.NewScript(t)
+			tt.expect(s)
+			db := s.DB()
+
+			err := transaction.NewTxManager(db).WithinTx(context.Background(), repository.TxOptions{}, func(ctx context.Context) error {
+				if !repository.InTx(ctx) {
+					t.Error("InTx(ctx) = false inside WithinTx")
+				}
+				return tt.fn(ctx, db)
+			})
+			if !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+				t.Fatalf("WithinTx error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithinTxSavepoints(t *testing.T) {
+	// nested - вложенный WithinTx, ошибку которого внешняя fn не пробрасывает: внешняя транзакция фиксируется
+	nested := func(m repository.TxManager, db *sql.DB, query string, result func() error) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			err := m.WithinTx(ctx, repository.TxOptions{}, func(ctx context.Context) error {
+				if err := exec(ctx, db, query); err != nil {
+					return err
+				}
+				return result()
+			})
+			if err != nil && !errors.Is(err, errRollback) {
+				return err
+			}
+			return nil
+		}
+	}
+	ok := func() error { return nil }
+	fail := func() error { return errRollback }
+
+	tests := []struct {
+		name   string
+		expect func(s *fakesql.Script)
+		fn     func(m repository.TxManager, db *sql.DB) func(ctx context.Context) error
+	}{
+		{
+			name: "nested success releases the savepoint",
+			expect: func(s *fakesql.Script) {
+				s.ExpectBegin()
+				s.ExpectExec(`SAVEPOINT sp_1`)
+				s.ExpectExec(`SELECT 1`)
+				s.ExpectExec(`RELEASE SAVEPOINT sp_1`)
+				s.ExpectCommit()
+			},
+			fn: func(m repository.TxManager, db *sql.DB) func(ctx context.Context) error {
+				return nested(m, db, `SELECT 1`, ok)
+			},
+		},
+		{
+			name: "nested error rolls back to the savepoint",
+			expect: func(s *fakesql.Script) {
+				s.ExpectBegin()
+				s.ExpectExec(`SAVEPOINT sp_1`)
+				s.ExpectExec(`SELECT 1`)
+				s.ExpectExec(`ROLLBACK TO SAVEPOINT sp_1`)
+				s.ExpectCommit()
+			},
+			fn: func(m repository.TxManager, db *sql.DB) func(ctx context.Context) error {
+				return nested(m, db, `SELECT 1`, fail)
+			},
+		},
+		{
+			name: "nested panic rolls back to the savepoint",
+			expect: func(s *fakesql.Script) {
+				s.ExpectBegin()
+				s.ExpectExec(`SAVEPOINT sp_1`)
+				s.ExpectExec(`SELECT 1`)
+				s.ExpectExec(`ROLLBACK TO SAVEPOINT sp_1`)
+				s.ExpectCommit()
+			},
+			fn: func(m repository.TxManager, db *sql.DB) func(ctx context.Context) error {
+				fn := nested(m, db, `SELECT 1`, func() error { panic("nested") })
+				return func(ctx context.Context) (err error) {
+					defer func() {
+						if r := recover(); r != "nested" {
+							t.Errorf("recovered %v, want the nested panic", r)
+						}
+					}()
+					return fn(ctx)
+				}
+			},
+		},
+		{
+			name: "sibling and deeper savepoints share the counter",
+			expect: func(s *fakesql.Script) {
+				s.ExpectBegin()
+				s.ExpectExec(`SAVEPOINT sp_1`)
+				s.ExpectExec(`SELECT 1`)
+				s.ExpectExec(`ROLLBACK TO SAVEPOINT sp_1`)
+				s.ExpectExec(`SAVEPOINT sp_2`)
+				s.ExpectExec(`SAVEPOINT sp_3`)
+				s.ExpectExec(`SELECT 3`)
+				s.ExpectExec(`RELEASE SAVEPOINT sp_3`)
+				s.ExpectExec(`RELEASE SAVEPOINT sp_2`)
+				s.ExpectCommit()
+			},
+			fn: func(m repository.TxManager, db *sql.DB) func(ctx context.Context) error {
+				first := nested(m, db, `SELECT 1`, fail)
+				deeper := nested(m, db, `SELECT 3`, ok)
+				return func(ctx context.Context) error {
+					if err := first(ctx); err != nil {
+						return err
+					}
+					return m.WithinTx(ctx, repository.TxOptions{}, deeper)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			s := fakesql.NewScript(t)
+			tt.expect(s)
+			db := s.DB()
+			m := transaction.NewTxManager(db)
+
+			if err := m.WithinTx(context.Background(), repository.TxOptions{}, tt.fn(m, db)); err != nil {
+				t.Fatalf("WithinTx error = %v", err)
+			}
+		})
+	}
+}
+
+func TestWithinTxPanic(t *testing.T) {
+	s := fakesql.NewScript(t)
+	s.ExpectBegin()
+	s.ExpectExec(`SELECT 1`)
+	s.ExpectRollback()
+	db := s.DB()
+
+	defer func() {
+		if r := recover(); r != "outer" {
+			t.Fatalf("recovered %v, want the panic of fn", r)
+		}
+	}()
+	_ = transaction.NewTxManager(db).WithinTx(context.Background(), repository.TxOptions{}, func(ctx context.Context) error {
+		if err := exec(ctx, db, `SELECT 1`); err != nil {
+			return err
+		}
+		panic("outer")
+	})
+}
@@ -0,0 +1,130 @@
+// Package inmemoryimplementation - repository.TxManager для репозиториев, хранящих данные в памяти
+// (для unit тестов бизнес логики без PostgreSQL).
+//
+// Репозитории хранят строки в Table. Изменения, сделанные с ctx транзакции WithinTx, пишутся в ее слой
+// (копирование при записи): их видят только запросы с этим ctx, в общие данные они попадают при фиксации,
+// а при ошибке или панике fn просто отбрасываются. Поэтому в транзакции участвуют все репозитории
+// на Table, без регистрации в TxManager.
+package inmemoryimplementation
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/moguchev/postgres/3/repository"
+)
+
+// проверка удовлетворению интерфейса repository.TxManager
+var _ repository.TxManager = (*txManager)(nil)
+
+type txKey struct{}
+
+// txState - слой изменений транзакции; у точки сохранения (вложенного WithinTx) parent - слой внешней транзакции
+type txState struct {
+	parent *txState
+
+	mu     sync.Mutex
+	writes map[*Table]map[int64]entry
+}
+
+// entry - измененная в транзакции строка: новое значение или удаление
+type entry struct {
+	value   interface{}
+	deleted bool
+}
+
+// txManager - транзакции над Table: изменения fn видны только внутри fn и применяются при успешном завершении.
+// Транзакции выполняются строго по очереди (как SERIALIZABLE без ошибок сериализации), опции игнорируются;
+// запросы вне WithinTx выполняются сразу и видят только зафиксированные данные.
+// Вложенный вызов WithinTx пишет в свой слой и при ошибке отбрасывает только свои изменения,
+// как ROLLBACK TO SAVEPOINT.
+// Ограничение: WithinTx с контекстом, не полученным от внешнего WithinTx, внутри fn ждет ее завершения вечно
+type txManager struct {
+	mu sync.Mutex
+}
+
+func NewTxManager() *txManager {
+	return &txManager{}
+}
+
+func (m *txManager) WithinTx(ctx context.Context, _ repository.TxOptions, fn func(ctx context.Context) error) error {
+	parent, _ := ctx.Value(txKey{}).(*txState)
+	if parent == nil {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+	}
+
+	state := &txState{
+		parent: parent,
+		writes: make(map[*Table]map[int64]entry),
+	}
+	if err := fn(repository.ContextWithTx(context.WithValue(ctx, txKey{}, state))); err != nil {
+		return err // слой не применяется - это и есть откат
+	}
+
+	state.commit()
+	return nil
+}
+
+// commit - переносит изменения в слой внешней транзакции или, для внешней транзакции, в общие данные таблиц
+func (s *txState) commit() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.parent != nil {
+		s.parent.mu.Lock()
+		defer s.parent.mu.Unlock()
+		for t, rows := range s.writes {
+			for key, e := range rows {
+				s.parent.put(t, key, e)
+			}
+		}
+		return
+	}
+
+	// все таблицы блокируются на время фиксации, чтобы запросы вне транзакции не увидели ее частично;
+	// порядок блокировки постоянный - нет взаимоблокировок с фиксациями других TxManager
+	tables := make([]*Table, 0, len(s.writes))
+	for t := range s.writes {
+		tables = append(tables, t)
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		return tables[i].seq < tables[j].seq
+	})
+	for _, t := range tables {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+	}
+
+	for _, t := range tables {
+		for key, e := range s.writes[t] {
+			t.apply(key, e)
+		}
+	}
+}
+
+// put - изменение строки в слое; s.mu должен быть захвачен
+func (s *txState) put(t *Table, key int64, e entry) {
+	rows, ok := s.writes[t]
+	if !ok {
+		rows = make(map[int64]entry)
+		s.writes[t] = rows
+	}
+	rows[key] = e
+}
+
+// lookup - строка из слоя; ok == false, если в слое ее не меняли
+func (s *txState) lookup(t *Table, key int64) (e entry, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok = s.writes[t][key]
+	return e, ok
+}
+
+// txFrom - слой транзакции из ctx или nil
+func txFrom(ctx context.Context) *txState {
+	state, _ := ctx.Value(txKey{}).(*txState)
+	return state
+}
@@ -0,0 +1,161 @@
+package inmemoryimplementation_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/moguchev/postgres/3/models"
+	"github.com/moguchev/postgres/3/repository"
+	groups_inmemory "github.com/moguchev/postgres/3/repository/groups/inmemory_implementation"
+	students_inmemory "github.com/moguchev/postgres/3/repository/students/inmemory_implementation"
+	transaction "github.com/moguchev/postgres/3/repository/transaction/inmemory_implementation"
+)
+
+var errRollback = errors.New("rollback")
+
+func newRepositories() (repository.StudentsRepository, repository.GroupsRepository) {
+	groups := groups_inmemory.NewRepository()
+	students := students_inmemory.NewRepository(students_inmemory.WithGroups(groups))
+	groups_inmemory.WithStudents(students)(groups)
+	return students, groups
+}
+
+func TestWithinTxCommitsAllRepositories(t *testing.T) {
+	students, groups := newRepositories()
+	m := transaction.NewTxManager()
+	ctx := context.Background()
+
+	var studentID, groupID int64
+	err := m.WithinTx(ctx, repository.TxOptions{}, func(ctx context.Context) (err error) {
+		if studentID, err = students.CreateStudent(ctx, models.Student{FirstName: "Tx", LastName: "Commit", Age: 20}); err != nil {
+			return err
+		}
+		if groupID, err = groups.CreateGroup(ctx, models.Group{Name: "tx"}); err != nil {
+			return err
+		}
+		if err = groups.AddStudentToGroup(ctx, studentID, groupID); err != nil {
+			return err
+		}
+
+		// вне транзакции незафиксированных изменений не видно
+		if _, err := students.GetStudent(context.Background(), studentID); !errors.Is(err, models.ErrNotFound) {
+			t.Errorf("GetStudent outside tx error = %v, want %v", err, models.ErrNotFound)
+		}
+		if _, err := groups.GetStudentGroup(context.Background(), studentID); !errors.Is(err, models.ErrNotFound) {
+			t.Errorf("GetStudentGroup outside tx error = %v, want %v", err, models.ErrNotFound)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithinTx error = %v", err)
+	}
+
+	group, err := groups.GetStudentGroup(ctx, studentID)
+	if err != nil || group.ID != groupID {
+		t.Fatalf("GetStudentGroup after commit = %+v, %v, want group %d", group, err, groupID)
+	}
+	if err = students.DeleteStudent(ctx, studentID); !errors.Is(err, models.ErrForeignKey) {
+		t.Fatalf("DeleteStudent of a group member error = %v, want %v", err, models.ErrForeignKey)
+	}
+}
+
+func TestWithinTxRollbackKeepsOtherWrites(t *testing.T) {
+	students, _ := newRepositories()
+	m := transaction.NewTxManager()
+	ctx := context.Background()
+
+	id, err := students.CreateStudent(ctx, models.Student{FirstName: "Tx", LastName: "Before", Age: 20})
+	if err != nil {
+		t.Fatalf("CreateStudent error = %v", err)
+	}
+
+	var outside int64
+	err = m.WithinTx(ctx, repository.TxOptions{}, func(txCtx context.Context) error {
+		if err := students.UpdateStudent(txCtx, models.Student{ID: id, FirstName: "Tx", LastName: "Inside", Age: 21}); err != nil {
+			return err
+		}
+		// запись вне транзакции, пока она открыта: откат не должен ее потерять
+		var err error
+		if outside, err = students.CreateStudent(ctx, models.Student{FirstName: "Tx", LastName: "Outside", Age: 22}); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("WithinTx error = %v, want %v", err, errRollback)
+	}
+
+	if got, err := students.GetStudent(ctx, id); err != nil || got.LastName != "Before" {
+		t.Fatalf("GetStudent(%d) after rollback = %+v, %v, want unchanged", id, got, err)
+	}
+	if _, err = students.GetStudent(ctx, outside); err != nil {
+		t.Fatalf("GetStudent(%d) written outside the rolled back tx error = %v", outside, err)
+	}
+}
+
+func TestWithinTxNestedRollback(t *testing.T) {
+	students, groups := newRepositories()
+	m := transaction.NewTxManager()
+	ctx := context.Background()
+
+	var kept, dropped int64
+	err := m.WithinTx(ctx, repository.TxOptions{}, func(ctx context.Context) (err error) {
+		if kept, err = students.CreateStudent(ctx, models.Student{FirstName: "Tx", LastName: "Kept", Age: 20}); err != nil {
+			return err
+		}
+		err = m.WithinTx(ctx, repository.TxOptions{}, func(ctx context.Context) (err error) {
+			if dropped, err = groups.CreateGroup(ctx, models.Group{Name: "dropped"}); err != nil {
+				return err
+			}
+			if err = students.DeleteStudent(ctx, kept); err != nil {
+				return err
+			}
+			return errRollback
+		})
+		if !errors.Is(err, errRollback) {
+			t.Errorf("nested WithinTx error = %v, want %v", err, errRollback)
+		}
+
+		// как ROLLBACK TO SAVEPOINT: изменения вложенного вызова отброшены, внешние остались
+		if _, err = students.GetStudent(ctx, kept); err != nil {
+			t.Errorf("GetStudent(%d) after nested rollback error = %v", kept, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithinTx error = %v", err)
+	}
+
+	if _, err = students.GetStudent(ctx, kept); err != nil {
+		t.Fatalf("GetStudent(%d) after commit error = %v", kept, err)
+	}
+	if _, err = groups.GetGroup(ctx, dropped); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("GetGroup(%d) from rolled back savepoint error = %v, want %v", dropped, err, models.ErrNotFound)
+	}
+}
+
+func TestWithinTxPanic(t *testing.T) {
+	students, _ := newRepositories()
+	m := transaction.NewTxManager()
+	ctx := context.Background()
+
+	var id int64
+	func() {
+		defer func() { _ = recover() }()
+		_ = m.WithinTx(ctx, repository.TxOptions{}, func(ctx context.Context) (err error) {
+			if id, err = students.CreateStudent(ctx, models.Student{FirstName: "Tx", LastName: "Panic", Age: 20}); err != nil {
+				return err
+			}
+			panic("boom")
+		})
+	}()
+
+	if _, err := students.GetStudent(ctx, id); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("GetStudent(%d) after panic error = %v, want %v", id, err, models.ErrNotFound)
+	}
+	// менеджер не остался заблокированным
+	if err := m.WithinTx(ctx, repository.TxOptions{}, func(context.Context) error { return nil }); err != nil {
+		t.Fatalf("WithinTx after panic error = %v", err)
+	}
+}
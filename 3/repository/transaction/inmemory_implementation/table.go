@@ -0,0 +1,103 @@
+package inmemoryimplementation
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+var tableSeq uint64
+
+// Table - строки хранилища в памяти по ключу int64 (аналог таблицы с первичным ключом).
+// Методы с ctx транзакции WithinTx читают ее слой поверх общих данных и пишут только в него.
+// Проверку ограничений (и атомарность "проверить и записать") обеспечивает репозиторий
+type Table struct {
+	seq uint64 // порядок блокировки таблиц при фиксации
+
+	mu   sync.RWMutex
+	rows map[int64]interface{}
+}
+
+func NewTable() *Table {
+	return &Table{
+		seq:  atomic.AddUint64(&tableSeq, 1),
+		rows: make(map[int64]interface{}),
+	}
+}
+
+// Get - строка key, видимая в ctx
+func (t *Table) Get(ctx context.Context, key int64) (interface{}, bool) {
+	for s := txFrom(ctx); s != nil; s = s.parent {
+		if e, ok := s.lookup(t, key); ok {
+			return e.value, !e.deleted
+		}
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	value, ok := t.rows[key]
+	return value, ok
+}
+
+// Put - вставляет или заменяет строку key
+func (t *Table) Put(ctx context.Context, key int64, value interface{}) {
+	t.write(ctx, key, entry{value: value})
+}
+
+// Delete - удаляет строку key (отсутствующая строка - не ошибка)
+func (t *Table) Delete(ctx context.Context, key int64) {
+	t.write(ctx, key, entry{deleted: true})
+}
+
+// Rows - копия всех строк, видимых в ctx, в произвольном порядке
+func (t *Table) Rows(ctx context.Context) map[int64]interface{} {
+	t.mu.RLock()
+	rows := make(map[int64]interface{}, len(t.rows))
+	for key, value := range t.rows {
+		rows[key] = value
+	}
+	t.mu.RUnlock()
+
+	// слои от внешней транзакции к самой вложенной: более вложенные изменения перекрывают внешние
+	var layers []*txState
+	for s := txFrom(ctx); s != nil; s = s.parent {
+		layers = append(layers, s)
+	}
+	for i := len(layers) - 1; i >= 0; i-- {
+		s := layers[i]
+		s.mu.Lock()
+		for key, e := range s.writes[t] {
+			if e.deleted {
+				delete(rows, key)
+			} else {
+				rows[key] = e.value
+			}
+		}
+		s.mu.Unlock()
+	}
+
+	return rows
+}
+
+func (t *Table) write(ctx context.Context, key int64, e entry) {
+	if s := txFrom(ctx); s != nil {
+		s.mu.Lock()
+		s.put(t, key, e)
+		s.mu.Unlock()
+		return
+	}
+
+	t.mu.Lock()
+	t.apply(key, e)
+	t.mu.Unlock()
+}
+
+// apply - изменение общих данных; t.mu должен быть захвачен на запись
+func (t *Table) apply(key int64, e entry) {
+	if e.deleted {
+		delete(t.rows, key)
+		return
+	}
+	t.rows[key] = e.value
+}
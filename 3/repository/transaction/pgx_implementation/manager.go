@@ -0,0 +1,210 @@
+package pgximplementation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/moguchev/postgres/3/logger"
+	"github.com/moguchev/postgres/3/models"
+	"github.com/moguchev/postgres/3/repository"
+	"github.com/moguchev/postgres/3/repository/pgerrors"
+)
+
+// проверка удовлетворению интерфейса repository.TxManager
+var _ repository.TxManager = (*txManager)(nil)
+
+// Querier - общие методы *pgxpool.Pool, *pgx.Conn и pgx.Tx, которыми пользуются репозитории.
+// Репозитории принимают Querier, а не *pgxpool.Pool, поэтому в тестах вместо пула можно передать
+// соединение, уже открытую транзакцию или мок (repositorytest/pgxmock)
+type Querier interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+	QueryFunc(ctx context.Context, sql string, args []interface{}, scans []interface{}, f func(pgx.QueryFuncRow) error) (pgconn.CommandTag, error)
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+}
+
+// проверка удовлетворению интерфейса Querier
+var (
+	_ Querier = (*pgxpool.Pool)(nil)
+	_ Querier = (*pgx.Conn)(nil)
+	_ Querier = (pgx.Tx)(nil)
+)
+
+type txKey struct{}
+
+// GetQuerier - транзакция, открытая через TxManager.WithinTx, или db, если ее нет в ctx
+func GetQuerier(ctx context.Context, db Querier) Querier {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return tx
+	}
+	return db
+}
+
+// ErrNoConn - Querier не дает доступа к соединению (см. AcquireConn)
+var ErrNoConn = errors.New("querier has no underlying connection")
+
+// AcquireConn - соединение db для операций, которых нет в Querier (например Prepare).
+// Из пула берется отдельное соединение, которое нужно вернуть вызовом release;
+// *pgx.Conn и pgx.Tx отдают свое соединение. Для других реализаций Querier (моков) - ErrNoConn
+func AcquireConn(ctx context.Context, db Querier) (conn *pgx.Conn, release func(), err error) {
+	switch db := db.(type) {
+	case *pgxpool.Pool:
+		c, err := db.Acquire(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		return c.Conn(), c.Release, nil
+	case *pgx.Conn:
+		return db, func() {}, nil
+	case pgx.Tx:
+		if conn := db.Conn(); conn != nil {
+			return conn, func() {}, nil
+		}
+	}
+	return nil, nil, ErrNoConn
+}
+
+// txBeginner - Querier, который открывает транзакции с опциями (*pgxpool.Pool и *pgx.Conn)
+type txBeginner interface {
+	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
+}
+
+// ErrNoTx - Querier не умеет открывать транзакции: это не *pgxpool.Pool, *pgx.Conn, pgx.Tx
+// или другая реализация с методом BeginTx (например pgxmock.Mock)
+var ErrNoTx = errors.New("querier can not begin transactions")
+
+// txManager - транзакции поверх db. Если db - уже открытая pgx.Tx, WithinTx вкладывается в нее через SAVEPOINT.
+// Если db не умеет открывать транзакции, WithinTx возвращает ErrNoTx и не выполняет fn
+type txManager struct {
+	db Querier
+
+	logger logger.Logger
+}
+
+// Option - необязательный параметр NewTxManager
+type Option func(m *txManager)
+
+// WithLogger - логгер ошибок открытия, фиксации и отката транзакций (по умолчанию logger.Nop())
+func WithLogger(l logger.Logger) Option {
+	return func(m *txManager) {
+		m.logger = l
+	}
+}
+
+func NewTxManager(db Querier, opts ...Option) *txManager {
+	m := &txManager{
+		db: db,
+
+		logger: logger.Nop(),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+func (m *txManager) WithinTx(ctx context.Context, opts repository.TxOptions, fn func(ctx context.Context) error) error {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return m.withinSavepoint(ctx, tx, fn) // уже внутри транзакции - вкладываем через SAVEPOINT
+	}
+
+	var beginner txBeginner
+	switch db := m.db.(type) {
+	case pgx.Tx:
+		return m.withinSavepoint(ctx, db, fn)
+	case txBeginner:
+		beginner = db
+	default:
+		return fmt.Errorf("%w: %T", ErrNoTx, m.db) // без транзакции fn потеряла бы атомарность незаметно
+	}
+
+	txOptions := pgx.TxOptions{
+		IsoLevel: isolationLevel(opts.Isolation),
+	}
+	if opts.ReadOnly {
+		txOptions.AccessMode = pgx.ReadOnly
+	}
+
+	tx, err := beginner.BeginTx(ctx, txOptions)
+	if err != nil {
+		if terr := pgerrors.Translate(err); terr != nil {
+			return terr
+		}
+		m.logger.Error("begin transaction: database error", logger.Error(err))
+		return models.ErrInternal
+	}
+
+	// откатываем и при ошибке, и при панике в fn; после Commit - no-op (pgx.ErrTxClosed)
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			m.logger.Error("rollback transaction: database error", logger.Error(err))
+		}
+	}()
+
+	if err = fn(repository.ContextWithTx(context.WithValue(ctx, txKey{}, tx))); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		if terr := pgerrors.Translate(err); terr != nil {
+			return terr
+		}
+		m.logger.Error("commit transaction: database error", logger.Error(err))
+		return models.ErrInternal
+	}
+
+	return nil
+}
+
+// withinSavepoint - выполняет fn внутри точки сохранения уже открытой транзакции:
+// pgx.Tx.Begin создает псевдо-вложенную транзакцию, у которой Commit - RELEASE SAVEPOINT, а Rollback - ROLLBACK TO SAVEPOINT.
+// Ошибка fn откатывает только изменения fn, внешняя транзакция продолжается.
+// Уровень изоляции и режим доступа вложенного вызова не меняются - они задаются внешней транзакцией.
+func (m *txManager) withinSavepoint(ctx context.Context, outer pgx.Tx, fn func(ctx context.Context) error) error {
+	tx, err := outer.Begin(ctx)
+	if err != nil {
+		if terr := pgerrors.Translate(err); terr != nil {
+			return terr
+		}
+		m.logger.Error("savepoint: database error", logger.Error(err))
+		return models.ErrInternal
+	}
+
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			m.logger.Error("rollback to savepoint: database error", logger.Error(err))
+		}
+	}()
+
+	if err = fn(repository.ContextWithTx(context.WithValue(ctx, txKey{}, tx))); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		if terr := pgerrors.Translate(err); terr != nil {
+			return terr
+		}
+		m.logger.Error("release savepoint: database error", logger.Error(err))
+		return models.ErrInternal
+	}
+
+	return nil
+}
+
+func isolationLevel(level repository.IsolationLevel) pgx.TxIsoLevel {
+	switch level {
+	case repository.IsolationReadCommitted:
+		return pgx.ReadCommitted
+	case repository.IsolationRepeatableRead:
+		return pgx.RepeatableRead
+	case repository.IsolationSerializable:
+		return pgx.Serializable
+	}
+	return "" // уровень по умолчанию
+}
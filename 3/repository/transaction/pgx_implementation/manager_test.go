@@ -0,0 +1,117 @@
+package pgximplementation_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/moguchev/postgres/3/models"
+	"github.com/moguchev/postgres/3/repository"
+	"github.com/moguchev/postgres/3/repository/repositorytest/pgxmock"
+	transaction "github.com/moguchev/postgres/3/repository/transaction/pgx_implementation"
+)
+
+var errRollback = errors.New("rollback")
+
+// exec - запрос через транзакцию из ctx (или db, если ее нет), как это делают репозитории
+func exec(ctx context.Context, db transaction.Querier, sql string) error {
+	_, err := transaction.GetQuerier(ctx, db).Exec(ctx, sql)
+	return err
+}
+
+func TestWithinTxCommit(t *testing.T) {
+	mock := pgxmock.New(t)
+	mock.ExpectBegin().WithTxOptions(pgx.TxOptions{IsoLevel: pgx.Serializable, AccessMode: pgx.ReadOnly})
+	mock.ExpectExec(`SELECT 1`)
+	mock.ExpectCommit()
+
+	opts := repository.TxOptions{Isolation: repository.IsolationSerializable, ReadOnly: true}
+	err := transaction.NewTxManager(mock).WithinTx(context.Background(), opts, func(ctx context.Context) error {
+		if !repository.InTx(ctx) {
+			t.Error("InTx(ctx) = false inside WithinTx")
+		}
+		return exec(ctx, mock, `SELECT 1`)
+	})
+	if err != nil {
+		t.Fatalf("WithinTx error = %v", err)
+	}
+}
+
+func TestWithinTxRollback(t *testing.T) {
+	mock := pgxmock.New(t)
+	mock.ExpectBegin()
+	mock.ExpectExec(`SELECT 1`)
+	mock.ExpectRollback()
+
+	err := transaction.NewTxManager(mock).WithinTx(context.Background(), repository.TxOptions{}, func(ctx context.Context) error {
+		if err := exec(ctx, mock, `SELECT 1`); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("WithinTx error = %v, want %v", err, errRollback)
+	}
+}
+
+func TestWithinTxCommitError(t *testing.T) {
+	mock := pgxmock.New(t)
+	mock.ExpectBegin()
+	mock.ExpectCommit().WillReturnError(&pgconn.PgError{Code: "40001"})
+
+	err := transaction.NewTxManager(mock).WithinTx(context.Background(), repository.TxOptions{}, func(context.Context) error {
+		return nil
+	})
+	if !errors.Is(err, models.ErrSerialization) {
+		t.Fatalf("WithinTx error = %v, want %v", err, models.ErrSerialization)
+	}
+}
+
+func TestWithinTxSavepoint(t *testing.T) {
+	mock := pgxmock.New(t)
+	mock.ExpectBegin()
+	mock.ExpectExec(`SELECT 1`)
+	mock.ExpectBegin() // SAVEPOINT
+	mock.ExpectExec(`SELECT 2`)
+	mock.ExpectRollback() // ROLLBACK TO SAVEPOINT
+	mock.ExpectCommit()
+
+	m := transaction.NewTxManager(mock)
+	err := m.WithinTx(context.Background(), repository.TxOptions{}, func(ctx context.Context) error {
+		if err := exec(ctx, mock, `SELECT 1`); err != nil {
+			return err
+		}
+		err := m.WithinTx(ctx, repository.TxOptions{}, func(ctx context.Context) error {
+			if err := exec(ctx, mock, `SELECT 2`); err != nil {
+				return err
+			}
+			return errRollback
+		})
+		if !errors.Is(err, errRollback) {
+			t.Errorf("nested WithinTx error = %v, want %v", err, errRollback)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithinTx error = %v", err)
+	}
+}
+
+func TestWithinTxNoTx(t *testing.T) {
+	// Querier без BeginTx: fn не должна выполниться без транзакции
+	db := struct{ transaction.Querier }{}
+
+	called := false
+	err := transaction.NewTxManager(db).WithinTx(context.Background(), repository.TxOptions{}, func(context.Context) error {
+		called = true
+		return nil
+	})
+	if !errors.Is(err, transaction.ErrNoTx) {
+		t.Fatalf("WithinTx error = %v, want %v", err, transaction.ErrNoTx)
+	}
+	if called {
+		t.Fatal("WithinTx ran fn without a transaction")
+	}
+}
@@ -0,0 +1,63 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/moguchev/postgres/3/models"
+	"github.com/moguchev/postgres/3/repository"
+)
+
+// GroupsUsecase - бизнес логика работы с группами
+type GroupsUsecase struct {
+	txManager repository.TxManager
+	groups    repository.GroupsRepository
+	retry     RetryPolicy
+}
+
+func NewGroupsUsecase(txManager repository.TxManager, groups repository.GroupsRepository, retry RetryPolicy) *GroupsUsecase {
+	return &GroupsUsecase{
+		txManager: txManager,
+		groups:    groups,
+		retry:     retry,
+	}
+}
+
+// ErrInTx - операция открывает собственную транзакцию и повторяет ее целиком, поэтому не может выполняться
+// внутри внешней транзакции (ctx из TxManager.WithinTx)
+var ErrInTx = errors.New("operation can not run inside an outer transaction")
+
+// MoveStudentToGroup - переводит студента в группу: исключает из текущей (если он в ней состоит) и добавляет в новую.
+// Транзакция выполняется с уровнем изоляции SERIALIZABLE, поэтому при конкурентных переводах
+// она может завершиться ошибкой сериализации - в этом случае повторяем ее целиком согласно RetryPolicy.
+// Внутри внешней транзакции - ErrInTx: вложенный WithinTx стал бы точкой сохранения с уровнем изоляции внешней
+// транзакции, а повтор точки сохранения не исправляет ошибку сериализации - откатить нужно всю транзакцию
+func (u *GroupsUsecase) MoveStudentToGroup(ctx context.Context, studentID, groupID int64) error {
+	if repository.InTx(ctx) {
+		return fmt.Errorf("move student to group: %w", ErrInTx)
+	}
+
+	opts := repository.TxOptions{
+		Isolation: repository.IsolationSerializable,
+	}
+
+	return u.retry.Do(ctx, func(ctx context.Context) error {
+		return u.txManager.WithinTx(ctx, opts, func(ctx context.Context) error {
+			current, err := u.groups.GetStudentGroup(ctx, studentID)
+			switch {
+			case errors.Is(err, models.ErrNotFound): // студент пока не состоит в группе
+			case err != nil:
+				return err
+			case current.ID == groupID: // уже в нужной группе
+				return nil
+			default:
+				if err = u.groups.RemoveStudentFromGroup(ctx, studentID, current.ID); err != nil {
+					return err
+				}
+			}
+
+			return u.groups.AddStudentToGroup(ctx, studentID, groupID)
+		})
+	})
+}
@@ -0,0 +1,172 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/moguchev/postgres/3/models"
+	"github.com/moguchev/postgres/3/repository"
+	groups_inmemory "github.com/moguchev/postgres/3/repository/groups/inmemory_implementation"
+	students_inmemory "github.com/moguchev/postgres/3/repository/students/inmemory_implementation"
+	tx_inmemory "github.com/moguchev/postgres/3/repository/transaction/inmemory_implementation"
+	"github.com/moguchev/postgres/3/usecase"
+)
+
+var retry = usecase.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+// fixture - студенты 1 (в группе 1) и 2 (без группы), группы 1 и 2 в репозиториях в памяти
+type fixture struct {
+	txManager repository.TxManager
+	groups    repository.GroupsRepository
+}
+
+func newFixture(t *testing.T) fixture {
+	t.Helper()
+
+	txManager := tx_inmemory.NewTxManager()
+	groups := groups_inmemory.NewRepository(groups_inmemory.WithTxManager(txManager))
+	students := students_inmemory.NewRepository(students_inmemory.WithGroups(groups), students_inmemory.WithTxManager(txManager))
+	groups_inmemory.WithStudents(students)(groups)
+
+	ctx := context.Background()
+	for _, s := range []models.Student{{FirstName: "Bob", LastName: "Brown", Age: 20}, {FirstName: "Will", LastName: "Williams", Age: 21}} {
+		if _, err := students.CreateStudent(ctx, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"first", "second"} {
+		if _, err := groups.CreateGroup(ctx, models.Group{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := groups.AddStudentToGroup(ctx, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	return fixture{txManager: txManager, groups: groups}
+}
+
+// expectGroup - группа студента; 0 - студент не состоит в группе
+func (f fixture) expectGroup(t *testing.T, studentID, want int64) {
+	t.Helper()
+
+	group, err := f.groups.GetStudentGroup(context.Background(), studentID)
+	if errors.Is(err, models.ErrNotFound) {
+		err = nil
+	}
+	if err != nil || group.ID != want {
+		t.Fatalf("student %d group = %d, %v, want %d", studentID, group.ID, err, want)
+	}
+}
+
+func TestMoveStudentToGroup(t *testing.T) {
+	tests := []struct {
+		name      string
+		studentID int64
+		groupID   int64
+		want      error
+		wantGroup int64
+	}{
+		{name: "from another group", studentID: 1, groupID: 2, wantGroup: 2},
+		{name: "student without a group", studentID: 2, groupID: 2, wantGroup: 2},
+		{name: "already in the group", studentID: 1, groupID: 1, wantGroup: 1},
+		// исключение из группы 1 откатывается вместе с транзакцией
+		{name: "missing group", studentID: 1, groupID: 3, want: models.ErrNotFound, wantGroup: 1},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			u := usecase.NewGroupsUsecase(f.txManager, f.groups, retry)
+
+			if err := u.MoveStudentToGroup(context.Background(), tt.studentID, tt.groupID); !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+				t.Fatalf("MoveStudentToGroup error = %v, want %v", err, tt.want)
+			}
+			f.expectGroup(t, tt.studentID, tt.wantGroup)
+		})
+	}
+}
+
+// flakyGroups - GroupsRepository, у которого первые failures вызовов AddStudentToGroup завершаются ошибкой сериализации
+type flakyGroups struct {
+	repository.GroupsRepository
+	failures int
+	adds     int
+}
+
+func (g *flakyGroups) AddStudentToGroup(ctx context.Context, studentID, groupID int64) error {
+	g.adds++
+	if g.adds <= g.failures {
+		return &models.DatabaseError{Kind: models.ErrSerialization, Code: "40001"}
+	}
+	return g.GroupsRepository.AddStudentToGroup(ctx, studentID, groupID)
+}
+
+func TestMoveStudentToGroupRetry(t *testing.T) {
+	f := newFixture(t)
+	groups := &flakyGroups{GroupsRepository: f.groups, failures: 2}
+	u := usecase.NewGroupsUsecase(f.txManager, groups, retry)
+
+	// каждая попытка исключает студента из группы 1 заново: изменения неудачной попытки откатываются
+	if err := u.MoveStudentToGroup(context.Background(), 1, 2); err != nil {
+		t.Fatalf("MoveStudentToGroup error = %v", err)
+	}
+	if groups.adds != 3 {
+		t.Fatalf("AddStudentToGroup called %d times, want 3", groups.adds)
+	}
+	f.expectGroup(t, 1, 2)
+
+	groups.adds, groups.failures = 0, 5
+	if err := u.MoveStudentToGroup(context.Background(), 1, 1); !errors.Is(err, models.ErrSerialization) {
+		t.Fatalf("MoveStudentToGroup error = %v, want %v", err, models.ErrSerialization)
+	}
+	if groups.adds != retry.MaxAttempts {
+		t.Fatalf("AddStudentToGroup called %d times, want %d", groups.adds, retry.MaxAttempts)
+	}
+	f.expectGroup(t, 1, 2)
+}
+
+// staleGroups - GetStudentGroup не видит текущую группу студента, как если бы его добавила конкурентная транзакция
+type staleGroups struct {
+	repository.GroupsRepository
+	adds int
+}
+
+func (g *staleGroups) GetStudentGroup(context.Context, int64) (models.Group, error) {
+	return models.Group{}, models.ErrNotFound
+}
+
+func (g *staleGroups) AddStudentToGroup(ctx context.Context, studentID, groupID int64) error {
+	g.adds++
+	return g.GroupsRepository.AddStudentToGroup(ctx, studentID, groupID)
+}
+
+func TestMoveStudentToGroupAlreadyInGroup(t *testing.T) {
+	f := newFixture(t)
+	groups := &staleGroups{GroupsRepository: f.groups}
+	u := usecase.NewGroupsUsecase(f.txManager, groups, retry)
+
+	if err := u.MoveStudentToGroup(context.Background(), 1, 2); !errors.Is(err, models.ErrAlreadyInGroup) {
+		t.Fatalf("MoveStudentToGroup error = %v, want %v", err, models.ErrAlreadyInGroup)
+	}
+	if groups.adds != 1 {
+		t.Fatalf("AddStudentToGroup called %d times, want 1 (no retry)", groups.adds)
+	}
+	f.expectGroup(t, 1, 1)
+}
+
+func TestMoveStudentToGroupInTx(t *testing.T) {
+	f := newFixture(t)
+	u := usecase.NewGroupsUsecase(f.txManager, f.groups, retry)
+
+	err := f.txManager.WithinTx(context.Background(), repository.TxOptions{}, func(ctx context.Context) error {
+		return u.MoveStudentToGroup(ctx, 1, 2)
+	})
+	if !errors.Is(err, usecase.ErrInTx) {
+		t.Fatalf("MoveStudentToGroup in a transaction error = %v, want %v", err, usecase.ErrInTx)
+	}
+	f.expectGroup(t, 1, 1)
+}
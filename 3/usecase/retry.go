@@ -0,0 +1,70 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/moguchev/postgres/3/models"
+)
+
+// RetryPolicy - политика повтора транзакций, завершившихся models.ErrSerialization
+type RetryPolicy struct {
+	MaxAttempts    int           // общее число попыток, включая первую (<= 1 - без повторов)
+	InitialBackoff time.Duration // пауза перед первым повтором
+	MaxBackoff     time.Duration // верхняя граница паузы
+	Multiplier     float64       // во сколько раз растет пауза после каждой попытки
+	Jitter         float64       // доля случайного разброса паузы [0, 1], чтобы конкурирующие транзакции не повторялись синхронно
+}
+
+// DefaultRetryPolicy - 5 попыток, паузы 10ms, 20ms, 40ms, 80ms (±20%)
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// Do - выполняет fn и повторяет ее с экспоненциальной паузой, пока она возвращает models.ErrSerialization.
+// Любая другая ошибка (или nil) возвращается сразу.
+func (p RetryPolicy) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	backoff := p.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || !errors.Is(err, models.ErrSerialization) || attempt >= p.MaxAttempts {
+			return err
+		}
+
+		timer := time.NewTimer(p.jittered(backoff))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		backoff = p.next(backoff)
+	}
+}
+
+func (p RetryPolicy) next(backoff time.Duration) time.Duration {
+	if p.Multiplier > 1 {
+		backoff = time.Duration(float64(backoff) * p.Multiplier)
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	return backoff
+}
+
+func (p RetryPolicy) jittered(backoff time.Duration) time.Duration {
+	if p.Jitter <= 0 || backoff <= 0 {
+		return backoff
+	}
+	delta := p.Jitter * float64(backoff)
+	return backoff + time.Duration(delta*(2*rand.Float64()-1))
+}
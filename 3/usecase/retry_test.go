@@ -0,0 +1,127 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/moguchev/postgres/3/models"
+)
+
+var errSerialization = &models.DatabaseError{Kind: models.ErrSerialization, Code: "40001"}
+
+func TestRetryPolicyDo(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxAttempts int
+		errs        []error // ошибки попыток по порядку, дальше - nil
+		want        error
+		calls       int
+	}{
+		{
+			name:        "success",
+			maxAttempts: 3,
+			calls:       1,
+		},
+		{
+			name:        "retry on serialization failure",
+			maxAttempts: 3,
+			errs:        []error{errSerialization, errSerialization},
+			calls:       3,
+		},
+		{
+			name:        "stop at MaxAttempts",
+			maxAttempts: 3,
+			errs:        []error{errSerialization, errSerialization, errSerialization, errSerialization},
+			want:        models.ErrSerialization,
+			calls:       3,
+		},
+		{
+			name:  "no retries without MaxAttempts",
+			errs:  []error{errSerialization},
+			want:  models.ErrSerialization,
+			calls: 1,
+		},
+		{
+			name:        "no retry on other errors",
+			maxAttempts: 3,
+			errs:        []error{&models.DatabaseError{Kind: models.ErrConflict, Code: "23505"}},
+			want:        models.ErrConflict,
+			calls:       1,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			p := RetryPolicy{MaxAttempts: tt.maxAttempts, InitialBackoff: time.Millisecond, Multiplier: 2}
+
+			calls := 0
+			err := p.Do(context.Background(), func(context.Context) error {
+				calls++
+				if calls <= len(tt.errs) {
+					return tt.errs[calls-1]
+				}
+				return nil
+			})
+			if !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+				t.Fatalf("Do error = %v, want %v", err, tt.want)
+			}
+			if calls != tt.calls {
+				t.Fatalf("Do called fn %d times, want %d", calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDoCanceledDuringBackoff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p := RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Hour}
+
+	calls := 0
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Do(ctx, func(context.Context) error {
+			calls++
+			cancel() // отмена во время паузы перед повтором
+			return errSerialization
+		})
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Do error = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Do waited for the backoff after ctx was canceled")
+	}
+	if calls != 1 {
+		t.Fatalf("Do called fn %d times, want 1", calls)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 30 * time.Millisecond, Multiplier: 2}
+
+	var got []time.Duration
+	for backoff, i := p.InitialBackoff, 0; i < 4; backoff, i = p.next(backoff), i+1 {
+		got = append(got, backoff)
+	}
+	want := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 30 * time.Millisecond, 30 * time.Millisecond}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("backoffs = %v, want %v", got, want)
+		}
+	}
+
+	p.Jitter = 0.2
+	for i := 0; i < 100; i++ {
+		if d := p.jittered(100 * time.Millisecond); d < 80*time.Millisecond || d > 120*time.Millisecond {
+			t.Fatalf("jittered(100ms) = %v, want within ±20%%", d)
+		}
+	}
+}
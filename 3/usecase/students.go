@@ -0,0 +1,41 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/moguchev/postgres/3/models"
+	"github.com/moguchev/postgres/3/repository"
+)
+
+// StudentsUsecase - бизнес логика работы со студентами
+type StudentsUsecase struct {
+	txManager repository.TxManager
+	students  repository.StudentsRepository
+	groups    repository.GroupsRepository
+}
+
+func NewStudentsUsecase(txManager repository.TxManager, students repository.StudentsRepository, groups repository.GroupsRepository) *StudentsUsecase {
+	return &StudentsUsecase{
+		txManager: txManager,
+		students:  students,
+		groups:    groups,
+	}
+}
+
+// EnrollStudent - создает студента и сразу зачисляет его в группу.
+// Оба репозитория работают в одной транзакции: если зачислить не удалось, студент тоже не создается.
+func (u *StudentsUsecase) EnrollStudent(ctx context.Context, student models.Student, groupID int64) (int64, error) {
+	var id int64
+	err := u.txManager.WithinTx(ctx, repository.TxOptions{}, func(ctx context.Context) error {
+		var err error
+		if id, err = u.students.CreateStudent(ctx, student); err != nil {
+			return err
+		}
+		return u.groups.AddStudentToGroup(ctx, id, groupID)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
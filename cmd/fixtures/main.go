@@ -0,0 +1,124 @@
+// fixtures - загрузка наборов тестовых и демо данных из пакета db/fixtures.
+//
+//	fixtures [-config db.yaml] [-dsn URL] load [-reset] DATASET...   загрузить наборы (имя встроенного набора или путь к .yaml/.yml/.json)
+//	fixtures [-config db.yaml] [-dsn URL] reset [TABLE...]           очистить таблицы (по умолчанию все, кроме schema_migrations)
+//	fixtures list                                                     встроенные наборы
+//
+// Параметры подключения - config.FromFlags: значения по умолчанию, затем -config, переменные PG* и DATABASE_URL, затем -dsn.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+
+	"github.com/moguchev/postgres/config"
+	"github.com/moguchev/postgres/db/fixtures"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("fixtures: ")
+
+	conn := config.FromFlags(flag.CommandLine)
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	connect := func() (*sql.DB, error) {
+		return conn.OpenDB(config.WithApplicationName("fixtures"))
+	}
+
+	var err error
+	switch cmd, args := flag.Arg(0), flag.Args()[1:]; cmd {
+	case "load":
+		err = loadCmd(ctx, connect, args)
+	case "reset":
+		err = resetCmd(ctx, connect, args)
+	case "list":
+		for _, name := range fixtures.Names() {
+			fmt.Println(name)
+		}
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "usage: fixtures [flags] load [-reset] DATASET... | reset [TABLE...] | list\n")
+	flag.PrintDefaults()
+}
+
+func loadCmd(ctx context.Context, connect func() (*sql.DB, error), args []string) error {
+	fs := flag.NewFlagSet("load", flag.ExitOnError)
+	reset := fs.Bool("reset", false, "truncate the datasets' tables (RESTART IDENTITY) before loading")
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		return fmt.Errorf("load: no datasets")
+	}
+
+	datasets := make([]fixtures.Dataset, 0, fs.NArg())
+	for _, arg := range fs.Args() {
+		ds, err := dataset(arg)
+		if err != nil {
+			return err
+		}
+		datasets = append(datasets, ds)
+	}
+
+	db, err := connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	loader := fixtures.NewLoader(db)
+	if *reset {
+		err = loader.Reload(ctx, datasets...)
+	} else {
+		err = loader.Load(ctx, datasets...)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, ds := range datasets {
+		fmt.Printf("loaded %s\n", ds.Name)
+	}
+	return nil
+}
+
+func resetCmd(ctx context.Context, connect func() (*sql.DB, error), tables []string) error {
+	db, err := connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return fixtures.NewLoader(db).Reset(ctx, tables...)
+}
+
+// dataset - встроенный набор по имени или набор из файла, если аргумент похож на путь
+func dataset(arg string) (fixtures.Dataset, error) {
+	if filepath.Ext(arg) != "" {
+		return fixtures.File(arg)
+	}
+	return fixtures.Named(arg)
+}
@@ -0,0 +1,156 @@
+// migrate - применение и откат миграций схемы БД из пакета db/migrations.
+//
+//	migrate [-config db.yaml] [-dsn URL] up [N]        применить N (по умолчанию все) новых миграций
+//	migrate [-config db.yaml] [-dsn URL] down [N]      откатить N (по умолчанию 1) последних миграций
+//	migrate [-config db.yaml] [-dsn URL] status        список миграций и их состояние
+//	migrate [-config db.yaml] [-dsn URL] force VERSION считать примененными версии до VERSION включительно, не выполняя их
+//
+// Для БД, созданной прежним db/init.sql (схема уже есть, таблицы schema_migrations нет), выполните один раз force 1.
+// Параметры подключения - config.FromFlags: значения по умолчанию, затем -config, переменные PG* и DATABASE_URL, затем -dsn.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/moguchev/postgres/config"
+	"github.com/moguchev/postgres/db/migrations"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("migrate: ")
+
+	conn := config.FromFlags(flag.CommandLine)
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	db, err := conn.OpenDB(config.WithApplicationName("migrate"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	migrator, err := migrations.New(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch cmd, args := flag.Arg(0), flag.Args()[1:]; cmd {
+	case "up":
+		err = upCmd(ctx, migrator, args)
+	case "down":
+		err = downCmd(ctx, migrator, args)
+	case "status":
+		err = statusCmd(ctx, migrator)
+	case "force":
+		err = forceCmd(ctx, migrator, args)
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "usage: migrate [flags] up [N] | down [N] | status | force VERSION\n")
+	flag.PrintDefaults()
+}
+
+func upCmd(ctx context.Context, migrator *migrations.Migrator, args []string) error {
+	n, err := stepsArg(args, 0)
+	if err != nil {
+		return err
+	}
+
+	applied, err := migrator.Up(ctx, n)
+	for _, m := range applied {
+		fmt.Printf("applied %d_%s\n", m.Version, m.Name)
+	}
+	if err == nil && len(applied) == 0 {
+		fmt.Println("no pending migrations")
+	}
+	return err
+}
+
+func downCmd(ctx context.Context, migrator *migrations.Migrator, args []string) error {
+	n, err := stepsArg(args, 1)
+	if err != nil {
+		return err
+	}
+
+	reverted, err := migrator.Down(ctx, n)
+	for _, m := range reverted {
+		fmt.Printf("reverted %d_%s\n", m.Version, m.Name)
+	}
+	if err == nil && len(reverted) == 0 {
+		fmt.Println("no applied migrations")
+	}
+	return err
+}
+
+func statusCmd(ctx context.Context, migrator *migrations.Migrator) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, s := range statuses {
+		status, appliedAt := "pending", ""
+		if s.Applied {
+			status, appliedAt = "applied", s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Version, s.Name, status, appliedAt)
+	}
+	return w.Flush()
+}
+
+func forceCmd(ctx context.Context, migrator *migrations.Migrator, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("force: want exactly one VERSION argument")
+	}
+	version, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || version < 0 {
+		return fmt.Errorf("force: invalid version %q", args[0])
+	}
+
+	if err = migrator.Force(ctx, version); err != nil {
+		return err
+	}
+	fmt.Printf("forced version %d\n", version)
+	return nil
+}
+
+// stepsArg - необязательный аргумент N (количество миграций)
+func stepsArg(args []string, fallback int) (int, error) {
+	switch len(args) {
+	case 0:
+		return fallback, nil
+	case 1:
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid number of migrations %q", args[0])
+		}
+		return n, nil
+	}
+	return 0, fmt.Errorf("too many arguments")
+}
@@ -0,0 +1,175 @@
+// pgrecord - запись обмена по протоколу PostgreSQL в golden файл и его воспроизведение без БД (пакет pgwire).
+//
+//	pgrecord record [-listen ADDR] [-upstream HOST:PORT] -golden FILE [COMMAND [ARG...]]   прокси к БД, записывающий обмен
+//	pgrecord replay [-listen ADDR] -golden FILE [COMMAND [ARG...]]                        сервер, отвечающий по записи
+//
+// С командой pgrecord слушает свободный порт на 127.0.0.1, запускает команду с PGHOST, PGPORT и PGSSLMODE=disable,
+// указывающими на себя, и завершается после нее: record сохраняет golden файл, только если команда успешна,
+// replay завершается с ошибкой, если запросы команды отличаются от записанных. Без команды pgrecord работает до Ctrl+C.
+//
+// БД для record - как в пакете config: значения по умолчанию, затем переменные PG* и DATABASE_URL, затем -upstream.
+// Записываются все сообщения, кроме обмена аутентификации (пароль в файл не попадает); replay сразу отвечает AuthenticationOk.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+
+	"github.com/moguchev/postgres/config"
+	"github.com/moguchev/postgres/pgwire"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("pgrecord: ")
+
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	var err error
+	switch cmd, args := flag.Arg(0), flag.Args()[1:]; cmd {
+	case "record":
+		err = recordCmd(ctx, args)
+	case "replay":
+		err = replayCmd(ctx, args)
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "usage: pgrecord record [-listen ADDR] [-upstream HOST:PORT] -golden FILE [COMMAND [ARG...]]\n"+
+		"       pgrecord replay [-listen ADDR] -golden FILE [COMMAND [ARG...]]\n")
+}
+
+func recordCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("record", flag.ExitOnError)
+	listen := fs.String("listen", "127.0.0.1:0", "address for clients")
+	upstream := fs.String("upstream", "", "database HOST:PORT (default from PG* and DATABASE_URL)")
+	golden := fs.String("golden", "", "golden file to write")
+	_ = fs.Parse(args)
+
+	if *golden == "" {
+		return errors.New("record: -golden is required")
+	}
+	if *upstream == "" {
+		cfg, err := config.Load(config.FromEnv())
+		if err != nil {
+			return err
+		}
+		*upstream = net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	}
+
+	// пишем во временный файл: неудачная запись не должна портить прежний golden файл
+	tmp := *golden + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	defer f.Close()
+
+	w := pgwire.NewGoldenWriter(f)
+	proxy := pgwire.NewProxy(*upstream, w.Observe)
+
+	listener, err := net.Listen("tcp", *listen)
+	if err != nil {
+		return err
+	}
+	go func() { _ = proxy.Serve(listener) }()
+	log.Printf("recording %s -> %s into %s", listener.Addr(), *upstream, *golden)
+
+	err = run(ctx, listener.Addr(), fs.Args())
+	if cerr := proxy.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = w.Err()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, *golden)
+}
+
+func replayCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	listen := fs.String("listen", "127.0.0.1:0", "address for clients")
+	golden := fs.String("golden", "", "golden file to replay")
+	_ = fs.Parse(args)
+
+	if *golden == "" {
+		return errors.New("replay: -golden is required")
+	}
+	f, err := os.Open(*golden)
+	if err != nil {
+		return err
+	}
+	events, err := pgwire.ReadGolden(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %w", *golden, err)
+	}
+
+	replayer := pgwire.NewReplayer(events)
+	listener, err := net.Listen("tcp", *listen)
+	if err != nil {
+		return err
+	}
+	go func() { _ = replayer.Serve(listener) }()
+	log.Printf("replaying %s on %s", *golden, listener.Addr())
+
+	err = run(ctx, listener.Addr(), fs.Args())
+	if rerr := replayer.Close(); rerr != nil {
+		return fmt.Errorf("%s: %w", *golden, rerr) // отличия от записи важнее кода завершения команды
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("%s: ok", *golden)
+	return nil
+}
+
+// run - выполняет команду с подключением к addr через PG*; без команды ждет Ctrl+C
+func run(ctx context.Context, addr net.Addr, command []string) error {
+	if len(command) == 0 {
+		<-ctx.Done()
+		return nil
+	}
+
+	host, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = append(os.Environ(), "PGHOST="+host, "PGPORT="+port, "PGSSLMODE=disable")
+	return cmd.Run()
+}
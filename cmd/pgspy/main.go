@@ -0,0 +1,117 @@
+// pgspy - прокси между приложением и PostgreSQL, который пишет журнал запросов в формате JSON Lines (пакет pgwire).
+//
+//	pgspy [-listen ADDR] [-upstream HOST:PORT] [-o FILE] [COMMAND [ARG...]]
+//
+// По строке на запрос: протокол (simple - Query, execute - Execute расширенного протокола, prepare - Parse/Describe
+// без выполнения, т.е. отдельный round-trip), имя подготовленного запроса, текст, параметры Bind, время от
+// отправки до ответа, число строк DataRow, тег команды и ошибка. Так видно, что на самом деле отправляют
+// lib/pq, pgx и sqlx, без журнала запросов на сервере:
+//
+//	{"time":"...","conn":1,"kind":"prepare","query":"SELECT name FROM students WHERE id = $1","param_types":["int8"],"duration_ms":0.41,"rows":0}
+//	{"time":"...","conn":1,"kind":"execute","query":"SELECT name FROM students WHERE id = $1","params":["1"],"duration_ms":0.37,"rows":1,"tag":"SELECT 1"}
+//
+// С командой pgspy слушает свободный порт на 127.0.0.1 и запускает команду с PGHOST, PGPORT и PGSSLMODE=disable,
+// указывающими на себя, например: pgspy -o spy.jsonl go run ./1/sqlx. Без команды pgspy работает до Ctrl+C.
+//
+// БД - как в пакете config: значения по умолчанию, затем переменные PG* и DATABASE_URL, затем -upstream.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+
+	"github.com/moguchev/postgres/config"
+	"github.com/moguchev/postgres/pgwire"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("pgspy: ")
+
+	listen := flag.String("listen", "127.0.0.1:0", "address for clients")
+	upstream := flag.String("upstream", "", "database HOST:PORT (default from PG* and DATABASE_URL)")
+	output := flag.String("o", "", "log file (default stdout)")
+	flag.Usage = usage
+	flag.Parse()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	if err := spyCmd(ctx, *listen, *upstream, *output, flag.Args()); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "usage: pgspy [-listen ADDR] [-upstream HOST:PORT] [-o FILE] [COMMAND [ARG...]]\n")
+	flag.PrintDefaults()
+}
+
+func spyCmd(ctx context.Context, listen, upstream, output string, command []string) error {
+	if upstream == "" {
+		cfg, err := config.Load(config.FromEnv())
+		if err != nil {
+			return err
+		}
+		upstream = net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	s := newSpy(w)
+	proxy := pgwire.NewProxy(upstream, s.Observe)
+
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return err
+	}
+	go func() { _ = proxy.Serve(listener) }()
+	log.Printf("listening on %s -> %s", listener.Addr(), upstream)
+
+	err = run(ctx, listener.Addr(), command)
+	if cerr := proxy.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = s.Err()
+	}
+	return err
+}
+
+// run - выполняет команду с подключением к addr через PG*; без команды ждет Ctrl+C
+func run(ctx context.Context, addr net.Addr, command []string) error {
+	if len(command) == 0 {
+		<-ctx.Done()
+		return nil
+	}
+
+	host, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = append(os.Environ(), "PGHOST="+host, "PGPORT="+port, "PGSSLMODE=disable")
+	return cmd.Run()
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgtype"
+
+	"github.com/moguchev/postgres/pgwire"
+)
+
+// Виды записей журнала
+const (
+	kindSimple  = "simple"  // Query простого протокола
+	kindPrepare = "prepare" // Parse/Describe без Execute в том же обмене до Sync (отдельный round-trip)
+	kindExecute = "execute" // Execute расширенного протокола
+)
+
+// entry - запись журнала (строка JSON Lines)
+type entry struct {
+	Time       time.Time `json:"time"`
+	Conn       int       `json:"conn"`
+	Kind       string    `json:"kind"`
+	Statement  string    `json:"statement,omitempty"` // имя подготовленного запроса, пусто - безымянный
+	Cached     bool      `json:"cached,omitempty"`    // именованный запрос подготовлен в одном из прошлых обменов соединения
+	Query      string    `json:"query"`
+	ParamTypes []string  `json:"param_types,omitempty"` // типы параметров из ParameterDescription
+	Params     []*string `json:"params,omitempty"`      // текстовое представление параметров, null - NULL
+	DurationMS float64   `json:"duration_ms"`
+	Rows       int       `json:"rows"`
+	Tag        string    `json:"tag,omitempty"`
+	Error      *pgError  `json:"error,omitempty"`
+}
+
+type pgError struct {
+	Severity   string `json:"severity"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	Detail     string `json:"detail,omitempty"`
+	Constraint string `json:"constraint,omitempty"`
+}
+
+// spy - разбирает сообщения прокси и пишет по записи на каждый выполненный запрос
+type spy struct {
+	mu    sync.Mutex
+	enc   *json.Encoder
+	err   error
+	conns map[int]*connState
+}
+
+func newSpy(w io.Writer) *spy {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &spy{enc: enc, conns: make(map[int]*connState)}
+}
+
+// Err - первая ошибка записи журнала
+func (s *spy) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.err
+}
+
+func (s *spy) write(e *entry) {
+	if err := s.enc.Encode(e); err != nil && s.err == nil {
+		s.err = err
+	}
+}
+
+// statement - подготовленный запрос соединения
+type statement struct {
+	name     string
+	query    string
+	oids     []uint32 // из Parse, затем из ParameterDescription
+	parsed   bool     // получен ParseComplete
+	prepared bool     // Parse завершен в прошлом обмене (до ReadyForQuery)
+}
+
+// portal - запрос с параметрами (Bind)
+type portal struct {
+	statement *statement
+	params    []*string
+}
+
+// op - сообщение клиента расширенного протокола, ожидающее ответа сервера
+type op struct {
+	kind      byte // 'P' Parse, 'B' Bind, 'D' Describe, 'E' Execute, 'C' Close, 'S' Sync
+	statement *statement
+	entry     *entry
+}
+
+// connState - разбор одного соединения
+type connState struct {
+	statements map[string]*statement
+	portals    map[string]*portal
+
+	ops      []op         // ответы сервер отправляет в порядке сообщений клиента
+	simple   *entry       // Query в работе
+	parsed   []*statement // Parse текущего обмена до Sync
+	executed bool         // в текущем обмене был Execute
+	failed   bool         // ошибка в текущем обмене: сервер пропускает сообщения до Sync
+	start    time.Time    // первое сообщение текущего обмена
+}
+
+// Observe - pgwire.Observer
+func (s *spy) Observe(m pgwire.Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.conns[m.Conn]
+	if c == nil {
+		c = &connState{statements: make(map[string]*statement), portals: make(map[string]*portal)}
+		s.conns[m.Conn] = c
+	}
+	if m.From == pgwire.FromFrontend {
+		s.frontend(m, c)
+	} else {
+		s.backend(m, c)
+	}
+}
+
+func (s *spy) frontend(m pgwire.Message, c *connState) {
+	if c.start.IsZero() {
+		c.start = m.Time
+	}
+
+	switch msg := m.Msg.(type) {
+	case *pgproto3.Query:
+		c.simple = &entry{Time: m.Time, Conn: m.Conn, Kind: kindSimple, Query: msg.String}
+	case *pgproto3.Parse:
+		st := &statement{name: msg.Name, query: msg.Query, oids: msg.ParameterOIDs}
+		c.statements[msg.Name] = st
+		c.ops = append(c.ops, op{kind: 'P', statement: st})
+		c.parsed = append(c.parsed, st)
+	case *pgproto3.Describe:
+		c.ops = append(c.ops, op{kind: 'D', statement: c.statements[msg.Name]})
+		if msg.ObjectType == 'P' {
+			c.ops[len(c.ops)-1].statement = nil // описание портала не меняет типы параметров
+		}
+	case *pgproto3.Bind:
+		st := c.statements[msg.PreparedStatement]
+		if st == nil {
+			st = &statement{name: msg.PreparedStatement}
+		}
+		c.portals[msg.DestinationPortal] = &portal{statement: st, params: params(st.oids, msg)}
+		c.ops = append(c.ops, op{kind: 'B', statement: st})
+	case *pgproto3.Execute:
+		p := c.portals[msg.Portal]
+		if p == nil {
+			p = &portal{statement: &statement{}}
+		}
+		c.executed = true
+		c.ops = append(c.ops, op{kind: 'E', statement: p.statement, entry: &entry{
+			Time:      m.Time,
+			Conn:      m.Conn,
+			Kind:      kindExecute,
+			Statement: p.statement.name,
+			Cached:    p.statement.name != "" && p.statement.prepared,
+			Query:     p.statement.query,
+			Params:    p.params,
+		}})
+	case *pgproto3.Close:
+		if msg.ObjectType == 'S' {
+			delete(c.statements, msg.Name)
+		} else {
+			delete(c.portals, msg.Name)
+		}
+		c.ops = append(c.ops, op{kind: 'C'})
+	case *pgproto3.Sync:
+		c.ops = append(c.ops, op{kind: 'S'})
+	case *pgproto3.Terminate:
+		delete(s.conns, m.Conn)
+	}
+}
+
+func (s *spy) backend(m pgwire.Message, c *connState) {
+	switch msg := m.Msg.(type) {
+	case *pgproto3.ParseComplete:
+		if head := c.head(); head != nil && head.kind == 'P' {
+			head.statement.parsed = true
+		}
+		c.pop()
+	case *pgproto3.BindComplete, *pgproto3.CloseComplete:
+		c.pop()
+	case *pgproto3.ParameterDescription:
+		if head := c.head(); head != nil && head.kind == 'D' && head.statement != nil {
+			head.statement.oids = msg.ParameterOIDs
+		}
+	case *pgproto3.RowDescription, *pgproto3.NoData:
+		if c.simple == nil {
+			c.pop() // ответ на Describe
+		}
+	case *pgproto3.DataRow:
+		if c.simple != nil {
+			c.simple.Rows++
+		} else if head := c.head(); head != nil && head.kind == 'E' {
+			head.entry.Rows++
+		}
+	case *pgproto3.CommandComplete:
+		s.complete(m, c, string(msg.CommandTag))
+	case *pgproto3.EmptyQueryResponse, *pgproto3.PortalSuspended:
+		s.complete(m, c, "")
+	case *pgproto3.ErrorResponse:
+		s.fail(m, c, msg)
+	case *pgproto3.ReadyForQuery:
+		s.ready(m, c)
+	}
+}
+
+// complete - завершение Execute или одной команды Query
+func (s *spy) complete(m pgwire.Message, c *connState, tag string) {
+	if c.simple != nil {
+		c.simple.Tag = tag // у Query из нескольких команд - тег последней
+		return
+	}
+	if head := c.head(); head != nil && head.kind == 'E' {
+		c.pop()
+		head.entry.Tag = tag
+		head.entry.DurationMS = duration(head.entry.Time, m.Time)
+		s.write(head.entry)
+	}
+}
+
+// fail - ErrorResponse относится к первому сообщению без ответа; следующие до Sync сервер пропускает.
+// Ошибку Parse, Bind или Describe журнал показывает на Execute этого обмена, а без него - на prepare
+func (s *spy) fail(m pgwire.Message, c *connState, msg *pgproto3.ErrorResponse) {
+	err := &pgError{
+		Severity:   msg.Severity,
+		Code:       msg.Code,
+		Message:    msg.Message,
+		Detail:     msg.Detail,
+		Constraint: msg.ConstraintName,
+	}
+	if c.simple != nil {
+		c.simple.Error = err
+		return
+	}
+
+	var failed *entry
+	var st *statement
+	for len(c.ops) > 0 && c.ops[0].kind != 'S' {
+		o := c.ops[0]
+		c.pop()
+		if st == nil {
+			st = o.statement
+		}
+		if failed == nil && o.kind == 'E' {
+			failed = o.entry
+		}
+	}
+	if failed == nil {
+		failed = c.prepareEntry(m.Conn, st)
+	}
+	failed.Error = err
+	failed.DurationMS = duration(failed.Time, m.Time)
+	s.write(failed)
+	c.failed = true
+}
+
+// ready - конец обмена: Query или сообщений до Sync
+func (s *spy) ready(m pgwire.Message, c *connState) {
+	if c.simple != nil {
+		c.simple.DurationMS = duration(c.simple.Time, m.Time)
+		s.write(c.simple)
+		c.simple = nil
+	} else {
+		c.pop() // Sync
+		if !c.executed && !c.failed {
+			// подготовка без выполнения: отдельный round-trip (lib/pq) или Prepare/Validate
+			for _, st := range c.parsed {
+				e := c.prepareEntry(m.Conn, st)
+				e.DurationMS = duration(e.Time, m.Time)
+				s.write(e)
+			}
+		}
+	}
+
+	for name, st := range c.statements {
+		if !st.parsed {
+			delete(c.statements, name) // Parse с ошибкой: сервер запрос не создал
+			continue
+		}
+		st.prepared = true
+	}
+	c.parsed, c.executed, c.failed, c.start = nil, false, false, time.Time{}
+}
+
+func (c *connState) prepareEntry(conn int, st *statement) *entry {
+	e := &entry{Time: c.start, Conn: conn, Kind: kindPrepare}
+	if st != nil {
+		e.Statement, e.Query, e.ParamTypes = st.name, st.query, typeNames(st.oids)
+	}
+	return e
+}
+
+func (c *connState) head() *op {
+	if len(c.ops) == 0 {
+		return nil
+	}
+	return &c.ops[0]
+}
+
+func (c *connState) pop() {
+	if len(c.ops) > 0 {
+		c.ops = c.ops[1:]
+	}
+}
+
+func duration(from, to time.Time) float64 {
+	return float64(to.Sub(from)) / float64(time.Millisecond)
+}
+
+// connInfo - типы PostgreSQL для разбора параметров в двоичном формате
+var connInfo = pgtype.NewConnInfo()
+
+// params - текстовое представление параметров Bind: текстовые как есть, двоичные - через pgtype
+// по типам запроса, а неизвестные - hex (\x...)
+func params(oids []uint32, bind *pgproto3.Bind) []*string {
+	values := make([]*string, len(bind.Parameters))
+	for i, param := range bind.Parameters {
+		if param == nil {
+			continue
+		}
+		format := int16(0)
+		switch len(bind.ParameterFormatCodes) {
+		case 0:
+		case 1:
+			format = bind.ParameterFormatCodes[0]
+		default:
+			format = bind.ParameterFormatCodes[i]
+		}
+
+		s := string(param)
+		if format != 0 {
+			s = binaryText(oids, i, param)
+		}
+		values[i] = &s
+	}
+	return values
+}
+
+func binaryText(oids []uint32, i int, param []byte) string {
+	raw := `\x` + hex.EncodeToString(param)
+	if i >= len(oids) {
+		return raw
+	}
+	dt, ok := connInfo.DataTypeForOID(oids[i])
+	if !ok {
+		return raw
+	}
+	value := pgtype.NewValue(dt.Value)
+	decoder, ok := value.(pgtype.BinaryDecoder)
+	if !ok || decoder.DecodeBinary(connInfo, param) != nil {
+		return raw
+	}
+	encoder, ok := value.(pgtype.TextEncoder)
+	if !ok {
+		return raw
+	}
+	text, err := encoder.EncodeText(connInfo, nil)
+	if err != nil {
+		return raw
+	}
+	return string(text)
+}
+
+func typeNames(oids []uint32) []string {
+	if len(oids) == 0 {
+		return nil
+	}
+	names := make([]string, len(oids))
+	for i, oid := range oids {
+		if dt, ok := connInfo.DataTypeForOID(oid); ok {
+			names[i] = dt.Name
+		} else {
+			names[i] = strconv.FormatUint(uint64(oid), 10)
+		}
+	}
+	return names
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgtype"
+
+	"github.com/moguchev/postgres/pgwire"
+)
+
+// step - сообщение соединения 1 в записанном порядке
+type step struct {
+	from string
+	msg  pgproto3.Message
+}
+
+func fe(msg pgproto3.FrontendMessage) step { return step{from: pgwire.FromFrontend, msg: msg} }
+func be(msg pgproto3.BackendMessage) step  { return step{from: pgwire.FromBackend, msg: msg} }
+
+// observe - сообщения идут через spy с интервалом в 1ms, поэтому duration_ms - число сообщений между
+// первым сообщением запроса и ответом на него
+func observe(t *testing.T, steps []step) []string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	s := newSpy(&buf)
+	start := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, st := range steps {
+		s.Observe(pgwire.Message{Conn: 1, From: st.from, Time: start.Add(time.Duration(i) * time.Millisecond), Msg: st.msg})
+	}
+	if err := s.Err(); err != nil {
+		t.Fatalf("spy error = %v", err)
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		lines = append(lines, withoutTime(t, scanner.Text()))
+	}
+	return lines
+}
+
+// withoutTime - строка журнала без поля time с ключами по алфавиту
+func withoutTime(t *testing.T, line string) string {
+	t.Helper()
+
+	var e map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &e); err != nil {
+		t.Fatalf("invalid JSON line %s: %v", line, err)
+	}
+	delete(e, "time")
+	b, err := json.Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+var selectStudentFields = &pgproto3.RowDescription{Fields: []pgproto3.FieldDescription{
+	{Name: []byte("id"), DataTypeOID: pgtype.Int8OID, DataTypeSize: 8, TypeModifier: -1, Format: 1},
+}}
+
+func TestSpy(t *testing.T) {
+	const (
+		getStudent    = `SELECT id FROM students WHERE id = $1`
+		insertStudent = `INSERT INTO students (first_name, age) VALUES ($1, $2)`
+	)
+	int8one := []byte{0, 0, 0, 0, 0, 0, 0, 1}
+
+	tests := []struct {
+		name  string
+		steps []step
+		want  []string
+	}{
+		{
+			name: "simple query",
+			steps: []step{
+				fe(&pgproto3.Query{String: "SELECT 1"}),
+				be(&pgproto3.RowDescription{Fields: []pgproto3.FieldDescription{{Name: []byte("?column?"), DataTypeOID: pgtype.Int4OID}}}),
+				be(&pgproto3.DataRow{Values: [][]byte{[]byte("1")}}),
+				be(&pgproto3.CommandComplete{CommandTag: []byte("SELECT 1")}),
+				be(&pgproto3.ReadyForQuery{TxStatus: 'I'}),
+			},
+			want: []string{
+				`{"conn":1,"kind":"simple","query":"SELECT 1","duration_ms":4,"rows":1,"tag":"SELECT 1"}`,
+			},
+		},
+		{
+			name: "pgx prepare then execute from cache",
+			steps: []step{
+				fe(&pgproto3.Parse{Name: "lrupsc_1_0", Query: getStudent}),
+				fe(&pgproto3.Describe{ObjectType: 'S', Name: "lrupsc_1_0"}),
+				fe(&pgproto3.Sync{}),
+				be(&pgproto3.ParseComplete{}),
+				be(&pgproto3.ParameterDescription{ParameterOIDs: []uint32{pgtype.Int8OID}}),
+				be(selectStudentFields),
+				be(&pgproto3.ReadyForQuery{TxStatus: 'I'}),
+
+				fe(&pgproto3.Bind{PreparedStatement: "lrupsc_1_0", ParameterFormatCodes: []int16{1}, Parameters: [][]byte{int8one}, ResultFormatCodes: []int16{1}}),
+				fe(&pgproto3.Describe{ObjectType: 'P'}),
+				fe(&pgproto3.Execute{}),
+				fe(&pgproto3.Sync{}),
+				be(&pgproto3.BindComplete{}),
+				be(selectStudentFields),
+				be(&pgproto3.DataRow{Values: [][]byte{int8one}}),
+				be(&pgproto3.CommandComplete{CommandTag: []byte("SELECT 1")}),
+				be(&pgproto3.ReadyForQuery{TxStatus: 'I'}),
+			},
+			want: []string{
+				`{"conn":1,"kind":"prepare","statement":"lrupsc_1_0","query":"` + getStudent + `","param_types":["int8"],"duration_ms":6,"rows":0}`,
+				`{"conn":1,"kind":"execute","statement":"lrupsc_1_0","cached":true,"query":"` + getStudent + `","params":["1"],"duration_ms":5,"rows":1,"tag":"SELECT 1"}`,
+			},
+		},
+		{
+			name: "pipelined parse bind execute",
+			steps: []step{
+				fe(&pgproto3.Parse{Query: insertStudent, ParameterOIDs: []uint32{pgtype.VarcharOID, pgtype.Int2OID}}),
+				fe(&pgproto3.Bind{Parameters: [][]byte{[]byte("Bob"), []byte("20")}}),
+				fe(&pgproto3.Describe{ObjectType: 'P'}),
+				fe(&pgproto3.Execute{}),
+				fe(&pgproto3.Parse{Query: getStudent, ParameterOIDs: []uint32{pgtype.Int8OID}}),
+				fe(&pgproto3.Bind{ParameterFormatCodes: []int16{1}, Parameters: [][]byte{int8one}}),
+				fe(&pgproto3.Describe{ObjectType: 'P'}),
+				fe(&pgproto3.Execute{}),
+				fe(&pgproto3.Sync{}),
+				be(&pgproto3.ParseComplete{}),
+				be(&pgproto3.BindComplete{}),
+				be(&pgproto3.NoData{}),
+				be(&pgproto3.CommandComplete{CommandTag: []byte("INSERT 0 1")}),
+				be(&pgproto3.ParseComplete{}),
+				be(&pgproto3.BindComplete{}),
+				be(selectStudentFields),
+				be(&pgproto3.DataRow{Values: [][]byte{int8one}}),
+				be(&pgproto3.DataRow{Values: [][]byte{int8one}}),
+				be(&pgproto3.CommandComplete{CommandTag: []byte("SELECT 2")}),
+				be(&pgproto3.ReadyForQuery{TxStatus: 'I'}),
+			},
+			want: []string{
+				`{"conn":1,"kind":"execute","query":"` + insertStudent + `","params":["Bob","20"],"duration_ms":9,"rows":0,"tag":"INSERT 0 1"}`,
+				`{"conn":1,"kind":"execute","query":"` + getStudent + `","params":["1"],"duration_ms":11,"rows":2,"tag":"SELECT 2"}`,
+			},
+		},
+		{
+			name: "error mid batch",
+			steps: []step{
+				fe(&pgproto3.Parse{Query: insertStudent, ParameterOIDs: []uint32{pgtype.VarcharOID, pgtype.Int2OID}}),
+				fe(&pgproto3.Bind{Parameters: [][]byte{[]byte("Bob"), []byte("20")}}),
+				fe(&pgproto3.Execute{}),
+				fe(&pgproto3.Bind{Parameters: [][]byte{[]byte("Bob"), []byte("20")}}),
+				fe(&pgproto3.Execute{}),
+				fe(&pgproto3.Bind{Parameters: [][]byte{[]byte("Will"), []byte("21")}}),
+				fe(&pgproto3.Execute{}),
+				fe(&pgproto3.Sync{}),
+				be(&pgproto3.ParseComplete{}),
+				be(&pgproto3.BindComplete{}),
+				be(&pgproto3.CommandComplete{CommandTag: []byte("INSERT 0 1")}),
+				be(&pgproto3.BindComplete{}),
+				be(&pgproto3.ErrorResponse{
+					Severity:       "ERROR",
+					Code:           "23505",
+					Message:        "duplicate key value violates unique constraint",
+					Detail:         "Key (first_name)=(Bob) already exists.",
+					ConstraintName: "students_first_name_key",
+				}),
+				be(&pgproto3.ReadyForQuery{TxStatus: 'I'}),
+
+				// после ReadyForQuery соединение работает дальше
+				fe(&pgproto3.Query{String: "SELECT 1"}),
+				be(&pgproto3.CommandComplete{CommandTag: []byte("SELECT 0")}),
+				be(&pgproto3.ReadyForQuery{TxStatus: 'I'}),
+			},
+			want: []string{
+				`{"conn":1,"kind":"execute","query":"` + insertStudent + `","params":["Bob","20"],"duration_ms":8,"rows":0,"tag":"INSERT 0 1"}`,
+				`{"conn":1,"kind":"execute","query":"` + insertStudent + `","params":["Bob","20"],"duration_ms":8,"rows":0,` +
+					`"error":{"severity":"ERROR","code":"23505","message":"duplicate key value violates unique constraint","detail":"Key (first_name)=(Bob) already exists.","constraint":"students_first_name_key"}}`,
+				`{"conn":1,"kind":"simple","query":"SELECT 1","duration_ms":2,"rows":0,"tag":"SELECT 0"}`,
+			},
+		},
+		{
+			name: "parse error without execute",
+			steps: []step{
+				fe(&pgproto3.Parse{Name: "s1", Query: "SELEC 1"}),
+				fe(&pgproto3.Describe{ObjectType: 'S', Name: "s1"}),
+				fe(&pgproto3.Sync{}),
+				be(&pgproto3.ErrorResponse{Severity: "ERROR", Code: "42601", Message: `syntax error at or near "SELEC"`}),
+				be(&pgproto3.ReadyForQuery{TxStatus: 'I'}),
+			},
+			want: []string{
+				`{"conn":1,"kind":"prepare","statement":"s1","query":"SELEC 1","duration_ms":3,"rows":0,` +
+					`"error":{"severity":"ERROR","code":"42601","message":"syntax error at or near \"SELEC\""}}`,
+			},
+		},
+		{
+			name: "binary params",
+			steps: []step{
+				fe(&pgproto3.Parse{
+					Query:         `SELECT $1::int8, $2::varchar, $3, $4::int2, $5::text, $6::bool`,
+					ParameterOIDs: []uint32{pgtype.Int8OID, pgtype.VarcharOID, 999999, pgtype.Int2OID, pgtype.TextOID, pgtype.BoolOID},
+				}),
+				fe(&pgproto3.Bind{
+					ParameterFormatCodes: []int16{1, 1, 1, 1, 0, 1},
+					Parameters:           [][]byte{{0, 0, 0, 0, 0, 0, 0, 42}, []byte("Bob"), {0x01, 0x02}, nil, []byte("text"), {1}},
+				}),
+				fe(&pgproto3.Execute{}),
+				// один код формата относится ко всем параметрам; int4 не совпадает с типом int8 - hex
+				fe(&pgproto3.Parse{Query: getStudent, ParameterOIDs: []uint32{pgtype.Int8OID}}),
+				fe(&pgproto3.Bind{ParameterFormatCodes: []int16{1}, Parameters: [][]byte{{0, 0, 0, 7}}}),
+				fe(&pgproto3.Execute{}),
+				fe(&pgproto3.Sync{}),
+				be(&pgproto3.ParseComplete{}),
+				be(&pgproto3.BindComplete{}),
+				be(&pgproto3.DataRow{Values: [][]byte{nil, nil, nil, nil, nil, nil}}),
+				be(&pgproto3.CommandComplete{CommandTag: []byte("SELECT 1")}),
+				be(&pgproto3.ParseComplete{}),
+				be(&pgproto3.BindComplete{}),
+				be(&pgproto3.CommandComplete{CommandTag: []byte("SELECT 0")}),
+				be(&pgproto3.ReadyForQuery{TxStatus: 'I'}),
+			},
+			want: []string{
+				`{"conn":1,"kind":"execute","query":"SELECT $1::int8, $2::varchar, $3, $4::int2, $5::text, $6::bool",` +
+					`"params":["42","Bob","\\x0102",null,"text","t"],"duration_ms":8,"rows":1,"tag":"SELECT 1"}`,
+				`{"conn":1,"kind":"execute","query":"` + getStudent + `","params":["\\x00000007"],"duration_ms":8,"rows":0,"tag":"SELECT 0"}`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got := observe(t, tt.steps)
+			if len(got) != len(tt.want) {
+				t.Fatalf("spy wrote %d entries, want %d:\n%s", len(got), len(tt.want), joinLines(got))
+			}
+			for i, want := range tt.want {
+				if want = withoutTime(t, want); got[i] != want {
+					t.Errorf("entry #%d:\n  want: %s\n  got:  %s", i, want, got[i])
+				}
+			}
+		})
+	}
+}
+
+func joinLines(lines []string) string {
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/moguchev/postgres/3/models"
+	"github.com/moguchev/postgres/3/repository"
+)
+
+func runExport(ctx context.Context, b *backend, table string, enc encoder) error {
+	var err error
+	switch table {
+	case tableStudents:
+		// построчно, без загрузки всей таблицы в память
+		err = b.students.StreamStudents(ctx, repository.StudentsFilter{}, func(s models.Student) error {
+			return enc.Encode(newStudentRecord(s))
+		})
+	case tableGroups:
+		err = b.groups.StreamGroups(ctx, func(g models.Group) error {
+			return enc.Encode(&groupRecord{ID: g.ID, Name: g.Name})
+		})
+	case tableMemberships:
+		// одним запросом, без обхода групп по одной
+		err = b.groups.StreamMemberships(ctx, func(studentID, groupID int64) error {
+			return enc.Encode(&membershipRecord{StudentID: studentID, GroupID: groupID})
+		})
+	default:
+		return fmt.Errorf("unknown table %q", table)
+	}
+	if err != nil {
+		return fmt.Errorf("export %s: %w", table, err)
+	}
+
+	return enc.Flush()
+}
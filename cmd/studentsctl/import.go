@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/moguchev/postgres/3/models"
+	"github.com/moguchev/postgres/3/repository"
+)
+
+type importOptions struct {
+	dryRun    bool
+	batchSize int
+	newIDs    bool // id студентов и групп назначает БД (не годится, если следом загружаются students_groups)
+}
+
+// parsedRecord - прошедшая проверку запись и номер ее строки во входном файле
+type parsedRecord struct {
+	rec  record
+	line int
+}
+
+// insertBatch - загружает пакет записей через BulkInsert* репозитория таблицы
+type insertBatch func(ctx context.Context, b *backend, batch []parsedRecord) (int64, error)
+
+// newImport - чтение и загрузка записей таблицы table
+func newImport(table string, dec decoder, opts importOptions) (*recordReader, insertBatch, error) {
+	r := &recordReader{dec: dec, seen: make(map[int64]int)}
+	var insert insertBatch
+	switch table {
+	case tableStudents:
+		r.newRecord = func() record { return &studentRecord{} }
+		r.validate = func(rec record) error {
+			if err := checkID(rec.(*studentRecord).ID, opts.newIDs); err != nil {
+				return err
+			}
+			return rec.(*studentRecord).student().Validate()
+		}
+		if !opts.newIDs {
+			r.key = func(rec record) (string, int64) { return "id", rec.(*studentRecord).ID }
+		}
+		insert = func(ctx context.Context, b *backend, batch []parsedRecord) (int64, error) {
+			students := make([]models.Student, 0, len(batch))
+			for _, p := range batch {
+				students = append(students, p.rec.(*studentRecord).student())
+			}
+			return b.students.BulkInsertStudents(ctx, students, bulkOptions(len(batch), opts)...)
+		}
+	case tableGroups:
+		r.newRecord = func() record { return &groupRecord{} }
+		r.validate = func(rec record) error {
+			if err := checkID(rec.(*groupRecord).ID, opts.newIDs); err != nil {
+				return err
+			}
+			return models.Group{Name: rec.(*groupRecord).Name}.Validate()
+		}
+		if !opts.newIDs {
+			r.key = func(rec record) (string, int64) { return "id", rec.(*groupRecord).ID }
+		}
+		insert = func(ctx context.Context, b *backend, batch []parsedRecord) (int64, error) {
+			groups := make([]models.Group, 0, len(batch))
+			for _, p := range batch {
+				groups = append(groups, models.Group{ID: p.rec.(*groupRecord).ID, Name: p.rec.(*groupRecord).Name})
+			}
+			return b.groups.BulkInsertGroups(ctx, groups, bulkOptions(len(batch), opts)...)
+		}
+	case tableMemberships:
+		if opts.newIDs {
+			// членства ссылаются на id из выгрузки: с новыми id студентов и групп они указали бы не на те строки
+			return nil, nil, fmt.Errorf("-new-ids can not be used with %s: import students and groups with their ids first", tableMemberships)
+		}
+		r.newRecord = func() record { return &membershipRecord{} }
+		r.validate = validateMembership
+		// UNIQUE(student_id): студент состоит не более чем в одной группе
+		r.key = func(rec record) (string, int64) { return "student_id", rec.(*membershipRecord).StudentID }
+		insert = func(ctx context.Context, b *backend, batch []parsedRecord) (int64, error) {
+			memberships := make([]models.Membership, 0, len(batch))
+			for _, p := range batch {
+				m := p.rec.(*membershipRecord)
+				memberships = append(memberships, models.Membership{StudentID: m.StudentID, GroupID: m.GroupID})
+			}
+			n, err := b.groups.BulkInsertMemberships(ctx, memberships, bulkOptions(len(batch), opts)...)
+
+			var rowErr *models.RowError
+			if errors.As(err, &rowErr) && errors.Is(rowErr.Err, models.ErrNotFound) && rowErr.Row < len(memberships) {
+				m := memberships[rowErr.Row]
+				rowErr.Err = fmt.Errorf("student %d or group %d does not exist: import students and groups with their ids first", m.StudentID, m.GroupID)
+			}
+			return n, err
+		}
+	default:
+		return nil, nil, fmt.Errorf("unknown table %q", table)
+	}
+	return r, insert, nil
+}
+
+func runImport(ctx context.Context, connect func() (*backend, error), table string, dec decoder, opts importOptions) error {
+	if opts.batchSize <= 0 {
+		opts.batchSize = repository.DefaultBulkBatchSize
+	}
+	r, insert, err := newImport(table, dec, opts)
+	if err != nil {
+		return err
+	}
+
+	if opts.dryRun {
+		var valid int
+		for {
+			batch, err := r.batch(opts.batchSize)
+			if err != nil {
+				return err
+			}
+			if len(batch) == 0 {
+				break
+			}
+			valid += len(batch)
+		}
+		if err := r.report(); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "dry run: %d rows are valid\n", valid)
+		return nil
+	}
+
+	b, err := connect()
+	if err != nil {
+		return err
+	}
+	defer b.close()
+
+	// все пакеты - в одной транзакции: ошибка любой строки откатывает весь импорт
+	var imported int64
+	err = b.tx.WithinTx(ctx, repository.TxOptions{}, func(ctx context.Context) error {
+		for batchNum := 1; ; batchNum++ {
+			batch, err := r.batch(opts.batchSize)
+			if err != nil {
+				return err
+			}
+			if len(batch) == 0 {
+				return r.report()
+			}
+			if len(r.invalid) > 0 {
+				continue // после невалидной строки не загружаем, а дочитываем файл, чтобы сообщить обо всех ошибках
+			}
+
+			n, err := insert(ctx, b, batch)
+			var rowErr *models.RowError
+			if errors.As(err, &rowErr) && rowErr.Row < len(batch) {
+				return &lineError{Line: batch[rowErr.Row].line, Err: rowErr.Err}
+			}
+			if err != nil {
+				return err
+			}
+
+			imported += n
+			fmt.Fprintf(os.Stderr, "batch %d: %d rows (%d total)\n", batchNum, n, imported)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("import %s: %w", table, err)
+	}
+
+	fmt.Fprintf(os.Stderr, "imported %d rows into %s\n", imported, table)
+	return nil
+}
+
+// bulkOptions - опции BulkInsert* для одного пакета импорта
+func bulkOptions(rows int, opts importOptions) []repository.BulkInsertOption {
+	bulkOpts := []repository.BulkInsertOption{repository.WithBatchSize(rows)}
+	if !opts.newIDs {
+		bulkOpts = append(bulkOpts, repository.WithKeepIDs())
+	}
+	return bulkOpts
+}
+
+// recordReader - читает файл потоково пакетами валидных записей. Невалидные записи и повторы ключа
+// внутри файла не прерывают чтение, а собираются в invalid
+type recordReader struct {
+	dec       decoder
+	newRecord func() record
+	validate  func(record) error
+	key       func(record) (field string, value int64) // уникальный ключ записи; nil - не проверяется
+	seen      map[int64]int                            // ключ -> строка, на которой он встретился впервые
+	invalid   []error
+}
+
+// batch - до size следующих валидных записей; пустой срез - конец файла
+func (r *recordReader) batch(size int) ([]parsedRecord, error) {
+	batch := make([]parsedRecord, 0, size)
+	for len(batch) < size {
+		rec := r.newRecord()
+		line, err := r.dec.Decode(rec)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		var lerr *lineError
+		switch {
+		case errors.As(err, &lerr):
+			r.invalid = append(r.invalid, lerr)
+			continue
+		case err != nil:
+			return nil, err
+		}
+
+		if err = r.validate(rec); err != nil {
+			r.invalid = append(r.invalid, &lineError{Line: line, Err: err})
+			continue
+		}
+		if r.key != nil {
+			field, value := r.key(rec)
+			if first, ok := r.seen[value]; ok {
+				r.invalid = append(r.invalid, &lineError{Line: line, Err: &models.ValidationError{
+					Field:  field,
+					Reason: fmt.Sprintf("duplicates line %d", first),
+				}})
+				continue
+			}
+			r.seen[value] = line
+		}
+		batch = append(batch, parsedRecord{rec: rec, line: line})
+	}
+	return batch, nil
+}
+
+// report - печатает невалидные записи; ошибка, если они есть
+func (r *recordReader) report() error {
+	for _, lerr := range r.invalid {
+		fmt.Fprintln(os.Stderr, lerr)
+	}
+	if len(r.invalid) > 0 {
+		return fmt.Errorf("%d invalid rows, nothing imported", len(r.invalid))
+	}
+	return nil
+}
+
+// checkID - без -new-ids id обязателен: по нему на строку ссылаются students_groups
+func checkID(id int64, newIDs bool) error {
+	if !newIDs && id <= 0 {
+		return &models.ValidationError{Field: "id", Reason: "is required to keep references from students_groups (or use -new-ids)"}
+	}
+	return nil
+}
+
+func validateMembership(rec record) error {
+	m := rec.(*membershipRecord)
+	switch {
+	case m.StudentID <= 0:
+		return &models.ValidationError{Field: "student_id", Reason: "must be a positive integer"}
+	case m.GroupID <= 0:
+		return &models.ValidationError{Field: "group_id", Reason: "must be a positive integer"}
+	}
+	return nil
+}
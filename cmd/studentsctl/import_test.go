@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/moguchev/postgres/3/models"
+	"github.com/moguchev/postgres/3/repository"
+	groups_inmemory "github.com/moguchev/postgres/3/repository/groups/inmemory_implementation"
+	students_inmemory "github.com/moguchev/postgres/3/repository/students/inmemory_implementation"
+	tx_inmemory "github.com/moguchev/postgres/3/repository/transaction/inmemory_implementation"
+)
+
+func TestRecordReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		table   string
+		input   string
+		newIDs  bool
+		valid   int
+		invalid []string
+	}{
+		{
+			name:  "duplicate student ids",
+			table: tableStudents,
+			input: "id,first_name,last_name,age\n1,Bob,Brown,20\n2,Will,Williams,21\n1,Ann,Smith,22\n,Kate,Lee,23\n2,Tom,Ford,24\n",
+			valid: 2,
+			invalid: []string{
+				"line 4: validation failed: id: duplicates line 2",
+				"line 5: validation failed: id: is required to keep references from students_groups (or use -new-ids)",
+				"line 6: validation failed: id: duplicates line 3",
+			},
+		},
+		{
+			name:   "ids are not checked with new ids",
+			table:  tableStudents,
+			input:  "id,first_name,last_name,age\n1,Bob,Brown,20\n1,Ann,Smith,22\n,Kate,Lee,23\n",
+			newIDs: true,
+			valid:  3,
+		},
+		{
+			name:    "duplicate group ids",
+			table:   tableGroups,
+			input:   "id,name\n1,first\n1,second\n",
+			valid:   1,
+			invalid: []string{"line 3: validation failed: id: duplicates line 2"},
+		},
+		{
+			name:    "student in two groups",
+			table:   tableMemberships,
+			input:   "student_id,group_id\n1,1\n2,1\n1,2\n",
+			valid:   2,
+			invalid: []string{"line 4: validation failed: student_id: duplicates line 2"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dec, err := newDecoder(formatCSV, strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// пакеты по 2 записи: повтор ищется и в пакете, и в уже прочитанных
+			err = runImport(context.Background(), noConnect(t), tt.table, dec, importOptions{dryRun: true, batchSize: 2, newIDs: tt.newIDs})
+			if (err != nil) != (len(tt.invalid) > 0) {
+				t.Fatalf("dry run error = %v, want %d invalid rows", err, len(tt.invalid))
+			}
+
+			dec, _ = newDecoder(formatCSV, strings.NewReader(tt.input))
+			r, _, err := newImport(tt.table, dec, importOptions{newIDs: tt.newIDs})
+			if err != nil {
+				t.Fatal(err)
+			}
+			valid := 0
+			for {
+				batch, err := r.batch(2)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(batch) == 0 {
+					break
+				}
+				valid += len(batch)
+			}
+
+			var invalid []string
+			for _, err := range r.invalid {
+				invalid = append(invalid, err.Error())
+			}
+			if valid != tt.valid || !reflect.DeepEqual(invalid, tt.invalid) {
+				t.Fatalf("read %d valid rows, invalid %q, want %d, %q", valid, invalid, tt.valid, tt.invalid)
+			}
+		})
+	}
+}
+
+// noConnect - connect для dry run: подключение к БД - ошибка теста
+func noConnect(t *testing.T) func() (*backend, error) {
+	return func() (*backend, error) {
+		t.Error("dry run connected to the database")
+		return nil, models.ErrInternal
+	}
+}
+
+// memoryBackend - репозитории в памяти вместо БД
+func memoryBackend() *backend {
+	txManager := tx_inmemory.NewTxManager()
+	groups := groups_inmemory.NewRepository(groups_inmemory.WithTxManager(txManager))
+	students := students_inmemory.NewRepository(students_inmemory.WithGroups(groups), students_inmemory.WithTxManager(txManager))
+	groups_inmemory.WithStudents(students)(groups)
+
+	return &backend{
+		tx:       txManager,
+		students: students,
+		groups:   groups,
+		close:    func() {},
+	}
+}
+
+func TestImport(t *testing.T) {
+	type file struct {
+		table string
+		input string
+	}
+	var (
+		students    = file{tableStudents, "id,first_name,last_name,age\n1,Bob,Brown,20\n2,Will,Williams,21\n3,Ann,Smith,22\n4,Kate,Lee,23\n5,Tom,Ford,24\n"}
+		groups      = file{tableGroups, "id,name\n1,first\n2,second\n"}
+		memberships = file{tableMemberships, "student_id,group_id\n1,1\n2,1\n3,2\n"}
+	)
+
+	tests := []struct {
+		name        string
+		files       []file
+		wantErr     string // ошибка импорта последнего файла
+		students    int
+		memberships int
+	}{
+		{
+			name:        "all tables in batches",
+			files:       []file{students, groups, memberships},
+			students:    5,
+			memberships: 3,
+		},
+		{
+			name: "invalid row after loaded batches rolls back the import",
+			files: []file{
+				{tableStudents, "id,first_name,last_name,age\n1,Bob,Brown,20\n2,Will,Williams,21\n3,Ann,Smith,22\n4,Kate,Lee,0\n"},
+			},
+			wantErr: "import students: 1 invalid rows, nothing imported",
+		},
+		{
+			name:    "missing group is reported with its line",
+			files:   []file{students, groups, {tableMemberships, "student_id,group_id\n1,1\n2,1\n3,1\n4,7\n"}},
+			wantErr: "import students_groups: line 5: student 4 or group 7 does not exist: import students and groups with their ids first",
+			// students и groups загружены отдельными импортами
+			students: 5,
+		},
+		{
+			name:     "student already in a group",
+			files:    []file{students, groups, memberships, {tableMemberships, "student_id,group_id\n4,1\n2,2\n"}},
+			wantErr:  `import students_groups: line 3: student is already in a group (constraint students_groups_student_id_key): duplicate key value violates unique constraint "students_groups_student_id_key"`,
+			students: 5, memberships: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			b := memoryBackend()
+			connect := func() (*backend, error) { return b, nil }
+
+			var err error
+			for _, f := range tt.files {
+				dec, _ := newDecoder(formatCSV, strings.NewReader(f.input))
+				if err = runImport(context.Background(), connect, f.table, dec, importOptions{batchSize: 2}); err != nil {
+					break
+				}
+			}
+			if (err == nil && tt.wantErr != "") || (err != nil && err.Error() != tt.wantErr) {
+				t.Fatalf("import error = %v, want %q", err, tt.wantErr)
+			}
+
+			ctx := context.Background()
+			students := 0
+			if err = b.students.StreamStudents(ctx, repository.StudentsFilter{}, func(models.Student) error {
+				students++
+				return nil
+			}); err != nil || students != tt.students {
+				t.Fatalf("imported %d students, %v, want %d", students, err, tt.students)
+			}
+			memberships := 0
+			if err = b.groups.StreamMemberships(ctx, func(int64, int64) error {
+				memberships++
+				return nil
+			}); err != nil || memberships != tt.memberships {
+				t.Fatalf("imported %d memberships, %v, want %d", memberships, err, tt.memberships)
+			}
+		})
+	}
+}
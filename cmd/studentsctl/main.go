@@ -0,0 +1,207 @@
+// studentsctl - выгрузка и загрузка таблиц students, groups и students_groups в CSV и JSON Lines.
+//
+//	studentsctl [-driver pgx|database-sql] [-config db.yaml] [-dsn URL] export -table students -format csv [-out students.csv]
+//	studentsctl [-driver pgx|database-sql] [-config db.yaml] [-dsn URL] import -table students -format jsonl [-in students.jsonl] [-dry-run] [-batch-size 5000] [-new-ids]
+//
+// Экспорт читает данные потоково через репозитории (students_groups - одним запросом). Импорт тоже читает файл
+// потоково и загружает все три таблицы через COPY (BulkInsert*) пакетами по -batch-size строк в одной транзакции:
+// ошибка любой строки откатывает весь импорт. id студентов и групп из файла сохраняются,
+// а последовательности сдвигаются за них, поэтому выгрузка students, groups и students_groups загружается
+// в пустую БД в этом же порядке без потери связей. С -new-ids id назначает БД (файл может быть без них);
+// students_groups с -new-ids не загружаются - их id указывали бы не на те строки.
+// Параметры подключения - config.FromFlags: значения по умолчанию, затем -config, переменные PG* и DATABASE_URL, затем -dsn.
+// -dry-run только проверяет файл (те же правила, что models.Student.Validate, и повторы id внутри файла)
+// и не подключается к БД.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/moguchev/postgres/3/logger"
+	"github.com/moguchev/postgres/3/repository"
+	groups_databasesql "github.com/moguchev/postgres/3/repository/groups/database_sql_implementation"
+	groups_pgx "github.com/moguchev/postgres/3/repository/groups/pgx_implementation"
+	students_databasesql "github.com/moguchev/postgres/3/repository/students/database_sql_implementation"
+	students_pgx "github.com/moguchev/postgres/3/repository/students/pgx_implementation"
+	tx_databasesql "github.com/moguchev/postgres/3/repository/transaction/database_sql_implementation"
+	tx_pgx "github.com/moguchev/postgres/3/repository/transaction/pgx_implementation"
+	"github.com/moguchev/postgres/config"
+	"go.uber.org/zap"
+)
+
+const (
+	tableStudents    = "students"
+	tableGroups      = "groups"
+	tableMemberships = "students_groups"
+)
+
+const (
+	driverPgx         = "pgx"
+	driverDatabaseSQL = "database-sql"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("studentsctl: ")
+
+	driver := flag.String("driver", driverPgx, "repository backend: pgx or database-sql")
+	conn := config.FromFlags(flag.CommandLine)
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	connect := func() (*backend, error) {
+		cfg, err := conn.Load(
+			config.WithApplicationName("studentsctl"),
+			config.WithLogLevel("warn"), // pgx пишет каждый запрос на уровне info
+		)
+		if err != nil {
+			return nil, err
+		}
+		return openBackend(ctx, *driver, cfg)
+	}
+
+	var err error
+	switch cmd, args := flag.Arg(0), flag.Args()[1:]; cmd {
+	case "export":
+		err = exportCmd(ctx, connect, args)
+	case "import":
+		err = importCmd(ctx, connect, args)
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "usage: studentsctl [flags] export|import [command flags]\n")
+	flag.PrintDefaults()
+}
+
+func exportCmd(ctx context.Context, connect func() (*backend, error), args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	table := fs.String("table", tableStudents, "students, groups or students_groups")
+	format := fs.String("format", formatCSV, "csv or jsonl")
+	out := fs.String("out", "", "output file (default stdout)")
+	_ = fs.Parse(args)
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	enc, err := newEncoder(*format, w)
+	if err != nil {
+		return err
+	}
+
+	b, err := connect()
+	if err != nil {
+		return err
+	}
+	defer b.close()
+
+	return runExport(ctx, b, *table, enc)
+}
+
+func importCmd(ctx context.Context, connect func() (*backend, error), args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	table := fs.String("table", tableStudents, "students, groups or students_groups")
+	format := fs.String("format", formatCSV, "csv or jsonl")
+	in := fs.String("in", "", "input file (default stdin)")
+	dryRun := fs.Bool("dry-run", false, "validate rows and report errors without writing anything")
+	batchSize := fs.Int("batch-size", repository.DefaultBulkBatchSize, "rows per COPY batch")
+	newIDs := fs.Bool("new-ids", false, "let the database assign student and group ids instead of keeping ids from the file")
+	_ = fs.Parse(args)
+
+	var r io.Reader = os.Stdin
+	if *in != "" {
+		f, err := os.Open(*in)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	dec, err := newDecoder(*format, r)
+	if err != nil {
+		return err
+	}
+
+	return runImport(ctx, connect, *table, dec, importOptions{
+		dryRun:    *dryRun,
+		batchSize: *batchSize,
+		newIDs:    *newIDs,
+	})
+}
+
+// backend - репозитории выбранного драйвера и менеджер транзакций к ним
+type backend struct {
+	tx       repository.TxManager
+	students repository.StudentsRepository
+	groups   repository.GroupsRepository
+	close    func()
+}
+
+func openBackend(ctx context.Context, driver string, cfg config.Config) (*backend, error) {
+	// внутренние ошибки репозиториев пишем в stderr, чтобы не смешивать их с экспортом в stdout
+	zapLogger, err := zap.NewDevelopment()
+	if err != nil {
+		return nil, err
+	}
+	repoLogger := logger.NewZap(zapLogger)
+
+	switch driver {
+	case driverPgx:
+		pool, err := config.NewPool(ctx, cfg, config.WithZapLogger(zapLogger))
+		if err != nil {
+			return nil, err
+		}
+		tx := tx_pgx.NewTxManager(pool, tx_pgx.WithLogger(repoLogger))
+		return &backend{
+			tx:       tx,
+			students: students_pgx.NewRepository(pool, students_pgx.WithLogger(repoLogger), students_pgx.WithTxManager(tx)),
+			groups:   groups_pgx.NewRepository(pool, groups_pgx.WithLogger(repoLogger), groups_pgx.WithTxManager(tx)),
+			close:    pool.Close,
+		}, nil
+	case driverDatabaseSQL:
+		db, err := cfg.OpenDB()
+		if err != nil {
+			return nil, err
+		}
+		if err = db.PingContext(ctx); err != nil {
+			db.Close()
+			return nil, err
+		}
+		tx := tx_databasesql.NewTxManager(db, tx_databasesql.WithLogger(repoLogger))
+		return &backend{
+			tx:       tx,
+			students: students_databasesql.NewRepository(db, students_databasesql.WithLogger(repoLogger), students_databasesql.WithTxManager(tx)),
+			groups:   groups_databasesql.NewRepository(db, groups_databasesql.WithLogger(repoLogger), groups_databasesql.WithTxManager(tx)),
+			close:    func() { db.Close() },
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown driver %q (want %s or %s)", driver, driverPgx, driverDatabaseSQL)
+}
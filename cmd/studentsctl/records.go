@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/moguchev/postgres/3/models"
+)
+
+// record - строка одной из таблиц в файле импорта/экспорта
+type record interface {
+	header() []string
+	csvRow() []string
+	fromCSV(fields []string) error
+}
+
+type studentRecord struct {
+	ID        int64  `json:"id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Age       uint   `json:"age"`
+}
+
+func newStudentRecord(s models.Student) *studentRecord {
+	return &studentRecord{ID: s.ID, FirstName: s.FirstName, LastName: s.LastName, Age: s.Age}
+}
+
+func (r *studentRecord) student() models.Student {
+	return models.Student{ID: r.ID, FirstName: r.FirstName, LastName: r.LastName, Age: r.Age}
+}
+
+func (r *studentRecord) header() []string {
+	return []string{"id", "first_name", "last_name", "age"}
+}
+
+func (r *studentRecord) csvRow() []string {
+	return []string{formatInt(r.ID), r.FirstName, r.LastName, strconv.FormatUint(uint64(r.Age), 10)}
+}
+
+func (r *studentRecord) fromCSV(fields []string) (err error) {
+	if r.ID, err = parseID("id", fields[0]); err != nil {
+		return err
+	}
+	r.FirstName, r.LastName = fields[1], fields[2]
+	age, err := strconv.ParseUint(fields[3], 10, 16)
+	if err != nil {
+		return &models.ValidationError{Field: "age", Reason: "must be a positive integer"}
+	}
+	r.Age = uint(age)
+	return nil
+}
+
+type groupRecord struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+func (r *groupRecord) header() []string {
+	return []string{"id", "name"}
+}
+
+func (r *groupRecord) csvRow() []string {
+	return []string{formatInt(r.ID), r.Name}
+}
+
+func (r *groupRecord) fromCSV(fields []string) (err error) {
+	if r.ID, err = parseID("id", fields[0]); err != nil {
+		return err
+	}
+	r.Name = fields[1]
+	return nil
+}
+
+type membershipRecord struct {
+	StudentID int64 `json:"student_id"`
+	GroupID   int64 `json:"group_id"`
+}
+
+func (r *membershipRecord) header() []string {
+	return []string{"student_id", "group_id"}
+}
+
+func (r *membershipRecord) csvRow() []string {
+	return []string{formatInt(r.StudentID), formatInt(r.GroupID)}
+}
+
+func (r *membershipRecord) fromCSV(fields []string) (err error) {
+	if r.StudentID, err = parseID("student_id", fields[0]); err != nil {
+		return err
+	}
+	r.GroupID, err = parseID("group_id", fields[1])
+	return err
+}
+
+func formatInt(v int64) string {
+	return strconv.FormatInt(v, 10)
+}
+
+// parseID - пустое значение допустимо: для id студентов и групп его отвергает checkID (кроме -new-ids)
+func parseID(field, value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id < 0 {
+		return 0, &models.ValidationError{Field: field, Reason: "must be a non-negative integer"}
+	}
+	return id, nil
+}
+
+const (
+	formatCSV   = "csv"
+	formatJSONL = "jsonl"
+)
+
+type encoder interface {
+	Encode(rec record) error
+	Flush() error
+}
+
+func newEncoder(format string, w io.Writer) (encoder, error) {
+	switch format {
+	case formatCSV:
+		return &csvEncoder{w: csv.NewWriter(w)}, nil
+	case formatJSONL:
+		bw := bufio.NewWriter(w)
+		return &jsonlEncoder{w: bw, enc: json.NewEncoder(bw)}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q (want %s or %s)", format, formatCSV, formatJSONL)
+}
+
+type csvEncoder struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (e *csvEncoder) Encode(rec record) error {
+	if !e.headerWritten {
+		if err := e.w.Write(rec.header()); err != nil {
+			return err
+		}
+		e.headerWritten = true
+	}
+	return e.w.Write(rec.csvRow())
+}
+
+func (e *csvEncoder) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type jsonlEncoder struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func (e *jsonlEncoder) Encode(rec record) error {
+	return e.enc.Encode(rec) // Encode дописывает '\n' после каждого объекта
+}
+
+func (e *jsonlEncoder) Flush() error {
+	return e.w.Flush()
+}
+
+// lineError - ошибка в конкретной записи входного файла; чтение следующих записей можно продолжать
+type lineError struct {
+	Line int
+	Err  error
+}
+
+func (e *lineError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+func (e *lineError) Unwrap() error {
+	return e.Err
+}
+
+// decoder - читает записи по одной и возвращает номер строки файла, на которой начинается запись.
+// Ошибка разбора записи возвращается как *lineError, в конце файла - io.EOF, остальные ошибки фатальны
+type decoder interface {
+	Decode(rec record) (line int, err error)
+}
+
+func newDecoder(format string, r io.Reader) (decoder, error) {
+	switch format {
+	case formatCSV:
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = -1 // количество полей проверяем сами, чтобы сообщить номер строки
+		return &csvDecoder{r: cr}, nil
+	case formatJSONL:
+		return &jsonlDecoder{s: bufio.NewScanner(r)}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q (want %s or %s)", format, formatCSV, formatJSONL)
+}
+
+type csvDecoder struct {
+	r          *csv.Reader
+	headerRead bool
+}
+
+func (d *csvDecoder) Decode(rec record) (int, error) {
+	if !d.headerRead {
+		header, err := d.r.Read()
+		if err != nil {
+			return 0, err
+		}
+		d.headerRead = true
+		if !equalStrings(header, rec.header()) {
+			return 1, fmt.Errorf("line 1: unexpected header %v, want %v", header, rec.header())
+		}
+	}
+
+	fields, err := d.r.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return parseErr.StartLine, &lineError{Line: parseErr.StartLine, Err: parseErr.Err}
+		}
+		return 0, err
+	}
+
+	line, _ := d.r.FieldPos(0)
+	if len(fields) != len(rec.header()) {
+		return line, &lineError{Line: line, Err: fmt.Errorf("expected %d fields, got %d", len(rec.header()), len(fields))}
+	}
+	if err = rec.fromCSV(fields); err != nil {
+		return line, &lineError{Line: line, Err: err}
+	}
+	return line, nil
+}
+
+type jsonlDecoder struct {
+	s    *bufio.Scanner
+	line int
+}
+
+func (d *jsonlDecoder) Decode(rec record) (int, error) {
+	for d.s.Scan() {
+		d.line++
+		data := bytes.TrimSpace(d.s.Bytes())
+		if len(data) == 0 {
+			continue // пустые строки пропускаем
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(rec); err != nil {
+			return d.line, &lineError{Line: d.line, Err: err}
+		}
+		// в строке ровно один объект: Decode читает только первое значение и не видит продолжения
+		if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+			return d.line, &lineError{Line: d.line, Err: errors.New("unexpected data after the object")}
+		}
+		return d.line, nil
+	}
+	if err := d.s.Err(); err != nil {
+		return d.line, err
+	}
+	return d.line, io.EOF
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// decoded - запись или ошибка строки из decodeAll
+type decoded struct {
+	line int
+	rec  record // nil, если строка не разобрана
+	err  string // текст *lineError
+}
+
+// decodeAll - все записи файла до io.EOF или фатальной ошибки
+func decodeAll(t *testing.T, format, input string, newRecord func() record) ([]decoded, error) {
+	t.Helper()
+
+	dec, err := newDecoder(format, strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []decoded
+	for {
+		rec := newRecord()
+		line, err := dec.Decode(rec)
+		if errors.Is(err, io.EOF) {
+			return got, nil
+		}
+
+		var lerr *lineError
+		switch {
+		case errors.As(err, &lerr):
+			if lerr.Line != line {
+				t.Fatalf("Decode line = %d, lineError.Line = %d", line, lerr.Line)
+			}
+			got = append(got, decoded{line: line, err: lerr.Error()})
+		case err != nil:
+			return got, err
+		default:
+			got = append(got, decoded{line: line, rec: rec})
+		}
+	}
+}
+
+func newStudent() record    { return &studentRecord{} }
+func newMembership() record { return &membershipRecord{} }
+
+func TestCSVDecoder(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		newRecord func() record
+		want      []decoded
+		wantErr   string // фатальная ошибка
+	}{
+		{
+			name:      "students",
+			input:     "id,first_name,last_name,age\n1,Bob,Brown,20\n2,Will,Williams,21\n",
+			newRecord: newStudent,
+			want: []decoded{
+				{line: 2, rec: &studentRecord{ID: 1, FirstName: "Bob", LastName: "Brown", Age: 20}},
+				{line: 3, rec: &studentRecord{ID: 2, FirstName: "Will", LastName: "Williams", Age: 21}},
+			},
+		},
+		{
+			name:      "quoted field spans lines",
+			input:     "id,first_name,last_name,age\n1,\"Bob\nJr\",Brown,20\n2,Will,Williams,21\n",
+			newRecord: newStudent,
+			want: []decoded{
+				{line: 2, rec: &studentRecord{ID: 1, FirstName: "Bob\nJr", LastName: "Brown", Age: 20}},
+				{line: 4, rec: &studentRecord{ID: 2, FirstName: "Will", LastName: "Williams", Age: 21}},
+			},
+		},
+		{
+			name:      "invalid rows do not stop decoding",
+			input:     "id,first_name,last_name,age\n1,Bob,Brown\n2,Will,Williams,old\nx,Ann,Smith,22\n4,Kate,Lee,23\n",
+			newRecord: newStudent,
+			want: []decoded{
+				{line: 2, err: "line 2: expected 4 fields, got 3"},
+				{line: 3, err: "line 3: validation failed: age: must be a positive integer"},
+				{line: 4, err: "line 4: validation failed: id: must be a non-negative integer"},
+				{line: 5, rec: &studentRecord{ID: 4, FirstName: "Kate", LastName: "Lee", Age: 23}},
+			},
+		},
+		{
+			name:      "bare quote",
+			input:     "student_id,group_id\n1,1\n2,\"1\"x\n3,1\n",
+			newRecord: newMembership,
+			want: []decoded{
+				{line: 2, rec: &membershipRecord{StudentID: 1, GroupID: 1}},
+				{line: 3, err: `line 3: extraneous or missing " in quoted-field`},
+				{line: 4, rec: &membershipRecord{StudentID: 3, GroupID: 1}},
+			},
+		},
+		{
+			name:      "unexpected header",
+			input:     "student_id,group_id\n1,1\n",
+			newRecord: newStudent,
+			wantErr:   "line 1: unexpected header [student_id group_id], want [id first_name last_name age]",
+		},
+		{
+			name:      "empty file",
+			newRecord: newStudent,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeAll(t, formatCSV, tt.input, tt.newRecord)
+			if (err == nil && tt.wantErr != "") || (err != nil && err.Error() != tt.wantErr) {
+				t.Fatalf("Decode error = %v, want %q", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("decoded %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONLDecoder(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		newRecord func() record
+		want      []decoded
+	}{
+		{
+			name:      "blank lines are skipped but counted",
+			input:     "{\"id\":1,\"first_name\":\"Bob\",\"last_name\":\"Brown\",\"age\":20}\n\n  \n{\"id\":2,\"first_name\":\"Will\",\"last_name\":\"Williams\",\"age\":21}\n",
+			newRecord: newStudent,
+			want: []decoded{
+				{line: 1, rec: &studentRecord{ID: 1, FirstName: "Bob", LastName: "Brown", Age: 20}},
+				{line: 4, rec: &studentRecord{ID: 2, FirstName: "Will", LastName: "Williams", Age: 21}},
+			},
+		},
+		{
+			name:      "last line without newline",
+			input:     `{"student_id":1,"group_id":2}`,
+			newRecord: newMembership,
+			want: []decoded{
+				{line: 1, rec: &membershipRecord{StudentID: 1, GroupID: 2}},
+			},
+		},
+		{
+			name:      "invalid rows do not stop decoding",
+			input:     "{\"student_id\":1,\"group\":2}\n{\"student_id\":\"1\",\"group_id\":2}\n{\"student_id\":1,\n{\"student_id\":3,\"group_id\":1}\n",
+			newRecord: newMembership,
+			want: []decoded{
+				{line: 1, err: `line 1: json: unknown field "group"`},
+				{line: 2, err: "line 2: json: cannot unmarshal string into Go struct field membershipRecord.student_id of type int64"},
+				{line: 3, err: "line 3: unexpected EOF"},
+				{line: 4, rec: &membershipRecord{StudentID: 3, GroupID: 1}},
+			},
+		},
+		{
+			name:      "trailing data after the object",
+			input:     "{\"student_id\":1,\"group_id\":2} {\"student_id\":2,\"group_id\":2}\n{\"student_id\":3,\"group_id\":2}x\n{\"student_id\":4,\"group_id\":2}]\n{\"student_id\":5,\"group_id\":2} \n",
+			newRecord: newMembership,
+			want: []decoded{
+				{line: 1, err: "line 1: unexpected data after the object"},
+				{line: 2, err: "line 2: unexpected data after the object"},
+				{line: 3, err: "line 3: unexpected data after the object"},
+				{line: 4, rec: &membershipRecord{StudentID: 5, GroupID: 2}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeAll(t, formatJSONL, tt.input, tt.newRecord)
+			if err != nil {
+				t.Fatalf("Decode error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("decoded %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,296 @@
+// Package config - параметры подключения к PostgreSQL и настройки пула соединений.
+//
+// Параметры собираются по слоям: значения по умолчанию (Default), затем источники в порядке
+// передачи в Load - файл YAML/TOML (FromFile), URL postgres:// (FromURL), переменные окружения
+// (FromEnv, совместимы с libpq: PGHOST, PGPORT, PGUSER, PGPASSWORD, PGDATABASE, ..., и DATABASE_URL поверх них).
+// Каждый следующий слой переопределяет только заданные в нем значения:
+//
+//	cfg, err := config.Load(config.FromFile("db.yaml"), config.FromEnv())
+//	db, err := cfg.OpenDB()          // *sql.DB (lib/pq)
+//	pool, err := config.NewPool(ctx, cfg, config.WithZapLogger(zapLogger)) // *pgxpool.Pool
+//
+// Пример файла:
+//
+//	host: localhost
+//	port: 5432
+//	user: user
+//	password: password
+//	database: playground
+//	sslmode: disable
+//	connect_timeout: 5s
+//	statement_timeout: 30s
+//	timezone: UTC
+//	log_level: info
+//	cursor_secret: change-me-32-random-bytes
+//	params:
+//	  target_session_attrs: read-write
+//	pool:
+//	  max_conns: 10
+//	  min_conns: 2
+//	  max_idle_conns: 2
+//	  max_conn_lifetime: 1h
+//	  max_conn_idle_time: 1m
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// Config - параметры подключения и пула, общие для database/sql и pgxpool
+type Config struct {
+	Host            string        `yaml:"host" toml:"host"`
+	Port            int           `yaml:"port" toml:"port"`
+	User            string        `yaml:"user" toml:"user"`
+	Password        string        `yaml:"password" toml:"password"`
+	Database        string        `yaml:"database" toml:"database"`
+	SSLMode         string        `yaml:"sslmode" toml:"sslmode"`
+	ApplicationName string        `yaml:"application_name" toml:"application_name"`
+	ConnectTimeout  time.Duration `yaml:"connect_timeout" toml:"connect_timeout"` // 0 - ждать бесконечно
+
+	// настройки сессии, выставляются каждому новому соединению
+	StatementTimeout time.Duration `yaml:"statement_timeout" toml:"statement_timeout"` // 0 - значение сервера
+	TimeZone         string        `yaml:"timezone" toml:"timezone"`                   // пусто - значение сервера
+
+	// LogLevel - уровень логирования pgx (trace, debug, info, warn, error, none), используется с WithZapLogger
+	LogLevel string `yaml:"log_level" toml:"log_level"`
+
+	// CursorSecret - секрет подписи курсоров keyset пагинации ListStudents (repository.NewCursorCodec).
+	// Общий у всех экземпляров сервиса, иначе курсор не переживет перезапуск и не подойдет другой реплике
+	CursorSecret string `yaml:"cursor_secret" toml:"cursor_secret"`
+
+	// Params - остальные параметры подключения (sslrootcert, sslcert, sslkey, target_session_attrs, ...),
+	// передаются драйверу в DSN как есть. Параметры, у которых есть поле в Config, здесь не задаются
+	Params map[string]string `yaml:"params" toml:"params"`
+
+	Pool Pool `yaml:"pool" toml:"pool"`
+}
+
+// Pool - настройки пула соединений
+type Pool struct {
+	MaxConns        int           `yaml:"max_conns" toml:"max_conns"`                   // sql.DB.SetMaxOpenConns, pgxpool.Config.MaxConns
+	MinConns        int           `yaml:"min_conns" toml:"min_conns"`                   // pgxpool.Config.MinConns (database/sql не держит соединения заранее)
+	MaxIdleConns    int           `yaml:"max_idle_conns" toml:"max_idle_conns"`         // sql.DB.SetMaxIdleConns (pgxpool не ограничивает простаивающие отдельно)
+	MaxConnLifetime time.Duration `yaml:"max_conn_lifetime" toml:"max_conn_lifetime"`   // sql.DB.SetConnMaxLifetime, pgxpool.Config.MaxConnLifetime
+	MaxConnIdleTime time.Duration `yaml:"max_conn_idle_time" toml:"max_conn_idle_time"` // sql.DB.SetConnMaxIdleTime, pgxpool.Config.MaxConnIdleTime
+}
+
+// MinCursorSecretLen - минимальная длина Config.CursorSecret
+const MinCursorSecretLen = 16
+
+// sslModes - допустимые значения sslmode (как в libpq)
+var sslModes = map[string]bool{
+	"disable":     true,
+	"allow":       true,
+	"prefer":      true,
+	"require":     true,
+	"verify-ca":   true,
+	"verify-full": true,
+}
+
+// Default - параметры локальной БД из docker-compose.yaml
+func Default() Config {
+	return Config{
+		Host:     "localhost",
+		Port:     5432,
+		User:     "user",
+		Password: "password",
+		Database: "playground",
+		SSLMode:  "disable",
+
+		Pool: Pool{
+			MaxConns:        10,
+			MinConns:        2,
+			MaxIdleConns:    2,
+			MaxConnLifetime: time.Hour,
+			MaxConnIdleTime: time.Minute,
+		},
+	}
+}
+
+// Option - источник или отдельная настройка, применяемая к Config в Load
+type Option func(c *Config) error
+
+// Load - Default(), к которому по порядку применены opts; результат проверяется Validate
+func Load(opts ...Option) (Config, error) {
+	c := Default()
+	for _, opt := range opts {
+		if err := opt(&c); err != nil {
+			return Config{}, err
+		}
+	}
+
+	if err := c.Validate(); err != nil {
+		return Config{}, err
+	}
+	return c, nil
+}
+
+// WithApplicationName - application_name сессии (видно в pg_stat_activity)
+func WithApplicationName(name string) Option {
+	return func(c *Config) error {
+		c.ApplicationName = name
+		return nil
+	}
+}
+
+// WithLogLevel - уровень логирования pgx (см. Config.LogLevel)
+func WithLogLevel(level string) Option {
+	return func(c *Config) error {
+		c.LogLevel = level
+		return nil
+	}
+}
+
+// WithPool - настройки пула целиком
+func WithPool(pool Pool) Option {
+	return func(c *Config) error {
+		c.Pool = pool
+		return nil
+	}
+}
+
+// Validate - проверяет, что параметры заданы и согласованы между собой
+func (c Config) Validate() error {
+	var errs []string
+	if c.Host == "" {
+		errs = append(errs, "host is empty")
+	}
+	if c.Port <= 0 || c.Port > 65535 {
+		errs = append(errs, fmt.Sprintf("port %d out of range", c.Port))
+	}
+	if c.User == "" {
+		errs = append(errs, "user is empty")
+	}
+	if c.Database == "" {
+		errs = append(errs, "database is empty")
+	}
+	if c.SSLMode != "" && !sslModes[c.SSLMode] {
+		errs = append(errs, fmt.Sprintf("unknown sslmode %q", c.SSLMode))
+	}
+	if c.ConnectTimeout < 0 {
+		errs = append(errs, "connect_timeout is negative")
+	}
+	if c.StatementTimeout < 0 {
+		errs = append(errs, "statement_timeout is negative")
+	}
+	if c.LogLevel != "" {
+		if _, err := pgx.LogLevelFromString(c.LogLevel); err != nil {
+			errs = append(errs, fmt.Sprintf("unknown log_level %q", c.LogLevel))
+		}
+	}
+	if c.CursorSecret != "" && len(c.CursorSecret) < MinCursorSecretLen {
+		errs = append(errs, fmt.Sprintf("cursor_secret is shorter than %d bytes", MinCursorSecretLen))
+	}
+	for _, key := range sortedKeys(c.Params) {
+		if isOwnParam(key) {
+			errs = append(errs, fmt.Sprintf("params: %s must be set by its own setting", key))
+		}
+	}
+
+	p := c.Pool
+	if p.MaxConns < 0 || p.MinConns < 0 || p.MaxIdleConns < 0 || p.MaxConnLifetime < 0 || p.MaxConnIdleTime < 0 {
+		errs = append(errs, "pool settings must not be negative")
+	}
+	if p.MaxConns > 0 && p.MinConns > p.MaxConns {
+		errs = append(errs, fmt.Sprintf("pool min_conns %d is greater than max_conns %d", p.MinConns, p.MaxConns))
+	}
+
+	if len(errs) > 0 {
+		return errors.New("config: " + strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// DSN - строка подключения в формате key=value, ее понимают и lib/pq, и pgx.
+// Настройки сессии (StatementTimeout, TimeZone) в нее не входят - см. OpenDB и NewPool
+func (c Config) DSN() string {
+	return c.dsn(nil)
+}
+
+// sessionSettings - настройки сессии в формате SET/set_config; незаданные пропускаются
+func (c Config) sessionSettings() map[string]string {
+	settings := make(map[string]string)
+	if c.ApplicationName != "" {
+		settings["application_name"] = c.ApplicationName
+	}
+	if c.StatementTimeout > 0 {
+		settings["statement_timeout"] = strconv.FormatInt(c.StatementTimeout.Milliseconds(), 10)
+	}
+	if c.TimeZone != "" {
+		settings["TimeZone"] = c.TimeZone
+	}
+	return settings
+}
+
+// dsn - DSN с дополнительными параметрами extra
+func (c Config) dsn(extra map[string]string) string {
+	params := make(map[string]string, len(c.Params)+len(extra)+8)
+	for k, v := range c.Params {
+		params[k] = v
+	}
+	for k, v := range map[string]string{
+		"host":             c.Host,
+		"port":             strconv.Itoa(c.Port),
+		"user":             c.User,
+		"password":         c.Password,
+		"dbname":           c.Database,
+		"sslmode":          c.SSLMode,
+		"application_name": c.ApplicationName,
+	} {
+		params[k] = v
+	}
+	if c.ConnectTimeout > 0 {
+		// libpq принимает connect_timeout в целых секундах
+		params["connect_timeout"] = strconv.Itoa(int((c.ConnectTimeout + time.Second - 1) / time.Second))
+	}
+	for k, v := range extra {
+		params[k] = v
+	}
+
+	pairs := make([]string, 0, len(params))
+	for _, k := range sortedKeys(params) {
+		if params[k] != "" {
+			pairs = append(pairs, k+"="+quoteDSNValue(params[k]))
+		}
+	}
+	return strings.Join(pairs, " ")
+}
+
+// isOwnParam - параметр DSN, который задается полем Config (в том числе через параметры URL), а не Config.Params
+func isOwnParam(key string) bool {
+	_, conn := urlConnParams[key]
+	_, own := urlParams[key]
+	return conn || own
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// String - DSN без пароля, для логов
+func (c Config) String() string {
+	if c.Password != "" {
+		c.Password = "xxxxx"
+	}
+	return c.DSN()
+}
+
+// quoteDSNValue - значение key=value строки; пробелы, кавычки и обратные слэши экранируются по правилам libpq
+func quoteDSNValue(v string) string {
+	if !strings.ContainsAny(v, ` '\`) {
+		return v
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+}
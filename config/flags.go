@@ -0,0 +1,43 @@
+package config
+
+import (
+	"database/sql"
+	"flag"
+)
+
+// Flags - флаги -config и -dsn командных утилит (cmd/...)
+type Flags struct {
+	File string // -config: файл настроек для FromFile
+	DSN  string // -dsn: postgres:// URL для FromURL
+}
+
+// FromFlags - регистрирует в fs флаги -config и -dsn; значения доступны после fs.Parse
+func FromFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{}
+	fs.StringVar(&f.File, "config", "", "connection and pool settings file (.yaml, .yml or .toml)")
+	fs.StringVar(&f.DSN, "dsn", "", "postgres:// URL, overrides -config and environment")
+	return f
+}
+
+// Load - Default(), затем defaults (значения по умолчанию утилиты, например WithApplicationName),
+// файл -config, переменные окружения (FromEnv) и -dsn
+func (f *Flags) Load(defaults ...Option) (Config, error) {
+	opts := append([]Option{}, defaults...)
+	if f.File != "" {
+		opts = append(opts, FromFile(f.File))
+	}
+	opts = append(opts, FromEnv())
+	if f.DSN != "" {
+		opts = append(opts, FromURL(f.DSN))
+	}
+	return Load(opts...)
+}
+
+// OpenDB - Load и Config.OpenDB
+func (f *Flags) OpenDB(defaults ...Option) (*sql.DB, error) {
+	cfg, err := f.Load(defaults...)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.OpenDB()
+}
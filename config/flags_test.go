@@ -0,0 +1,65 @@
+package config
+
+import (
+	"flag"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestFromFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want func(c *Config)
+	}{
+		{
+			name: "defaults of the command",
+			want: func(c *Config) {
+				c.ApplicationName, c.LogLevel = "cmd", "error"
+			},
+		},
+		{
+			name: "file over defaults, environment over file, dsn over environment",
+			args: []string{"-config", "testdata/db.yaml", "-dsn", "postgres://dsn@db.dsn/dsn"},
+			env:  map[string]string{"PGUSER": "env", "PGAPPNAME": "env-app"},
+			want: func(c *Config) {
+				*c = fileConfig()
+				c.ApplicationName = "env-app"
+				c.Host, c.User, c.Database = "db.dsn", "dsn", "dsn" // порт и пароль URL не задает
+			},
+		},
+		{
+			name: "environment without flags",
+			env:  map[string]string{"DATABASE_URL": "postgres://url@db.url:6543/url"},
+			want: func(c *Config) {
+				c.ApplicationName, c.LogLevel = "cmd", "error"
+				c.Host, c.Port, c.User, c.Database = "db.url", 6543, "url", "url"
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t, tt.env)
+
+			fs := flag.NewFlagSet("cmd", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			flags := FromFlags(fs)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := flags.Load(WithApplicationName("cmd"), WithLogLevel("error"))
+			if err != nil {
+				t.Fatalf("Load error = %v", err)
+			}
+			want := Default()
+			tt.want(&want)
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("Load = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/log/zapadapter"
+	"github.com/jackc/pgx/v4/pgxpool"
+	_ "github.com/lib/pq" // драйвер postgres для database/sql
+	"go.uber.org/zap"
+)
+
+// OpenDB - *sql.DB (lib/pq) с настройками пула из c.Pool (нулевые значения оставляют значения database/sql
+// по умолчанию, как и в PoolConfig). Как и sql.Open, не подключается к БД.
+// Настройки сессии lib/pq передает серверу при установке каждого соединения
+func (c Config) OpenDB() (*sql.DB, error) {
+	db, err := sql.Open("postgres", c.dsn(c.sessionSettings()))
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Pool.MaxConns > 0 {
+		db.SetMaxOpenConns(c.Pool.MaxConns)
+	}
+	if c.Pool.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(c.Pool.MaxIdleConns)
+	}
+	if c.Pool.MaxConnLifetime > 0 {
+		db.SetConnMaxLifetime(c.Pool.MaxConnLifetime)
+	}
+	if c.Pool.MaxConnIdleTime > 0 {
+		db.SetConnMaxIdleTime(c.Pool.MaxConnIdleTime)
+	}
+
+	return db, nil
+}
+
+// PoolOption - необязательный параметр NewPool
+type PoolOption func(c Config, poolConfig *pgxpool.Config) error
+
+// WithZapLogger - логирование pgx через zap с уровнем Config.LogLevel (по умолчанию info)
+func WithZapLogger(l *zap.Logger) PoolOption {
+	return func(c Config, poolConfig *pgxpool.Config) error {
+		poolConfig.ConnConfig.Logger = zapadapter.NewLogger(l)
+		poolConfig.ConnConfig.LogLevel = pgx.LogLevelInfo
+		if c.LogLevel != "" {
+			level, err := pgx.LogLevelFromString(c.LogLevel)
+			if err != nil {
+				return fmt.Errorf("config: log_level %q: %w", c.LogLevel, err)
+			}
+			poolConfig.ConnConfig.LogLevel = level
+		}
+		return nil
+	}
+}
+
+// WithAfterConnect - дополнительный хук нового соединения, выполняется после настроек сессии
+func WithAfterConnect(fn func(ctx context.Context, conn *pgx.Conn) error) PoolOption {
+	return func(_ Config, poolConfig *pgxpool.Config) error {
+		prev := poolConfig.AfterConnect
+		poolConfig.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+			if prev != nil {
+				if err := prev(ctx, conn); err != nil {
+					return err
+				}
+			}
+			return fn(ctx, conn)
+		}
+		return nil
+	}
+}
+
+// PoolConfig - *pgxpool.Config из c: DSN разбирается pgxpool.ParseConfig, затем применяются
+// ограничения пула (нулевые значения оставляют значения pgxpool по умолчанию), хук AfterConnect
+// с настройками сессии и opts. Настраивать пул нужно до подключения: pgxpool.Pool.Config()
+// возвращает копию, и ее изменения на уже созданный пул не влияют
+func (c Config) PoolConfig(opts ...PoolOption) (*pgxpool.Config, error) {
+	poolConfig, err := pgxpool.ParseConfig(c.DSN())
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+
+	if c.Pool.MaxConns > 0 {
+		poolConfig.MaxConns = int32(c.Pool.MaxConns)
+	}
+	if c.Pool.MinConns > 0 {
+		poolConfig.MinConns = int32(c.Pool.MinConns)
+	}
+	if c.Pool.MaxConnLifetime > 0 {
+		poolConfig.MaxConnLifetime = c.Pool.MaxConnLifetime
+	}
+	if c.Pool.MaxConnIdleTime > 0 {
+		poolConfig.MaxConnIdleTime = c.Pool.MaxConnIdleTime
+	}
+
+	if settings := c.sessionSettings(); len(settings) > 0 {
+		poolConfig.AfterConnect = applySessionSettings(settings)
+	}
+
+	for _, opt := range opts {
+		if err = opt(c, poolConfig); err != nil {
+			return nil, err
+		}
+	}
+
+	return poolConfig, nil
+}
+
+// NewPool - пул соединений pgx, настроенный по cfg (см. Config.PoolConfig)
+func NewPool(ctx context.Context, cfg Config, opts ...PoolOption) (*pgxpool.Pool, error) {
+	poolConfig, err := cfg.PoolConfig(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return pgxpool.ConnectConfig(ctx, poolConfig)
+}
+
+// applySessionSettings - хук AfterConnect, выставляющий настройки сессии одним запросом.
+// Значения передаются параметрами set_config, поэтому не требуют экранирования;
+// неверное значение (например неизвестный часовой пояс) - ошибка подключения
+func applySessionSettings(settings map[string]string) func(ctx context.Context, conn *pgx.Conn) error {
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	query := "SELECT "
+	args := make([]interface{}, 0, 2*len(names))
+	for i, name := range names {
+		if i > 0 {
+			query += ", "
+		}
+		query += fmt.Sprintf("set_config($%d, $%d, false)", 2*i+1, 2*i+2)
+		args = append(args, name, settings[name])
+	}
+
+	return func(ctx context.Context, conn *pgx.Conn) error {
+		if _, err := conn.Exec(ctx, query, args...); err != nil {
+			return fmt.Errorf("config: apply session settings: %w", err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+	"github.com/moguchev/postgres/3/repository/repositorytest/pgstub"
+	"go.uber.org/zap"
+)
+
+func TestPoolConfig(t *testing.T) {
+	defaults, err := Config{Host: "localhost", Port: 5432, User: "u", Database: "db"}.PoolConfig()
+	if err != nil {
+		t.Fatalf("PoolConfig error = %v", err)
+	}
+
+	for _, tt := range []struct {
+		name         string
+		pool         Pool
+		logLevel     string
+		wantMaxConns int32
+		wantMinConns int32
+		wantLifetime time.Duration
+		wantLogLevel pgx.LogLevel
+	}{
+		{
+			name:         "pool settings",
+			pool:         Pool{MaxConns: 7, MinConns: 3, MaxConnLifetime: time.Hour, MaxConnIdleTime: time.Minute},
+			logLevel:     "warn",
+			wantMaxConns: 7,
+			wantMinConns: 3,
+			wantLifetime: time.Hour,
+			wantLogLevel: pgx.LogLevelWarn,
+		},
+		{
+			name:         "zero values keep pgxpool defaults",
+			wantMaxConns: defaults.MaxConns,
+			wantMinConns: defaults.MinConns,
+			wantLifetime: defaults.MaxConnLifetime,
+			wantLogLevel: pgx.LogLevelInfo,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			cfg.Pool = tt.pool
+			cfg.LogLevel = tt.logLevel
+
+			poolConfig, err := cfg.PoolConfig(WithZapLogger(zap.NewNop()))
+			if err != nil {
+				t.Fatalf("PoolConfig error = %v", err)
+			}
+			if poolConfig.MaxConns != tt.wantMaxConns || poolConfig.MinConns != tt.wantMinConns {
+				t.Errorf("MaxConns, MinConns = %d, %d, want %d, %d", poolConfig.MaxConns, poolConfig.MinConns, tt.wantMaxConns, tt.wantMinConns)
+			}
+			if poolConfig.MaxConnLifetime != tt.wantLifetime {
+				t.Errorf("MaxConnLifetime = %s, want %s", poolConfig.MaxConnLifetime, tt.wantLifetime)
+			}
+			if poolConfig.ConnConfig.LogLevel != tt.wantLogLevel {
+				t.Errorf("LogLevel = %s, want %s", poolConfig.ConnConfig.LogLevel, tt.wantLogLevel)
+			}
+			if poolConfig.AfterConnect != nil {
+				t.Errorf("AfterConnect is set without session settings")
+			}
+		})
+	}
+}
+
+func TestNewPoolSessionSettings(t *testing.T) {
+	srv := pgstub.NewServer(t)
+	srv.Expect(`SELECT set_config($1, $2, false), set_config($3, $4, false), set_config($5, $6, false)`).
+		WithArgs("TimeZone", "Europe/Moscow", "application_name", "configtest", "statement_timeout", "1500").
+		WillReturnRows([]pgstub.Column{
+			{Name: "set_config", OID: pgtype.TextOID},
+			{Name: "set_config", OID: pgtype.TextOID},
+			{Name: "set_config", OID: pgtype.TextOID},
+		}, []interface{}{"Europe/Moscow", "configtest", "1500"})
+
+	var hooked bool
+	cfg, err := Load(
+		FromURL(srv.DSN()),
+		WithApplicationName("configtest"),
+		func(c *Config) error {
+			c.StatementTimeout = 1500 * time.Millisecond
+			c.TimeZone = "Europe/Moscow"
+			c.Pool = Pool{MaxConns: 1}
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("Load error = %v", err)
+	}
+
+	ctx := context.Background()
+	pool, err := NewPool(ctx, cfg, WithAfterConnect(func(context.Context, *pgx.Conn) error {
+		hooked = true // WithAfterConnect выполняется после настроек сессии
+		return nil
+	}))
+	if err != nil {
+		t.Fatalf("NewPool error = %v", err)
+	}
+	defer pool.Close()
+
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("Acquire error = %v", err)
+	}
+	conn.Release()
+
+	if !hooked {
+		t.Errorf("WithAfterConnect hook was not called")
+	}
+	startup, ok := srv.Messages()[0].(*pgproto3.StartupMessage)
+	if !ok || startup.Parameters["application_name"] != "configtest" || startup.Parameters["database"] != "pgstub" {
+		t.Errorf("first message = %#v, want StartupMessage with application_name configtest", srv.Messages()[0])
+	}
+}
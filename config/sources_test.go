@@ -0,0 +1,210 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFromURLParams(t *testing.T) {
+	cfg, err := Load(FromURL("postgres://u:p@db:6432/app?sslmode=verify-full&sslrootcert=/certs/root.crt" +
+		"&target_session_attrs=read-write&connect_timeout=3&pool_max_conns=7"))
+	if err != nil {
+		t.Fatalf("Load error = %v", err)
+	}
+
+	if cfg.SSLMode != "verify-full" || cfg.Pool.MaxConns != 7 {
+		t.Fatalf("Load = %+v, want sslmode verify-full and pool max_conns 7", cfg)
+	}
+	want := "connect_timeout=3 dbname=app host=db password=p port=6432 sslmode=verify-full sslrootcert=/certs/root.crt target_session_attrs=read-write user=u"
+	if got := cfg.DSN(); got != want {
+		t.Fatalf("DSN() = %q, want %q", got, want)
+	}
+}
+
+func TestFromURLQueryHost(t *testing.T) {
+	cfg, err := Load(FromURL("postgres:///app?host=/var/run/postgresql&user=u"))
+	if err != nil {
+		t.Fatalf("Load error = %v", err)
+	}
+	if cfg.Host != "/var/run/postgresql" || cfg.User != "u" || cfg.Database != "app" || len(cfg.Params) != 0 {
+		t.Fatalf("Load = %+v, want host, user and database from the url", cfg)
+	}
+}
+
+func TestValidateOwnParams(t *testing.T) {
+	cfg := Default()
+	cfg.Params = map[string]string{"sslmode": "require", "dbname": "other"}
+
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "params: dbname") || !strings.Contains(err.Error(), "params: sslmode") {
+		t.Fatalf("Validate() = %v, want errors for params dbname and sslmode", err)
+	}
+}
+
+// fileConfig - Default() с параметрами из testdata/db.yaml и testdata/db.toml
+func fileConfig() Config {
+	c := Default()
+	c.Host, c.Port, c.User, c.Password, c.Database = "db.internal", 6432, "app", "secret", "students"
+	c.SSLMode = "require"
+	c.ConnectTimeout = 5 * time.Second
+	c.StatementTimeout = 30 * time.Second
+	c.TimeZone = "UTC"
+	c.LogLevel = "warn"
+	c.Params = map[string]string{"target_session_attrs": "read-write"}
+	c.Pool.MaxConns = 20
+	c.Pool.MaxConnLifetime = 30 * time.Minute
+	return c
+}
+
+func TestFromFile(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    Config
+		wantErr string
+	}{
+		{path: "testdata/db.yaml", want: fileConfig()},
+		{path: "testdata/db.toml", want: fileConfig()},
+		{path: "testdata/unknown.yaml", wantErr: "field max_conn not found"},
+		{path: "testdata/missing.yaml", wantErr: "no such file or directory"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.path, func(t *testing.T) {
+			cfg, err := Load(FromFile(tt.path))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load error = %v", err)
+			}
+			if !reflect.DeepEqual(cfg, tt.want) {
+				t.Fatalf("Load = %+v, want %+v", cfg, tt.want)
+			}
+		})
+	}
+}
+
+// setEnv - переменные окружения теста; остальные настройки подключения из окружения сбрасываются
+func setEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+
+	t.Setenv("DATABASE_URL", "")
+	for name := range envParams {
+		t.Setenv(name, "")
+	}
+	for name, value := range env {
+		t.Setenv(name, value)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    func(c *Config)
+		wantErr string
+	}{
+		{
+			name: "libpq variables",
+			env:  map[string]string{"PGHOST": "pghost", "PGPORT": "6432", "PGUSER": "pguser", "PGSTATEMENT_TIMEOUT": "1500", "PGPOOL_MAX_CONNS": "3"},
+			want: func(c *Config) {
+				c.Host, c.Port, c.User = "pghost", 6432, "pguser"
+				c.StatementTimeout = 1500 * time.Millisecond
+				c.Pool.MaxConns = 3
+			},
+		},
+		{
+			name: "port in PGHOST",
+			env:  map[string]string{"PGHOST": "pghost:6432"},
+			want: func(c *Config) {
+				c.Host, c.Port = "pghost", 6432
+			},
+		},
+		{
+			name: "PGPORT overrides port in PGHOST",
+			env:  map[string]string{"PGHOST": "pghost:6432", "PGPORT": "7432"},
+			want: func(c *Config) {
+				c.Host, c.Port = "pghost", 7432
+			},
+		},
+		{
+			name: "DATABASE_URL overrides PG variables",
+			env: map[string]string{
+				"PGHOST": "pghost", "PGPORT": "6432", "PGUSER": "pguser", "PGPASSWORD": "pgpassword",
+				"DATABASE_URL": "postgres://urluser@urlhost:7432/urldb?sslmode=require",
+			},
+			want: func(c *Config) {
+				c.Host, c.Port, c.User, c.Database, c.SSLMode = "urlhost", 7432, "urluser", "urldb", "require"
+				c.Password = "pgpassword" // в URL пароля нет
+			},
+		},
+		{
+			name: "DATABASE_URL without host",
+			env:  map[string]string{"PGHOST": "pghost:6432", "DATABASE_URL": "postgres:///urldb"},
+			want: func(c *Config) {
+				c.Host, c.Port, c.Database = "pghost", 6432, "urldb"
+			},
+		},
+		{
+			name:    "invalid PGPORT",
+			env:     map[string]string{"PGPORT": "pgport"},
+			wantErr: "config: PGPORT:",
+		},
+		{
+			name:    "invalid DATABASE_URL",
+			env:     map[string]string{"DATABASE_URL": "mysql://host/db"},
+			wantErr: `unsupported url scheme "mysql"`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t, tt.env)
+
+			cfg, err := Load(FromEnv())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load error = %v", err)
+			}
+			want := Default()
+			tt.want(&want)
+			if !reflect.DeepEqual(cfg, want) {
+				t.Fatalf("Load = %+v, want %+v", cfg, want)
+			}
+		})
+	}
+}
+
+func TestLoadLayers(t *testing.T) {
+	for _, path := range []string{"testdata/db.yaml", "testdata/db.toml"} {
+		path := path
+		t.Run(path, func(t *testing.T) {
+			// файл < переменные окружения < явный URL (-dsn): каждый слой меняет только заданное в нем
+			setEnv(t, map[string]string{"PGHOST": "pghost", "PGPASSWORD": "pgpassword", "DATABASE_URL": "postgres:///urldb"})
+
+			cfg, err := Load(FromFile(path), FromEnv(), FromURL("postgres://dsnuser@dsnhost"))
+			if err != nil {
+				t.Fatalf("Load error = %v", err)
+			}
+
+			want := fileConfig()
+			want.Password, want.Database = "pgpassword", "urldb"
+			want.Host, want.User = "dsnhost", "dsnuser"
+			if !reflect.DeepEqual(cfg, want) {
+				t.Fatalf("Load = %+v, want %+v", cfg, want)
+			}
+		})
+	}
+}
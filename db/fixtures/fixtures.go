@@ -0,0 +1,109 @@
+// Package fixtures - наборы тестовых и демо данных отдельно от схемы БД (схема - в db/migrations).
+//
+// Набор данных - файл YAML или JSON, в котором для каждой таблицы перечислены строки (колонка: значение):
+//
+//	students:
+//	  - {id: 1, first_name: Bob, last_name: Brown, age: 20}
+//	students_groups:
+//	  - {student_id: 1, group_id: 1}
+//
+// Loader вставляет таблицы в порядке внешних ключей (сначала те, на которые ссылаются),
+// поэтому порядок таблиц в файле не важен, и умеет очищать таблицы между тестами (TRUNCATE ... RESTART IDENTITY).
+// Встроенные наборы (Named): demo - студенты Bob, Will, Harry и две группы.
+package fixtures
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed *.yaml
+var files embed.FS
+
+// Row - строка таблицы: имя колонки -> значение
+type Row map[string]interface{}
+
+// Dataset - именованный набор строк по таблицам
+type Dataset struct {
+	Name   string
+	Tables map[string][]Row
+}
+
+// ErrUnknownDataset - встроенного набора с таким именем нет
+var ErrUnknownDataset = errors.New("unknown dataset")
+
+// Named - встроенный набор данных name
+func Named(name string) (Dataset, error) {
+	for _, ext := range []string{".yaml", ".yml", ".json"} {
+		data, err := fs.ReadFile(files, name+ext)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return Dataset{}, fmt.Errorf("fixtures: %w", err)
+		}
+		return Parse(name, ext, data)
+	}
+	return Dataset{}, fmt.Errorf("fixtures: %w %q (available: %s)", ErrUnknownDataset, name, strings.Join(Names(), ", "))
+}
+
+// Names - имена встроенных наборов данных
+func Names() []string {
+	entries, _ := fs.ReadDir(files, ".") // встроенный каталог читается всегда
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), path.Ext(entry.Name())))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// File - набор данных из файла; имя набора - имя файла без расширения
+func File(filename string) (Dataset, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return Dataset{}, fmt.Errorf("fixtures: %w", err)
+	}
+	ext := filepath.Ext(filename)
+	return Parse(strings.TrimSuffix(filepath.Base(filename), ext), ext, data)
+}
+
+// Parse - набор данных name из data в формате ext (.yaml, .yml или .json)
+func Parse(name, ext string, data []byte) (Dataset, error) {
+	ds := Dataset{Name: name}
+
+	switch strings.ToLower(ext) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &ds.Tables); err != nil {
+			return Dataset{}, fmt.Errorf("fixtures: parse %s: %w", name, err)
+		}
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber() // числа - как есть, без потери точности через float64
+		if err := dec.Decode(&ds.Tables); err != nil {
+			return Dataset{}, fmt.Errorf("fixtures: parse %s: %w", name, err)
+		}
+	default:
+		return Dataset{}, fmt.Errorf("fixtures: %s: unsupported format %q (want .yaml, .yml or .json)", name, ext)
+	}
+
+	for table, rows := range ds.Tables {
+		for i, row := range rows {
+			if len(row) == 0 {
+				return Dataset{}, fmt.Errorf("fixtures: %s: table %s row %d is empty", name, table, i)
+			}
+		}
+	}
+	return ds, nil
+}
@@ -0,0 +1,103 @@
+package fixtures
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNamed(t *testing.T) {
+	if names := Names(); !reflect.DeepEqual(names, []string{"demo"}) {
+		t.Fatalf("Names() = %q, want [demo]", names)
+	}
+
+	ds, err := Named("demo")
+	if err != nil {
+		t.Fatalf("Named error = %v", err)
+	}
+	want := Dataset{
+		Name: "demo",
+		Tables: map[string][]Row{
+			"students": {
+				{"id": 1, "first_name": "Bob", "last_name": "Brown", "age": 20},
+				{"id": 2, "first_name": "Will", "last_name": "Williams", "age": 21},
+				{"id": 3, "first_name": "Harry", "last_name": "Bell", "age": 19},
+			},
+			"groups": {
+				{"id": 1, "name": "group-1"},
+				{"id": 2, "name": "group-2"},
+			},
+			"students_groups": {
+				{"student_id": 1, "group_id": 1},
+				{"student_id": 2, "group_id": 2},
+				{"student_id": 3, "group_id": 2},
+			},
+		},
+	}
+	if !reflect.DeepEqual(ds, want) {
+		t.Fatalf("Named = %+v, want %+v", ds, want)
+	}
+
+	if _, err = Named("missing"); !errors.Is(err, ErrUnknownDataset) {
+		t.Fatalf("Named error = %v, want ErrUnknownDataset", err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		ext     string
+		data    string
+		want    map[string][]Row
+		wantErr string
+	}{
+		{
+			name: "json keeps numbers as is",
+			ext:  ".json",
+			data: `{"students": [{"id": 9007199254740993, "first_name": "Bob"}]}`,
+			want: map[string][]Row{
+				"students": {{"id": json.Number("9007199254740993"), "first_name": "Bob"}},
+			},
+		},
+		{
+			name: "yml extension in upper case",
+			ext:  ".YML",
+			data: "groups:\n  - {id: 1, name: group-1}\n",
+			want: map[string][]Row{
+				"groups": {{"id": 1, "name": "group-1"}},
+			},
+		},
+		{
+			name:    "empty row",
+			ext:     ".yaml",
+			data:    "groups:\n  - {id: 1, name: group-1}\n  - {}\n",
+			wantErr: "fixtures: set: table groups row 1 is empty",
+		},
+		{
+			name:    "invalid yaml",
+			ext:     ".yaml",
+			data:    "groups: [",
+			wantErr: "fixtures: parse set: yaml: line 1: did not find expected node content",
+		},
+		{
+			name:    "unsupported format",
+			ext:     ".csv",
+			data:    "id,name\n",
+			wantErr: `fixtures: set: unsupported format ".csv" (want .yaml, .yml or .json)`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ds, err := Parse("set", tt.ext, []byte(tt.data))
+			if (err == nil && tt.wantErr != "") || (err != nil && err.Error() != tt.wantErr) {
+				t.Fatalf("Parse error = %v, want %q", err, tt.wantErr)
+			}
+			if err == nil && (ds.Name != "set" || !reflect.DeepEqual(ds.Tables, tt.want)) {
+				t.Fatalf("Parse = %+v, want tables %+v", ds, tt.want)
+			}
+		})
+	}
+}
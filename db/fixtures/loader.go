@@ -0,0 +1,257 @@
+package fixtures
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/moguchev/postgres/db/migrations"
+)
+
+type Loader struct {
+	db *sql.DB
+}
+
+func NewLoader(db *sql.DB) *Loader {
+	return &Loader{
+		db: db,
+	}
+}
+
+// Load - вставляет строки наборов данных в одной транзакции, таблицы - в порядке внешних ключей.
+// Если строки задают id явно, последовательность serial колонки id сдвигается на максимальный id,
+// чтобы следующие INSERT без id не конфликтовали с данными набора
+func (l *Loader) Load(ctx context.Context, datasets ...Dataset) error {
+	return l.withinTx(ctx, func(tx *sql.Tx) error {
+		return load(ctx, tx, merge(datasets))
+	})
+}
+
+// Reload - очищает таблицы наборов данных (TRUNCATE ... RESTART IDENTITY CASCADE) и загружает их заново,
+// все в одной транзакции
+func (l *Loader) Reload(ctx context.Context, datasets ...Dataset) error {
+	tables := merge(datasets)
+	return l.withinTx(ctx, func(tx *sql.Tx) error {
+		names := make([]string, 0, len(tables))
+		for table := range tables {
+			names = append(names, table)
+		}
+		sort.Strings(names) // порядок map случаен, а текст запроса должен быть один
+		if err := truncate(ctx, tx, names); err != nil {
+			return err
+		}
+		return load(ctx, tx, tables)
+	})
+}
+
+// Reset - очищает tables и сбрасывает их последовательности (TRUNCATE ... RESTART IDENTITY CASCADE).
+// Без tables очищает все таблицы текущей схемы, кроме таблицы версий миграций
+func (l *Loader) Reset(ctx context.Context, tables ...string) error {
+	return l.withinTx(ctx, func(tx *sql.Tx) error {
+		if len(tables) == 0 {
+			var err error
+			if tables, err = schemaTables(ctx, tx); err != nil {
+				return err
+			}
+		}
+		return truncate(ctx, tx, tables)
+	})
+}
+
+func (l *Loader) withinTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := l.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("fixtures: %w", err)
+	}
+	defer tx.Rollback() // после Commit - no-op
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("fixtures: %w", err)
+	}
+	return nil
+}
+
+// merge - строки всех наборов по таблицам, в порядке наборов
+func merge(datasets []Dataset) map[string][]Row {
+	tables := make(map[string][]Row)
+	for _, ds := range datasets {
+		for table, rows := range ds.Tables {
+			tables[table] = append(tables[table], rows...)
+		}
+	}
+	return tables
+}
+
+func load(ctx context.Context, tx *sql.Tx, tables map[string][]Row) error {
+	order, err := insertOrder(ctx, tx, tables)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range order {
+		withID := false
+		for i, row := range tables[table] {
+			columns := make([]string, 0, len(row))
+			for column := range row {
+				columns = append(columns, column)
+			}
+			sort.Strings(columns)
+
+			quoted := make([]string, len(columns))
+			placeholders := make([]string, len(columns))
+			args := make([]interface{}, len(columns))
+			for j, column := range columns {
+				quoted[j] = pq.QuoteIdentifier(column)
+				placeholders[j] = "$" + strconv.Itoa(j+1)
+				args[j] = row[column]
+			}
+			_, withRowID := row["id"]
+			withID = withID || withRowID
+
+			query := "INSERT INTO " + pq.QuoteIdentifier(table) +
+				" (" + strings.Join(quoted, ", ") + ") VALUES (" + strings.Join(placeholders, ", ") + ")"
+			if _, err = tx.ExecContext(ctx, query, args...); err != nil {
+				return fmt.Errorf("fixtures: insert into %s row %d: %w", table, i, err)
+			}
+		}
+
+		if withID {
+			if err = syncSequence(ctx, tx, table); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// syncSequence - сдвигает последовательность serial колонки id на максимальный id таблицы
+func syncSequence(ctx context.Context, tx *sql.Tx, table string) error {
+	var sequence sql.NullString
+	if err := tx.QueryRowContext(ctx, "SELECT pg_get_serial_sequence($1, 'id')", pq.QuoteIdentifier(table)).Scan(&sequence); err != nil {
+		return fmt.Errorf("fixtures: %s id sequence: %w", table, err)
+	}
+	if !sequence.Valid {
+		return nil // id без последовательности
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"SELECT setval($1, max(id)) FROM "+pq.QuoteIdentifier(table), sequence.String,
+	); err != nil {
+		return fmt.Errorf("fixtures: %s id sequence: %w", table, err)
+	}
+	return nil
+}
+
+// insertOrder - таблицы наборов данных так, что таблица идет после всех таблиц, на которые ссылается
+// ее внешний ключ (ссылки на таблицы вне набора и на саму себя не учитываются)
+func insertOrder(ctx context.Context, tx *sql.Tx, tables map[string][]Row) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, `
+	SELECT conrelid::regclass::text, confrelid::regclass::text
+	FROM pg_constraint
+	WHERE contype = 'f'`)
+	if err != nil {
+		return nil, fmt.Errorf("fixtures: foreign keys: %w", err)
+	}
+	defer rows.Close()
+
+	dependsOn := make(map[string]map[string]bool, len(tables))
+	for table := range tables {
+		dependsOn[table] = make(map[string]bool)
+	}
+	for rows.Next() {
+		var table, referenced string
+		if err = rows.Scan(&table, &referenced); err != nil {
+			return nil, fmt.Errorf("fixtures: foreign keys: %w", err)
+		}
+		if _, ok := tables[referenced]; ok && table != referenced && dependsOn[table] != nil {
+			dependsOn[table][referenced] = true
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("fixtures: foreign keys: %w", err)
+	}
+
+	// топологическая сортировка; среди готовых таблиц - по имени, чтобы порядок был детерминированным
+	order := make([]string, 0, len(tables))
+	for len(order) < len(tables) {
+		var ready []string
+		for table, deps := range dependsOn {
+			if deps != nil && len(deps) == 0 {
+				ready = append(ready, table)
+			}
+		}
+		if len(ready) == 0 {
+			return nil, fmt.Errorf("fixtures: foreign key cycle between tables %v", pending(dependsOn))
+		}
+		sort.Strings(ready)
+
+		for _, table := range ready {
+			dependsOn[table] = nil
+			for _, deps := range dependsOn {
+				delete(deps, table)
+			}
+		}
+		order = append(order, ready...)
+	}
+	return order, nil
+}
+
+func pending(dependsOn map[string]map[string]bool) []string {
+	var tables []string
+	for table, deps := range dependsOn {
+		if deps != nil {
+			tables = append(tables, table)
+		}
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+// schemaTables - таблицы текущей схемы, кроме таблицы версий миграций
+func schemaTables(ctx context.Context, tx *sql.Tx) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, `
+	SELECT tablename
+	FROM pg_tables
+	WHERE schemaname = current_schema() AND tablename <> $1
+	ORDER BY tablename`, migrations.Table)
+	if err != nil {
+		return nil, fmt.Errorf("fixtures: list tables: %w", err)
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err = rows.Scan(&table); err != nil {
+			return nil, fmt.Errorf("fixtures: list tables: %w", err)
+		}
+		tables = append(tables, table)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("fixtures: list tables: %w", err)
+	}
+	return tables, nil
+}
+
+func truncate(ctx context.Context, tx *sql.Tx, tables []string) error {
+	if len(tables) == 0 {
+		return nil
+	}
+
+	quoted := make([]string, len(tables))
+	for i, table := range tables {
+		quoted[i] = pq.QuoteIdentifier(table)
+	}
+	if _, err := tx.ExecContext(ctx, "TRUNCATE "+strings.Join(quoted, ", ")+" RESTART IDENTITY CASCADE"); err != nil {
+		return fmt.Errorf("fixtures: truncate: %w", err)
+	}
+	return nil
+}
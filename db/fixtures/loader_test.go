@@ -0,0 +1,258 @@
+package fixtures
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/moguchev/postgres/3/repository/repositorytest/fakesql"
+)
+
+const (
+	foreignKeys    = `^SELECT conrelid::regclass::text, confrelid::regclass::text FROM pg_constraint WHERE contype = 'f'$`
+	serialSequence = `SELECT pg_get_serial_sequence($1, 'id')`
+)
+
+// expectForeignKeys - граф зависимостей вместо pg_constraint: пары {таблица, таблица, на которую она ссылается}
+func expectForeignKeys(s *fakesql.Script, refs ...[2]string) {
+	rows := make([][]interface{}, 0, len(refs))
+	for _, ref := range refs {
+		rows = append(rows, []interface{}{ref[0], ref[1]})
+	}
+	s.ExpectQueryRegexp(foreignKeys).WillReturnRows([]string{"conrelid", "confrelid"}, rows...)
+}
+
+// expectSyncSequence - поиск последовательности id таблицы и ее сдвиг
+func expectSyncSequence(s *fakesql.Script, table string) {
+	sequence := "public." + table + "_id_seq"
+	s.ExpectQuery(serialSequence).WithArgs(`"`+table+`"`).
+		WillReturnRows([]string{"pg_get_serial_sequence"}, []interface{}{sequence})
+	s.ExpectExec(`SELECT setval($1, max(id)) FROM "` + table + `"`).WithArgs(sequence)
+}
+
+// demoGraph - внешние ключи схемы db/migrations и посторонние ссылки, которые не должны влиять на порядок
+var demoGraph = [][2]string{
+	{"students_groups", "students"},
+	{"students_groups", "groups"},
+	{"groups", "groups"},     // ссылка на себя
+	{"grades", "students"},   // таблицы нет в наборе
+	{"students", "teachers"}, // ссылка на таблицу вне набора
+}
+
+func expectDemo(s *fakesql.Script) {
+	expectForeignKeys(s, demoGraph...)
+	// groups и students готовы одновременно и идут по имени, students_groups - после обеих
+	s.ExpectExec(`INSERT INTO "groups" ("id", "name") VALUES ($1, $2)`).WithArgs(1, "group-1")
+	s.ExpectExec(`INSERT INTO "groups" ("id", "name") VALUES ($1, $2)`).WithArgs(2, "group-2")
+	expectSyncSequence(s, "groups")
+	insertStudent := `INSERT INTO "students" ("age", "first_name", "id", "last_name") VALUES ($1, $2, $3, $4)`
+	s.ExpectExec(insertStudent).WithArgs(20, "Bob", 1, "Brown")
+	s.ExpectExec(insertStudent).WithArgs(21, "Will", 2, "Williams")
+	s.ExpectExec(insertStudent).WithArgs(19, "Harry", 3, "Bell")
+	expectSyncSequence(s, "students")
+	insertMembership := `INSERT INTO "students_groups" ("group_id", "student_id") VALUES ($1, $2)`
+	s.ExpectExec(insertMembership).WithArgs(1, 1)
+	s.ExpectExec(insertMembership).WithArgs(2, 2)
+	s.ExpectExec(insertMembership).WithArgs(2, 3)
+	// у students_groups нет id - последовательность не трогаем
+}
+
+func TestLoad(t *testing.T) {
+	demo, err := Named("demo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		datasets []Dataset
+		expect   func(s *fakesql.Script)
+		wantErr  string
+	}{
+		{
+			name:     "tables in foreign key order",
+			datasets: []Dataset{demo},
+			expect: func(s *fakesql.Script) {
+				s.ExpectBegin()
+				expectDemo(s)
+				s.ExpectCommit()
+			},
+		},
+		{
+			name: "datasets are merged by table",
+			datasets: []Dataset{
+				{Name: "first", Tables: map[string][]Row{"groups": {{"id": 1, "name": "group-1"}}}},
+				{Name: "second", Tables: map[string][]Row{"groups": {{"name": "group-2"}}}},
+			},
+			expect: func(s *fakesql.Script) {
+				s.ExpectBegin()
+				expectForeignKeys(s)
+				s.ExpectExec(`INSERT INTO "groups" ("id", "name") VALUES ($1, $2)`).WithArgs(1, "group-1")
+				s.ExpectExec(`INSERT INTO "groups" ("name") VALUES ($1)`).WithArgs("group-2")
+				expectSyncSequence(s, "groups")
+				s.ExpectCommit()
+			},
+		},
+		{
+			name: "rows without id keep the sequence",
+			datasets: []Dataset{
+				{Name: "set", Tables: map[string][]Row{"groups": {{"name": "group-1"}}}},
+			},
+			expect: func(s *fakesql.Script) {
+				s.ExpectBegin()
+				expectForeignKeys(s)
+				s.ExpectExec(`INSERT INTO "groups" ("name") VALUES ($1)`).WithArgs("group-1")
+				s.ExpectCommit()
+			},
+		},
+		{
+			name: "id without a sequence",
+			datasets: []Dataset{
+				{Name: "set", Tables: map[string][]Row{"codes": {{"id": "a"}}}},
+			},
+			expect: func(s *fakesql.Script) {
+				s.ExpectBegin()
+				expectForeignKeys(s)
+				s.ExpectExec(`INSERT INTO "codes" ("id") VALUES ($1)`).WithArgs("a")
+				s.ExpectQuery(serialSequence).WithArgs(`"codes"`).
+					WillReturnRows([]string{"pg_get_serial_sequence"}, []interface{}{nil})
+				s.ExpectCommit()
+			},
+		},
+		{
+			name: "foreign key cycle",
+			datasets: []Dataset{
+				{Name: "set", Tables: map[string][]Row{
+					"a":     {{"id": 1}},
+					"b":     {{"id": 1}},
+					"c":     {{"id": 1}},
+					"plain": {{"id": 1}},
+				}},
+			},
+			expect: func(s *fakesql.Script) {
+				s.ExpectBegin()
+				expectForeignKeys(s, [2]string{"a", "b"}, [2]string{"b", "c"}, [2]string{"c", "a"})
+				s.ExpectRollback()
+			},
+			wantErr: "fixtures: foreign key cycle between tables [a b c]",
+		},
+		{
+			name: "insert error rolls back",
+			datasets: []Dataset{
+				{Name: "set", Tables: map[string][]Row{"groups": {{"id": 1, "name": "group-1"}, {"id": 1, "name": "group-2"}}}},
+			},
+			expect: func(s *fakesql.Script) {
+				s.ExpectBegin()
+				expectForeignKeys(s)
+				s.ExpectExec(`INSERT INTO "groups" ("id", "name") VALUES ($1, $2)`).WithArgs(1, "group-1")
+				s.ExpectExec(`INSERT INTO "groups" ("id", "name") VALUES ($1, $2)`).WithArgs(1, "group-2").
+					WillReturnError(&pq.Error{Code: "23505", Message: `duplicate key value violates unique constraint "groups_pkey"`})
+				s.ExpectRollback()
+			},
+			wantErr: `fixtures: insert into groups row 1: pq: duplicate key value violates unique constraint "groups_pkey"`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			s := fakesql.NewScript(t)
+			tt.expect(s)
+
+			err := NewLoader(s.DB()).Load(context.Background(), tt.datasets...)
+			if (err == nil && tt.wantErr != "") || (err != nil && err.Error() != tt.wantErr) {
+				t.Fatalf("Load error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReset(t *testing.T) {
+	demo, err := Named("demo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		run     func(ctx context.Context, l *Loader) error
+		expect  func(s *fakesql.Script)
+		wantErr string
+	}{
+		{
+			name: "all tables of the schema except schema_migrations",
+			run: func(ctx context.Context, l *Loader) error {
+				return l.Reset(ctx)
+			},
+			expect: func(s *fakesql.Script) {
+				s.ExpectBegin()
+				s.ExpectQueryRegexp(`^SELECT tablename FROM pg_tables WHERE schemaname = current_schema\(\) AND tablename <> \$1`).
+					WithArgs("schema_migrations").
+					WillReturnRows([]string{"tablename"}, []interface{}{"groups"}, []interface{}{"students"}, []interface{}{"students_groups"})
+				s.ExpectExec(`TRUNCATE "groups", "students", "students_groups" RESTART IDENTITY CASCADE`)
+				s.ExpectCommit()
+			},
+		},
+		{
+			name: "empty schema",
+			run: func(ctx context.Context, l *Loader) error {
+				return l.Reset(ctx)
+			},
+			expect: func(s *fakesql.Script) {
+				s.ExpectBegin()
+				s.ExpectQueryRegexp(`^SELECT tablename FROM pg_tables`).WithArgs("schema_migrations").
+					WillReturnRows([]string{"tablename"})
+				s.ExpectCommit()
+			},
+		},
+		{
+			name: "given tables are quoted",
+			run: func(ctx context.Context, l *Loader) error {
+				return l.Reset(ctx, "students_groups", `odd"name`)
+			},
+			expect: func(s *fakesql.Script) {
+				s.ExpectBegin()
+				s.ExpectExec(`TRUNCATE "students_groups", "odd""name" RESTART IDENTITY CASCADE`)
+				s.ExpectCommit()
+			},
+		},
+		{
+			name: "truncate error rolls back",
+			run: func(ctx context.Context, l *Loader) error {
+				return l.Reset(ctx, "students")
+			},
+			expect: func(s *fakesql.Script) {
+				s.ExpectBegin()
+				s.ExpectExec(`TRUNCATE "students" RESTART IDENTITY CASCADE`).
+					WillReturnError(&pq.Error{Code: "42P01", Message: `relation "students" does not exist`})
+				s.ExpectRollback()
+			},
+			wantErr: `fixtures: truncate: pq: relation "students" does not exist`,
+		},
+		{
+			name: "reload truncates the tables of the datasets and loads them in one transaction",
+			run: func(ctx context.Context, l *Loader) error {
+				return l.Reload(ctx, demo)
+			},
+			expect: func(s *fakesql.Script) {
+				s.ExpectBegin()
+				s.ExpectExec(`TRUNCATE "groups", "students", "students_groups" RESTART IDENTITY CASCADE`)
+				expectDemo(s)
+				s.ExpectCommit()
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			s := fakesql.NewScript(t)
+			tt.expect(s)
+
+			err := tt.run(context.Background(), NewLoader(s.DB()))
+			if (err == nil && tt.wantErr != "") || (err != nil && err.Error() != tt.wantErr) {
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS public.students_groups;
+DROP TABLE IF EXISTS public.groups;
+DROP TABLE IF EXISTS public.students;
@@ -0,0 +1,95 @@
+// Package migrations - версионированные миграции схемы БД, встроенные в бинарник (embed.FS).
+//
+// Миграция - пара файлов NNNN_name.up.sql и NNNN_name.down.sql, версия - число NNNN.
+// Примененные версии хранятся в таблице schema_migrations. Каждая миграция выполняется в отдельной
+// транзакции вместе с записью в schema_migrations, поэтому при ошибке схема остается в состоянии
+// предыдущей версии. На время работы Migrator берет advisory lock, чтобы одновременные деплои
+// не применяли миграции параллельно.
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// FS - миграции этого пакета
+func FS() fs.FS {
+	return files
+}
+
+// Migration - одна версия схемы
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string // пусто, если откат не предусмотрен
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Load - миграции из корня fsys, отсортированные по версии.
+// Файлы, не подходящие под шаблон NNNN_name.(up|down).sql, игнорируются
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("migrations: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	seen := make(map[string]string) // "версия.направление" -> файл, например 0001_init.up.sql и 1_init.up.sql
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migrations: %s: %w", entry.Name(), err)
+		}
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("migrations: %w", err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migrations: version %d has different names: %s and %s", version, m.Name, match[2])
+		}
+		key := fmt.Sprintf("%d.%s", version, match[3])
+		if prev, ok := seen[key]; ok {
+			return nil, fmt.Errorf("migrations: version %d has two %s files: %s and %s", version, match[3], prev, entry.Name())
+		}
+		seen[key] = entry.Name()
+
+		if match[3] == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migrations: version %d (%s) has no up migration", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
@@ -0,0 +1,417 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/moguchev/postgres/3/repository/repositorytest/fakesql"
+)
+
+func file(data string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(data)}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		fsys    fstest.MapFS
+		want    []Migration
+		wantErr string
+	}{
+		{
+			name: "sorted by version with up and down paired",
+			fsys: fstest.MapFS{
+				"0010_groups.up.sql":  file("CREATE TABLE groups ();"),
+				"0002_index.up.sql":   file("CREATE INDEX students_age ON students (age);"),
+				"0002_index.down.sql": file("DROP INDEX students_age;"),
+				"0001_init.down.sql":  file("DROP TABLE students;"),
+				"0001_init.up.sql":    file("CREATE TABLE students ();"),
+			},
+			want: []Migration{
+				{Version: 1, Name: "init", Up: "CREATE TABLE students ();", Down: "DROP TABLE students;"},
+				{Version: 2, Name: "index", Up: "CREATE INDEX students_age ON students (age);", Down: "DROP INDEX students_age;"},
+				{Version: 10, Name: "groups", Up: "CREATE TABLE groups ();"},
+			},
+		},
+		{
+			name: "other files are ignored",
+			fsys: fstest.MapFS{
+				"0001_init.up.sql":      file("CREATE TABLE students ();"),
+				"README.md":             file("migrations"),
+				"0002_draft.sql":        file("SELECT 1;"),
+				"0003_dir.up.sql/x.sql": file("SELECT 1;"),
+			},
+			want: []Migration{
+				{Version: 1, Name: "init", Up: "CREATE TABLE students ();"},
+			},
+		},
+		{
+			name: "missing up file",
+			fsys: fstest.MapFS{
+				"0001_init.up.sql":    file("CREATE TABLE students ();"),
+				"0002_index.down.sql": file("DROP INDEX students_age;"),
+			},
+			wantErr: "migrations: version 2 (index) has no up migration",
+		},
+		{
+			name: "same version with different names",
+			fsys: fstest.MapFS{
+				"0001_init.up.sql":     file("CREATE TABLE students ();"),
+				"0001_students.up.sql": file("CREATE TABLE students ();"),
+			},
+			wantErr: "migrations: version 1 has different names: init and students",
+		},
+		{
+			name: "same version written differently",
+			fsys: fstest.MapFS{
+				"0001_init.up.sql": file("CREATE TABLE students ();"),
+				"1_init.up.sql":    file("CREATE TABLE groups ();"),
+			},
+			wantErr: "migrations: version 1 has two up files: 0001_init.up.sql and 1_init.up.sql",
+		},
+		{
+			name: "empty directory",
+			fsys: fstest.MapFS{},
+			want: []Migration{},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Load(tt.fsys)
+			if (err == nil && tt.wantErr != "") || (err != nil && err.Error() != tt.wantErr) {
+				t.Fatalf("Load error = %v, want %q", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Load = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadEmbedded(t *testing.T) {
+	migrations, err := Load(FS())
+	if err != nil {
+		t.Fatalf("Load error = %v", err)
+	}
+	if len(migrations) == 0 || migrations[0].Version != 1 || migrations[0].Down == "" {
+		t.Fatalf("Load = %+v, want 0001_init with up and down", migrations)
+	}
+}
+
+// testFS - 1 и 2 с откатом, 3 без down файла
+var testFS = fstest.MapFS{
+	"0001_init.up.sql":     file("CREATE TABLE students ();"),
+	"0001_init.down.sql":   file("DROP TABLE students;"),
+	"0002_groups.up.sql":   file("CREATE TABLE groups ();"),
+	"0002_groups.down.sql": file("DROP TABLE groups;"),
+	"0003_seed.up.sql":     file("INSERT INTO groups DEFAULT VALUES;"),
+}
+
+var appliedAt = time.Date(2022, 7, 1, 12, 0, 0, 0, time.UTC)
+
+const (
+	insertVersion = "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)"
+	deleteVersion = "DELETE FROM schema_migrations WHERE version = $1"
+)
+
+// expectLocked - блокировка, таблица версий и чтение примененных версий; fn - запросы под блокировкой
+func expectLocked(s *fakesql.Script, applied []int64, fn func()) {
+	s.ExpectExec("SELECT pg_advisory_lock($1)").WithArgs(lockKey)
+	s.ExpectExecRegexp(`^CREATE TABLE IF NOT EXISTS schema_migrations \(`)
+	rows := make([][]interface{}, 0, len(applied))
+	for _, version := range applied {
+		rows = append(rows, []interface{}{version, appliedAt})
+	}
+	s.ExpectQuery("SELECT version, applied_at FROM schema_migrations").
+		WillReturnRows([]string{"version", "applied_at"}, rows...)
+	fn()
+	s.ExpectExec("SELECT pg_advisory_unlock($1)").WithArgs(lockKey)
+}
+
+// expectApply - транзакция одной миграции: SQL файла и запись в schema_migrations
+func expectApply(s *fakesql.Script, script, record string, args ...interface{}) {
+	s.ExpectBegin()
+	s.ExpectExec(script)
+	s.ExpectExec(record).WithArgs(args...)
+	s.ExpectCommit()
+}
+
+func versions(migrations []Migration) []int64 {
+	got := []int64{}
+	for _, m := range migrations {
+		got = append(got, m.Version)
+	}
+	return got
+}
+
+func TestMigratorUpDown(t *testing.T) {
+	errSyntax := &pq.Error{Code: "42601", Message: "syntax error"}
+
+	tests := []struct {
+		name    string
+		expect  func(s *fakesql.Script)
+		run     func(ctx context.Context, m *Migrator) ([]Migration, error)
+		want    []int64
+		wantErr string
+	}{
+		{
+			name: "up applies pending migrations in order",
+			expect: func(s *fakesql.Script) {
+				expectLocked(s, []int64{1}, func() {
+					expectApply(s, "CREATE TABLE groups ();", insertVersion, int64(2), "groups")
+					expectApply(s, "INSERT INTO groups DEFAULT VALUES;", insertVersion, int64(3), "seed")
+				})
+			},
+			run: func(ctx context.Context, m *Migrator) ([]Migration, error) {
+				return m.Up(ctx, 0)
+			},
+			want: []int64{2, 3},
+		},
+		{
+			name: "up n",
+			expect: func(s *fakesql.Script) {
+				expectLocked(s, nil, func() {
+					expectApply(s, "CREATE TABLE students ();", insertVersion, int64(1), "init")
+				})
+			},
+			run: func(ctx context.Context, m *Migrator) ([]Migration, error) {
+				return m.Up(ctx, 1)
+			},
+			want: []int64{1},
+		},
+		{
+			name: "up with nothing pending",
+			expect: func(s *fakesql.Script) {
+				expectLocked(s, []int64{1, 2, 3}, func() {})
+			},
+			run: func(ctx context.Context, m *Migrator) ([]Migration, error) {
+				return m.Up(ctx, 0)
+			},
+			want: []int64{},
+		},
+		{
+			name: "failed migration is rolled back and stops up",
+			expect: func(s *fakesql.Script) {
+				expectLocked(s, nil, func() {
+					expectApply(s, "CREATE TABLE students ();", insertVersion, int64(1), "init")
+					s.ExpectBegin()
+					s.ExpectExec("CREATE TABLE groups ();").WillReturnError(errSyntax)
+					s.ExpectRollback()
+				})
+			},
+			run: func(ctx context.Context, m *Migrator) ([]Migration, error) {
+				return m.Up(ctx, 0)
+			},
+			want:    []int64{1},
+			wantErr: "migrations: up 2 (groups): pq: syntax error",
+		},
+		{
+			name: "failed version record rolls back the migration",
+			expect: func(s *fakesql.Script) {
+				expectLocked(s, []int64{1, 2}, func() {
+					s.ExpectBegin()
+					s.ExpectExec("INSERT INTO groups DEFAULT VALUES;")
+					s.ExpectExec(insertVersion).WithArgs(int64(3), "seed").WillReturnError(&pq.Error{Code: "23505", Message: "duplicate key"})
+					s.ExpectRollback()
+				})
+			},
+			run: func(ctx context.Context, m *Migrator) ([]Migration, error) {
+				return m.Up(ctx, 0)
+			},
+			want:    []int64{},
+			wantErr: "migrations: up 3 (seed): pq: duplicate key",
+		},
+		{
+			name: "down n rolls back the latest migrations",
+			expect: func(s *fakesql.Script) {
+				expectLocked(s, []int64{1, 2}, func() {
+					expectApply(s, "DROP TABLE groups;", deleteVersion, int64(2))
+				})
+			},
+			run: func(ctx context.Context, m *Migrator) ([]Migration, error) {
+				return m.Down(ctx, 1)
+			},
+			want: []int64{2},
+		},
+		{
+			name: "down all",
+			expect: func(s *fakesql.Script) {
+				expectLocked(s, []int64{1, 2}, func() {
+					expectApply(s, "DROP TABLE groups;", deleteVersion, int64(2))
+					expectApply(s, "DROP TABLE students;", deleteVersion, int64(1))
+				})
+			},
+			run: func(ctx context.Context, m *Migrator) ([]Migration, error) {
+				return m.Down(ctx, 0)
+			},
+			want: []int64{2, 1},
+		},
+		{
+			name: "down without down file",
+			expect: func(s *fakesql.Script) {
+				expectLocked(s, []int64{1, 2, 3}, func() {})
+			},
+			run: func(ctx context.Context, m *Migrator) ([]Migration, error) {
+				return m.Down(ctx, 1)
+			},
+			want:    []int64{},
+			wantErr: "migrations: down 3 (seed): no down migration",
+		},
+		{
+			name: "lock error",
+			expect: func(s *fakesql.Script) {
+				s.ExpectExec("SELECT pg_advisory_lock($1)").WithArgs(lockKey).WillReturnError(&pq.Error{Code: "57014", Message: "canceling statement due to statement timeout"})
+			},
+			run: func(ctx context.Context, m *Migrator) ([]Migration, error) {
+				return m.Up(ctx, 0)
+			},
+			want:    []int64{},
+			wantErr: "migrations: lock: pq: canceling statement due to statement timeout",
+		},
+		{
+			name: "lock is released when the version table cannot be created",
+			expect: func(s *fakesql.Script) {
+				s.ExpectExec("SELECT pg_advisory_lock($1)").WithArgs(lockKey)
+				s.ExpectExecRegexp(`^CREATE TABLE IF NOT EXISTS schema_migrations \(`).WillReturnError(&pq.Error{Code: "42501", Message: "permission denied for schema public"})
+				s.ExpectExec("SELECT pg_advisory_unlock($1)").WithArgs(lockKey)
+			},
+			run: func(ctx context.Context, m *Migrator) ([]Migration, error) {
+				return m.Up(ctx, 0)
+			},
+			want:    []int64{},
+			wantErr: "migrations: create schema_migrations: pq: permission denied for schema public",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			s := fakesql.NewScript(t)
+			tt.expect(s)
+			m, err := New(s.DB(), WithFS(testFS))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			done, err := tt.run(context.Background(), m)
+			if (err == nil && tt.wantErr != "") || (err != nil && err.Error() != tt.wantErr) {
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+			if got := versions(done); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("done versions %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMigratorDownErrNoDownMigration(t *testing.T) {
+	s := fakesql.NewScript(t)
+	expectLocked(s, []int64{3}, func() {})
+	m, err := New(s.DB(), WithFS(testFS))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = m.Down(context.Background(), 0); !errors.Is(err, ErrNoDownMigration) {
+		t.Fatalf("Down error = %v, want ErrNoDownMigration", err)
+	}
+}
+
+func TestMigratorStatus(t *testing.T) {
+	s := fakesql.NewScript(t)
+	expectLocked(s, []int64{1}, func() {})
+	m, err := New(s.DB(), WithFS(testFS))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatalf("Status error = %v", err)
+	}
+	migrations, _ := Load(testFS)
+	want := []Status{
+		{Migration: migrations[0], Applied: true, AppliedAt: appliedAt},
+		{Migration: migrations[1]},
+		{Migration: migrations[2]},
+	}
+	if !reflect.DeepEqual(statuses, want) {
+		t.Fatalf("Status = %+v, want %+v", statuses, want)
+	}
+}
+
+func TestMigratorForce(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int64
+		expect  func(s *fakesql.Script)
+		wantErr string
+	}{
+		{
+			name:    "marks earlier versions applied and later ones not",
+			version: 2,
+			expect: func(s *fakesql.Script) {
+				expectLocked(s, []int64{1, 3}, func() {
+					s.ExpectBegin()
+					s.ExpectExec("DELETE FROM schema_migrations WHERE version > $1").WithArgs(int64(2))
+					s.ExpectExec(insertVersion).WithArgs(int64(2), "groups")
+					s.ExpectCommit()
+				})
+			},
+		},
+		{
+			name:    "version 0 forgets all versions",
+			version: 0,
+			expect: func(s *fakesql.Script) {
+				expectLocked(s, []int64{1, 2}, func() {
+					s.ExpectBegin()
+					s.ExpectExec("DELETE FROM schema_migrations WHERE version > $1").WithArgs(int64(0))
+					s.ExpectCommit()
+				})
+			},
+		},
+		{
+			name:    "error rolls back",
+			version: 3,
+			expect: func(s *fakesql.Script) {
+				expectLocked(s, nil, func() {
+					s.ExpectBegin()
+					s.ExpectExec("DELETE FROM schema_migrations WHERE version > $1").WithArgs(int64(3))
+					s.ExpectExec(insertVersion).WithArgs(int64(1), "init").WillReturnError(&pq.Error{Code: "23505", Message: "duplicate key"})
+					s.ExpectRollback()
+				})
+			},
+			wantErr: "migrations: force: pq: duplicate key",
+		},
+		{
+			name:    "unknown version does not touch the database",
+			version: 4,
+			expect:  func(*fakesql.Script) {},
+			wantErr: "migrations: force: unknown version 4",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			s := fakesql.NewScript(t)
+			tt.expect(s)
+			m, err := New(s.DB(), WithFS(testFS))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = m.Force(context.Background(), tt.version)
+			if (err == nil && tt.wantErr != "") || (err != nil && err.Error() != tt.wantErr) {
+				t.Fatalf("Force error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/fs"
+	"time"
+)
+
+// Table - таблица с примененными версиями
+const Table = "schema_migrations"
+
+// lockKey - ключ pg_advisory_lock, общий для всех экземпляров Migrator
+var lockKey = int64(crc32.ChecksumIEEE([]byte("github.com/moguchev/postgres/db/migrations")))
+
+// ErrNoDownMigration - откат версии, для которой нет down файла
+var ErrNoDownMigration = errors.New("no down migration")
+
+// Status - состояние одной миграции
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// Option - необязательный параметр New
+type Option func(m *Migrator) error
+
+// WithFS - миграции из fsys вместо встроенных в пакет
+func WithFS(fsys fs.FS) Option {
+	return func(m *Migrator) (err error) {
+		m.migrations, err = Load(fsys)
+		return err
+	}
+}
+
+func New(db *sql.DB, opts ...Option) (*Migrator, error) {
+	m := &Migrator{
+		db: db,
+	}
+	if err := WithFS(FS())(m); err != nil {
+		return nil, err
+	}
+	for _, opt := range opts {
+		if err := opt(m); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Up - применяет до n еще не примененных миграций по возрастанию версий (n <= 0 - все).
+// Возвращает примененные миграции
+func (m *Migrator) Up(ctx context.Context, n int) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn, applied map[int64]time.Time) error {
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if n > 0 && len(done) == n {
+				break
+			}
+
+			if err := apply(ctx, conn, migration.Up,
+				"INSERT INTO "+Table+" (version, name) VALUES ($1, $2)", migration.Version, migration.Name,
+			); err != nil {
+				return fmt.Errorf("migrations: up %d (%s): %w", migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down - откатывает n последних примененных миграций по убыванию версий (n <= 0 - все).
+// Возвращает откаченные миграции
+func (m *Migrator) Down(ctx context.Context, n int) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn, applied map[int64]time.Time) error {
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if n > 0 && len(done) == n {
+				break
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migrations: down %d (%s): %w", migration.Version, migration.Name, ErrNoDownMigration)
+			}
+
+			if err := apply(ctx, conn, migration.Down,
+				"DELETE FROM "+Table+" WHERE version = $1", migration.Version,
+			); err != nil {
+				return fmt.Errorf("migrations: down %d (%s): %w", migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status - все известные миграции и признак применения каждой
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.locked(ctx, func(_ *sql.Conn, applied map[int64]time.Time) error {
+		statuses = make([]Status, 0, len(m.migrations))
+		for _, migration := range m.migrations {
+			appliedAt, ok := applied[migration.Version]
+			statuses = append(statuses, Status{
+				Migration: migration,
+				Applied:   ok,
+				AppliedAt: appliedAt,
+			})
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// Force - помечает версии до version включительно примененными, а более поздние - не примененными,
+// не выполняя SQL миграций. Нужна, чтобы подключить миграции к уже существующей схеме
+// (например созданной прежним db/init.sql: force 1) или восстановиться после ручного исправления схемы
+func (m *Migrator) Force(ctx context.Context, version int64) error {
+	known := version == 0
+	for _, migration := range m.migrations {
+		known = known || migration.Version == version
+	}
+	if !known {
+		return fmt.Errorf("migrations: force: unknown version %d", version)
+	}
+
+	return m.locked(ctx, func(conn *sql.Conn, applied map[int64]time.Time) error {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("migrations: force: %w", err)
+		}
+		defer tx.Rollback() // после Commit - no-op
+
+		if _, err = tx.ExecContext(ctx, "DELETE FROM "+Table+" WHERE version > $1", version); err != nil {
+			return fmt.Errorf("migrations: force: %w", err)
+		}
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok || migration.Version > version {
+				continue
+			}
+			if _, err = tx.ExecContext(ctx, "INSERT INTO "+Table+" (version, name) VALUES ($1, $2)", migration.Version, migration.Name); err != nil {
+				return fmt.Errorf("migrations: force: %w", err)
+			}
+		}
+
+		if err = tx.Commit(); err != nil {
+			return fmt.Errorf("migrations: force: %w", err)
+		}
+		return nil
+	})
+}
+
+// locked - выполняет fn на отдельном соединении под advisory lock; таблица версий создается при необходимости.
+// applied - примененные версии и время их применения на момент взятия блокировки
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn, applied map[int64]time.Time) error) error {
+	// блокировка принадлежит сессии, поэтому все запросы идут через одно соединение
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("migrations: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+		return fmt.Errorf("migrations: lock: %w", err)
+	}
+	defer func() {
+		// ctx мог быть отменен, а блокировку нужно снять до возврата соединения в пул
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockKey); err != nil {
+			// соединение с неснятой блокировкой не должно вернуться в пул - driver.ErrBadConn закрывает его
+			_ = conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+	}()
+
+	if _, err = conn.ExecContext(ctx, `
+	CREATE TABLE IF NOT EXISTS `+Table+` (
+	    version    bigint      PRIMARY KEY,
+	    name       text        NOT NULL,
+	    applied_at timestamptz NOT NULL DEFAULT now()
+	)`); err != nil {
+		return fmt.Errorf("migrations: create %s: %w", Table, err)
+	}
+
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM "+Table)
+	if err != nil {
+		return fmt.Errorf("migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var (
+			version   int64
+			appliedAt time.Time
+		)
+		if err = rows.Scan(&version, &appliedAt); err != nil {
+			return fmt.Errorf("migrations: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("migrations: %w", err)
+	}
+	rows.Close()
+
+	return fn(conn, applied)
+}
+
+// apply - SQL миграции и изменение schema_migrations в одной транзакции
+func apply(ctx context.Context, conn *sql.Conn, script, record string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // после Commit - no-op
+
+	// без аргументов lib/pq использует простой протокол, поэтому в файле может быть несколько команд
+	if _, err = tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
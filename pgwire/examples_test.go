@@ -0,0 +1,229 @@
+package pgwire_test
+
+import (
+	"context"
+	"flag"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgtype"
+
+	"github.com/moguchev/postgres/3/repository/repositorytest/pgstub"
+	"github.com/moguchev/postgres/pgwire"
+)
+
+// golden файлы testdata/wire записаны этим тестом с -update: примеры работают через Proxy со сценарием pgstub,
+// который отвечает как БД с демо данными (db/fixtures/demo.yaml). make record-examples перезаписывает их
+// обменом с настоящей БД - тест воспроизводит любую из записей
+var update = flag.Bool("update", false, "record testdata/wire through the proxy against the pgstub scenario")
+
+var (
+	studentNameColumns = []pgstub.Column{
+		{Name: "first_name", OID: pgtype.VarcharOID},
+		{Name: "last_name", OID: pgtype.VarcharOID},
+		{Name: "age", OID: pgtype.Int2OID},
+	}
+	studentColumns = append([]pgstub.Column{{Name: "id", OID: pgtype.Int4OID}}, studentNameColumns...)
+
+	// студенты db/fixtures/demo.yaml
+	studentNames = [][]interface{}{
+		{"Bob", "Brown", 20},
+		{"Will", "Williams", 21},
+		{"Harry", "Bell", 19},
+	}
+	students = [][]interface{}{
+		{1, "Bob", "Brown", 20},
+		{2, "Will", "Williams", 21},
+		{3, "Harry", "Bell", 19},
+	}
+)
+
+const studentsOlderThan = `SELECT first_name, last_name, age FROM students WHERE age >= $1`
+
+var examples = []struct {
+	dir      string // пакет примера относительно корня модуля
+	scenario func(srv *pgstub.Server)
+}{
+	{
+		dir: "1/database-sql",
+		scenario: func(srv *pgstub.Server) {
+			count := func() {
+				srv.Expect(`SELECT count(*) FROM students`).
+					WillReturnRows([]pgstub.Column{{Name: "count", OID: pgtype.Int8OID}}, []interface{}{len(students)})
+			}
+			olderThan := func() {
+				srv.Expect(studentsOlderThan).WithParamOIDs(pgtype.Int2OID).WithArgs(18).
+					WillReturnRows(studentNameColumns, studentNames...)
+			}
+			incAge := func(id, affected int64) {
+				srv.Expect(`UPDATE students SET age = age+1 WHERE id = $1`).WithParamOIDs(pgtype.Int4OID).WithArgs(id).
+					WillReturnRowsAffected(affected)
+			}
+			null := func() {
+				srv.Expect(`SELECT null`).WillReturnRows([]pgstub.Column{{Name: "?column?", OID: pgtype.TextOID}}, []interface{}{nil})
+			}
+			one := func() {
+				srv.Expect(`SELECT 1`).WillReturnRows([]pgstub.Column{{Name: "?column?", OID: pgtype.Int4OID}}, []interface{}{1})
+			}
+
+			count()
+			srv.Expect(`SELECT id FROM students WHERE age = 10000`).
+				WillReturnRows([]pgstub.Column{{Name: "id", OID: pgtype.Int4OID}})
+			olderThan()
+			incAge(1234567, 0)
+			count()
+			olderThan()
+			incAge(1, 1)
+
+			srv.Expect(`BEGIN ISOLATION LEVEL SERIALIZABLE READ WRITE`)
+			one()
+			one()
+			srv.Expect(`COMMIT`)
+
+			null()
+			srv.Expect(`SELECT COALESCE(null, -1) AS some_field`).
+				WillReturnRows([]pgstub.Column{{Name: "some_field", OID: pgtype.Int4OID}}, []interface{}{-1})
+			null()
+			null()
+		},
+	},
+	{
+		dir: "1/sqlx",
+		scenario: func(srv *pgstub.Server) {
+			srv.Expect(studentsOlderThan).WithParamOIDs(pgtype.Int2OID).WithArgs(18).
+				WillReturnRows(studentNameColumns, studentNames...)
+			srv.Expect(`SELECT first_name, last_name,age FROM students LIMIT 1`).
+				WillReturnRows(studentNameColumns, studentNames[0])
+			srv.Expect(studentsOlderThan).WithParamOIDs(pgtype.Int2OID).WithArgs(18).
+				WillReturnRows(studentNameColumns, studentNames...)
+			srv.Expect(`SELECT first_name, last_name, age FROM students WHERE first_name=$1 OR last_name=$2`).
+				WithParamOIDs(pgtype.VarcharOID, pgtype.VarcharOID).
+				WithArgs("Bob", "Brown").
+				WillReturnRows(studentNameColumns, studentNames[0])
+		},
+	},
+	{
+		dir: "2/pgx",
+		scenario: func(srv *pgstub.Server) {
+			srv.Expect(`SELECT first_name, last_name, age FROM students`).
+				WillReturnRows(studentNameColumns, studentNames...)
+			for i := 0; i < 3; i++ {
+				srv.Expect(`SELECT id, first_name, last_name, age FROM students`).
+					WillReturnRows(studentColumns, students...)
+			}
+		},
+	},
+}
+
+// TestExamples - примеры 1/ и 2/ воспроизводятся по golden файлам testdata/wire без БД (как make replay-examples)
+func TestExamples(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds and runs the examples")
+	}
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command is not available")
+	}
+
+	for _, ex := range examples {
+		ex := ex
+		t.Run(ex.dir, func(t *testing.T) {
+			bin := filepath.Join(t.TempDir(), "example")
+			build := exec.Command(goBin, "build", "-o", bin, "./"+ex.dir)
+			build.Dir = ".."
+			if out, err := build.CombinedOutput(); err != nil {
+				t.Fatalf("go build ./%s: %v\n%s", ex.dir, err, out)
+			}
+
+			golden := filepath.Join("..", "testdata", "wire", strings.ReplaceAll(ex.dir, "/", "-")+".jsonl")
+			if *update {
+				record(t, bin, golden, ex.scenario)
+			}
+			replay(t, bin, golden)
+		})
+	}
+}
+
+// record - записывает обмен примера со сценарием pgstub в golden файл
+func record(t *testing.T, bin, golden string, scenario func(srv *pgstub.Server)) {
+	srv := pgstub.NewServer(t)
+	scenario(srv)
+
+	f, err := os.Create(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	w := pgwire.NewGoldenWriter(f)
+	proxy := pgwire.NewProxy(srv.Addr(), w.Observe)
+	runExample(t, bin, func(listener net.Listener) error {
+		return proxy.Serve(listener)
+	})
+	if err = proxy.Close(); err != nil {
+		t.Fatalf("proxy: %v", err)
+	}
+	if err = w.Err(); err != nil {
+		t.Fatalf("golden writer: %v", err)
+	}
+}
+
+// replay - пример выполняется по записи и отправляет те же запросы
+func replay(t *testing.T, bin, golden string) {
+	f, err := os.Open(golden)
+	if err != nil {
+		t.Fatalf("%v (record it with go test ./pgwire -run TestExamples -update)", err)
+	}
+	events, err := pgwire.ReadGolden(f)
+	f.Close()
+	if err != nil {
+		t.Fatalf("%s: %v", golden, err)
+	}
+
+	replayer := pgwire.NewReplayer(events)
+	runExample(t, bin, replayer.Serve)
+	if err = replayer.Close(); err != nil {
+		t.Fatalf("%s: %v", golden, err)
+	}
+}
+
+// runExample - запускает пример с подключением к серверу serve на свободном порту 127.0.0.1
+func runExample(t *testing.T, bin string, serve func(net.Listener) error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = serve(listener) }()
+
+	host, port, err := net.SplitHostPort(listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, bin)
+	cmd.Env = append(exampleEnv(), "PGHOST="+host, "PGPORT="+port, "PGSSLMODE=disable")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("example: %v\n%s", err, out)
+	}
+}
+
+// exampleEnv - окружение без настроек подключения (PG*, DATABASE_URL): запись и воспроизведение
+// должны использовать одни и те же значения config.Default
+func exampleEnv() []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, "PG") || strings.HasPrefix(kv, "DATABASE_URL=") {
+			continue
+		}
+		env = append(env, kv)
+	}
+	return env
+}
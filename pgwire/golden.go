@@ -0,0 +1,84 @@
+package pgwire
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// GoldenWriter - запись обмена в golden файл: по событию (Event) в строке JSON Lines.
+// Безопасен для одновременного использования
+type GoldenWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+func NewGoldenWriter(w io.Writer) *GoldenWriter {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &GoldenWriter{enc: enc}
+}
+
+// Write - записывает событие
+func (w *GoldenWriter) Write(e Event) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.enc.Encode(e)
+}
+
+// Observe - Observer для Proxy: записывает каждое сообщение; первая ошибка записи возвращается из Err
+func (w *GoldenWriter) Observe(m Message) {
+	e, err := NewEvent(m.Conn, m.From, m.Msg)
+	if err == nil {
+		err = w.Write(e)
+	}
+	if err != nil {
+		w.mu.Lock()
+		if w.err == nil {
+			w.err = err
+		}
+		w.mu.Unlock()
+	}
+}
+
+// Err - первая ошибка Observe
+func (w *GoldenWriter) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.err
+}
+
+// ReadGolden - события golden файла по порядку
+func ReadGolden(r io.Reader) ([]Event, error) {
+	var events []Event
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64<<20) // DataRow с большими значениями
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		var e Event
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if e.From != FromFrontend && e.From != FromBackend {
+			return nil, fmt.Errorf("line %d: unknown direction %q", line, e.From)
+		}
+		if _, err := e.Message(); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		events = append(events, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
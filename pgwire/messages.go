@@ -0,0 +1,204 @@
+// Package pgwire - обмен сообщениями протокола PostgreSQL (pgproto3) между клиентом и сервером:
+// прокси, который разбирает сообщения в обе стороны (Proxy), запись обмена в golden файл JSON Lines
+// (GoldenWriter) и сервер, воспроизводящий записанные ответы и сверяющий с записью запросы клиента (Replayer).
+//
+// Записав один раз обмен примеров 1/ и 2/ с настоящей БД (cmd/pgrecord record), можно без БД проверить,
+// что после рефакторинга они отправляют те же запросы тем же протоколом (cmd/pgrecord replay).
+package pgwire
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"unicode/utf8"
+
+	"github.com/jackc/pgproto3/v2"
+)
+
+// Направление сообщения
+const (
+	FromFrontend = "F" // клиент -> сервер
+	FromBackend  = "B" // сервер -> клиент
+)
+
+// Event - сообщение обмена, одна строка golden файла
+type Event struct {
+	Conn int             `json:"conn"` // номер соединения в порядке подключения, с 1
+	From string          `json:"from"` // FromFrontend или FromBackend
+	Type string          `json:"type"` // тип сообщения pgproto3 (Query, Bind, DataRow, ...)
+	Msg  json.RawMessage `json:"msg"`
+}
+
+// NewEvent - событие для сообщения msg соединения conn
+func NewEvent(conn int, from string, msg pgproto3.Message) (Event, error) {
+	data, err := marshal(msg)
+	if err != nil {
+		return Event{}, fmt.Errorf("marshal %T: %w", msg, err)
+	}
+	return Event{Conn: conn, From: from, Type: typeName(msg), Msg: data}, nil
+}
+
+// Message - сообщение события
+func (e Event) Message() (pgproto3.Message, error) {
+	types := backendTypes
+	if e.From == FromFrontend {
+		types = frontendTypes
+	}
+	t, ok := types[e.Type]
+	if !ok {
+		return nil, fmt.Errorf("conn %d: unknown %s message type %q", e.Conn, e.From, e.Type)
+	}
+
+	msg := reflect.New(t).Interface().(pgproto3.Message)
+	if err := unmarshal(e.Msg, msg); err != nil {
+		return nil, fmt.Errorf("conn %d: %s: %w", e.Conn, e.Type, err)
+	}
+	return msg, nil
+}
+
+// Copy - независимая копия сообщения: pgproto3 переиспользует сообщения при следующем Receive
+func Copy(msg pgproto3.Message) (pgproto3.Message, error) {
+	buf := msg.Encode(nil)
+	switch msg.(type) {
+	case *pgproto3.StartupMessage, *pgproto3.SSLRequest, *pgproto3.GSSEncRequest, *pgproto3.CancelRequest:
+		buf = buf[4:] // длина без типа сообщения
+	default:
+		buf = buf[5:] // тип и длина
+	}
+
+	c := reflect.New(reflect.TypeOf(msg).Elem()).Interface().(pgproto3.Message)
+	if err := c.Decode(buf); err != nil {
+		return nil, fmt.Errorf("copy %T: %w", msg, err)
+	}
+	return c, nil
+}
+
+// copyResponse - JSON CopyInResponse/CopyOutResponse/CopyBothResponse: у pgproto3 OverallFormat не
+// попадает в JSON, и сообщение из него не восстанавливается
+type copyResponse struct {
+	OverallFormat     byte
+	ColumnFormatCodes []uint16
+}
+
+// copyData - JSON CopyData: у pgproto3 данные - hex строка, которая читается обратно как текст.
+// Данные в кодировке UTF-8 (COPY в текстовом формате и CSV) записываются текстом, остальные - hex
+type copyData struct {
+	Text *string `json:",omitempty"`
+	Hex  *string `json:",omitempty"`
+}
+
+func marshal(msg pgproto3.Message) ([]byte, error) {
+	switch m := msg.(type) {
+	case *pgproto3.CopyData:
+		var data copyData
+		if s := string(m.Data); utf8.ValidString(s) {
+			data.Text = &s
+		} else {
+			s = hex.EncodeToString(m.Data)
+			data.Hex = &s
+		}
+		return json.Marshal(data)
+	case *pgproto3.CopyInResponse:
+		return json.Marshal(copyResponse{m.OverallFormat, m.ColumnFormatCodes})
+	case *pgproto3.CopyOutResponse:
+		return json.Marshal(copyResponse{m.OverallFormat, m.ColumnFormatCodes})
+	case *pgproto3.CopyBothResponse:
+		return json.Marshal(copyResponse{m.OverallFormat, m.ColumnFormatCodes})
+	}
+	return json.Marshal(msg)
+}
+
+func unmarshal(data []byte, msg pgproto3.Message) error {
+	var response copyResponse
+	switch m := msg.(type) {
+	case *pgproto3.CopyData:
+		var v copyData
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		switch {
+		case v.Text != nil:
+			m.Data = []byte(*v.Text)
+		case v.Hex != nil:
+			b, err := hex.DecodeString(*v.Hex)
+			if err != nil {
+				return err
+			}
+			m.Data = b
+		}
+		return nil
+	case *pgproto3.CopyInResponse:
+		err := json.Unmarshal(data, &response)
+		m.OverallFormat, m.ColumnFormatCodes = response.OverallFormat, response.ColumnFormatCodes
+		return err
+	case *pgproto3.CopyOutResponse:
+		err := json.Unmarshal(data, &response)
+		m.OverallFormat, m.ColumnFormatCodes = response.OverallFormat, response.ColumnFormatCodes
+		return err
+	case *pgproto3.CopyBothResponse:
+		err := json.Unmarshal(data, &response)
+		m.OverallFormat, m.ColumnFormatCodes = response.OverallFormat, response.ColumnFormatCodes
+		return err
+	}
+	return json.Unmarshal(data, msg)
+}
+
+func typeName(msg pgproto3.Message) string {
+	return reflect.TypeOf(msg).Elem().Name()
+}
+
+var (
+	frontendTypes = types(
+		&pgproto3.Bind{},
+		&pgproto3.CancelRequest{},
+		&pgproto3.Close{},
+		&pgproto3.CopyData{},
+		&pgproto3.CopyDone{},
+		&pgproto3.CopyFail{},
+		&pgproto3.Describe{},
+		&pgproto3.Execute{},
+		&pgproto3.Flush{},
+		&pgproto3.FunctionCall{},
+		&pgproto3.GSSEncRequest{},
+		&pgproto3.Parse{},
+		&pgproto3.Query{},
+		&pgproto3.SSLRequest{},
+		&pgproto3.StartupMessage{},
+		&pgproto3.Sync{},
+		&pgproto3.Terminate{},
+	)
+	backendTypes = types(
+		&pgproto3.AuthenticationOk{},
+		&pgproto3.BackendKeyData{},
+		&pgproto3.BindComplete{},
+		&pgproto3.CloseComplete{},
+		&pgproto3.CommandComplete{},
+		&pgproto3.CopyBothResponse{},
+		&pgproto3.CopyData{},
+		&pgproto3.CopyDone{},
+		&pgproto3.CopyInResponse{},
+		&pgproto3.CopyOutResponse{},
+		&pgproto3.DataRow{},
+		&pgproto3.EmptyQueryResponse{},
+		&pgproto3.ErrorResponse{},
+		&pgproto3.FunctionCallResponse{},
+		&pgproto3.NoData{},
+		&pgproto3.NoticeResponse{},
+		&pgproto3.NotificationResponse{},
+		&pgproto3.ParameterDescription{},
+		&pgproto3.ParameterStatus{},
+		&pgproto3.ParseComplete{},
+		&pgproto3.PortalSuspended{},
+		&pgproto3.ReadyForQuery{},
+		&pgproto3.RowDescription{},
+	)
+)
+
+func types(msgs ...pgproto3.Message) map[string]reflect.Type {
+	m := make(map[string]reflect.Type, len(msgs))
+	for _, msg := range msgs {
+		m[typeName(msg)] = reflect.TypeOf(msg).Elem()
+	}
+	return m
+}
@@ -0,0 +1,133 @@
+package pgwire_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgtype"
+
+	"github.com/moguchev/postgres/pgwire"
+)
+
+func TestEventRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		msg  pgproto3.Message
+	}{
+		{"startup", pgwire.FromFrontend, &pgproto3.StartupMessage{
+			ProtocolVersion: pgproto3.ProtocolVersionNumber,
+			Parameters:      map[string]string{"user": "user", "database": "playground"},
+		}},
+		{"parse", pgwire.FromFrontend, &pgproto3.Parse{
+			Name:          "lrupsc_1_0",
+			Query:         "SELECT id FROM students WHERE age >= $1",
+			ParameterOIDs: []uint32{pgtype.Int2OID},
+		}},
+		{"bind text and binary", pgwire.FromFrontend, &pgproto3.Bind{
+			PreparedStatement:    "lrupsc_1_0",
+			ParameterFormatCodes: []int16{0, 1},
+			Parameters:           [][]byte{[]byte("Bob"), {0x00, 0x12}, nil},
+			ResultFormatCodes:    []int16{1},
+		}},
+		{"copy data text", pgwire.FromFrontend, &pgproto3.CopyData{Data: []byte("1\tBob\tBrown\t20\n")}},
+		{"copy data binary", pgwire.FromFrontend, &pgproto3.CopyData{Data: []byte("PGCOPY\n\xff\r\n\x00")}},
+		{"copy in response", pgwire.FromBackend, &pgproto3.CopyInResponse{OverallFormat: 1, ColumnFormatCodes: []uint16{1, 1}}},
+		{"copy out response", pgwire.FromBackend, &pgproto3.CopyOutResponse{OverallFormat: 0, ColumnFormatCodes: []uint16{0}}},
+		{"row description", pgwire.FromBackend, &pgproto3.RowDescription{Fields: []pgproto3.FieldDescription{
+			{Name: []byte("id"), DataTypeOID: pgtype.Int4OID, DataTypeSize: 4, TypeModifier: -1, Format: 1},
+		}}},
+		{"data row with null and binary", pgwire.FromBackend, &pgproto3.DataRow{Values: [][]byte{{0, 0, 0, 1}, nil, []byte("Bob")}}},
+		{"error", pgwire.FromBackend, &pgproto3.ErrorResponse{
+			Severity:       "ERROR",
+			Code:           "23505",
+			Message:        "duplicate key value violates unique constraint",
+			Detail:         "Key (id)=(1) already exists.",
+			ConstraintName: "students_pkey",
+		}},
+		{"ready for query", pgwire.FromBackend, &pgproto3.ReadyForQuery{TxStatus: 'T'}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := pgwire.NewEvent(1, tt.from, tt.msg)
+			if err != nil {
+				t.Fatalf("NewEvent error = %v", err)
+			}
+
+			// через golden файл, как при записи и воспроизведении
+			var buf bytes.Buffer
+			if err = pgwire.NewGoldenWriter(&buf).Write(e); err != nil {
+				t.Fatalf("Write error = %v", err)
+			}
+			events, err := pgwire.ReadGolden(&buf)
+			if err != nil || len(events) != 1 {
+				t.Fatalf("ReadGolden = %v, %v, want one event", events, err)
+			}
+			got, err := events[0].Message()
+			if err != nil {
+				t.Fatalf("Message error = %v", err)
+			}
+
+			if !sameMessage(tt.msg, got) {
+				t.Fatalf("round trip through %s changed the message:\n  want: %#v\n  got:  %#v", buf.String(), tt.msg, got)
+			}
+		})
+	}
+}
+
+// sameMessage - сообщения одинаково кодируются в протокол; параметры StartupMessage - map, их порядок в Encode случаен
+func sameMessage(want, got pgproto3.Message) bool {
+	if want, ok := want.(*pgproto3.StartupMessage); ok {
+		got, ok := got.(*pgproto3.StartupMessage)
+		return ok && want.ProtocolVersion == got.ProtocolVersion && reflect.DeepEqual(want.Parameters, got.Parameters)
+	}
+	return bytes.Equal(want.Encode(nil), got.Encode(nil))
+}
+
+func TestCopy(t *testing.T) {
+	value := []byte("Bob")
+	msg := &pgproto3.DataRow{Values: [][]byte{value, nil}}
+
+	c, err := pgwire.Copy(msg)
+	if err != nil {
+		t.Fatalf("Copy error = %v", err)
+	}
+	value[0] = 'R' // pgproto3 переиспользует буфер при следующем Receive
+
+	row, ok := c.(*pgproto3.DataRow)
+	if !ok {
+		t.Fatalf("Copy = %T, want *pgproto3.DataRow", c)
+	}
+	if len(row.Values) != 2 || string(row.Values[0]) != "Bob" || row.Values[1] != nil {
+		t.Fatalf("Copy = %q, want independent [Bob <nil>]", row.Values)
+	}
+
+	startup := &pgproto3.StartupMessage{ProtocolVersion: pgproto3.ProtocolVersionNumber, Parameters: map[string]string{"user": "user"}}
+	if c, err = pgwire.Copy(startup); err != nil || !sameMessage(startup, c) {
+		t.Fatalf("Copy(StartupMessage) = %#v, %v", c, err)
+	}
+}
+
+func TestReadGoldenErrors(t *testing.T) {
+	tests := []struct {
+		name, line, want string
+	}{
+		{"json", `{"conn":1,`, "line 1:"},
+		{"direction", `{"conn":1,"from":"X","type":"Query","msg":{}}`, `line 1: unknown direction "X"`},
+		{"type", `{"conn":1,"from":"B","type":"Query","msg":{}}`, `line 1: conn 1: unknown B message type "Query"`},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := pgwire.ReadGolden(strings.NewReader(tt.line + "\n"))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("ReadGolden error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
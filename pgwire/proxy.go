@@ -0,0 +1,227 @@
+package pgwire
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgproto3/v2"
+)
+
+// DialTimeout - таймаут подключения прокси к серверу
+const DialTimeout = 10 * time.Second
+
+// ErrUnsupported - обмен, который прокси не может передать (аутентификация GSS)
+var ErrUnsupported = errors.New("unsupported message")
+
+// Message - сообщение, прошедшее через прокси
+type Message struct {
+	Conn int              // номер соединения в порядке подключения, с 1
+	From string           // FromFrontend или FromBackend
+	Time time.Time        // когда прокси получил сообщение
+	Msg  pgproto3.Message // копия, ее можно хранить
+}
+
+// Observer - получает каждое сообщение до того, как прокси передаст его дальше. Вызовы не пересекаются,
+// поэтому порядок сообщений одного соединения совпадает с порядком, в котором их видели клиент и сервер
+type Observer func(m Message)
+
+// Proxy - прокси между клиентами и сервером PostgreSQL, разбирающий сообщения в обе стороны.
+//
+// Клиенту прокси отказывает в SSL (sslmode=disable или prefer), к серверу подключается без TLS.
+// Обмен аутентификации (пароль, md5, SCRAM) передается как есть, но Observer его не получает: в запись
+// не попадают ни пароль, ни его хэш, а после StartupMessage сразу идет AuthenticationOk
+type Proxy struct {
+	server
+	upstream  string
+	observe   Observer
+	observeMu sync.Mutex
+}
+
+// NewProxy - прокси к серверу upstream (host:port); observe может быть nil
+func NewProxy(upstream string, observe Observer) *Proxy {
+	return &Proxy{
+		upstream: upstream,
+		observe:  observe,
+	}
+}
+
+// Serve - принимает соединения клиентов до Close
+func (p *Proxy) Serve(listener net.Listener) error {
+	return p.serve(listener, p.handle)
+}
+
+// Close - останавливает прокси, закрывает соединения и ждет их обработчики
+func (p *Proxy) Close() error {
+	return p.close()
+}
+
+func (p *Proxy) handle(id int, client net.Conn) {
+	backend := pgproto3.NewBackend(pgproto3.NewChunkReader(client), client)
+
+	startup, err := ReceiveStartup(client, backend)
+	if err != nil {
+		return
+	}
+
+	db, err := net.DialTimeout("tcp", p.upstream, DialTimeout)
+	if err != nil {
+		if _, ok := startup.(*pgproto3.StartupMessage); ok {
+			_ = backend.Send(&pgproto3.ErrorResponse{
+				Severity: "FATAL",
+				Code:     "08001",
+				Message:  fmt.Sprintf("pgwire proxy: %v", err),
+			})
+		}
+		return
+	}
+	if !p.track(db) {
+		db.Close()
+		return
+	}
+	defer p.wg.Done()
+	defer p.untrack(db)
+
+	frontend := pgproto3.NewFrontend(pgproto3.NewChunkReader(db), db)
+
+	p.notify(id, FromFrontend, startup)
+	if err := frontend.Send(startup); err != nil {
+		return
+	}
+	if _, ok := startup.(*pgproto3.CancelRequest); ok {
+		return // сервер закрывает соединение отмены сам
+	}
+	if err := p.authenticate(id, backend, frontend); err != nil {
+		return
+	}
+
+	done := make(chan struct{}, 2)
+	go func() {
+		defer func() { done <- struct{}{} }()
+		for {
+			msg, err := backend.Receive()
+			if err != nil {
+				return
+			}
+			p.notify(id, FromFrontend, msg)
+			if err := frontend.Send(msg); err != nil {
+				return
+			}
+		}
+	}()
+	go func() {
+		defer func() { done <- struct{}{} }()
+		for {
+			msg, err := frontend.Receive()
+			if err != nil {
+				return
+			}
+			p.notify(id, FromBackend, msg)
+			if err := backend.Send(msg); err != nil {
+				return
+			}
+		}
+	}()
+
+	// одна сторона закрыла соединение - закрываем обе
+	<-done
+	client.Close()
+	db.Close()
+	<-done
+}
+
+// authenticate - передает обмен аутентификации без Observer до AuthenticationOk или ошибки
+func (p *Proxy) authenticate(id int, backend *pgproto3.Backend, frontend *pgproto3.Frontend) error {
+	for {
+		msg, err := frontend.Receive()
+		if err != nil {
+			return err
+		}
+
+		var authType uint32
+		switch msg.(type) {
+		case *pgproto3.AuthenticationCleartextPassword:
+			authType = pgproto3.AuthTypeCleartextPassword
+		case *pgproto3.AuthenticationMD5Password:
+			authType = pgproto3.AuthTypeMD5Password
+		case *pgproto3.AuthenticationSASL:
+			authType = pgproto3.AuthTypeSASL
+		case *pgproto3.AuthenticationSASLContinue:
+			authType = pgproto3.AuthTypeSASLContinue
+		case *pgproto3.AuthenticationSASLFinal:
+			if err := backend.Send(msg); err != nil {
+				return err
+			}
+			continue // ответа клиента нет, дальше AuthenticationOk
+		case *pgproto3.AuthenticationGSS, *pgproto3.AuthenticationGSSContinue:
+			_ = backend.Send(&pgproto3.ErrorResponse{
+				Severity: "FATAL",
+				Code:     "28000",
+				Message:  "pgwire proxy: GSS authentication is not supported",
+			})
+			return ErrUnsupported
+		default:
+			// AuthenticationOk или ErrorResponse - дальше обычный обмен
+			p.notify(id, FromBackend, msg)
+			return backend.Send(msg)
+		}
+
+		if err := backend.Send(msg); err != nil {
+			return err
+		}
+		if err := backend.SetAuthType(authType); err != nil {
+			return err
+		}
+		response, err := backend.Receive()
+		if err != nil {
+			return err
+		}
+		if err := frontend.Send(response); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *Proxy) notify(id int, from string, msg pgproto3.Message) {
+	if p.observe == nil {
+		return
+	}
+	now := time.Now()
+	c, err := Copy(msg)
+	if err != nil {
+		return // сообщение, которое pgproto3 не декодирует из своей же кодировки, не записываем
+	}
+
+	p.observeMu.Lock()
+	defer p.observeMu.Unlock()
+
+	p.observe(Message{Conn: id, From: from, Time: now, Msg: c})
+}
+
+// ReceiveStartup - первое сообщение клиента: StartupMessage или CancelRequest. На SSLRequest
+// и GSSEncRequest отвечает отказом, после которого клиент продолжает без шифрования
+func ReceiveStartup(conn net.Conn, backend *pgproto3.Backend) (pgproto3.FrontendMessage, error) {
+	for {
+		msg, err := backend.ReceiveStartupMessage()
+		if err != nil {
+			return nil, err
+		}
+
+		switch msg.(type) {
+		case *pgproto3.SSLRequest, *pgproto3.GSSEncRequest:
+			if _, err := conn.Write([]byte{'N'}); err != nil {
+				return nil, err
+			}
+		case *pgproto3.StartupMessage, *pgproto3.CancelRequest:
+			c, err := Copy(msg)
+			if err != nil {
+				return nil, err
+			}
+			return c.(pgproto3.FrontendMessage), nil
+		default:
+			return nil, fmt.Errorf("%w: %T before startup", ErrUnsupported, msg)
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package pgwire
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jackc/pgproto3/v2"
+)
+
+// Replayer - сервер, который отвечает клиентам записанными ответами golden файла и сверяет сообщения
+// клиентов с записанными. Соединение клиента сопоставляется с записанным по StartupMessage и первому
+// запросу, поэтому порядок, в котором пул открывает соединения, не важен.
+//
+// На первое отличие Replayer отвечает клиенту ErrorResponse и закрывает соединение; все отличия
+// и не воспроизведенные соединения с запросами возвращает Close
+type Replayer struct {
+	server
+	recordings []*recording
+
+	mu       sync.Mutex
+	problems []string
+}
+
+// recording - записанное соединение
+type recording struct {
+	conn    int
+	events  []Event
+	names   statementNames // для сравнения сообщений клиента по порядку воспроизведения
+	claimed bool
+}
+
+// NewReplayer - сервер для событий golden файла (ReadGolden)
+func NewReplayer(events []Event) *Replayer {
+	r := &Replayer{}
+	byConn := make(map[int]*recording)
+	for _, e := range events {
+		rec := byConn[e.Conn]
+		if rec == nil {
+			rec = &recording{conn: e.Conn, names: make(statementNames)}
+			byConn[e.Conn] = rec
+			r.recordings = append(r.recordings, rec)
+		}
+		rec.events = append(rec.events, e)
+	}
+	return r
+}
+
+// Serve - принимает соединения клиентов до Close
+func (r *Replayer) Serve(listener net.Listener) error {
+	return r.serve(listener, r.handle)
+}
+
+// Close - останавливает сервер и возвращает отличия от записи
+func (r *Replayer) Close() error {
+	r.close()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	problems := append([]string(nil), r.problems...)
+	for _, rec := range r.recordings {
+		if !rec.claimed && rec.active() {
+			problems = append(problems, fmt.Sprintf("recorded conn %d was not replayed: first query %s", rec.conn, rec.events[rec.startupEnd()].Msg))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("replay differs from recording:\n%s", strings.Join(problems, "\n"))
+}
+
+func (r *Replayer) handle(id int, conn net.Conn) {
+	backend := pgproto3.NewBackend(pgproto3.NewChunkReader(conn), conn)
+	c := &replayConn{id: id, backend: backend, names: make(statementNames)}
+
+	startup, err := ReceiveStartup(conn, backend)
+	if err != nil {
+		return
+	}
+	first, err := NewEvent(0, FromFrontend, startup)
+	if err != nil {
+		r.fail(c, "%v", err)
+		return
+	}
+
+	// ответ на startup - из первого подходящего записанного соединения: у соединений пула он одинаков
+	// с точностью до BackendKeyData
+	candidate := r.candidate(first)
+	if candidate == nil {
+		r.fail(c, "no recorded connection starts with %s %s", first.Type, first.Msg)
+		return
+	}
+	if _, ok := startup.(*pgproto3.CancelRequest); ok {
+		return
+	}
+	pos := 1
+	for ; pos < len(candidate.events) && candidate.events[pos].From == FromBackend; pos++ {
+		if !r.send(c, candidate.events[pos]) {
+			return
+		}
+	}
+
+	// первый запрос определяет записанное соединение
+	msg, err := backend.Receive()
+	if err != nil {
+		return // соединение пула, которое не понадобилось
+	}
+	got, err := c.event(msg)
+	if err != nil {
+		r.fail(c, "%v", err)
+		return
+	}
+	rec, pos := r.claim(first, got)
+	if rec == nil {
+		want := "end of recording"
+		if end := candidate.startupEnd(); end < len(candidate.events) {
+			want = describe(candidate.events[end])
+		}
+		r.fail(c, "no recorded connection continues with this message:\n  want: %s\n  got:  %s", want, describe(got))
+		return
+	}
+	c.rec = rec
+
+	for ; pos < len(rec.events); pos++ {
+		e := rec.events[pos]
+		if e.From == FromBackend {
+			if !r.send(c, e) {
+				return
+			}
+			continue
+		}
+
+		msg, err := backend.Receive()
+		if err != nil {
+			if rest := rec.events[pos:]; !onlyTerminate(rest) {
+				r.fail(c, "client closed the connection, but the recording continues with %s", describe(e))
+			}
+			return
+		}
+		got, err := c.event(msg)
+		if err != nil {
+			r.fail(c, "%v", err)
+			return
+		}
+		if e = rec.names.normalize(e); !equal(e, got) {
+			r.fail(c, "message #%d differs:\n  want: %s\n  got:  %s", pos, describe(e), describe(got))
+			return
+		}
+	}
+
+	for {
+		msg, err := backend.Receive()
+		if err != nil {
+			return
+		}
+		if _, ok := msg.(*pgproto3.Terminate); ok {
+			continue
+		}
+		got, _ := NewEvent(0, FromFrontend, msg)
+		r.fail(c, "unexpected message after the end of recording: %s", describe(got))
+		return
+	}
+}
+
+// candidate - первое не воспроизведенное соединение, которое начинается с first
+func (r *Replayer) candidate(first Event) *recording {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, rec := range r.recordings {
+		if rec.claimed || !equal(rec.events[0], first) {
+			continue
+		}
+		if first.Type == "CancelRequest" {
+			rec.claimed = true
+		}
+		return rec
+	}
+	return nil
+}
+
+// claim - первое не воспроизведенное соединение с тем же началом и первым запросом; pos - следующее событие
+func (r *Replayer) claim(first, query Event) (*recording, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, rec := range r.recordings {
+		if rec.claimed || !equal(rec.events[0], first) {
+			continue
+		}
+		if end := rec.startupEnd(); end < len(rec.events) && equal(make(statementNames).normalize(rec.events[end]), query) {
+			rec.claimed = true
+			rec.names.normalize(rec.events[end])
+			return rec, end + 1
+		}
+	}
+	return nil, 0
+}
+
+func (r *Replayer) send(c *replayConn, e Event) bool {
+	msg, err := e.Message()
+	if err != nil {
+		r.fail(c, "%v", err)
+		return false
+	}
+	return c.backend.Send(msg.(pgproto3.BackendMessage)) == nil
+}
+
+// fail - запоминает отличие и сообщает о нем клиенту
+func (r *Replayer) fail(c *replayConn, format string, args ...interface{}) {
+	prefix := fmt.Sprintf("conn %d", c.id)
+	if c.rec != nil {
+		prefix += fmt.Sprintf(" (recorded conn %d)", c.rec.conn)
+	}
+	problem := prefix + ": " + fmt.Sprintf(format, args...)
+
+	r.mu.Lock()
+	r.problems = append(r.problems, problem)
+	r.mu.Unlock()
+
+	_ = c.backend.Send(&pgproto3.ErrorResponse{Severity: "FATAL", Code: "XX000", Message: "pgwire replay: " + problem})
+}
+
+// replayConn - соединение клиента
+type replayConn struct {
+	id      int
+	backend *pgproto3.Backend
+	names   statementNames
+	rec     *recording
+}
+
+// event - сообщение клиента для сравнения с записью
+func (c *replayConn) event(msg pgproto3.FrontendMessage) (Event, error) {
+	e, err := NewEvent(0, FromFrontend, msg)
+	if err != nil {
+		return Event{}, err
+	}
+	return c.names.normalize(e), nil
+}
+
+// statementNames - порядковые имена (s1, s2, ...) подготовленных запросов соединения вместо настоящих:
+// pgx называет их по счетчику соединений процесса (lrupsc_<соединение>_<запрос>), и у одинаковых
+// запросов в записи и при воспроизведении имена могут отличаться
+type statementNames map[string]string
+
+func (n statementNames) name(name string) string {
+	if name == "" {
+		return "" // безымянный запрос
+	}
+	if normalized, ok := n[name]; ok {
+		return normalized
+	}
+	normalized := "s" + strconv.Itoa(len(n)+1)
+	n[name] = normalized
+	return normalized
+}
+
+// normalize - событие с порядковыми именами запросов в Parse, Bind, Describe и Close
+func (n statementNames) normalize(e Event) Event {
+	switch e.Type {
+	case "Parse", "Bind", "Describe", "Close":
+	default:
+		return e
+	}
+	msg, err := e.Message()
+	if err != nil {
+		return e
+	}
+	switch m := msg.(type) {
+	case *pgproto3.Parse:
+		m.Name = n.name(m.Name)
+	case *pgproto3.Bind:
+		m.PreparedStatement = n.name(m.PreparedStatement)
+	case *pgproto3.Describe:
+		if m.ObjectType == 'S' {
+			m.Name = n.name(m.Name)
+		}
+	case *pgproto3.Close:
+		if m.ObjectType == 'S' {
+			m.Name = n.name(m.Name)
+		}
+	}
+	normalized, err := NewEvent(e.Conn, e.From, msg)
+	if err != nil {
+		return e
+	}
+	return normalized
+}
+
+// startupEnd - номер первого сообщения клиента после startup
+func (rec *recording) startupEnd() int {
+	i := 1
+	for i < len(rec.events) && rec.events[i].From == FromBackend {
+		i++
+	}
+	return i
+}
+
+// active - в соединении были запросы
+func (rec *recording) active() bool {
+	return !onlyTerminate(rec.events[rec.startupEnd():])
+}
+
+func onlyTerminate(events []Event) bool {
+	for _, e := range events {
+		if e.From == FromFrontend && e.Type != "Terminate" {
+			return false
+		}
+	}
+	return true
+}
+
+// equal - сообщения совпадают (JSON без учета форматирования)
+func equal(a, b Event) bool {
+	if a.From != b.From || a.Type != b.Type {
+		return false
+	}
+	var ca, cb bytes.Buffer
+	if json.Compact(&ca, a.Msg) != nil || json.Compact(&cb, b.Msg) != nil {
+		return false
+	}
+	return bytes.Equal(ca.Bytes(), cb.Bytes())
+}
+
+func describe(e Event) string {
+	return e.Type + " " + string(e.Msg)
+}
@@ -0,0 +1,226 @@
+package pgwire_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/moguchev/postgres/3/repository/repositorytest/pgstub"
+	"github.com/moguchev/postgres/pgwire"
+)
+
+// client - действия клиента с БД по адресу dsn
+type client func(ctx context.Context, dsn string) error
+
+func dsn(addr net.Addr) string {
+	return fmt.Sprintf("postgres://pgstub@%s/pgstub?sslmode=disable", addr)
+}
+
+// recordEvents - события обмена клиента со сценарием pgstub через Proxy
+func recordEvents(t *testing.T, scenario func(srv *pgstub.Server), run client) []pgwire.Event {
+	t.Helper()
+
+	srv := pgstub.NewServer(t)
+	scenario(srv)
+
+	var buf bytes.Buffer
+	w := pgwire.NewGoldenWriter(&buf)
+	proxy := pgwire.NewProxy(srv.Addr(), w.Observe)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = proxy.Serve(listener) }()
+
+	if err = run(context.Background(), dsn(listener.Addr())); err != nil {
+		t.Fatalf("recording: %v", err)
+	}
+	if err = proxy.Close(); err != nil {
+		t.Fatalf("proxy: %v", err)
+	}
+	if err = w.Err(); err != nil {
+		t.Fatalf("golden writer: %v", err)
+	}
+
+	events, err := pgwire.ReadGolden(&buf)
+	if err != nil {
+		t.Fatalf("ReadGolden: %v", err)
+	}
+	return events
+}
+
+// replayEvents - клиент выполняется по записи; ошибку клиента и отличия от записи (Close) возвращает отдельно
+func replayEvents(t *testing.T, events []pgwire.Event, run client) (clientErr, err error) {
+	t.Helper()
+
+	replayer := pgwire.NewReplayer(events)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = replayer.Serve(listener) }()
+
+	clientErr = run(context.Background(), dsn(listener.Addr()))
+	return clientErr, replayer.Close()
+}
+
+// simpleQueries - открывает по соединению на каждый список и выполняет запросы простым протоколом:
+// сначала все запросы первого соединения, затем второго
+func simpleQueries(conns ...[]string) client {
+	return func(ctx context.Context, dsn string) (err error) {
+		var opened []*pgconn.PgConn
+		defer func() {
+			for _, conn := range opened {
+				_ = conn.Close(ctx)
+			}
+		}()
+		for range conns {
+			conn, err := pgconn.Connect(ctx, dsn)
+			if err != nil {
+				return err
+			}
+			opened = append(opened, conn)
+		}
+
+		for i, queries := range conns {
+			for _, query := range queries {
+				if _, err = opened[i].Exec(ctx, query).ReadAll(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+}
+
+func TestReplayer(t *testing.T) {
+	tests := []struct {
+		name   string
+		record [][]string
+		replay [][]string
+		want   string // часть ошибки Close, пусто - воспроизведение без отличий
+	}{
+		{
+			name:   "same queries",
+			record: [][]string{{"SELECT 1", "SELECT 2"}},
+			replay: [][]string{{"SELECT 1", "SELECT 2"}},
+		},
+		{
+			name:   "connections matched by first query",
+			record: [][]string{{"SELECT 1"}, {"SELECT 2"}},
+			replay: [][]string{{"SELECT 2"}, {"SELECT 1"}},
+		},
+		{
+			name:   "unused pool connection",
+			record: [][]string{{"SELECT 1"}, nil},
+			replay: [][]string{nil, {"SELECT 1"}},
+		},
+		{
+			name:   "different first query",
+			record: [][]string{{"SELECT 1"}},
+			replay: [][]string{{"SELECT 3"}},
+			want:   "no recorded connection continues with this message:\n  want: Query {\"Type\":\"Query\",\"String\":\"SELECT 1\"}\n  got:  Query {\"Type\":\"Query\",\"String\":\"SELECT 3\"}",
+		},
+		{
+			name:   "different next query",
+			record: [][]string{{"SELECT 1", "SELECT 2"}},
+			replay: [][]string{{"SELECT 1", "SELECT 3"}},
+			want:   "differs:\n  want: Query {\"Type\":\"Query\",\"String\":\"SELECT 2\"}\n  got:  Query {\"Type\":\"Query\",\"String\":\"SELECT 3\"}",
+		},
+		{
+			name:   "extra query",
+			record: [][]string{{"SELECT 1"}},
+			replay: [][]string{{"SELECT 1", "SELECT 2"}},
+			want:   "unexpected message after the end of recording: Query {\"Type\":\"Query\",\"String\":\"SELECT 2\"}",
+		},
+		{
+			name:   "connection not replayed",
+			record: [][]string{{"SELECT 1"}, {"SELECT 2"}},
+			replay: [][]string{{"SELECT 1"}},
+			want:   "recorded conn 2 was not replayed: first query {\"Type\":\"Query\",\"String\":\"SELECT 2\"}",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			events := recordEvents(t, func(srv *pgstub.Server) {
+				for _, queries := range tt.record {
+					for _, query := range queries {
+						srv.Expect(query)
+					}
+				}
+			}, simpleQueries(tt.record...))
+
+			clientErr, err := replayEvents(t, events, simpleQueries(tt.replay...))
+			if tt.want == "" {
+				if clientErr != nil || err != nil {
+					t.Fatalf("replay: client error = %v, Close error = %v", clientErr, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Close error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplayerStatementNames(t *testing.T) {
+	const query = `SELECT id FROM students WHERE id = $1`
+
+	run := func(ctx context.Context, dsn string) error {
+		conn, err := pgx.Connect(ctx, dsn)
+		if err != nil {
+			return err
+		}
+		defer conn.Close(ctx)
+
+		// второй запрос - Bind подготовленного запроса из кэша pgx
+		for _, id := range []int64{1, 2} {
+			var got int64
+			if err = conn.QueryRow(ctx, query, id).Scan(&got); err != nil {
+				return err
+			}
+			if got != id {
+				return fmt.Errorf("id = %d, want %d", got, id)
+			}
+		}
+		return nil
+	}
+
+	events := recordEvents(t, func(srv *pgstub.Server) {
+		for _, id := range []int{1, 2} {
+			srv.Expect(query).WithParamOIDs(pgtype.Int8OID).WithArgs(id).
+				WillReturnRows([]pgstub.Column{{Name: "id", OID: pgtype.Int8OID}}, []interface{}{id})
+		}
+	}, run)
+
+	var recorded string
+	for _, e := range events {
+		if e.Type == "Parse" {
+			msg, err := e.Message()
+			if err != nil {
+				t.Fatal(err)
+			}
+			recorded = msg.(*pgproto3.Parse).Name
+		}
+	}
+	if recorded == "" {
+		t.Fatal("recording has no named Parse")
+	}
+
+	// новое соединение pgx называет запросы иначе (имя включает номер соединения), Replayer сопоставляет их по порядку
+	clientErr, err := replayEvents(t, events, run)
+	if clientErr != nil || err != nil {
+		t.Fatalf("replay of statement %s: client error = %v, Close error = %v", recorded, clientErr, err)
+	}
+}
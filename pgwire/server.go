@@ -0,0 +1,98 @@
+package pgwire
+
+import (
+	"net"
+	"sync"
+)
+
+// server - прием соединений и их закрытие, общие для Proxy и Replayer
+type server struct {
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+	seq      int
+	wg       sync.WaitGroup
+}
+
+// serve - принимает соединения до close; handle получает номер соединения с 1
+func (s *server) serve(listener net.Listener, handle func(id int, conn net.Conn)) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return listener.Close()
+	}
+	s.listener = listener
+	s.mu.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+
+		if !s.track(conn) {
+			conn.Close()
+			return nil
+		}
+		s.mu.Lock()
+		s.seq++
+		id := s.seq
+		s.mu.Unlock()
+
+		go func() {
+			defer s.wg.Done()
+			defer s.untrack(conn)
+
+			handle(id, conn)
+		}()
+	}
+}
+
+// close - закрывает listener и соединения и ждет их обработчики
+func (s *server) close() error {
+	s.mu.Lock()
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+// track - регистрирует соединение, чтобы close его закрыл; false, если сервер уже закрыт.
+// После track нужно вызвать s.wg.Done и untrack
+func (s *server) track(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+	if s.conns == nil {
+		s.conns = make(map[net.Conn]struct{})
+	}
+	s.conns[conn] = struct{}{}
+	s.wg.Add(1)
+	return true
+}
+
+func (s *server) untrack(conn net.Conn) {
+	s.mu.Lock()
+	delete(s.conns, conn)
+	s.mu.Unlock()
+
+	conn.Close()
+}